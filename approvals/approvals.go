@@ -5,11 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/quilla-hq/quilla/extension/notification"
 	"github.com/quilla-hq/quilla/pkg/store"
 	"github.com/quilla-hq/quilla/types"
 
@@ -20,21 +21,33 @@ import (
 type Manager interface {
 	// Subscribe for approval request events, subscriber should provide
 	// its name. Indented to be used by extensions that collect
-	// approvals
-	Subscribe(ctx context.Context) (<-chan *types.Approval, error)
+	// approvals. Deliveries have to be acknowledged.
+	Subscribe(ctx context.Context, name string) (<-chan *Delivery, error)
 
-	// SubscribeApproved - is used to get approved events by the manager
-	SubscribeApproved(ctx context.Context) (<-chan *types.Approval, error)
+	// SubscribeApproved - is used to get approved events by the manager,
+	// deliveries have to be acknowledged
+	SubscribeApproved(ctx context.Context, name string) (<-chan *Delivery, error)
 
 	// request approval for deployment/release/etc..
 	Create(r *types.Approval) error
 	// Update whole approval object
 	Update(r *types.Approval) error
 
-	// Increases Approval votes by 1
-	Approve(identifier, voter string) (*types.Approval, error)
-	// Rejects Approval
-	Reject(identifier string) (*types.Approval, error)
+	// Increases Approval votes by 1, votes of voters that aren't
+	// eligible are rejected with ErrIneligibleVoter. Comment is optional.
+	Approve(identifier string, voter *types.Voter, comment string) (*types.Approval, error)
+	// Records rejection of the voter, approval is rejected once rejections
+	// satisfy its veto rule. Voters that aren't eligible to approve can't
+	// reject either (ErrIneligibleVoter). Comment is optional.
+	Reject(identifier string, voter *types.Voter, comment string) (*types.Approval, error)
+	// ApproveWithLinkToken and RejectWithLinkToken - votes cast through approval links,
+	// link token is recorded as used together with the vote. Token that was already
+	// used fails with store.ErrTokenUsed, token isn't used if the vote fails.
+	ApproveWithLinkToken(identifier string, voter *types.Voter, comment string, token *types.LinkToken) (*types.Approval, error)
+	RejectWithLinkToken(identifier string, voter *types.Voter, comment string, token *types.LinkToken) (*types.Approval, error)
+	// Resets votes of the approval when image digest changes,
+	// approval is requested again
+	ResetVotes(identifier, digest string) (*types.Approval, error)
 
 	Get(identifier string) (*types.Approval, error)
 	List() ([]*types.Approval, error)
@@ -47,6 +60,7 @@ type Manager interface {
 // Approvals related errors
 var (
 	ErrApprovalAlreadyExists = errors.New("approval already exists")
+	ErrIneligibleVoter       = errors.New("voter is not eligible")
 )
 
 // Approvals cache prefix
@@ -54,6 +68,20 @@ const (
 	ApprovalsPrefix = "approvals"
 )
 
+// GroupPrefix - prefix of grouped approval identifiers
+const GroupPrefix = "group/"
+
+// GroupIdentifier - identifier of a single approval that covers all members
+// of an update group, ie: group/shop:1.2.0
+func GroupIdentifier(group, version string) string {
+	return GroupPrefix + group + ":" + version
+}
+
+// IsGroupIdentifier - checks whether approval covers an update group
+func IsGroupIdentifier(identifier string) bool {
+	return strings.HasPrefix(identifier, GroupPrefix)
+}
+
 // DefaultManager - default manager implementation
 type DefaultManager struct {
 	// cache is used to store approvals, key example:
@@ -62,12 +90,12 @@ type DefaultManager struct {
 
 	store store.Store
 
-	// subscriber channels
-	channels map[uint32]chan *types.Approval
-	index    uint32
+	sender    notification.Sender
+	reminders Reminders
 
-	// approved channels
-	approvedCh map[uint32]chan *types.Approval
+	// subscribers waiting for outbox events
+	subscribers map[uint32]*subscriber
+	index       uint32
 
 	mu    *sync.Mutex
 	subMu *sync.RWMutex
@@ -75,6 +103,11 @@ type DefaultManager struct {
 
 type Opts struct {
 	Store store.Store
+
+	// Sender - optional, sends reminders, escalations and expiry notifications
+	Sender notification.Sender
+	// Reminders - when reminders and escalation of pending approvals are sent
+	Reminders Reminders
 	// Cache cache.Cache
 }
 
@@ -82,21 +115,23 @@ type Opts struct {
 func New(opts *Opts) *DefaultManager {
 	man := &DefaultManager{
 		// cache:      opts.Cache,
-		store:      opts.Store,
-		channels:   make(map[uint32]chan *types.Approval),
-		approvedCh: make(map[uint32]chan *types.Approval),
-		index:      0,
-		mu:         &sync.Mutex{},
-		subMu:      &sync.RWMutex{},
+		store:       opts.Store,
+		sender:      opts.Sender,
+		reminders:   opts.Reminders,
+		subscribers: make(map[uint32]*subscriber),
+		index:       0,
+		mu:          &sync.Mutex{},
+		subMu:       &sync.RWMutex{},
 	}
 
 	return man
 }
 
 // StartExpiryService - starts approval expiry service which deletes approvals
-// that already reached their deadline
+// that already reached their deadline, sends reminders and escalations of pending
+// approvals and auto-approves approvals nobody rejected in time
 func (m *DefaultManager) StartExpiryService(ctx context.Context) error {
-	ticker := time.NewTicker(60 * time.Minute)
+	ticker := time.NewTicker(expiryCheckInterval)
 	defer ticker.Stop()
 	err := m.expireEntries()
 	if err != nil {
@@ -120,125 +155,119 @@ func (m *DefaultManager) StartExpiryService(ctx context.Context) error {
 	}
 }
 
+// expireEntries - expires, auto-approves and sends reminders for pending
+// approvals. Archived, approved and rejected approvals are left alone,
+// their outcome is already decided
 func (m *DefaultManager) expireEntries() error {
 	approvals, err := m.store.ListApprovals(&types.GetApprovalQuery{
-		Archived: false,
+		ExcludeArchived: true,
+		Status:          types.ApprovalStatusPending,
 	})
 	if err != nil {
 		return err
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.pruneEvents(now)
 	for _, approval := range approvals {
+		if approval.Archived || approval.Status() != types.ApprovalStatusPending {
+			continue
+		}
+
 		if approval.Expired() {
 			err = m.Delete(approval)
 			if err != nil {
 				log.WithFields(log.Fields{
-					"error": err,
-					// "identifier": k,
+					"error":      err,
+					"identifier": approval.Identifier,
 				}).Error("approvals.expireEntries: failed to delete expired approval")
 				continue
 			}
 
-			m.addAuditEntry(approval, types.AuditActionApprovalExpired, "")
+			m.addAuditEntry(approval, types.AuditActionApprovalExpired, "", "")
+			m.notifyExpired(approval)
+			continue
 		}
+
+		if m.autoApprove(approval, now) {
+			continue
+		}
+		m.remind(approval, now)
 	}
 
 	return nil
 }
 
 // Subscribe - subscribe for approval events
-func (m *DefaultManager) Subscribe(ctx context.Context) (<-chan *types.Approval, error) {
-	m.subMu.Lock()
-	index := atomic.AddUint32(&m.index, 1)
-	approvalsCh := make(chan *types.Approval, 10)
-	m.channels[index] = approvalsCh
-	m.subMu.Unlock()
-
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				m.subMu.Lock()
-
-				delete(m.channels, index)
-
-				m.subMu.Unlock()
-				return
-			}
-		}
-	}()
-
-	return approvalsCh, nil
+func (m *DefaultManager) Subscribe(ctx context.Context, name string) (<-chan *Delivery, error) {
+	return m.subscribe(ctx, types.ApprovalEventRequested, name)
 }
 
 // SubscribeApproved - subscribe for approved update requests
-func (m *DefaultManager) SubscribeApproved(ctx context.Context) (<-chan *types.Approval, error) {
-	m.subMu.Lock()
-	index := atomic.AddUint32(&m.index, 1)
-	approvedCh := make(chan *types.Approval, 10)
-	m.approvedCh[index] = approvedCh
-	m.subMu.Unlock()
-
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				m.subMu.Lock()
-
-				delete(m.approvedCh, index)
-
-				m.subMu.Unlock()
-				return
-			}
-		}
-	}()
-
-	return approvedCh, nil
+func (m *DefaultManager) SubscribeApproved(ctx context.Context, name string) (<-chan *Delivery, error) {
+	return m.subscribe(ctx, types.ApprovalEventApproved, name)
 }
 
-func (m *DefaultManager) publishRequest(approval *types.Approval) error {
-	m.subMu.RLock()
-	defer m.subMu.RUnlock()
-
-	for _, subscriber := range m.channels {
-		subscriber <- approval
-	}
-	return nil
-}
-
-func (m *DefaultManager) publishApproved(approval *types.Approval) error {
-	m.subMu.RLock()
-	defer m.subMu.RUnlock()
-
-	for _, subscriber := range m.approvedCh {
-		subscriber <- approval
-	}
-	return nil
+// Update - update approval, approval that becomes approved is saved together
+// with the approved event so the update is applied even after a crash
+func (m *DefaultManager) Update(r *types.Approval) error {
+	return m.update(r, nil)
 }
 
-// Update - update approval
-func (m *DefaultManager) Update(r *types.Approval) error {
-	_, err := m.Get(r.Identifier)
+// update - saves approval, link token (if any) is recorded as used in the same transaction
+func (m *DefaultManager) update(r *types.Approval, token *types.LinkToken) error {
+	existing, err := m.Get(r.Identifier)
 	if err != nil {
 		return err
 	}
 
-	if r.Status() == types.ApprovalStatusApproved {
-		err = m.publishApproved(r)
+	var event *types.ApprovalEvent
+	if r.Status() == types.ApprovalStatusApproved && existing.Status() != types.ApprovalStatusApproved {
+		event, err = newApprovalEvent(types.ApprovalEventApproved, r)
 		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case token != nil:
+		err = m.store.UpdateApprovalWithLinkToken(r, event, token)
+	case event != nil:
+		err = m.store.UpdateApprovalWithEvent(r, event)
+	default:
+		return m.store.UpdateApproval(r)
+	}
+	if err != nil {
+		if event != nil && err != store.ErrTokenUsed {
 			log.WithFields(log.Fields{
 				"error":    err,
 				"approval": r.Identifier,
 				"provider": r.Provider,
-			}).Error("approvals.manager: failed to re-submit event after approvals were collected")
+			}).Error("approvals.manager: failed to save approved update")
 		}
+		return err
 	}
 
-	return m.store.UpdateApproval(r)
+	if event != nil {
+		m.wake(event.Kind)
+	}
+	return nil
 }
 
 // Approve - increase VotesReceived by 1 and returns updated version
-func (m *DefaultManager) Approve(identifier, voter string) (*types.Approval, error) {
+func (m *DefaultManager) Approve(identifier string, voter *types.Voter, comment string) (*types.Approval, error) {
+	return m.approve(identifier, voter, comment, nil)
+}
+
+// ApproveWithLinkToken - approves and records approval link token as used
+func (m *DefaultManager) ApproveWithLinkToken(identifier string, voter *types.Voter, comment string, token *types.LinkToken) (*types.Approval, error) {
+	return m.approve(identifier, voter, comment, token)
+}
+
+func (m *DefaultManager) approve(identifier string, voter *types.Voter, comment string, token *types.LinkToken) (*types.Approval, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -252,16 +281,24 @@ func (m *DefaultManager) Approve(identifier, voter string) (*types.Approval, err
 	}
 
 	for _, v := range existing.GetVoters() {
-		if v == voter {
+		if v == voter.Name {
 			// nothing to do, same voter
-			return existing, nil
+			return m.useLinkToken(existing, token)
 		}
 	}
 
-	existing.AddVoter(voter)
-	existing.VotesReceived++
+	if reason := existing.CanVote(voter); reason != "" {
+		log.WithFields(log.Fields{
+			"identifier": identifier,
+			"voter":      voter.Name,
+			"reason":     reason,
+		}).Warn("approvals.manager: vote rejected")
+		return nil, fmt.Errorf("%w: %s", ErrIneligibleVoter, reason)
+	}
+
+	existing.AddVote(voter, comment)
 
-	err = m.Update(existing)
+	err = m.update(existing, token)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"identifier": identifier,
@@ -270,7 +307,7 @@ func (m *DefaultManager) Approve(identifier, voter string) (*types.Approval, err
 		return nil, err
 	}
 
-	m.addAuditEntry(existing, types.AuditActionApprovalApproved, voter)
+	m.addAuditEntry(existing, types.AuditActionApprovalApproved, voter.Name, comment)
 
 	log.WithFields(log.Fields{
 		"identifier": identifier,
@@ -279,7 +316,7 @@ func (m *DefaultManager) Approve(identifier, voter string) (*types.Approval, err
 	return existing, nil
 }
 
-func (m *DefaultManager) addAuditEntry(approval *types.Approval, action string, voter string) {
+func (m *DefaultManager) addAuditEntry(approval *types.Approval, action string, voter string, comment string) {
 
 	entry := &types.AuditLog{
 		ID:           uuid.New().String(),
@@ -288,16 +325,21 @@ func (m *DefaultManager) addAuditEntry(approval *types.Approval, action string,
 		Action:       action,
 		ResourceKind: types.AuditResourceKindApproval,
 		Identifier:   approval.Identifier,
+		Message:      comment,
 	}
 
-	entry.SetMetadata(map[string]string{
+	metadata := map[string]string{
 		"provider":        approval.Provider.String(),
 		"approval_id":     approval.ID,
 		"new_version":     approval.NewVersion,
 		"current_version": approval.CurrentVersion,
 		"votes_required":  strconv.Itoa(approval.VotesReceived),
 		"votes_received":  strconv.Itoa(approval.VotesReceived),
-	})
+	}
+	if comment != "" {
+		metadata["comment"] = comment
+	}
+	entry.SetMetadata(metadata)
 
 	_, err := m.store.CreateAuditLog(entry)
 	if err != nil {
@@ -308,9 +350,30 @@ func (m *DefaultManager) addAuditEntry(approval *types.Approval, action string,
 	}
 }
 
-// Reject - rejects approval (marks rejected=true), approval will not be valid even if it
-// collects required votes
-func (m *DefaultManager) Reject(identifier string) (*types.Approval, error) {
+// useLinkToken - link of the voter that already voted is used up without a new vote
+func (m *DefaultManager) useLinkToken(approval *types.Approval, token *types.LinkToken) (*types.Approval, error) {
+	if token == nil {
+		return approval, nil
+	}
+	err := m.store.UseLinkToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+// Reject - records rejection, approval is rejected (marks rejected=true) once rejections
+// satisfy its veto rule and will not be valid even if it collects required votes
+func (m *DefaultManager) Reject(identifier string, voter *types.Voter, comment string) (*types.Approval, error) {
+	return m.reject(identifier, voter, comment, nil)
+}
+
+// RejectWithLinkToken - records rejection and approval link token as used
+func (m *DefaultManager) RejectWithLinkToken(identifier string, voter *types.Voter, comment string, token *types.LinkToken) (*types.Approval, error) {
+	return m.reject(identifier, voter, comment, token)
+}
+
+func (m *DefaultManager) reject(identifier string, voter *types.Voter, comment string, token *types.LinkToken) (*types.Approval, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -319,14 +382,70 @@ func (m *DefaultManager) Reject(identifier string) (*types.Approval, error) {
 		return nil, err
 	}
 
-	existing.Rejected = true
+	if existing.Rejections.Has(voter.Name) {
+		// nothing to do, same voter
+		return m.useLinkToken(existing, token)
+	}
 
-	err = m.Update(existing)
+	if reason := existing.CanVote(voter); reason != "" {
+		log.WithFields(log.Fields{
+			"identifier": identifier,
+			"voter":      voter.Name,
+			"reason":     reason,
+		}).Warn("approvals.manager: rejection refused")
+		return nil, fmt.Errorf("%w: %s", ErrIneligibleVoter, reason)
+	}
+
+	wasRejected := existing.Rejected
+	existing.AddRejection(voter, comment)
+
+	err = m.update(existing, token)
 	if err != nil {
 		return nil, err
 	}
 
-	m.addAuditEntry(existing, types.AuditActionApprovalRejected, "")
+	m.addAuditEntry(existing, types.AuditActionApprovalRejected, voter.Name, comment)
+
+	log.WithFields(log.Fields{
+		"identifier": identifier,
+		"voter":      voter.Name,
+		"rejected":   existing.Rejected,
+	}).Info("approvals.manager: rejection recorded")
+
+	if existing.Rejected && !wasRejected {
+		m.notifyRejected(existing)
+	}
+
+	return existing, nil
+}
+
+// ResetVotes - drops collected votes and pins approval to the new digest, votes
+// given for a different image can't approve the update
+func (m *DefaultManager) ResetVotes(identifier, digest string) (*types.Approval, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, err := m.Get(identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := existing.Digest
+	existing.ResetVotes(digest)
+	existing.UpdatedAt = time.Now()
+
+	err = m.saveAndPublish(types.ApprovalEventRequested, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	m.addAuditEntry(existing, types.AuditActionApprovalReset, "", "")
+
+	log.WithFields(log.Fields{
+		"identifier": identifier,
+		"previous":   previous,
+		"digest":     digest,
+	}).Warn("approvals.manager: image digest changed, votes reset")
 
 	return existing, nil
 }
@@ -367,7 +486,7 @@ func (m *DefaultManager) Delete(approval *types.Approval) error {
 		return err
 	}
 
-	m.addAuditEntry(existing, types.AuditActionDeleted, "")
+	m.addAuditEntry(existing, types.AuditActionDeleted, "", "")
 
 	return m.store.DeleteApproval(existing)
 }
@@ -379,7 +498,7 @@ func (m *DefaultManager) Archive(identifier string) error {
 	}
 	existing.Archived = true
 
-	m.addAuditEntry(existing, types.AuditActionApprovalArchived, "")
+	m.addAuditEntry(existing, types.AuditActionApprovalArchived, "", "")
 
 	return m.store.UpdateApproval(existing)
 }
@@ -391,15 +510,26 @@ func (m *DefaultManager) Create(r *types.Approval) error {
 		return ErrApprovalAlreadyExists
 	}
 
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
 	r.CreatedAt = time.Now()
 	r.UpdatedAt = time.Now()
 
-	created, err := m.store.CreateApproval(r)
+	// requested event is stored together with the approval, subscribers
+	// get notified about every approval that exists
+	event, err := newApprovalEvent(types.ApprovalEventRequested, r)
+	if err != nil {
+		return err
+	}
+
+	err = m.store.CreateApprovalWithEvent(r, event)
 	if err != nil {
 		return fmt.Errorf("failed to create approval: %s", err)
 	}
 
-	return m.publishRequest(created)
+	m.wake(types.ApprovalEventRequested)
+	return nil
 }
 
 func getKey(identifier string) string {
@@ -2,6 +2,7 @@ package approvals
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"log"
 	"os"
@@ -11,6 +12,7 @@ import (
 
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 
+	"github.com/quilla-hq/quilla/pkg/store"
 	"github.com/quilla-hq/quilla/pkg/store/sql"
 	"github.com/quilla-hq/quilla/types"
 )
@@ -131,26 +133,21 @@ func TestUpdateApproval(t *testing.T) {
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	ch, err := am.SubscribeApproved(ctx)
+	ch, err := am.SubscribeApproved(ctx, "test")
 	if err != nil {
 		t.Fatalf("failed to subscribe: %s", err)
 	}
 
-	err = am.Update(&types.Approval{
-		Provider:       types.ProviderTypeKubernetes,
-		Identifier:     "xxx/app-1",
-		CurrentVersion: "1.2.3",
-		NewVersion:     "1.2.5",
-		VotesRequired:  1,
-		VotesReceived:  1,
-		Deadline:       time.Now().Add(5 * time.Minute),
-		Event: &types.Event{
-			Repository: types.Repository{
-				Name: "very/repo",
-				Tag:  "1.2.5",
-			},
-		},
-	})
+	existing, err := am.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	existing.VotesReceived = 1
+
+	err = am.Update(existing)
+	if err != nil {
+		t.Fatalf("failed to update approval: %s", err)
+	}
 
 	approved := <-ch
 
@@ -194,7 +191,7 @@ func TestUpdateApprovalRejected(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	ch, err := am.SubscribeApproved(ctx)
+	ch, err := am.SubscribeApproved(ctx, "test")
 	if err != nil {
 		t.Fatalf("failed to subscribe: %s", err)
 	}
@@ -275,7 +272,7 @@ func TestApprove(t *testing.T) {
 		t.Fatalf("failed to create approval: %s", err)
 	}
 
-	am.Approve("xxx/app-1:1.2.5", "warda")
+	am.Approve("xxx/app-1:1.2.5", &types.Voter{Name: "warda"}, "")
 
 	stored, err := am.Get("xxx/app-1:1.2.5")
 	if err != nil {
@@ -308,8 +305,8 @@ func TestApproveTwiceSameVoter(t *testing.T) {
 		t.Fatalf("failed to create approval: %s", err)
 	}
 
-	am.Approve("xxx/app-1:1.2.5", "warda")
-	am.Approve("xxx/app-1:1.2.5", "warda")
+	am.Approve("xxx/app-1:1.2.5", &types.Voter{Name: "warda"}, "")
+	am.Approve("xxx/app-1:1.2.5", &types.Voter{Name: "warda"}, "")
 
 	stored, err := am.Get("xxx/app-1:1.2.5")
 	if err != nil {
@@ -322,6 +319,46 @@ func TestApproveTwiceSameVoter(t *testing.T) {
 	}
 }
 
+func TestApproveWithUsedLinkToken(t *testing.T) {
+	db, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := New(&Opts{
+		Store: db,
+	})
+
+	err := am.Create(&types.Approval{
+		Provider:       types.ProviderTypeKubernetes,
+		Identifier:     "xxx/app-1:1.2.5",
+		CurrentVersion: "1.2.3",
+		NewVersion:     "1.2.5",
+		Deadline:       time.Now().Add(5 * time.Minute),
+		VotesRequired:  2,
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	_, err = am.ApproveWithLinkToken("xxx/app-1:1.2.5", &types.Voter{Name: "warda"}, "", &types.LinkToken{ID: "token-1"})
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+
+	// the same link used by another voter (ie: forwarded email)
+	_, err = am.ApproveWithLinkToken("xxx/app-1:1.2.5", &types.Voter{Name: "karolis"}, "", &types.LinkToken{ID: "token-1"})
+	if err != store.ErrTokenUsed {
+		t.Fatalf("expected token used error, got: %v", err)
+	}
+
+	stored, err := am.Get("xxx/app-1:1.2.5")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if stored.VotesReceived != 1 {
+		t.Errorf("vote with used token shouldn't be recorded, votes: %d", stored.VotesReceived)
+	}
+}
+
 func TestApproveTwoVoters(t *testing.T) {
 	store, teardown := NewTestingUtils()
 	defer teardown()
@@ -344,8 +381,8 @@ func TestApproveTwoVoters(t *testing.T) {
 		t.Fatalf("failed to create approval: %s", err)
 	}
 
-	am.Approve("xxx/app-1:1.2.5", "w")
-	am.Approve("xxx/app-1:1.2.5", "k")
+	am.Approve("xxx/app-1:1.2.5", &types.Voter{Name: "w"}, "")
+	am.Approve("xxx/app-1:1.2.5", &types.Voter{Name: "k"}, "")
 
 	stored, err := am.Get("xxx/app-1:1.2.5")
 	if err != nil {
@@ -380,7 +417,7 @@ func TestReject(t *testing.T) {
 		t.Fatalf("failed to create approval: %s", err)
 	}
 
-	am.Reject("xxx/app-1")
+	am.Reject("xxx/app-1", &types.Voter{Name: "admin"}, "")
 
 	stored, err := am.Get("xxx/app-1")
 	if err != nil {
@@ -392,6 +429,126 @@ func TestReject(t *testing.T) {
 	}
 }
 
+func TestRejectWithComment(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := New(&Opts{
+		Store: store,
+	})
+
+	err := am.Create(&types.Approval{
+		Provider:       types.ProviderTypeKubernetes,
+		Identifier:     "xxx/app-1",
+		CurrentVersion: "1.2.3",
+		NewVersion:     "1.2.5",
+		Deadline:       time.Now().Add(5 * time.Minute),
+		VotesRequired:  2,
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	_, err = am.Reject("xxx/app-1", &types.Voter{Name: "jane"}, "breaks checkout")
+	if err != nil {
+		t.Fatalf("failed to reject: %s", err)
+	}
+
+	stored, err := am.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if len(stored.Rejections) != 1 || stored.Rejections[0].Voter != "jane" || stored.Rejections[0].Comment != "breaks checkout" {
+		t.Errorf("unexpected rejections: %v", stored.Rejections)
+	}
+
+	logs, err := store.GetAuditLogs(&types.AuditLogQuery{
+		ResourceKindFilter: []string{types.AuditResourceKindApproval},
+	})
+	if err != nil {
+		t.Fatalf("failed to get audit logs: %s", err)
+	}
+	var found bool
+	for _, l := range logs {
+		if l.Action != types.AuditActionApprovalRejected {
+			continue
+		}
+		found = true
+		if l.Username != "jane" {
+			t.Errorf("expected rejection audited under jane, got: %s", l.Username)
+		}
+		if l.Message != "breaks checkout" {
+			t.Errorf("expected comment in audit log, got: %s", l.Message)
+		}
+	}
+	if !found {
+		t.Errorf("expected rejection to be audited")
+	}
+}
+
+func TestRejectVeto(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := New(&Opts{
+		Store: store,
+	})
+
+	for identifier, veto := range map[string]*types.VetoRule{
+		"xxx/app-1": {Rejections: 2},
+		"xxx/app-2": {Rejections: 3, Group: "sre"},
+	} {
+		err := am.Create(&types.Approval{
+			Provider:       types.ProviderTypeKubernetes,
+			Identifier:     identifier,
+			CurrentVersion: "1.2.3",
+			NewVersion:     "1.2.5",
+			Deadline:       time.Now().Add(5 * time.Minute),
+			VotesRequired:  2,
+			Veto:           veto,
+		})
+		if err != nil {
+			t.Fatalf("failed to create approval: %s", err)
+		}
+	}
+
+	// threshold
+	rejected, err := am.Reject("xxx/app-1", &types.Voter{Name: "jane"}, "")
+	if err != nil {
+		t.Fatalf("failed to reject: %s", err)
+	}
+	if rejected.Rejected {
+		t.Errorf("single rejection shouldn't veto the update")
+	}
+	// same voter doesn't count twice
+	rejected, _ = am.Reject("xxx/app-1", &types.Voter{Name: "jane"}, "")
+	if rejected.Rejected || len(rejected.Rejections) != 1 {
+		t.Errorf("expected repeated rejection to be ignored, got: %v", rejected.Rejections)
+	}
+	rejected, _ = am.Reject("xxx/app-1", &types.Voter{Name: "bob"}, "")
+	if !rejected.Rejected {
+		t.Errorf("expected second rejection to veto the update")
+	}
+
+	// group
+	rejected, _ = am.Reject("xxx/app-2", &types.Voter{Name: "bob", Groups: []string{"dev"}}, "")
+	if rejected.Rejected {
+		t.Errorf("rejection from outside of the group shouldn't veto the update")
+	}
+	rejected, _ = am.Reject("xxx/app-2", &types.Voter{Name: "carol", Groups: []string{"sre"}}, "")
+	if !rejected.Rejected {
+		t.Errorf("expected rejection from sre to veto the update")
+	}
+
+	stored, err := am.Get("xxx/app-2")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if stored.Veto == nil || stored.Veto.Group != "sre" || !stored.Rejected {
+		t.Errorf("unexpected stored approval: %v, rejected: %v", stored.Veto, stored.Rejected)
+	}
+}
+
 func TestExpire(t *testing.T) {
 	store, teardown := NewTestingUtils()
 	defer teardown()
@@ -469,3 +626,119 @@ func TestGetArchived(t *testing.T) {
 		t.Errorf("didn't expect approval to be archived")
 	}
 }
+
+func TestApproveEligibility(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := New(&Opts{
+		Store: store,
+	})
+
+	approvers, err := types.ParseApproverRules("group:sre=1,group:product=1")
+	if err != nil {
+		t.Fatalf("failed to parse approvers: %s", err)
+	}
+	err = am.Create(&types.Approval{
+		Provider:       types.ProviderTypeKubernetes,
+		Identifier:     "xxx/app-1:1.2.5",
+		CurrentVersion: "1.2.3",
+		NewVersion:     "1.2.5",
+		Deadline:       time.Now().Add(5 * time.Minute),
+		VotesRequired:  2,
+		Approvers:      approvers,
+		Author:         "carol",
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	for _, voter := range []*types.Voter{
+		{Name: "carol", Groups: []string{"sre"}},
+		{Name: "dave", Groups: []string{"finance"}},
+	} {
+		_, err = am.Approve("xxx/app-1:1.2.5", voter, "")
+		if !errors.Is(err, ErrIneligibleVoter) {
+			t.Errorf("expected vote of %s to be rejected, got: %v", voter.Name, err)
+		}
+	}
+
+	for _, voter := range []*types.Voter{
+		{Name: "alice", Groups: []string{"sre"}},
+		{Name: "bob", Groups: []string{"sre"}},
+	} {
+		_, err = am.Approve("xxx/app-1:1.2.5", voter, "")
+		if err != nil {
+			t.Fatalf("failed to approve: %s", err)
+		}
+	}
+
+	stored, err := am.Get("xxx/app-1:1.2.5")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if stored.VotesReceived != 2 || stored.Status() != types.ApprovalStatusPending {
+		t.Errorf("approval needs a vote from product, got %d votes, status %s", stored.VotesReceived, stored.Status())
+	}
+
+	approved, err := am.Approve("xxx/app-1:1.2.5", &types.Voter{Name: "erin", Groups: []string{"product"}}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+	if approved.Status() != types.ApprovalStatusApproved {
+		t.Errorf("expected approval to be approved, got: %s", approved.Status())
+	}
+}
+
+func TestRejectEligibility(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := New(&Opts{
+		Store: store,
+	})
+
+	approvers, err := types.ParseApproverRules("group:sre=1")
+	if err != nil {
+		t.Fatalf("failed to parse approvers: %s", err)
+	}
+	err = am.Create(&types.Approval{
+		Provider:       types.ProviderTypeKubernetes,
+		Identifier:     "xxx/app-1:1.2.5",
+		CurrentVersion: "1.2.3",
+		NewVersion:     "1.2.5",
+		Deadline:       time.Now().Add(5 * time.Minute),
+		VotesRequired:  1,
+		Approvers:      approvers,
+		Author:         "carol",
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	for _, voter := range []*types.Voter{
+		{Name: "carol", Groups: []string{"sre"}},
+		{Name: "dave", Groups: []string{"finance"}},
+	} {
+		_, err = am.Reject("xxx/app-1:1.2.5", voter, "")
+		if !errors.Is(err, ErrIneligibleVoter) {
+			t.Errorf("expected rejection of %s to be refused, got: %v", voter.Name, err)
+		}
+	}
+
+	stored, err := am.Get("xxx/app-1:1.2.5")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if len(stored.Rejections) != 0 || stored.Rejected {
+		t.Errorf("ineligible rejections mustn't be recorded, got: %v", stored.Rejections)
+	}
+
+	rejected, err := am.Reject("xxx/app-1:1.2.5", &types.Voter{Name: "alice", Groups: []string{"sre"}}, "")
+	if err != nil {
+		t.Fatalf("failed to reject: %s", err)
+	}
+	if !rejected.Rejected {
+		t.Errorf("expected approval to be rejected")
+	}
+}
@@ -0,0 +1,112 @@
+package approvals
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+
+	"github.com/quilla-hq/quilla/types"
+)
+
+// Approval link actions
+const (
+	LinkActionApprove = "approve"
+	LinkActionReject  = "reject"
+)
+
+// DefaultLinkTTL - approval links are valid for a day unless configured otherwise
+const DefaultLinkTTL = 24 * time.Hour
+
+// Approval link errors
+var (
+	ErrInvalidLink = errors.New("invalid approval link")
+	ErrExpiredLink = errors.New("approval link expired")
+)
+
+// LinkClaims - claims of approval link token, link can only be used by
+// the recipient to vote on the approval
+type LinkClaims struct {
+	ApprovalID string `json:"aid"`
+	Action     string `json:"act"`
+	jwt.StandardClaims
+}
+
+// Recipient - address the link was sent to
+func (c *LinkClaims) Recipient() string {
+	return c.Subject
+}
+
+// LinkSigner - signs and verifies tokens of approval links sent to approvers
+type LinkSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewLinkSigner - links expire after ttl or with the approval, whichever is first
+func NewLinkSigner(secret []byte, ttl time.Duration) *LinkSigner {
+	if ttl <= 0 {
+		ttl = DefaultLinkTTL
+	}
+	return &LinkSigner{secret: secret, ttl: ttl}
+}
+
+// Sign - creates token bound to the approval, action and recipient
+func (s *LinkSigner) Sign(approval *types.Approval, action, recipient string) (string, error) {
+	if approval.ID == "" {
+		return "", fmt.Errorf("approval ID not set")
+	}
+	switch action {
+	case LinkActionApprove, LinkActionReject:
+	default:
+		return "", fmt.Errorf("unknown action %q", action)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.ttl)
+	if !approval.Deadline.IsZero() && approval.Deadline.Before(expiresAt) {
+		expiresAt = approval.Deadline
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &LinkClaims{
+		ApprovalID: approval.ID,
+		Action:     action,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			Subject:   recipient,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+	})
+	return token.SignedString(s.secret)
+}
+
+// Verify - checks token signature and expiry, tokens are single-use so callers
+// have to record them once used
+func (s *LinkSigner) Verify(tokenString string) (*LinkClaims, error) {
+	claims := &LinkClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return s.secret, nil
+	})
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired {
+			return nil, ErrExpiredLink
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidLink, err)
+	}
+	if !token.Valid || claims.Id == "" || claims.ApprovalID == "" || claims.Subject == "" {
+		return nil, ErrInvalidLink
+	}
+	switch claims.Action {
+	case LinkActionApprove, LinkActionReject:
+	default:
+		return nil, ErrInvalidLink
+	}
+	return claims, nil
+}
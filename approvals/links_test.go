@@ -0,0 +1,91 @@
+package approvals
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/types"
+)
+
+func TestLinkSignVerify(t *testing.T) {
+	signer := NewLinkSigner([]byte("secret"), time.Hour)
+	approval := &types.Approval{ID: "id-1", Identifier: "xxx/app-1", Deadline: time.Now().Add(24 * time.Hour)}
+
+	token, err := signer.Sign(approval, LinkActionApprove, "jane@example.com")
+	if err != nil {
+		t.Fatalf("failed to sign link: %s", err)
+	}
+
+	claims, err := signer.Verify(token)
+	if err != nil {
+		t.Fatalf("failed to verify link: %s", err)
+	}
+	if claims.ApprovalID != "id-1" {
+		t.Errorf("unexpected approval ID: %s", claims.ApprovalID)
+	}
+	if claims.Action != LinkActionApprove {
+		t.Errorf("unexpected action: %s", claims.Action)
+	}
+	if claims.Recipient() != "jane@example.com" {
+		t.Errorf("unexpected recipient: %s", claims.Recipient())
+	}
+	if claims.Id == "" {
+		t.Errorf("expected token ID to be set")
+	}
+	if expiresAt := time.Unix(claims.ExpiresAt, 0); expiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expected link to expire within TTL, got: %s", expiresAt)
+	}
+
+	other, err := signer.Sign(approval, LinkActionApprove, "jane@example.com")
+	if err != nil {
+		t.Fatalf("failed to sign link: %s", err)
+	}
+	if other == token {
+		t.Errorf("expected every link to get unique token")
+	}
+}
+
+func TestLinkVerifyTampered(t *testing.T) {
+	signer := NewLinkSigner([]byte("secret"), time.Hour)
+	approval := &types.Approval{ID: "id-1", Identifier: "xxx/app-1"}
+
+	token, err := NewLinkSigner([]byte("other-secret"), time.Hour).Sign(approval, LinkActionApprove, "jane@example.com")
+	if err != nil {
+		t.Fatalf("failed to sign link: %s", err)
+	}
+
+	_, err = signer.Verify(token)
+	if !errors.Is(err, ErrInvalidLink) {
+		t.Errorf("expected invalid link error, got: %v", err)
+	}
+
+	_, err = signer.Verify("not-a-token")
+	if !errors.Is(err, ErrInvalidLink) {
+		t.Errorf("expected invalid link error, got: %v", err)
+	}
+}
+
+func TestLinkExpiresWithApproval(t *testing.T) {
+	signer := NewLinkSigner([]byte("secret"), time.Hour)
+	approval := &types.Approval{ID: "id-1", Identifier: "xxx/app-1", Deadline: time.Now().Add(-time.Minute)}
+
+	token, err := signer.Sign(approval, LinkActionReject, "jane@example.com")
+	if err != nil {
+		t.Fatalf("failed to sign link: %s", err)
+	}
+
+	_, err = signer.Verify(token)
+	if err != ErrExpiredLink {
+		t.Errorf("expected expired link error, got: %v", err)
+	}
+}
+
+func TestLinkUnknownAction(t *testing.T) {
+	signer := NewLinkSigner([]byte("secret"), time.Hour)
+
+	_, err := signer.Sign(&types.Approval{ID: "id-1"}, "delete", "jane@example.com")
+	if err == nil {
+		t.Errorf("expected error for unknown action")
+	}
+}
@@ -0,0 +1,214 @@
+package approvals
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// outbox defaults
+const (
+	outboxPollInterval = 5 * time.Second
+	outboxBatchSize    = 100
+	// outboxRetention - events older than that are pruned, subscribers that
+	// were gone for longer miss them
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+// Delivery - approval event delivered to a subscriber. Events are delivered one
+// at a time, the next one once the subscriber acknowledges the current one.
+// Unacknowledged events are delivered again after restart.
+type Delivery struct {
+	*types.Approval
+
+	commit func()
+	acked  chan struct{}
+	once   sync.Once
+}
+
+// Ack - acknowledges the event, it won't be delivered to the subscriber again
+func (d *Delivery) Ack() {
+	d.once.Do(func() {
+		d.commit()
+		close(d.acked)
+	})
+}
+
+type subscriber struct {
+	kind string
+	// wake - signals that events were published, never blocks publishers
+	wake chan struct{}
+}
+
+// saveAndPublish - saves approval together with the event, event is stored
+// only if the approval is
+func (m *DefaultManager) saveAndPublish(kind string, approval *types.Approval) error {
+	event, err := newApprovalEvent(kind, approval)
+	if err != nil {
+		return err
+	}
+
+	err = m.store.UpdateApprovalWithEvent(approval, event)
+	if err != nil {
+		return err
+	}
+
+	m.wake(kind)
+	return nil
+}
+
+func newApprovalEvent(kind string, approval *types.Approval) (*types.ApprovalEvent, error) {
+	payload, err := json.Marshal(approval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode approval: %s", err)
+	}
+	return &types.ApprovalEvent{
+		Kind:       kind,
+		ApprovalID: approval.ID,
+		Identifier: approval.Identifier,
+		Approval:   string(payload),
+	}, nil
+}
+
+// wake - wakes up subscribers of the kind, never blocks
+func (m *DefaultManager) wake(kind string) {
+	m.subMu.RLock()
+	defer m.subMu.RUnlock()
+	for _, s := range m.subscribers {
+		if s.kind != kind {
+			continue
+		}
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// subscribe - delivers events of the kind starting from the subscriber's cursor, new
+// subscribers get events published after they subscribed for the first time
+func (m *DefaultManager) subscribe(ctx context.Context, kind, name string) (<-chan *Delivery, error) {
+	if name == "" {
+		return nil, fmt.Errorf("subscriber name not specified")
+	}
+
+	key := kind + "/" + name
+	cursor, err := m.store.GetOutboxCursor(key)
+	if err == store.ErrRecordNotFound {
+		seq, err := m.store.LastApprovalEventSeq()
+		if err != nil {
+			return nil, err
+		}
+		cursor = &types.OutboxCursor{Subscriber: key, Seq: seq}
+		err = m.store.SaveOutboxCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := &subscriber{kind: kind, wake: make(chan struct{}, 1)}
+	m.subMu.Lock()
+	index := atomic.AddUint32(&m.index, 1)
+	m.subscribers[index] = s
+	m.subMu.Unlock()
+
+	deliveries := make(chan *Delivery)
+	go func() {
+		m.deliver(ctx, cursor, s, deliveries)
+
+		m.subMu.Lock()
+		delete(m.subscribers, index)
+		m.subMu.Unlock()
+	}()
+
+	return deliveries, nil
+}
+
+func (m *DefaultManager) deliver(ctx context.Context, cursor *types.OutboxCursor, s *subscriber, deliveries chan<- *Delivery) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		events, err := m.store.ListApprovalEvents(&types.GetApprovalEventsQuery{
+			Kind:  s.kind,
+			After: cursor.Seq,
+			Limit: outboxBatchSize,
+		})
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"subscriber": cursor.Subscriber,
+			}).Error("approvals.deliver: failed to list approval events")
+		}
+
+		for _, event := range events {
+			seq := event.Seq
+			commit := func() {
+				cursor.Seq = seq
+				err := m.store.SaveOutboxCursor(cursor)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"error":      err,
+						"subscriber": cursor.Subscriber,
+					}).Error("approvals.deliver: failed to save cursor")
+				}
+			}
+
+			var approval types.Approval
+			err := json.Unmarshal([]byte(event.Approval), &approval)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error":      err,
+					"seq":        event.Seq,
+					"identifier": event.Identifier,
+				}).Error("approvals.deliver: failed to decode approval event, skipping")
+				commit()
+				continue
+			}
+
+			delivery := &Delivery{Approval: &approval, commit: commit, acked: make(chan struct{})}
+			select {
+			case deliveries <- delivery:
+			case <-ctx.Done():
+				return
+			}
+			// cursor is saved by Ack
+			select {
+			case <-delivery.acked:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if len(events) == outboxBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// pruneEvents - drops events past retention
+func (m *DefaultManager) pruneEvents(now time.Time) {
+	err := m.store.DeleteApprovalEvents(now.Add(-outboxRetention))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("approvals.pruneEvents: failed to prune approval events")
+	}
+}
@@ -0,0 +1,267 @@
+package approvals
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/types"
+)
+
+func approvedApproval(identifier string) *types.Approval {
+	return &types.Approval{
+		Provider:       types.ProviderTypeKubernetes,
+		Identifier:     identifier,
+		CurrentVersion: "1.2.3",
+		NewVersion:     "1.2.5",
+		VotesRequired:  1,
+		Deadline:       time.Now().Add(5 * time.Minute),
+		Event: &types.Event{
+			Repository: types.Repository{
+				Name: "very/repo",
+				Tag:  "1.2.5",
+			},
+		},
+	}
+}
+
+func receive(t *testing.T, ch <-chan *Delivery) *Delivery {
+	t.Helper()
+	select {
+	case d := <-ch:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatalf("event wasn't delivered")
+	}
+	return nil
+}
+
+func TestPublishDoesNotBlock(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := New(&Opts{
+		Store: store,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// subscriber that never reads
+	_, err := am.Subscribe(ctx, "stuck")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 20; i++ {
+			err := am.Create(approvedApproval(fmt.Sprintf("xxx/app-%d", i)))
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("failed to create approval: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("creating approvals blocked on subscriber")
+	}
+}
+
+func TestCreateStoresRequestedEventWithApproval(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := New(&Opts{
+		Store: store,
+	})
+
+	first := approvedApproval("xxx/app-1")
+	first.ID = "same-id"
+	err := am.Create(first)
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	// approval that can't be stored mustn't leave an event behind
+	second := approvedApproval("xxx/app-2")
+	second.ID = "same-id"
+	err = am.Create(second)
+	if err == nil {
+		t.Fatalf("expected approval with a duplicate ID to fail")
+	}
+
+	events, err := store.ListApprovalEvents(&types.GetApprovalEventsQuery{Kind: types.ApprovalEventRequested})
+	if err != nil {
+		t.Fatalf("failed to list events: %s", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 requested event, got: %d", len(events))
+	}
+	if events[0].ApprovalID != "same-id" || events[0].Identifier != "xxx/app-1" {
+		t.Errorf("unexpected event: %+v", events[0])
+	}
+}
+
+func TestApprovedRedeliveredAfterRestart(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := New(&Opts{
+		Store: store,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := am.SubscribeApproved(ctx, "providers")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+
+	err = am.Create(approvedApproval("xxx/app-1"))
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+	_, err = am.Approve("xxx/app-1", &types.Voter{Name: "jane"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+
+	// crashing before the update is applied
+	d := receive(t, ch)
+	if d.Identifier != "xxx/app-1" {
+		t.Errorf("unexpected identifier: %s", d.Identifier)
+	}
+	cancel()
+
+	restarted := New(&Opts{
+		Store: store,
+	})
+	ctx, cancel = context.WithCancel(context.Background())
+	ch, err = restarted.SubscribeApproved(ctx, "providers")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+
+	d = receive(t, ch)
+	if d.Identifier != "xxx/app-1" {
+		t.Errorf("unexpected identifier: %s", d.Identifier)
+	}
+	if d.Event == nil || d.Event.Repository.Tag != "1.2.5" {
+		t.Errorf("expected approved event to be delivered, got: %v", d.Event)
+	}
+	d.Ack()
+	cancel()
+
+	// acknowledged events aren't delivered again
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	ch, err = New(&Opts{Store: store}).SubscribeApproved(ctx, "providers")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+	select {
+	case d := <-ch:
+		t.Errorf("unexpected redelivery of %s", d.Identifier)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestSubscribersHaveOwnCursors(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := New(&Opts{
+		Store: store,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	slackCh, err := am.Subscribe(ctx, "bot/slack")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+	teamsCh, err := am.Subscribe(ctx, "bot/teams")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+
+	err = am.Create(approvedApproval("xxx/app-1"))
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+	err = am.Create(approvedApproval("xxx/app-2"))
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	// slack is processing both, teams is stuck on the first one
+	d := receive(t, slackCh)
+	d.Ack()
+	d = receive(t, slackCh)
+	if d.Identifier != "xxx/app-2" {
+		t.Errorf("unexpected identifier: %s", d.Identifier)
+	}
+	d.Ack()
+
+	d = receive(t, teamsCh)
+	if d.Identifier != "xxx/app-1" {
+		t.Errorf("unexpected identifier: %s", d.Identifier)
+	}
+
+	slack, err := store.GetOutboxCursor(types.ApprovalEventRequested + "/bot/slack")
+	if err != nil {
+		t.Fatalf("failed to get cursor: %s", err)
+	}
+	teams, err := store.GetOutboxCursor(types.ApprovalEventRequested + "/bot/teams")
+	if err != nil {
+		t.Fatalf("failed to get cursor: %s", err)
+	}
+	if teams.Seq >= slack.Seq {
+		t.Errorf("expected teams cursor (%d) behind slack cursor (%d)", teams.Seq, slack.Seq)
+	}
+}
+
+func TestApprovedPublishedOnce(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := New(&Opts{
+		Store: store,
+	})
+
+	err := am.Create(approvedApproval("xxx/app-1"))
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+	for _, voter := range []string{"jane", "bob", "alice"} {
+		_, err = am.Approve("xxx/app-1", &types.Voter{Name: voter}, "")
+		if err != nil {
+			t.Fatalf("failed to approve: %s", err)
+		}
+	}
+
+	events, err := store.ListApprovalEvents(&types.GetApprovalEventsQuery{
+		Kind: types.ApprovalEventApproved,
+	})
+	if err != nil {
+		t.Fatalf("failed to list events: %s", err)
+	}
+	if len(events) != 1 {
+		t.Errorf("expected approved event once, got: %d", len(events))
+	}
+
+	approval, err := am.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.VotesReceived != 3 {
+		t.Errorf("expected votes to be saved, got: %d", approval.VotesReceived)
+	}
+}
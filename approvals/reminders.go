@@ -0,0 +1,188 @@
+package approvals
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// expiryCheckInterval - how often approvals are checked for expiry, reminders
+// and auto-approval
+var expiryCheckInterval = time.Minute
+
+// Reminders - notifications sent while approval is pending
+type Reminders struct {
+	// Before - reminders are sent when time left until the deadline drops
+	// below each of the durations
+	Before []time.Duration
+	// EscalateBefore - approval is escalated when time left until the deadline
+	// drops below it, zero disables escalation
+	EscalateBefore time.Duration
+	// EscalationChannels - channels escalations are sent to, defaults
+	// to approval channels
+	EscalationChannels []string
+}
+
+// ParseReminders - parses comma separated durations before the deadline, ie: "4h,1h,15m"
+func ParseReminders(value string) ([]time.Duration, error) {
+	var reminders []time.Duration
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		d, err := time.ParseDuration(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reminder %q: %s", entry, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid reminder %q, duration must be positive", entry)
+		}
+		reminders = append(reminders, d)
+	}
+	// longest first, reminders are sent in that order
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i] > reminders[j] })
+	return reminders, nil
+}
+
+// autoApprove - approves pending approval once auto-approve time passes without
+// a rejection, approved event is published to the providers. Any rejection, even
+// one below the veto threshold, means there is no consensus and voting continues.
+func (m *DefaultManager) autoApprove(approval *types.Approval, now time.Time) bool {
+	if approval.AutoApproveAt.IsZero() || now.Before(approval.AutoApproveAt) {
+		return false
+	}
+	if len(approval.Rejections) > 0 {
+		return false
+	}
+
+	approval.AutoApproved = true
+	err := m.Update(approval)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": approval.Identifier,
+		}).Error("approvals.autoApprove: failed to update approval")
+		return false
+	}
+
+	m.addAuditEntry(approval, types.AuditActionApprovalAutoApproved, "", "")
+	m.notify(approval, types.EventNotification{
+		Name:    "update auto-approved",
+		Message: fmt.Sprintf("Update %s (%s) was auto-approved, nobody rejected it by %s (votes %d/%d)", approval.Identifier, approval.Delta(), approval.AutoApproveAt.Format(time.RFC3339), approval.VotesReceived, approval.VotesRequired),
+		Type:    types.NotificationUpdateApproved,
+		Level:   types.LevelInfo,
+	})
+	return true
+}
+
+// remind - sends reminder once time left drops below the next reminder and
+// escalates approval when it's close to the deadline
+func (m *DefaultManager) remind(approval *types.Approval, now time.Time) {
+	if m.sender == nil {
+		return
+	}
+
+	left := approval.Deadline.Sub(now)
+	var changed bool
+
+	due := 0
+	for _, before := range m.reminders.Before {
+		if left <= before {
+			due++
+		}
+	}
+	if due > approval.RemindersSent {
+		approval.RemindersSent = due
+		changed = true
+
+		m.addAuditEntry(approval, types.AuditActionApprovalReminded, "", "")
+		m.notify(approval, types.EventNotification{
+			Name:    "approval reminder",
+			Message: fmt.Sprintf("Update %s (%s) is waiting for approval, votes %d/%d, expires in %s", approval.Identifier, approval.Delta(), approval.VotesReceived, approval.VotesRequired, left.Round(time.Minute)),
+			Type:    types.NotificationSystemEvent,
+			Level:   types.LevelInfo,
+		})
+	}
+
+	if m.reminders.EscalateBefore > 0 && !approval.Escalated && left <= m.reminders.EscalateBefore {
+		approval.Escalated = true
+		changed = true
+
+		channels := m.reminders.EscalationChannels
+		if len(channels) == 0 {
+			channels = approval.Channels
+		}
+		m.addAuditEntry(approval, types.AuditActionApprovalEscalated, "", "")
+		m.notify(approval, types.EventNotification{
+			Name:     "approval escalated",
+			Message:  fmt.Sprintf("Update %s (%s) still needs approval, votes %d/%d, expires in %s", approval.Identifier, approval.Delta(), approval.VotesReceived, approval.VotesRequired, left.Round(time.Minute)),
+			Type:     types.NotificationSystemEvent,
+			Level:    types.LevelWarn,
+			Channels: channels,
+		})
+	}
+
+	if !changed {
+		return
+	}
+	err := m.store.UpdateApproval(approval)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": approval.Identifier,
+		}).Error("approvals.remind: failed to update approval")
+	}
+}
+
+func (m *DefaultManager) notifyExpired(approval *types.Approval) {
+	m.notify(approval, types.EventNotification{
+		Name:    "approval expired",
+		Message: fmt.Sprintf("Approval of update %s (%s) expired with votes %d/%d, update won't be applied", approval.Identifier, approval.Delta(), approval.VotesReceived, approval.VotesRequired),
+		Type:    types.NotificationSystemEvent,
+		Level:   types.LevelWarn,
+	})
+}
+
+func (m *DefaultManager) notifyRejected(approval *types.Approval) {
+	m.notify(approval, types.EventNotification{
+		Name:    "update rejected",
+		Message: fmt.Sprintf("Update %s (%s) was rejected by %s, update won't be applied", approval.Identifier, approval.Delta(), approval.Rejections),
+		Type:    types.NotificationSystemEvent,
+		Level:   types.LevelWarn,
+	})
+}
+
+// notify - sends notification about the approval, approval channels are
+// used unless notification sets its own
+func (m *DefaultManager) notify(approval *types.Approval, event types.EventNotification) {
+	if m.sender == nil {
+		return
+	}
+	event.CreatedAt = time.Now()
+	event.ResourceKind = types.AuditResourceKindApproval
+	event.Identifier = approval.Identifier
+	if len(event.Channels) == 0 {
+		event.Channels = approval.Channels
+	}
+	event.Metadata = map[string]string{
+		"provider":        approval.Provider.String(),
+		"approval_id":     approval.ID,
+		"new_version":     approval.NewVersion,
+		"current_version": approval.CurrentVersion,
+	}
+
+	err := m.sender.Send(event)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": approval.Identifier,
+			"name":       event.Name,
+		}).Error("approvals.notify: failed to send notification")
+	}
+}
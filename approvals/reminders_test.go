@@ -0,0 +1,356 @@
+package approvals
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/extension/notification"
+	"github.com/quilla-hq/quilla/types"
+)
+
+type fakeSender struct {
+	sent []types.EventNotification
+}
+
+func (s *fakeSender) Configure(cfg *notification.Config) (bool, error) {
+	return true, nil
+}
+
+func (s *fakeSender) Send(event types.EventNotification) error {
+	s.sent = append(s.sent, event)
+	return nil
+}
+
+func (s *fakeSender) names() []string {
+	var names []string
+	for _, event := range s.sent {
+		names = append(names, event.Name)
+	}
+	return names
+}
+
+func auditActions(t *testing.T, am *DefaultManager, identifier string) map[string]bool {
+	logs, err := am.store.GetAuditLogs(&types.AuditLogQuery{
+		ResourceKindFilter: []string{types.AuditResourceKindApproval},
+	})
+	if err != nil {
+		t.Fatalf("failed to get audit logs: %s", err)
+	}
+	actions := map[string]bool{}
+	for _, l := range logs {
+		if l.Identifier == identifier {
+			actions[l.Action] = true
+		}
+	}
+	return actions
+}
+
+func TestParseReminders(t *testing.T) {
+	reminders, err := ParseReminders("15m, 4h,1h")
+	if err != nil {
+		t.Fatalf("failed to parse reminders: %s", err)
+	}
+	want := []time.Duration{4 * time.Hour, time.Hour, 15 * time.Minute}
+	if !reflect.DeepEqual(reminders, want) {
+		t.Errorf("unexpected reminders: %v", reminders)
+	}
+
+	for _, value := range []string{"1x", "-1h"} {
+		if _, err := ParseReminders(value); err == nil {
+			t.Errorf("expected %q to fail", value)
+		}
+	}
+}
+
+func TestRemindersAndEscalation(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	sender := &fakeSender{}
+	am := New(&Opts{
+		Store:  store,
+		Sender: sender,
+		Reminders: Reminders{
+			Before:             []time.Duration{4 * time.Hour, time.Hour},
+			EscalateBefore:     30 * time.Minute,
+			EscalationChannels: []string{"sre-oncall"},
+		},
+	})
+
+	err := am.Create(&types.Approval{
+		Provider:       types.ProviderTypeKubernetes,
+		Identifier:     "xxx/app-1:1.2.5",
+		CurrentVersion: "1.2.3",
+		NewVersion:     "1.2.5",
+		VotesRequired:  1,
+		Deadline:       time.Now().Add(2 * time.Hour),
+		Channels:       types.Channels{"deployments"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	// first reminder is due, repeated checks don't send it again
+	for i := 0; i < 2; i++ {
+		err = am.expireEntries()
+		if err != nil {
+			t.Fatalf("failed to check approvals: %s", err)
+		}
+	}
+	if len(sender.sent) != 1 || sender.sent[0].Name != "approval reminder" {
+		t.Fatalf("expected a single reminder, got: %v", sender.names())
+	}
+	if !reflect.DeepEqual(sender.sent[0].Channels, []string{"deployments"}) {
+		t.Errorf("reminder should be sent to approval channels, got: %v", sender.sent[0].Channels)
+	}
+
+	approval, err := am.Get("xxx/app-1:1.2.5")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	approval.Deadline = time.Now().Add(20 * time.Minute)
+	err = store.UpdateApproval(approval)
+	if err != nil {
+		t.Fatalf("failed to update approval: %s", err)
+	}
+
+	err = am.expireEntries()
+	if err != nil {
+		t.Fatalf("failed to check approvals: %s", err)
+	}
+	if !reflect.DeepEqual(sender.names(), []string{"approval reminder", "approval reminder", "approval escalated"}) {
+		t.Fatalf("unexpected notifications: %v", sender.names())
+	}
+	escalation := sender.sent[2]
+	if escalation.Level != types.LevelWarn || !reflect.DeepEqual(escalation.Channels, []string{"sre-oncall"}) {
+		t.Errorf("unexpected escalation: %+v", escalation)
+	}
+
+	actions := auditActions(t, am, "xxx/app-1:1.2.5")
+	if !actions[types.AuditActionApprovalReminded] || !actions[types.AuditActionApprovalEscalated] {
+		t.Errorf("expected reminders and escalation to be audited, got: %v", actions)
+	}
+}
+
+func TestAutoApprove(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	sender := &fakeSender{}
+	am := New(&Opts{
+		Store:  store,
+		Sender: sender,
+	})
+
+	for identifier, autoApproveAt := range map[string]time.Time{
+		"xxx/app-1:1.2.5": time.Now().Add(-time.Minute),
+		"xxx/app-2:1.2.5": time.Now().Add(time.Hour),
+		"xxx/app-3:1.2.5": time.Now().Add(-time.Minute),
+	} {
+		err := am.Create(&types.Approval{
+			Provider:       types.ProviderTypeKubernetes,
+			Identifier:     identifier,
+			CurrentVersion: "1.2.3",
+			NewVersion:     "1.2.5",
+			VotesRequired:  2,
+			Deadline:       time.Now().Add(24 * time.Hour),
+			AutoApproveAt:  autoApproveAt,
+		})
+		if err != nil {
+			t.Fatalf("failed to create approval: %s", err)
+		}
+	}
+	// single rejection doesn't veto the update but there's no consensus either
+	err := am.Create(&types.Approval{
+		Provider:       types.ProviderTypeKubernetes,
+		Identifier:     "xxx/app-4:1.2.5",
+		CurrentVersion: "1.2.3",
+		NewVersion:     "1.2.5",
+		VotesRequired:  2,
+		Veto:           &types.VetoRule{Rejections: 2},
+		Deadline:       time.Now().Add(24 * time.Hour),
+		AutoApproveAt:  time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+	_, err = am.Reject("xxx/app-4:1.2.5", &types.Voter{Name: "admin"}, "")
+	if err != nil {
+		t.Fatalf("failed to reject: %s", err)
+	}
+
+	_, err = am.Reject("xxx/app-3:1.2.5", &types.Voter{Name: "admin"}, "")
+	if err != nil {
+		t.Fatalf("failed to reject: %s", err)
+	}
+
+	err = am.expireEntries()
+	if err != nil {
+		t.Fatalf("failed to check approvals: %s", err)
+	}
+
+	for identifier, want := range map[string]types.ApprovalStatus{
+		"xxx/app-1:1.2.5": types.ApprovalStatusApproved,
+		"xxx/app-2:1.2.5": types.ApprovalStatusPending,
+		"xxx/app-3:1.2.5": types.ApprovalStatusRejected,
+		"xxx/app-4:1.2.5": types.ApprovalStatusPending,
+	} {
+		approval, err := am.Get(identifier)
+		if err != nil {
+			t.Fatalf("failed to get approval: %s", err)
+		}
+		if approval.Status() != want {
+			t.Errorf("%s: expected %s, got %s", identifier, want, approval.Status())
+		}
+	}
+
+	if !reflect.DeepEqual(sender.names(), []string{"update rejected", "update auto-approved"}) {
+		t.Errorf("unexpected notifications: %v", sender.names())
+	}
+	if !auditActions(t, am, "xxx/app-1:1.2.5")[types.AuditActionApprovalAutoApproved] {
+		t.Errorf("expected auto-approval to be audited")
+	}
+}
+
+func TestAutoApprovedResetOnRepush(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := New(&Opts{
+		Store:  store,
+		Sender: &fakeSender{},
+	})
+
+	err := am.Create(&types.Approval{
+		Provider:         types.ProviderTypeKubernetes,
+		Identifier:       "xxx/app-1:1.2.5",
+		CurrentVersion:   "1.2.3",
+		NewVersion:       "1.2.5",
+		VotesRequired:    2,
+		Digest:           "sha256:aaa",
+		Deadline:         time.Now().Add(24 * time.Hour),
+		AutoApproveAt:    time.Now().Add(-time.Minute),
+		AutoApproveAfter: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	err = am.expireEntries()
+	if err != nil {
+		t.Fatalf("failed to check approvals: %s", err)
+	}
+	approval, err := am.Get("xxx/app-1:1.2.5")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.Status() != types.ApprovalStatusApproved {
+		t.Fatalf("expected approval to be auto-approved, got %s", approval.Status())
+	}
+
+	// tag pushed again, auto-approval was given to the previous image
+	reset, err := am.ResetVotes("xxx/app-1:1.2.5", "sha256:bbb")
+	if err != nil {
+		t.Fatalf("failed to reset votes: %s", err)
+	}
+	if reset.Status() != types.ApprovalStatusPending {
+		t.Errorf("expected re-pushed image to need approval, got %s", reset.Status())
+	}
+	if reset.AutoApproveAt.Before(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("expected auto-approval to start over, auto-approve at: %s", reset.AutoApproveAt)
+	}
+
+	err = am.expireEntries()
+	if err != nil {
+		t.Fatalf("failed to check approvals: %s", err)
+	}
+	approval, err = am.Get("xxx/app-1:1.2.5")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.Status() != types.ApprovalStatusPending {
+		t.Errorf("expected approval to stay pending, got %s", approval.Status())
+	}
+}
+
+func TestExpireNotification(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	sender := &fakeSender{}
+	am := New(&Opts{
+		Store:  store,
+		Sender: sender,
+	})
+
+	err := am.Create(&types.Approval{
+		Provider:       types.ProviderTypeKubernetes,
+		Identifier:     "xxx/app-1:1.2.5",
+		CurrentVersion: "1.2.3",
+		NewVersion:     "1.2.5",
+		VotesRequired:  1,
+		Deadline:       time.Now().Add(-time.Minute),
+		Channels:       types.Channels{"deployments"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	err = am.expireEntries()
+	if err != nil {
+		t.Fatalf("failed to check approvals: %s", err)
+	}
+
+	if len(sender.sent) != 1 || sender.sent[0].Name != "approval expired" {
+		t.Fatalf("expected expiry notification, got: %v", sender.names())
+	}
+	if !reflect.DeepEqual(sender.sent[0].Channels, []string{"deployments"}) {
+		t.Errorf("unexpected channels: %v", sender.sent[0].Channels)
+	}
+}
+
+func TestExpireSkipsDecidedApprovals(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	sender := &fakeSender{}
+	am := New(&Opts{
+		Store:  store,
+		Sender: sender,
+	})
+
+	for _, approval := range []*types.Approval{
+		{Identifier: "xxx/app-1:1.2.5", VotesRequired: 1, VotesReceived: 1, Archived: true},
+		{Identifier: "xxx/app-2:1.2.5", VotesRequired: 1, VotesReceived: 1},
+		{Identifier: "xxx/app-3:1.2.5", VotesRequired: 1, Rejected: true},
+	} {
+		approval.Provider = types.ProviderTypeKubernetes
+		approval.CurrentVersion = "1.2.3"
+		approval.NewVersion = "1.2.5"
+		approval.Deadline = time.Now().Add(-time.Minute)
+		err := am.Create(approval)
+		if err != nil {
+			t.Fatalf("failed to create approval: %s", err)
+		}
+	}
+
+	err := am.expireEntries()
+	if err != nil {
+		t.Fatalf("failed to check approvals: %s", err)
+	}
+
+	for identifier, archived := range map[string]bool{
+		"xxx/app-1:1.2.5": true,
+		"xxx/app-2:1.2.5": false,
+		"xxx/app-3:1.2.5": false,
+	} {
+		_, err := am.store.GetApproval(&types.GetApprovalQuery{Identifier: identifier, Archived: archived})
+		if err != nil {
+			t.Errorf("%s: expected approval to be kept, got: %s", identifier, err)
+		}
+	}
+	if len(sender.sent) != 0 {
+		t.Errorf("expected no notifications, got: %v", sender.names())
+	}
+}
@@ -3,6 +3,7 @@ package bot
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -16,8 +17,8 @@ import (
 type BotRequestApproval func(req *types.Approval) error
 type BotReplyApproval func(approval *types.Approval) error
 
-func (bm *BotManager) SubscribeForApprovals(ctx context.Context, approval BotRequestApproval) error {
-	approvalsCh, err := bm.approvalsManager.Subscribe(ctx)
+func (bm *BotManager) SubscribeForApprovals(ctx context.Context, botName string, approval BotRequestApproval) error {
+	approvalsCh, err := bm.approvalsManager.Subscribe(ctx, "bot/"+botName)
 	if err != nil {
 		log.Errorf("bot.subscribeForApprovals(): %s", err.Error())
 		return err
@@ -28,18 +29,19 @@ func (bm *BotManager) SubscribeForApprovals(ctx context.Context, approval BotReq
 		case <-ctx.Done():
 			return nil
 		case a := <-approvalsCh:
-			err = approval(a)
+			err = approval(a.Approval)
 			if err != nil {
 				log.WithFields(log.Fields{
 					"error":    err,
 					"approval": a.Identifier,
 				}).Error("bot.subscribeForApprovals: approval request failed")
 			}
+			a.Ack()
 		}
 	}
 }
 
-func (bm *BotManager) ProcessApprovalResponses(ctx context.Context, reply BotReplyApproval) error {
+func (bm *BotManager) ProcessApprovalResponses(ctx context.Context, reply BotReplyApproval, respond BotMessageResponder) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -47,14 +49,14 @@ func (bm *BotManager) ProcessApprovalResponses(ctx context.Context, reply BotRep
 		case resp := <-bm.approvalsRespCh:
 			switch resp.Status {
 			case types.ApprovalStatusApproved:
-				err := bm.processApprovedResponse(resp, reply)
+				err := bm.processApprovedResponse(resp, reply, respond)
 				if err != nil {
 					log.WithFields(log.Fields{
 						"error": err,
 					}).Error("bot.processApprovalResponses: failed to process approval response message")
 				}
 			case types.ApprovalStatusRejected:
-				err := bm.processRejectedResponse(resp, reply)
+				err := bm.processRejectedResponse(resp, reply, respond)
 				if err != nil {
 					log.WithFields(log.Fields{
 						"error": err,
@@ -65,8 +67,8 @@ func (bm *BotManager) ProcessApprovalResponses(ctx context.Context, reply BotRep
 	}
 }
 
-func (bm *BotManager) processApprovedResponse(approvalResponse *ApprovalResponse, reply BotReplyApproval) error {
-	trimmed := strings.TrimPrefix(approvalResponse.Text, ApprovalResponseKeyword)
+func (bm *BotManager) processApprovedResponse(approvalResponse *ApprovalResponse, reply BotReplyApproval, respond BotMessageResponder) error {
+	trimmed, comment := SplitComment(strings.TrimPrefix(approvalResponse.Text, ApprovalResponseKeyword))
 	identifiers := strings.Split(trimmed, " ")
 	if len(identifiers) == 0 {
 		return nil
@@ -76,7 +78,11 @@ func (bm *BotManager) processApprovedResponse(approvalResponse *ApprovalResponse
 		if identifier == "" {
 			continue
 		}
-		approval, err := bm.approvalsManager.Approve(identifier, approvalResponse.User)
+		approval, err := bm.approvalsManager.Approve(identifier, bm.users.Voter(approvalResponse.User), comment)
+		if errors.Is(err, approvals.ErrIneligibleVoter) {
+			respond(fmt.Sprintf("vote for '%s' rejected: %s", identifier, err), approvalResponse.Channel)
+			continue
+		}
 		if err != nil {
 			log.WithFields(log.Fields{
 				"error":      err,
@@ -96,15 +102,22 @@ func (bm *BotManager) processApprovedResponse(approvalResponse *ApprovalResponse
 	return nil
 }
 
-func (bm *BotManager) processRejectedResponse(approvalResponse *ApprovalResponse, reply BotReplyApproval) error {
-	trimmed := strings.TrimPrefix(approvalResponse.Text, RejectResponseKeyword)
+func (bm *BotManager) processRejectedResponse(approvalResponse *ApprovalResponse, reply BotReplyApproval, respond BotMessageResponder) error {
+	trimmed, comment := SplitComment(strings.TrimPrefix(approvalResponse.Text, RejectResponseKeyword))
 	identifiers := strings.Split(trimmed, " ")
 	if len(identifiers) == 0 {
 		return nil
 	}
 
 	for _, identifier := range identifiers {
-		approval, err := bm.approvalsManager.Reject(identifier)
+		if identifier == "" {
+			continue
+		}
+		approval, err := bm.approvalsManager.Reject(identifier, bm.users.Voter(approvalResponse.User), comment)
+		if errors.Is(err, approvals.ErrIneligibleVoter) {
+			respond(fmt.Sprintf("rejection of '%s' refused: %s", identifier, err), approvalResponse.Channel)
+			continue
+		}
 		if err != nil {
 			log.WithFields(log.Fields{
 				"error":      err,
@@ -149,6 +162,19 @@ func ApprovalsResponse(approvalsManager approvals.Manager) string {
 	return buf.String()
 }
 
+// CommentSeparator - separates approval identifiers from the comment,
+// ie: reject default/app:1.2.0 -- breaks checkout
+const CommentSeparator = " -- "
+
+// SplitComment - splits approve/reject command text into identifiers and comment
+func SplitComment(text string) (identifiers, comment string) {
+	idx := strings.Index(text, CommentSeparator)
+	if idx < 0 {
+		return text, ""
+	}
+	return text[:idx], strings.TrimSpace(text[idx+len(CommentSeparator):])
+}
+
 func IsApproval(eventUser string, eventText string) (resp *ApprovalResponse, ok bool) {
 	if strings.HasPrefix(strings.ToLower(eventText), ApprovalResponseKeyword) {
 		return &ApprovalResponse{
@@ -3,6 +3,7 @@ package bot
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 	"sync"
 
@@ -24,8 +25,8 @@ var (
 			`- "get deployments" -> get a list of all deployments`,
 			`- "get approvals" -> get a list of approvals`,
 			`- "rm approval <approval identifier>" -> remove approval`,
-			`- "approve <approval identifier>" -> approve update request`,
-			`- "reject <approval identifier>" -> reject update request`,
+			`- "approve <approval identifier> [-- <comment>]" -> approve update request`,
+			`- "reject <approval identifier> [-- <comment>]" -> reject update request`,
 			// `- "get deployments all" -> get a list of all deployments`,
 			// `- "describe deployment <deployment>" -> get details for specified deployment`,
 		},
@@ -52,6 +53,13 @@ type Bot interface {
 	ReplyToApproval(approval *types.Approval) error
 }
 
+// InteractiveBot - bot that receives interactions, ie: button clicks, over HTTP.
+// Handler is nil when interactivity isn't configured.
+type InteractiveBot interface {
+	Bot
+	InteractionHandler() http.Handler
+}
+
 type teardown func()
 type BotMessageResponder func(response string, channel string)
 
@@ -75,6 +83,8 @@ type ApprovalResponse struct {
 	User   string
 	Status types.ApprovalStatus
 	Text   string
+	// Channel - where the vote was received, rejected votes are explained there
+	Channel string
 }
 
 // BotManager holds approvalsManager and k8sImplementer for every bot
@@ -83,6 +93,7 @@ type BotManager struct {
 	k8sImplementer     kubernetes.Implementer
 	botMessagesChannel chan *BotMessage
 	approvalsRespCh    chan *ApprovalResponse
+	users              UserMapping
 }
 
 // RegisterBot makes a bot implementation available by the provided name.
@@ -109,11 +120,12 @@ func RegisterBot(name string, b Bot) {
 	bots[name] = b
 }
 
-// Run all implemented bots
-func Run(k8sImplementer kubernetes.Implementer, approvalsManager approvals.Manager) {
+// Run all implemented bots, users map chat users to voters
+func Run(k8sImplementer kubernetes.Implementer, approvalsManager approvals.Manager, users UserMapping) {
 	bm := &BotManager{
 		approvalsManager:   approvalsManager,
 		k8sImplementer:     k8sImplementer,
+		users:              users,
 		approvalsRespCh:    make(chan *ApprovalResponse), // don't add buffer to make it blocking
 		botMessagesChannel: make(chan *BotMessage),
 	}
@@ -140,8 +152,8 @@ func (bm *BotManager) SetupBot(botName string, bot Bot) {
 		teardowns[botName] = func() { cancel() }
 
 		go bm.ProcessBotMessages(ctx, bot.Respond)
-		go bm.ProcessApprovalResponses(ctx, bot.ReplyToApproval)
-		go bm.SubscribeForApprovals(ctx, bot.RequestApproval)
+		go bm.ProcessApprovalResponses(ctx, bot.ReplyToApproval, bot.Respond)
+		go bm.SubscribeForApprovals(ctx, botName, bot.RequestApproval)
 	}
 }
 
@@ -226,6 +238,27 @@ func (bm *BotManager) handleBotMessage(m *BotMessage) string {
 	return fmt.Sprintf("unknown command '%s'", command)
 }
 
+// InteractionHandlers - interaction handlers of running bots by bot name
+func InteractionHandlers() map[string]http.Handler {
+	botsM.RLock()
+	defer botsM.RUnlock()
+
+	handlers := make(map[string]http.Handler)
+	for botName, b := range bots {
+		if _, ok := teardowns[botName]; !ok {
+			continue
+		}
+		interactive, ok := b.(InteractiveBot)
+		if !ok {
+			continue
+		}
+		if handler := interactive.InteractionHandler(); handler != nil {
+			handlers[botName] = handler
+		}
+	}
+	return handlers
+}
+
 // UnregisterBot removes a Sender with a particular name from the list.
 func UnregisterBot(name string) {
 	botsM.Lock()
@@ -2,6 +2,7 @@ package hipchat
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/quilla-hq/quilla/types"
 )
@@ -11,6 +12,9 @@ func (b *Bot) RequestApproval(req *types.Approval) error {
 		req.Message, req.Identifier, req.Identifier,
 		req.VotesReceived, req.VotesRequired, req.Delta(), req.Identifier,
 		req.Provider.String())
+	if !req.Plan.Empty() {
+		msg += fmt.Sprintf(PlannedChangesTempl, strings.Replace(req.Plan.Diff(), "\n", "\n      ", -1))
+	}
 	return b.postMessage(formatAsSnippet(msg))
 }
 
@@ -19,11 +23,15 @@ func (b *Bot) ReplyToApproval(approval *types.Approval) error {
 	case types.ApprovalStatusPending:
 		msg := fmt.Sprintf(VoteReceivedTempl,
 			approval.VotesReceived, approval.VotesRequired, approval.Delta(), approval.Identifier)
+		if len(approval.Rejections) > 0 {
+			msg += fmt.Sprintf(RejectedByTempl, approval.Rejections)
+		}
 		b.postMessage(formatAsSnippet(msg))
 	case types.ApprovalStatusRejected:
 		msg := fmt.Sprintf(ChangeRejectedTempl,
 			approval.Status().String(), approval.VotesReceived, approval.VotesRequired,
 			approval.Delta(), approval.Identifier)
+		msg += fmt.Sprintf(RejectedByTempl, approval.Rejections)
 		b.postMessage(formatAsSnippet(msg))
 	case types.ApprovalStatusApproved:
 		msg := fmt.Sprintf(UpdateApprovedTempl,
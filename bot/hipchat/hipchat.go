@@ -114,6 +114,7 @@ func (b *Bot) handleMessage(message *h.Message) {
 
 	approval, ok := bot.IsApproval(msg.From, msg.Body)
 	if ok {
+		approval.Channel = b.approvalsChannel
 		b.approvalsRespCh <- approval
 		return
 	}
@@ -99,7 +99,7 @@ func NewBot(k8sImplementer kubernetes.Implementer,
 	os.Setenv("HIPCHAT_CONNECTION_ATTEMPTS", "0")
 
 	b.RegisterBot("fakechat", fakeBot)
-	b.Run(k8sImplementer, approvalsManager)
+	b.Run(k8sImplementer, approvalsManager, nil)
 	return fakeBot
 }
 
@@ -267,9 +267,17 @@ func TestBotReject(t *testing.T) {
 	}
 
 	// reject
-	fi.messageFromChat("reject k8s/project/repo:1.2.3")
+	fi.messageFromChat("reject k8s/project/repo:1.2.3 -- breaks checkout")
 	time.Sleep(1 * time.Second)
 
+	rejected, err := am.Get("k8s/project/repo:1.2.3")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if len(rejected.Rejections) != 1 || rejected.Rejections[0].Voter != "test" || rejected.Rejections[0].Comment != "breaks checkout" {
+		t.Errorf("unexpected rejections: %v", rejected.Rejections)
+	}
+
 	if len(fi.postedMessages) != 3 {
 		t.Errorf("expected to find 3 message, but got: %d", len(fi.postedMessages))
 	}
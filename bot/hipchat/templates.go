@@ -9,6 +9,13 @@ var ApprovalRequiredTempl = `Approval required!
     Identifier: %s
     Provider: %s`
 
+var PlannedChangesTempl = `
+    Planned changes:
+      %s`
+
+var RejectedByTempl = `
+    Rejected by: %s`
+
 var VoteReceivedTempl = `Vote received
   Waiting for remaining votes!
     Votes: %d/%d
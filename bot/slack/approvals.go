@@ -2,6 +2,7 @@ package slack
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/quilla-hq/quilla/types"
 	"github.com/slack-go/slack"
@@ -9,68 +10,106 @@ import (
 
 // Request - request approval
 func (b *Bot) RequestApproval(req *types.Approval) error {
+	// approvers vote with buttons once interactivity is configured
+	if b.signingSecret != "" {
+		return b.postApprovalMessage(req)
+	}
+
+	fields := []slack.AttachmentField{
+		{
+			Title: "Approval required!",
+			Value: req.Message + "\n" + fmt.Sprintf("To vote for change type '%s approve %s' to reject it: '%s reject %s'.", b.name, req.Identifier, b.name, req.Identifier),
+			Short: false,
+		},
+		{
+			Title: "Votes",
+			Value: fmt.Sprintf("%d/%d", req.VotesReceived, req.VotesRequired),
+			Short: true,
+		},
+		{
+			Title: "Delta",
+			Value: req.Delta(),
+			Short: true,
+		},
+		{
+			Title: "Identifier",
+			Value: req.Identifier,
+			Short: true,
+		},
+		{
+			Title: "Provider",
+			Value: req.Provider.String(),
+			Short: true,
+		},
+	}
+
+	// update group is approved as a single request
+	if len(req.Members) > 0 {
+		fields = append(fields, slack.AttachmentField{
+			Title: "Members",
+			Value: strings.Join(req.Members, "\n"),
+			Short: false,
+		})
+	}
+
+	// exact changes that will be applied once approved
+	if !req.Plan.Empty() {
+		fields = append(fields, slack.AttachmentField{
+			Title: "Planned changes",
+			Value: "```" + req.Plan.Diff() + "```",
+			Short: false,
+		})
+	}
+
 	return b.postMessage(
 		"Approval required",
 		req.Message,
 		types.LevelSuccess.Color(),
-		[]slack.AttachmentField{
+		fields)
+}
+
+func (b *Bot) ReplyToApproval(approval *types.Approval) error {
+	if msg, ok := b.getApprovalMessage(approval.Identifier); ok {
+		return b.updateApprovalMessage(approval, msg)
+	}
+
+	switch approval.Status() {
+	case types.ApprovalStatusPending:
+		fields := []slack.AttachmentField{
 			{
-				Title: "Approval required!",
-				Value: req.Message + "\n" + fmt.Sprintf("To vote for change type '%s approve %s' to reject it: '%s reject %s'.", b.name, req.Identifier, b.name, req.Identifier),
+				Title: "vote received!",
+				Value: "Waiting for remaining votes.",
 				Short: false,
 			},
 			{
 				Title: "Votes",
-				Value: fmt.Sprintf("%d/%d", req.VotesReceived, req.VotesRequired),
+				Value: fmt.Sprintf("%d/%d", approval.VotesReceived, approval.VotesRequired),
 				Short: true,
 			},
 			{
 				Title: "Delta",
-				Value: req.Delta(),
+				Value: approval.Delta(),
 				Short: true,
 			},
 			{
 				Title: "Identifier",
-				Value: req.Identifier,
-				Short: true,
-			},
-			{
-				Title: "Provider",
-				Value: req.Provider.String(),
+				Value: approval.Identifier,
 				Short: true,
 			},
-		})
-}
-
-func (b *Bot) ReplyToApproval(approval *types.Approval) error {
-	switch approval.Status() {
-	case types.ApprovalStatusPending:
+		}
+		// rejections that didn't veto the update yet
+		if len(approval.Rejections) > 0 {
+			fields = append(fields, slack.AttachmentField{
+				Title: "Rejections",
+				Value: fmt.Sprintf("%s, update is vetoed by %s", approval.Rejections, approval.Veto),
+				Short: false,
+			})
+		}
 		b.postMessage(
 			"Vote received",
 			"All approvals received, thanks for voting!",
 			types.LevelInfo.Color(),
-			[]slack.AttachmentField{
-				{
-					Title: "vote received!",
-					Value: "Waiting for remaining votes.",
-					Short: false,
-				},
-				{
-					Title: "Votes",
-					Value: fmt.Sprintf("%d/%d", approval.VotesReceived, approval.VotesRequired),
-					Short: true,
-				},
-				{
-					Title: "Delta",
-					Value: approval.Delta(),
-					Short: true,
-				},
-				{
-					Title: "Identifier",
-					Value: approval.Identifier,
-					Short: true,
-				},
-			})
+			fields)
 	case types.ApprovalStatusRejected:
 		b.postMessage(
 			"Change rejected",
@@ -82,6 +121,11 @@ func (b *Bot) ReplyToApproval(approval *types.Approval) error {
 					Value: "Change was rejected.",
 					Short: false,
 				},
+				{
+					Title: "Rejected by",
+					Value: approval.Rejections.String(),
+					Short: false,
+				},
 				{
 					Title: "Status",
 					Value: approval.Status().String(),
@@ -0,0 +1,235 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/slack-go/slack"
+
+	"github.com/quilla-hq/quilla/bot"
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	approveActionID = "approve"
+	rejectActionID  = "reject"
+
+	approvalActionsBlockID = "approval_actions"
+)
+
+// approvalMessage - posted approval request, updated in place once votes come in
+type approvalMessage struct {
+	channel string
+	ts      string
+}
+
+// InteractionHandler - handles Slack interactivity payloads, approval buttons
+// are only posted when signing secret is set
+func (b *Bot) InteractionHandler() http.Handler {
+	if b.signingSecret == "" {
+		return nil
+	}
+	return http.HandlerFunc(b.interactionHandler)
+}
+
+func (b *Bot) interactionHandler(resp http.ResponseWriter, req *http.Request) {
+	verifier, err := slack.NewSecretsVerifier(req.Header, b.signingSecret)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("bot.slack.interactionHandler: invalid request signature headers")
+		resp.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.TeeReader(req.Body, &verifier))
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := verifier.Ensure(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("bot.slack.interactionHandler: request signature verification failed")
+		resp.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var callback slack.InteractionCallback
+	err = json.Unmarshal([]byte(form.Get("payload")), &callback)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("bot.slack.interactionHandler: failed to decode interaction payload")
+		resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if callback.Type != slack.InteractionTypeBlockActions {
+		resp.WriteHeader(http.StatusOK)
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		var response *bot.ApprovalResponse
+		switch action.ActionID {
+		case approveActionID:
+			response = &bot.ApprovalResponse{Status: types.ApprovalStatusApproved, Text: bot.ApprovalResponseKeyword + " " + action.Value}
+		case rejectActionID:
+			response = &bot.ApprovalResponse{Status: types.ApprovalStatusRejected, Text: bot.RejectResponseKeyword + " " + action.Value}
+		default:
+			continue
+		}
+		response.User = callback.User.ID
+		response.Channel = callback.Channel.ID
+
+		// message is known even if the bot was restarted after posting it
+		b.trackApprovalMessage(action.Value, callback.Container.ChannelID, callback.Container.MessageTs)
+
+		// Slack expects response within 3 seconds, votes are processed asynchronously
+		go func(response *bot.ApprovalResponse) {
+			select {
+			case b.approvalsRespCh <- response:
+			case <-b.ctx.Done():
+			}
+		}(response)
+	}
+
+	resp.WriteHeader(http.StatusOK)
+}
+
+func (b *Bot) trackApprovalMessage(identifier, channel, ts string) {
+	if channel == "" || ts == "" {
+		return
+	}
+	b.messagesMu.Lock()
+	b.messages[identifier] = approvalMessage{channel: channel, ts: ts}
+	b.messagesMu.Unlock()
+}
+
+func (b *Bot) getApprovalMessage(identifier string) (approvalMessage, bool) {
+	b.messagesMu.Lock()
+	defer b.messagesMu.Unlock()
+	msg, ok := b.messages[identifier]
+	return msg, ok
+}
+
+func (b *Bot) forgetApprovalMessage(identifier string) {
+	b.messagesMu.Lock()
+	delete(b.messages, identifier)
+	b.messagesMu.Unlock()
+}
+
+// postApprovalMessage - posts approval request with approve/reject buttons
+func (b *Bot) postApprovalMessage(approval *types.Approval) error {
+	channel, ts, err := b.slackClient.PostMessage(b.approvalsChannel,
+		slack.MsgOptionUsername(b.name),
+		slack.MsgOptionText(approvalSummary(approval), false),
+		slack.MsgOptionBlocks(approvalBlocks(approval)...),
+	)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":             err,
+			"approvals_channel": b.approvalsChannel,
+		}).Error("bot.slack.postApprovalMessage: failed to send message")
+		return err
+	}
+	b.trackApprovalMessage(approval.Identifier, channel, ts)
+	return nil
+}
+
+// updateApprovalMessage - updates approval request in place with current votes,
+// buttons are removed once approval is approved or rejected
+func (b *Bot) updateApprovalMessage(approval *types.Approval, msg approvalMessage) error {
+	_, _, _, err := b.slackClient.UpdateMessage(msg.channel, msg.ts,
+		slack.MsgOptionText(approvalSummary(approval), false),
+		slack.MsgOptionBlocks(approvalBlocks(approval)...),
+	)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": approval.Identifier,
+		}).Error("bot.slack.updateApprovalMessage: failed to update message")
+		return err
+	}
+	if approval.Status() != types.ApprovalStatusPending {
+		b.forgetApprovalMessage(approval.Identifier)
+	}
+	return nil
+}
+
+// approvalSummary - notification text of the approval message
+func approvalSummary(approval *types.Approval) string {
+	switch approval.Status() {
+	case types.ApprovalStatusApproved:
+		return fmt.Sprintf("Update %s approved", approval.Identifier)
+	case types.ApprovalStatusRejected:
+		return fmt.Sprintf("Update %s rejected", approval.Identifier)
+	}
+	return fmt.Sprintf("Approval required for %s", approval.Identifier)
+}
+
+func approvalBlocks(approval *types.Approval) []slack.Block {
+	var title string
+	switch approval.Status() {
+	case types.ApprovalStatusApproved:
+		title = ":white_check_mark: *Update approved*"
+	case types.ApprovalStatusRejected:
+		title = ":no_entry: *Change rejected*"
+	default:
+		title = ":hourglass: *Approval required*"
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, title+"\n"+approval.Message, false, false), nil, nil),
+		slack.NewSectionBlock(nil, []*slack.TextBlockObject{
+			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Votes*\n%d/%d", approval.VotesReceived, approval.VotesRequired), false, false),
+			slack.NewTextBlockObject(slack.MarkdownType, "*Delta*\n"+approval.Delta(), false, false),
+			slack.NewTextBlockObject(slack.MarkdownType, "*Identifier*\n"+approval.Identifier, false, false),
+			slack.NewTextBlockObject(slack.MarkdownType, "*Provider*\n"+approval.Provider.String(), false, false),
+		}, nil),
+	}
+
+	// update group is approved as a single request
+	if len(approval.Members) > 0 {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, "*Members*\n"+strings.Join(approval.Members, "\n"), false, false), nil, nil))
+	}
+	if !approval.Plan.Empty() {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, "*Planned changes*\n```"+approval.Plan.Diff()+"```", false, false), nil, nil))
+	}
+
+	if len(approval.Votes) > 0 {
+		blocks = append(blocks, slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.PlainTextType, "Votes from "+approval.Votes.String(), false, false)))
+	} else if voters := approval.GetVoters(); len(voters) > 0 {
+		blocks = append(blocks, slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, "Votes from "+strings.Join(voters, ", "), false, false)))
+	}
+	if len(approval.Rejections) > 0 {
+		blocks = append(blocks, slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.PlainTextType, "Rejected by "+approval.Rejections.String(), false, false)))
+	}
+
+	if approval.Status() == types.ApprovalStatusPending {
+		approve := slack.NewButtonBlockElement(approveActionID, approval.Identifier, slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false))
+		approve.Style = slack.StylePrimary
+		reject := slack.NewButtonBlockElement(rejectActionID, approval.Identifier, slack.NewTextBlockObject(slack.PlainTextType, "Reject", false, false))
+		reject.Style = slack.StyleDanger
+		blocks = append(blocks, slack.NewActionBlock(approvalActionsBlockID, approve, reject))
+	}
+
+	return blocks
+}
@@ -0,0 +1,253 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/quilla-hq/quilla/approvals"
+	b "github.com/quilla-hq/quilla/bot"
+	"github.com/quilla-hq/quilla/types"
+)
+
+const testSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+type slackAPICall struct {
+	method string
+	form   url.Values
+}
+
+// fakeSlackAPI - stands in for Slack Web API, records called methods
+type fakeSlackAPI struct {
+	mu    sync.Mutex
+	calls []slackAPICall
+}
+
+func (f *fakeSlackAPI) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	method := strings.TrimPrefix(req.URL.Path, "/")
+
+	f.mu.Lock()
+	f.calls = append(f.calls, slackAPICall{method: method, form: req.PostForm})
+	f.mu.Unlock()
+
+	resp.Header().Set("Content-Type", "application/json")
+	switch method {
+	case "chat.postMessage", "chat.update":
+		fmt.Fprint(resp, `{"ok": true, "channel": "C0APPROVALS", "ts": "1700000000.000100"}`)
+	default:
+		fmt.Fprint(resp, `{"ok": true}`)
+	}
+}
+
+func (f *fakeSlackAPI) called(method string) []slackAPICall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []slackAPICall
+	for _, c := range f.calls {
+		if c.method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func newInteractiveBot(t *testing.T, api *fakeSlackAPI) (*Bot, func()) {
+	srv := httptest.NewServer(api)
+	ctx, cancel := context.WithCancel(context.Background())
+	bot := &Bot{
+		name:             "quilla",
+		slackClient:      slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/")),
+		approvalsChannel: "approvals",
+		signingSecret:    testSigningSecret,
+		messages:         make(map[string]approvalMessage),
+		approvalsRespCh:  make(chan *b.ApprovalResponse),
+		ctx:              ctx,
+	}
+	return bot, func() {
+		cancel()
+		srv.Close()
+	}
+}
+
+func signedInteraction(t *testing.T, payloadFile string, timestamp time.Time) *http.Request {
+	payload, err := os.ReadFile(payloadFile)
+	if err != nil {
+		t.Fatalf("failed to read payload: %s", err)
+	}
+	body := url.Values{"payload": {string(payload)}}.Encode()
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(testSigningSecret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/bots/slack/interactions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+// processResponse - votes the way bot manager does once the response is received
+func processResponse(t *testing.T, bot *Bot, am approvals.Manager, users b.UserMapping) *types.Approval {
+	select {
+	case resp := <-bot.approvalsRespCh:
+		if resp.Status != types.ApprovalStatusApproved || resp.Channel != "C0APPROVALS" {
+			t.Fatalf("unexpected approval response: %+v", resp)
+		}
+		identifier := strings.TrimSpace(strings.TrimPrefix(resp.Text, b.ApprovalResponseKeyword))
+		approval, err := am.Approve(identifier, users.Voter(resp.User), "")
+		if err != nil {
+			t.Fatalf("failed to approve: %s", err)
+		}
+		err = bot.ReplyToApproval(approval)
+		if err != nil {
+			t.Fatalf("failed to reply to approval: %s", err)
+		}
+		return approval
+	case <-time.After(5 * time.Second):
+		t.Fatalf("approval response wasn't received")
+	}
+	return nil
+}
+
+func TestApprovalButtons(t *testing.T) {
+	api := &fakeSlackAPI{}
+	bot, teardown := newInteractiveBot(t, api)
+	defer teardown()
+
+	store, teardownStore := newTestingUtils()
+	defer teardownStore()
+	am := approvals.New(&approvals.Opts{Store: store})
+
+	approval := &types.Approval{
+		Provider:       types.ProviderTypeKubernetes,
+		Identifier:     "deployment/default/app:1.2.3",
+		Message:        "New image is available for resource default/app (1.2.2 -> 1.2.3).",
+		VotesRequired:  2,
+		CurrentVersion: "1.2.2",
+		NewVersion:     "1.2.3",
+		Deadline:       time.Now().Add(time.Hour),
+		Event:          &types.Event{Repository: types.Repository{Name: "quilla/app", Tag: "1.2.3"}},
+	}
+	err := am.Create(approval)
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	err = bot.RequestApproval(approval)
+	if err != nil {
+		t.Fatalf("failed to request approval: %s", err)
+	}
+	posted := api.called("chat.postMessage")
+	if len(posted) != 1 {
+		t.Fatalf("expected approval request to be posted, got %d messages", len(posted))
+	}
+	if blocks := posted[0].form.Get("blocks"); !strings.Contains(blocks, `"action_id":"approve"`) || !strings.Contains(blocks, `"action_id":"reject"`) {
+		t.Errorf("expected approve and reject buttons, got: %s", blocks)
+	}
+
+	rec := httptest.NewRecorder()
+	bot.InteractionHandler().ServeHTTP(rec, signedInteraction(t, "testdata/block_actions.json", time.Now()))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", rec.Code)
+	}
+
+	users := b.UserMapping{"U0123ABC": {Name: "alice", Groups: []string{"sre"}}}
+	approval = processResponse(t, bot, am, users)
+	if approval.VotesReceived != 1 || approval.GetVoters()[0] != "alice" {
+		t.Errorf("expected mapped user vote, got votes %d, voters %v", approval.VotesReceived, approval.GetVoters())
+	}
+
+	updated := api.called("chat.update")
+	if len(updated) != 1 {
+		t.Fatalf("expected approval message to be updated in place, got %d updates", len(updated))
+	}
+	if updated[0].form.Get("channel") != "C0APPROVALS" || updated[0].form.Get("ts") != "1700000000.000100" {
+		t.Errorf("unexpected message updated: %v", updated[0].form)
+	}
+	blocks := updated[0].form.Get("blocks")
+	if !strings.Contains(blocks, "1/2") || !strings.Contains(blocks, "Votes from alice") {
+		t.Errorf("expected vote count and voter in updated message, got: %s", blocks)
+	}
+	if !strings.Contains(blocks, `"action_id":"approve"`) {
+		t.Errorf("pending approval should keep the buttons, got: %s", blocks)
+	}
+	if len(api.called("chat.postMessage")) != 1 {
+		t.Errorf("vote shouldn't be replied to with a new message")
+	}
+
+	// second approver completes the approval, buttons are removed
+	approval, err = am.Approve(approval.Identifier, &types.Voter{Name: "bob"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+	err = bot.ReplyToApproval(approval)
+	if err != nil {
+		t.Fatalf("failed to reply to approval: %s", err)
+	}
+	updated = api.called("chat.update")
+	if len(updated) != 2 {
+		t.Fatalf("expected approval message to be updated in place, got %d updates", len(updated))
+	}
+	blocks = updated[1].form.Get("blocks")
+	if !strings.Contains(blocks, "Update approved") || strings.Contains(blocks, `"action_id":"approve"`) {
+		t.Errorf("expected final state without buttons, got: %s", blocks)
+	}
+}
+
+func TestInteractionSignature(t *testing.T) {
+	api := &fakeSlackAPI{}
+	bot, teardown := newInteractiveBot(t, api)
+	defer teardown()
+
+	tamperedSignature := signedInteraction(t, "testdata/block_actions.json", time.Now())
+	tamperedSignature.Header.Set("X-Slack-Signature", "v0=0000")
+
+	noSignature := signedInteraction(t, "testdata/block_actions.json", time.Now())
+	noSignature.Header.Del("X-Slack-Signature")
+
+	tests := []struct {
+		name string
+		req  *http.Request
+	}{
+		{name: "tampered signature", req: tamperedSignature},
+		{name: "missing signature", req: noSignature},
+		{name: "replayed request", req: signedInteraction(t, "testdata/block_actions.json", time.Now().Add(-10*time.Minute))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			bot.InteractionHandler().ServeHTTP(rec, tt.req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("expected request to be rejected, got: %d", rec.Code)
+			}
+		})
+	}
+
+	select {
+	case resp := <-bot.approvalsRespCh:
+		t.Errorf("unverified interaction shouldn't vote, got: %+v", resp)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestInteractionHandlerDisabled(t *testing.T) {
+	bot := &Bot{}
+	if bot.InteractionHandler() != nil {
+		t.Errorf("interactions shouldn't be handled without signing secret")
+	}
+}
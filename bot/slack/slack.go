@@ -8,6 +8,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/slack-go/slack"
@@ -33,6 +34,12 @@ type Bot struct {
 
 	approvalsChannel string // slack approvals channel name
 
+	// signingSecret - verifies interactivity requests, approval requests
+	// are posted with buttons when it's set
+	signingSecret string
+	messagesMu    sync.Mutex
+	messages      map[string]approvalMessage // approval messages by approval identifier
+
 	ctx                context.Context
 	botMessagesChannel chan *bot.BotMessage
 	approvalsRespCh    chan *bot.ApprovalResponse
@@ -60,6 +67,9 @@ func (b *Bot) Configure(approvalsRespCh chan *bot.ApprovalResponse, botMessagesC
 			b.approvalsChannel = strings.TrimPrefix(channel, "#")
 		}
 
+		b.signingSecret = os.Getenv(constants.EnvSlackSigningSecret)
+		b.messages = make(map[string]approvalMessage)
+
 		b.slackClient = client
 		b.approvalsRespCh = approvalsRespCh
 		b.botMessagesChannel = botMessagesChannel
@@ -212,6 +222,7 @@ func (b *Bot) handleMessage(event *slack.MessageEvent) {
 	approval, ok := bot.IsApproval(event.User, eventText)
 	// only accepting approvals from approvals channel
 	if ok && b.isApprovalsChannel(event) {
+		approval.Channel = event.Channel
 		b.approvalsRespCh <- approval
 		return
 	} else if ok {
@@ -234,11 +245,21 @@ func (b *Bot) handleMessage(event *slack.MessageEvent) {
 func (b *Bot) Respond(text string, channel string) {
 
 	// if message is short, replying directly via slack RTM
-	if len(text) < 3000 {
+	if len(text) < 3000 && b.slackRTM != nil {
 		b.slackRTM.SendMessage(b.slackRTM.NewOutgoingMessage(formatAsSnippet(text), channel))
 		return
 	}
 
+	if len(text) < 3000 {
+		_, _, err := b.slackClient.PostMessage(channel, slack.MsgOptionText(formatAsSnippet(text), false))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Error("Respond: failed to send message")
+		}
+		return
+	}
+
 	// longer messages are getting uploaded as files
 
 	f := slack.FileUploadParameters{
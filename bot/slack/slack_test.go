@@ -38,7 +38,7 @@ func New(name, token, channel string,
 
 	slack := &Bot{}
 	b.RegisterBot(name, slack)
-	b.Run(k8sImplementer, approvalsManager)
+	b.Run(k8sImplementer, approvalsManager, nil)
 	return slack
 }
 
@@ -0,0 +1,73 @@
+package teams
+
+import (
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestApproval - sends approval card with approve/reject actions to the approvals conversation
+func (b *Bot) RequestApproval(req *types.Approval) error {
+	if b.approvalsConversation == "" {
+		return nil
+	}
+
+	id, err := b.sendActivity(b.approvalsConversation, approvalCardActivity(req))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":        err,
+			"conversation": b.approvalsConversation,
+		}).Error("bot.teams.RequestApproval: failed to send approval card")
+		return err
+	}
+	b.trackCard(req.Identifier, b.approvalsConversation, id)
+	return nil
+}
+
+// ReplyToApproval - updates approval card in place with current votes, new card
+// is sent if the original one isn't known, ie: bot was restarted
+func (b *Bot) ReplyToApproval(approval *types.Approval) error {
+	card, ok := b.getCard(approval.Identifier)
+	if !ok {
+		if b.approvalsConversation == "" {
+			return nil
+		}
+		_, err := b.sendActivity(b.approvalsConversation, approvalCardActivity(approval))
+		return err
+	}
+
+	err := b.updateActivity(card.conversation, card.activityID, approvalCardActivity(approval))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": approval.Identifier,
+		}).Error("bot.teams.ReplyToApproval: failed to update approval card")
+		return err
+	}
+	if approval.Status() != types.ApprovalStatusPending {
+		b.forgetCard(approval.Identifier)
+	}
+	return nil
+}
+
+func (b *Bot) trackCard(identifier, conversation, activityID string) {
+	if conversation == "" || activityID == "" {
+		return
+	}
+	b.cardsMu.Lock()
+	b.cards[identifier] = approvalCard{conversation: conversation, activityID: activityID}
+	b.cardsMu.Unlock()
+}
+
+func (b *Bot) getCard(identifier string) (approvalCard, bool) {
+	b.cardsMu.Lock()
+	defer b.cardsMu.Unlock()
+	card, ok := b.cards[identifier]
+	return card, ok
+}
+
+func (b *Bot) forgetCard(identifier string) {
+	b.cardsMu.Lock()
+	delete(b.cards, identifier)
+	b.cardsMu.Unlock()
+}
@@ -0,0 +1,151 @@
+package teams
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// botFrameworkIssuer - issuer of tokens Bot Framework signs activities with
+const botFrameworkIssuer = "https://api.botframework.com"
+
+// keysRefreshInterval - Bot Framework rotates signing keys, they are
+// fetched again at least this often
+var keysRefreshInterval = 24 * time.Hour
+
+var errUnauthorized = errors.New("unauthorized")
+
+// tokenValidator - validates tokens of activities sent by Bot Framework
+type tokenValidator struct {
+	appID       string
+	metadataURL string
+	client      *http.Client
+
+	mu        sync.Mutex
+	keys      jwk.Set
+	fetchedAt time.Time
+}
+
+func newTokenValidator(appID, metadataURL string) *tokenValidator {
+	return &tokenValidator{
+		appID:       appID,
+		metadataURL: metadataURL,
+		client:      &http.Client{Timeout: timeout},
+	}
+}
+
+// validate - checks that activity was sent by Bot Framework to this bot,
+// token has to be issued for the service URL of the activity
+func (v *tokenValidator) validate(ctx context.Context, authorization, serviceURL string) error {
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return fmt.Errorf("%w: bearer token missing", errUnauthorized)
+	}
+
+	token, err := jwt.Parse(strings.TrimPrefix(authorization, "Bearer "), func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, fmt.Errorf("kid header not found")
+		}
+		return v.publicKey(ctx, kid)
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s", errUnauthorized, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return fmt.Errorf("%w: invalid token", errUnauthorized)
+	}
+	if !claims.VerifyIssuer(botFrameworkIssuer, true) {
+		return fmt.Errorf("%w: unexpected issuer %v", errUnauthorized, claims["iss"])
+	}
+	if !claims.VerifyAudience(v.appID, true) {
+		return fmt.Errorf("%w: token wasn't issued for the bot", errUnauthorized)
+	}
+	claimed, _ := claims["serviceurl"].(string)
+	if claimed == "" {
+		return fmt.Errorf("%w: serviceurl claim missing", errUnauthorized)
+	}
+	if claimed != serviceURL {
+		return fmt.Errorf("%w: token wasn't issued for service URL %s", errUnauthorized, serviceURL)
+	}
+	return nil
+}
+
+// publicKey - signing key by key ID, keys are fetched again when the key
+// isn't known as it may have been rotated
+func (v *tokenValidator) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.keys == nil || time.Since(v.fetchedAt) > keysRefreshInterval {
+		err := v.fetchKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, ok := v.keys.LookupKeyID(kid)
+	if !ok && time.Since(v.fetchedAt) > time.Minute {
+		err := v.fetchKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		key, ok = v.keys.LookupKeyID(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("key %s not found", kid)
+	}
+
+	publicKey := &rsa.PublicKey{}
+	err := key.Raw(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key: %s", err)
+	}
+	return publicKey, nil
+}
+
+type openIDMetadata struct {
+	JWKSURI string `json:"jwks_uri"`
+}
+
+func (v *tokenValidator) fetchKeys(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.metadataURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch OpenID metadata: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch OpenID metadata, status code: %d", resp.StatusCode)
+	}
+
+	var metadata openIDMetadata
+	err = json.NewDecoder(resp.Body).Decode(&metadata)
+	if err != nil {
+		return fmt.Errorf("failed to decode OpenID metadata: %s", err)
+	}
+
+	keys, err := jwk.Fetch(ctx, metadata.JWKSURI, jwk.WithHTTPClient(v.client))
+	if err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %s", err)
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,130 @@
+package teams
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/quilla-hq/quilla/types"
+)
+
+const (
+	approveAction = "approve"
+	rejectAction  = "reject"
+)
+
+// adaptiveCard - Adaptive Card, see https://adaptivecards.io/explorer/
+type adaptiveCard struct {
+	Schema  string        `json:"$schema"`
+	Type    string        `json:"type"`
+	Version string        `json:"version"`
+	Body    []cardElement `json:"body"`
+	Actions []cardAction  `json:"actions,omitempty"`
+}
+
+type cardElement struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Weight   string `json:"weight,omitempty"`
+	Size     string `json:"size,omitempty"`
+	Color    string `json:"color,omitempty"`
+	FontType string `json:"fontType,omitempty"`
+	Wrap     bool   `json:"wrap,omitempty"`
+	Facts    []fact `json:"facts,omitempty"`
+
+	// Input.Text fields, input values are submitted together with action data
+	ID          string `json:"id,omitempty"`
+	Placeholder string `json:"placeholder,omitempty"`
+	IsMultiline bool   `json:"isMultiline,omitempty"`
+	MaxLength   int    `json:"maxLength,omitempty"`
+}
+
+type fact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type cardAction struct {
+	Type  string         `json:"type"`
+	Title string         `json:"title"`
+	Style string         `json:"style,omitempty"`
+	Data  cardActionData `json:"data"`
+}
+
+// cardActionData - submitted as activity value when approver clicks the button
+type cardActionData struct {
+	Action     string `json:"action"`
+	Identifier string `json:"identifier"`
+	// Comment - value of the comment input
+	Comment string `json:"comment,omitempty"`
+}
+
+// maxCommentLength - length limit of the comment input
+const maxCommentLength = 500
+
+// approvalCard - sent approval card, updated in place once votes come in
+type approvalCard struct {
+	conversation string
+	activityID   string
+}
+
+func approvalCardActivity(approval *types.Approval) *activity {
+	return &activity{
+		Type:        activityTypeMessage,
+		Attachments: []attachment{{ContentType: adaptiveCardContentType, Content: newApprovalCard(approval)}},
+	}
+}
+
+func newApprovalCard(approval *types.Approval) *adaptiveCard {
+	title, color := "Approval required", "Accent"
+	switch approval.Status() {
+	case types.ApprovalStatusApproved:
+		title, color = "Update approved", "Good"
+	case types.ApprovalStatusRejected:
+		title, color = "Change rejected", "Attention"
+	}
+
+	card := &adaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body: []cardElement{
+			{Type: "TextBlock", Text: title, Weight: "Bolder", Size: "Medium", Color: color},
+			{Type: "TextBlock", Text: approval.Message, Wrap: true},
+			{Type: "FactSet", Facts: []fact{
+				{Title: "Votes", Value: fmt.Sprintf("%d/%d", approval.VotesReceived, approval.VotesRequired)},
+				{Title: "Delta", Value: approval.Delta()},
+				{Title: "Identifier", Value: approval.Identifier},
+				{Title: "Provider", Value: approval.Provider.String()},
+			}},
+		},
+	}
+
+	// update group is approved as a single request
+	if len(approval.Members) > 0 {
+		card.Body = append(card.Body,
+			cardElement{Type: "TextBlock", Text: "Members", Weight: "Bolder"},
+			cardElement{Type: "TextBlock", Text: strings.Join(approval.Members, "\n\n"), Wrap: true})
+	}
+	if !approval.Plan.Empty() {
+		card.Body = append(card.Body,
+			cardElement{Type: "TextBlock", Text: "Planned changes", Weight: "Bolder"},
+			cardElement{Type: "TextBlock", Text: strings.Replace(approval.Plan.Diff(), "\n", "\n\n", -1), FontType: "Monospace", Wrap: true})
+	}
+	if len(approval.Votes) > 0 {
+		card.Body = append(card.Body, cardElement{Type: "TextBlock", Text: "Votes from " + approval.Votes.String(), Size: "Small", Wrap: true})
+	} else if voters := approval.GetVoters(); len(voters) > 0 {
+		card.Body = append(card.Body, cardElement{Type: "TextBlock", Text: "Votes from " + strings.Join(voters, ", "), Size: "Small", Wrap: true})
+	}
+	if len(approval.Rejections) > 0 {
+		card.Body = append(card.Body, cardElement{Type: "TextBlock", Text: "Rejected by " + approval.Rejections.String(), Size: "Small", Color: "Attention", Wrap: true})
+	}
+
+	if approval.Status() == types.ApprovalStatusPending {
+		card.Body = append(card.Body, cardElement{Type: "Input.Text", ID: "comment", Placeholder: "Comment (optional)", IsMultiline: true, MaxLength: maxCommentLength})
+		card.Actions = []cardAction{
+			{Type: "Action.Submit", Title: "Approve", Style: "positive", Data: cardActionData{Action: approveAction, Identifier: approval.Identifier}},
+			{Type: "Action.Submit", Title: "Reject", Style: "destructive", Data: cardActionData{Action: rejectAction, Identifier: approval.Identifier}},
+		}
+	}
+	return card
+}
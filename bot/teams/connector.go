@@ -0,0 +1,104 @@
+package teams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	activityTypeMessage = "message"
+
+	adaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+)
+
+// activity - Bot Framework activity, only fields used by the bot are decoded
+type activity struct {
+	Type         string               `json:"type"`
+	ID           string               `json:"id,omitempty"`
+	ServiceURL   string               `json:"serviceUrl,omitempty"`
+	ChannelID    string               `json:"channelId,omitempty"`
+	From         *channelAccount      `json:"from,omitempty"`
+	Conversation *conversationAccount `json:"conversation,omitempty"`
+	Recipient    *channelAccount      `json:"recipient,omitempty"`
+	Text         string               `json:"text,omitempty"`
+	TextFormat   string               `json:"textFormat,omitempty"`
+	ReplyToID    string               `json:"replyToId,omitempty"`
+	Value        json.RawMessage      `json:"value,omitempty"`
+	Attachments  []attachment         `json:"attachments,omitempty"`
+}
+
+type channelAccount struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	AADObjectID string `json:"aadObjectId,omitempty"`
+}
+
+type conversationAccount struct {
+	ID               string `json:"id"`
+	Name             string `json:"name,omitempty"`
+	ConversationType string `json:"conversationType,omitempty"`
+	IsGroup          bool   `json:"isGroup,omitempty"`
+}
+
+type attachment struct {
+	ContentType string      `json:"contentType"`
+	Content     interface{} `json:"content"`
+}
+
+type resourceResponse struct {
+	ID string `json:"id"`
+}
+
+// sendActivity - sends activity to the conversation, returns ID of the sent activity
+func (b *Bot) sendActivity(conversationID string, a *activity) (string, error) {
+	return b.doActivity(http.MethodPost, b.activitiesURL(conversationID, ""), a)
+}
+
+// updateActivity - replaces previously sent activity, ie: approval card
+func (b *Bot) updateActivity(conversationID, activityID string, a *activity) error {
+	a.ID = activityID
+	_, err := b.doActivity(http.MethodPut, b.activitiesURL(conversationID, activityID), a)
+	return err
+}
+
+func (b *Bot) activitiesURL(conversationID, activityID string) string {
+	endpoint := strings.TrimSuffix(b.getServiceURL(), "/") + "/v3/conversations/" + url.PathEscape(conversationID) + "/activities"
+	if activityID != "" {
+		endpoint += "/" + url.PathEscape(activityID)
+	}
+	return endpoint
+}
+
+func (b *Bot) doActivity(method, endpoint string, a *activity) (string, error) {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(b.ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("connector returned status code %d: %s", resp.StatusCode, msg)
+	}
+
+	var result resourceResponse
+	// some channels don't return activity ID
+	json.NewDecoder(resp.Body).Decode(&result)
+	return result.ID, nil
+}
@@ -0,0 +1,143 @@
+package teams
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/quilla-hq/quilla/bot"
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxActivitySize - activities larger than that are rejected
+const maxActivitySize = 1 << 20
+
+var mentionRe = regexp.MustCompile(`<at>[^<]*</at>`)
+
+// InteractionHandler - Bot Framework messaging endpoint
+func (b *Bot) InteractionHandler() http.Handler {
+	return http.HandlerFunc(b.activityHandler)
+}
+
+func (b *Bot) activityHandler(resp http.ResponseWriter, req *http.Request) {
+	var a activity
+	err := json.NewDecoder(io.LimitReader(req.Body, maxActivitySize)).Decode(&a)
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = b.validator.validate(req.Context(), req.Header.Get("Authorization"), a.ServiceURL)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err,
+			"service_url": a.ServiceURL,
+		}).Warn("bot.teams.activityHandler: activity token validation failed")
+		resp.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	b.setServiceURL(a.ServiceURL)
+
+	if a.Type == activityTypeMessage && a.Conversation != nil && a.From != nil {
+		b.handleMessage(&a)
+	}
+
+	resp.WriteHeader(http.StatusOK)
+}
+
+func (b *Bot) handleMessage(a *activity) {
+	user := a.From.ID
+	// Azure AD object ID is stable across Teams conversations
+	if a.From.AADObjectID != "" {
+		user = a.From.AADObjectID
+	}
+
+	if len(a.Value) > 0 {
+		var data cardActionData
+		err := json.Unmarshal(a.Value, &data)
+		if err == nil && data.Identifier != "" {
+			b.handleCardAction(a, user, data)
+			return
+		}
+	}
+
+	text := strings.ToLower(strings.TrimSpace(mentionRe.ReplaceAllString(a.Text, "")))
+	if text == "" {
+		return
+	}
+
+	approval, ok := bot.IsApproval(user, text)
+	// only accepting approvals from approvals conversation
+	if ok && b.isApprovalsConversation(a.Conversation.ID) {
+		approval.Channel = a.Conversation.ID
+		b.sendApprovalResponse(approval)
+		return
+	} else if ok {
+		log.WithFields(log.Fields{
+			"received_on":    a.Conversation.ID,
+			"approvals_chan": b.approvalsConversation,
+		}).Warnf("message was received not in approvals conversation: %s", a.Conversation.ID)
+		go b.Respond(fmt.Sprintf("please use approvals channel '%s'", b.approvalsConversation), a.Conversation.ID)
+		return
+	}
+
+	message := &bot.BotMessage{
+		Message: text,
+		User:    user,
+		Channel: a.Conversation.ID,
+		Name:    "teams",
+	}
+	go func() {
+		select {
+		case b.botMessagesChannel <- message:
+		case <-b.ctx.Done():
+		}
+	}()
+}
+
+// handleCardAction - vote submitted from the approval card
+func (b *Bot) handleCardAction(a *activity, user string, data cardActionData) {
+	response := &bot.ApprovalResponse{User: user, Channel: a.Conversation.ID}
+	switch data.Action {
+	case approveAction:
+		response.Status = types.ApprovalStatusApproved
+		response.Text = bot.ApprovalResponseKeyword + " " + data.Identifier
+	case rejectAction:
+		response.Status = types.ApprovalStatusRejected
+		response.Text = bot.RejectResponseKeyword + " " + data.Identifier
+	default:
+		return
+	}
+	if comment := strings.TrimSpace(data.Comment); comment != "" {
+		response.Text += bot.CommentSeparator + comment
+	}
+
+	// card is known even if the bot was restarted after sending it
+	b.trackCard(data.Identifier, a.Conversation.ID, a.ReplyToID)
+	b.sendApprovalResponse(response)
+}
+
+// sendApprovalResponse - Bot Framework expects response within 15 seconds,
+// votes are processed asynchronously
+func (b *Bot) sendApprovalResponse(response *bot.ApprovalResponse) {
+	go func() {
+		select {
+		case b.approvalsRespCh <- response:
+		case <-b.ctx.Done():
+		}
+	}()
+}
+
+// isApprovalsConversation - replies in channel threads carry message ID
+// in the conversation ID, ie: 19:abc@thread.tacv2;messageid=1700000000000
+func (b *Bot) isApprovalsConversation(conversationID string) bool {
+	if b.approvalsConversation == "" {
+		return false
+	}
+	return strings.SplitN(conversationID, ";", 2)[0] == strings.SplitN(b.approvalsConversation, ";", 2)[0]
+}
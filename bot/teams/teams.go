@@ -0,0 +1,154 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/quilla-hq/quilla/bot"
+	"github.com/quilla-hq/quilla/constants"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	timeout = 10 * time.Second
+
+	defaultServiceURL  = "https://smba.trafficmanager.net/teams/"
+	defaultTokenURL    = "https://login.microsoftonline.com/botframework.com/oauth2/v2.0/token"
+	defaultMetadataURL = "https://login.botframework.com/v1/.well-known/openidconfiguration"
+
+	botFrameworkScope = "https://api.botframework.com/.default"
+)
+
+// Bot - Microsoft Teams bot, receives activities from Bot Framework
+// and replies through Bot Framework connector
+type Bot struct {
+	name string // bot name
+
+	appID       string
+	appPassword string
+
+	// tokenURL - issues connector tokens to the bot
+	tokenURL string
+	// metadataURL - OpenID metadata of Bot Framework, keys incoming
+	// activities are signed with are listed there
+	metadataURL string
+
+	serviceURLMu sync.RWMutex
+	serviceURL   string
+
+	approvalsConversation string // approvals conversation (channel) ID
+
+	client    *http.Client // authenticated connector client
+	validator *tokenValidator
+
+	cardsMu sync.Mutex
+	cards   map[string]approvalCard // approval cards by approval identifier
+
+	ctx                context.Context
+	botMessagesChannel chan *bot.BotMessage
+	approvalsRespCh    chan *bot.ApprovalResponse
+}
+
+func init() {
+	bot.RegisterBot("teams", &Bot{})
+}
+
+func (b *Bot) Configure(approvalsRespCh chan *bot.ApprovalResponse, botMessagesChannel chan *bot.BotMessage) bool {
+	if os.Getenv(constants.EnvTeamsAppID) == "" || os.Getenv(constants.EnvTeamsAppPassword) == "" {
+		log.Info("bot.teams.Configure(): Teams approval bot is not configured")
+		return false
+	}
+
+	b.name = "quilla"
+	if botName := os.Getenv(constants.EnvTeamsBotName); botName != "" {
+		b.name = botName
+	}
+
+	b.appID = os.Getenv(constants.EnvTeamsAppID)
+	b.appPassword = os.Getenv(constants.EnvTeamsAppPassword)
+	b.approvalsConversation = os.Getenv(constants.EnvTeamsApprovalsConversation)
+
+	b.serviceURL = defaultServiceURL
+	if serviceURL := os.Getenv(constants.EnvTeamsServiceURL); serviceURL != "" {
+		b.serviceURL = serviceURL
+	}
+	b.tokenURL = defaultTokenURL
+	b.metadataURL = defaultMetadataURL
+
+	b.approvalsRespCh = approvalsRespCh
+	b.botMessagesChannel = botMessagesChannel
+
+	if b.approvalsConversation == "" {
+		log.Warnf("bot.teams.Configure(): %s is not set, approval requests won't be sent", constants.EnvTeamsApprovalsConversation)
+	}
+
+	return true
+}
+
+// Start - start bot, Teams sends activities to the interaction handler
+func (b *Bot) Start(ctx context.Context) error {
+	b.ctx = ctx
+
+	credentials := clientcredentials.Config{
+		ClientID:     b.appID,
+		ClientSecret: b.appPassword,
+		TokenURL:     b.tokenURL,
+		Scopes:       []string{botFrameworkScope},
+	}
+	b.client = credentials.Client(ctx)
+	b.client.Timeout = timeout
+
+	b.validator = newTokenValidator(b.appID, b.metadataURL)
+	b.cards = make(map[string]approvalCard)
+
+	log.WithFields(log.Fields{
+		"name":        b.name,
+		"service_url": b.getServiceURL(),
+	}).Info("bot.teams: bot started")
+
+	return nil
+}
+
+// Respond - sends text to the conversation
+func (b *Bot) Respond(text string, channel string) {
+	_, err := b.sendActivity(channel, &activity{
+		Type:       activityTypeMessage,
+		Text:       formatAsSnippet(text),
+		TextFormat: "markdown",
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":        err,
+			"conversation": channel,
+		}).Error("bot.teams.Respond: failed to send message")
+	}
+}
+
+func (b *Bot) getServiceURL() string {
+	b.serviceURLMu.RLock()
+	defer b.serviceURLMu.RUnlock()
+	return b.serviceURL
+}
+
+// setServiceURL - Bot Framework may change service URL, replies are sent
+// to the one of the last received activity
+func (b *Bot) setServiceURL(serviceURL string) {
+	if serviceURL == "" {
+		return
+	}
+	b.serviceURLMu.Lock()
+	b.serviceURL = serviceURL
+	b.serviceURLMu.Unlock()
+}
+
+func formatAsSnippet(response string) string {
+	return fmt.Sprintf("```\n%s\n```", strings.TrimRight(response, "\n"))
+}
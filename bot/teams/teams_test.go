@@ -0,0 +1,381 @@
+package teams
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/lestrrat-go/jwx/jwk"
+
+	"github.com/quilla-hq/quilla/approvals"
+	b "github.com/quilla-hq/quilla/bot"
+	"github.com/quilla-hq/quilla/pkg/store/sql"
+	"github.com/quilla-hq/quilla/types"
+)
+
+const (
+	testAppID        = "quilla-app-id"
+	testConversation = "19:approvals@thread.tacv2"
+	recordedService  = "https://smba.trafficmanager.net/emea/"
+)
+
+type connectorCall struct {
+	method        string
+	path          string
+	authorization string
+	activity      activity
+}
+
+// emulator - stands in for Bot Framework: issues connector tokens, publishes
+// signing keys and records activities sent by the bot
+type emulator struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	calls []connectorCall
+}
+
+func newEmulator(t *testing.T) *emulator {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	e := &emulator{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openid", func(resp http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(resp, `{"issuer": %q, "jwks_uri": %q}`, botFrameworkIssuer, e.URL+"/keys")
+	})
+	mux.HandleFunc("/keys", func(resp http.ResponseWriter, req *http.Request) {
+		publicKey, _ := jwk.New(&e.key.PublicKey)
+		publicKey.Set(jwk.KeyIDKey, "emulator-key")
+		publicKey.Set(jwk.AlgorithmKey, "RS256")
+		set := jwk.NewSet()
+		set.Add(publicKey)
+		json.NewEncoder(resp).Encode(set)
+	})
+	mux.HandleFunc("/token", func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(resp, `{"access_token": "connector-token", "token_type": "Bearer", "expires_in": 3600}`)
+	})
+	mux.HandleFunc("/v3/conversations/", func(resp http.ResponseWriter, req *http.Request) {
+		var a activity
+		json.NewDecoder(req.Body).Decode(&a)
+		e.mu.Lock()
+		e.calls = append(e.calls, connectorCall{
+			method:        req.Method,
+			path:          req.URL.EscapedPath(),
+			authorization: req.Header.Get("Authorization"),
+			activity:      a,
+		})
+		id := fmt.Sprintf("17000000000%02d", len(e.calls))
+		e.mu.Unlock()
+		fmt.Fprintf(resp, `{"id": %q}`, id)
+	})
+	e.Server = httptest.NewServer(mux)
+	return e
+}
+
+func (e *emulator) connectorCalls(method string) []connectorCall {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var calls []connectorCall
+	for _, c := range e.calls {
+		if c.method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// token - Bot Framework token, claims override the valid ones, nil removes the claim
+func (e *emulator) token(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	valid := jwt.MapClaims{
+		"iss":        botFrameworkIssuer,
+		"aud":        testAppID,
+		"serviceurl": e.URL + "/",
+		"nbf":        time.Now().Add(-time.Minute).Unix(),
+		"exp":        time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(valid, k)
+			continue
+		}
+		valid[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, valid)
+	token.Header["kid"] = "emulator-key"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %s", err)
+	}
+	return signed
+}
+
+// activityRequest - recorded activity as if it was sent by the emulator
+func (e *emulator) activityRequest(t *testing.T, payloadFile, token string) *http.Request {
+	payload, err := os.ReadFile(payloadFile)
+	if err != nil {
+		t.Fatalf("failed to read payload: %s", err)
+	}
+	body := strings.Replace(string(payload), recordedService, e.URL+"/", -1)
+	req := httptest.NewRequest(http.MethodPost, "/v1/bots/teams/interactions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func newTestBot(t *testing.T, e *emulator) (*Bot, func()) {
+	bot := &Bot{
+		name:                  "quilla",
+		appID:                 testAppID,
+		appPassword:           "secret",
+		tokenURL:              e.URL + "/token",
+		metadataURL:           e.URL + "/openid",
+		serviceURL:            e.URL + "/",
+		approvalsConversation: testConversation,
+		approvalsRespCh:       make(chan *b.ApprovalResponse),
+		botMessagesChannel:    make(chan *b.BotMessage),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	err := bot.Start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start bot: %s", err)
+	}
+	return bot, func() {
+		cancel()
+		e.Close()
+	}
+}
+
+func newTestingUtils() (*sql.SQLStore, func()) {
+	dir, err := ioutil.TempDir("", "teamsbottest")
+	if err != nil {
+		panic(err)
+	}
+	store, err := sql.New(sql.Opts{DatabaseType: "sqlite3", URI: filepath.Join(dir, "gorm.db")})
+	if err != nil {
+		panic(err)
+	}
+	return store, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func cardContent(t *testing.T, a activity) string {
+	if len(a.Attachments) != 1 || a.Attachments[0].ContentType != adaptiveCardContentType {
+		t.Fatalf("expected adaptive card, got: %+v", a.Attachments)
+	}
+	content, err := json.Marshal(a.Attachments[0].Content)
+	if err != nil {
+		t.Fatalf("failed to encode card: %s", err)
+	}
+	return string(content)
+}
+
+func TestApprovalCard(t *testing.T) {
+	e := newEmulator(t)
+	bot, teardown := newTestBot(t, e)
+	defer teardown()
+
+	store, teardownStore := newTestingUtils()
+	defer teardownStore()
+	am := approvals.New(&approvals.Opts{Store: store})
+
+	approval := &types.Approval{
+		Provider:       types.ProviderTypeKubernetes,
+		Identifier:     "deployment/default/app:1.2.3",
+		Message:        "New image is available for resource default/app (1.2.2 -> 1.2.3).",
+		VotesRequired:  2,
+		CurrentVersion: "1.2.2",
+		NewVersion:     "1.2.3",
+		Deadline:       time.Now().Add(time.Hour),
+		Event:          &types.Event{Repository: types.Repository{Name: "quilla/app", Tag: "1.2.3"}},
+	}
+	err := am.Create(approval)
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	err = bot.RequestApproval(approval)
+	if err != nil {
+		t.Fatalf("failed to request approval: %s", err)
+	}
+	sent := e.connectorCalls(http.MethodPost)
+	if len(sent) != 1 {
+		t.Fatalf("expected approval card to be sent, got %d activities", len(sent))
+	}
+	if sent[0].path != "/v3/conversations/19:approvals@thread.tacv2/activities" {
+		t.Errorf("unexpected conversation: %s", sent[0].path)
+	}
+	if sent[0].authorization != "Bearer connector-token" {
+		t.Errorf("expected connector token, got: %s", sent[0].authorization)
+	}
+	card := cardContent(t, sent[0].activity)
+	if !strings.Contains(card, `"type":"Action.Submit"`) || !strings.Contains(card, `{"action":"reject","identifier":"deployment/default/app:1.2.3"}`) {
+		t.Errorf("expected approve and reject actions, got: %s", card)
+	}
+
+	rec := httptest.NewRecorder()
+	bot.InteractionHandler().ServeHTTP(rec, e.activityRequest(t, "testdata/card_submit.json", e.token(t, e.key, nil)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", rec.Code)
+	}
+
+	users := b.UserMapping{"6b7c1e0a-3f2d-4c5b-9a8e-1d2c3b4a5f60": {Name: "alice", Groups: []string{"sre"}}}
+	select {
+	case resp := <-bot.approvalsRespCh:
+		if resp.Status != types.ApprovalStatusApproved || resp.Text != "approve deployment/default/app:1.2.3" {
+			t.Fatalf("unexpected approval response: %+v", resp)
+		}
+		// voting the way bot manager does
+		approval, err = am.Approve("deployment/default/app:1.2.3", users.Voter(resp.User), "")
+		if err != nil {
+			t.Fatalf("failed to approve: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("approval response wasn't received")
+	}
+	if approval.GetVoters()[0] != "alice" {
+		t.Errorf("expected mapped user vote, got voters %v", approval.GetVoters())
+	}
+
+	err = bot.ReplyToApproval(approval)
+	if err != nil {
+		t.Fatalf("failed to reply to approval: %s", err)
+	}
+	updated := e.connectorCalls(http.MethodPut)
+	if len(updated) != 1 {
+		t.Fatalf("expected approval card to be updated in place, got %d updates", len(updated))
+	}
+	// card is updated in the thread vote was received in
+	if updated[0].path != "/v3/conversations/19:approvals@thread.tacv2%3Bmessageid=1700000000000/activities/1700000000001" {
+		t.Errorf("unexpected card updated: %s", updated[0].path)
+	}
+	card = cardContent(t, updated[0].activity)
+	if !strings.Contains(card, `"value":"1/2"`) || !strings.Contains(card, "Votes from alice") || !strings.Contains(card, "Action.Submit") {
+		t.Errorf("expected pending card with votes, got: %s", card)
+	}
+
+	approval, err = am.Approve(approval.Identifier, &types.Voter{Name: "bob"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+	err = bot.ReplyToApproval(approval)
+	if err != nil {
+		t.Fatalf("failed to reply to approval: %s", err)
+	}
+	updated = e.connectorCalls(http.MethodPut)
+	if len(updated) != 2 {
+		t.Fatalf("expected approval card to be updated in place, got %d updates", len(updated))
+	}
+	card = cardContent(t, updated[1].activity)
+	if !strings.Contains(card, "Update approved") || strings.Contains(card, "Action.Submit") {
+		t.Errorf("expected final card without actions, got: %s", card)
+	}
+	if len(e.connectorCalls(http.MethodPost)) != 1 {
+		t.Errorf("votes shouldn't be replied to with new messages")
+	}
+}
+
+func TestTextCommand(t *testing.T) {
+	e := newEmulator(t)
+	bot, teardown := newTestBot(t, e)
+	defer teardown()
+
+	rec := httptest.NewRecorder()
+	bot.InteractionHandler().ServeHTTP(rec, e.activityRequest(t, "testdata/message.json", e.token(t, e.key, nil)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", rec.Code)
+	}
+
+	select {
+	case msg := <-bot.botMessagesChannel:
+		if msg.Message != "get approvals" || msg.Channel != "19:approvals@thread.tacv2;messageid=1700000411456" || msg.Name != "teams" {
+			t.Errorf("unexpected bot message: %+v", msg)
+		}
+		bot.Respond("there are currently no request waiting to be approved.", msg.Channel)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("bot message wasn't received")
+	}
+
+	sent := e.connectorCalls(http.MethodPost)
+	if len(sent) != 1 || !strings.Contains(sent[0].activity.Text, "no request waiting") {
+		t.Fatalf("expected response in the thread, got: %+v", sent)
+	}
+	if sent[0].path != "/v3/conversations/19:approvals@thread.tacv2%3Bmessageid=1700000411456/activities" {
+		t.Errorf("unexpected conversation: %s", sent[0].path)
+	}
+}
+
+func TestActivityTokenValidation(t *testing.T) {
+	e := newEmulator(t)
+	bot, teardown := newTestBot(t, e)
+	defer teardown()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "missing token", token: ""},
+		{name: "not signed by bot framework", token: e.token(t, otherKey, nil)},
+		{name: "other bot", token: e.token(t, e.key, jwt.MapClaims{"aud": "other-app-id"})},
+		{name: "other issuer", token: e.token(t, e.key, jwt.MapClaims{"iss": "https://sts.windows.net/"})},
+		{name: "expired", token: e.token(t, e.key, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})},
+		{name: "other service url", token: e.token(t, e.key, jwt.MapClaims{"serviceurl": "https://attacker.example.com/"})},
+		{name: "missing service url", token: e.token(t, e.key, jwt.MapClaims{"serviceurl": nil})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			bot.InteractionHandler().ServeHTTP(rec, e.activityRequest(t, "testdata/card_submit.json", tt.token))
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("expected activity to be rejected, got: %d", rec.Code)
+			}
+		})
+	}
+
+	select {
+	case resp := <-bot.approvalsRespCh:
+		t.Errorf("unverified activity shouldn't vote, got: %+v", resp)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if bot.getServiceURL() != e.URL+"/" {
+		t.Errorf("service URL of unverified activity shouldn't be used, got: %s", bot.getServiceURL())
+	}
+}
+
+func TestGarbageActivity(t *testing.T) {
+	e := newEmulator(t)
+	bot, teardown := newTestBot(t, e)
+	defer teardown()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/bots/teams/interactions", io.NopCloser(strings.NewReader("{not json")))
+	rec := httptest.NewRecorder()
+	bot.InteractionHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected bad request, got: %d", rec.Code)
+	}
+}
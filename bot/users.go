@@ -0,0 +1,37 @@
+package bot
+
+import (
+	"encoding/json"
+
+	"github.com/quilla-hq/quilla/types"
+)
+
+// UserMapping - maps chat users to quilla voters so votes from bots are checked
+// against approver groups, ie: {"U024BE7LH": {"name": "alice", "groups": ["sre"]}}
+type UserMapping map[string]types.Voter
+
+// ParseUserMapping - parses JSON encoded user mapping
+func ParseUserMapping(value string) (UserMapping, error) {
+	mapping := UserMapping{}
+	if value == "" {
+		return mapping, nil
+	}
+	err := json.Unmarshal([]byte(value), &mapping)
+	if err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// Voter - returns voter of the chat user, unmapped users vote
+// with their chat name and don't belong to any groups
+func (m UserMapping) Voter(user string) *types.Voter {
+	voter, ok := m[user]
+	if !ok {
+		return &types.Voter{Name: user}
+	}
+	if voter.Name == "" {
+		voter.Name = user
+	}
+	return &voter
+}
@@ -6,6 +6,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"context"
@@ -25,6 +26,7 @@ import (
 	"github.com/quilla-hq/quilla/pkg/store/sql"
 
 	"github.com/quilla-hq/quilla/constants"
+	"github.com/quilla-hq/quilla/extension/approval"
 	"github.com/quilla-hq/quilla/extension/credentialshelper"
 	"github.com/quilla-hq/quilla/extension/notification"
 	"github.com/quilla-hq/quilla/internal/k8s"
@@ -58,6 +60,7 @@ import (
 	// bots
 	_ "github.com/quilla-hq/quilla/bot/hipchat"
 	_ "github.com/quilla-hq/quilla/bot/slack"
+	_ "github.com/quilla-hq/quilla/bot/teams"
 
 	log "github.com/sirupsen/logrus"
 	// importing to ensure correct dependencies
@@ -196,10 +199,22 @@ func main() {
 	k8s.WatchDaemonSets(&g, implementer.Client(), wl, buf)
 	k8s.WatchCronJobs(&g, implementer.Client(), wl, buf)
 
+	// helm releases are cached from release secrets when helm uses secrets storage driver
+	var helmReleaseCache *helm3.ReleaseCache
+	if helm3Enabled() {
+		switch os.Getenv("HELM_DRIVER") {
+		case "", "secret", "secrets":
+			helmReleaseCache = helm3.NewReleaseCache()
+			helmReleaseCache.SetSynced(k8s.WatchHelmReleaseSecrets(&g, implementer.Client(), wl, helmReleaseCache))
+		}
+	}
+
 	// approvalsCache := memory.NewMemoryCache()
 	approvalsManager := approvals.New(&approvals.Opts{
 		// Cache: approvalsCache,
-		Store: sqlStore,
+		Store:     sqlStore,
+		Sender:    sender,
+		Reminders: approvalReminders(),
 	})
 
 	pendindApprovalsCounter := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
@@ -216,12 +231,16 @@ func main() {
 
 	go approvalsManager.StartExpiryService(ctx)
 
+	// approval collectors, ie: approval requests sent by email
+	approval.New().Configure(approvalsManager)
+
 	// setting up providers
-	providers := setupProviders(&ProviderOpts{
+	providers, helmReleases, updateQueue := setupProviders(&ProviderOpts{
 		k8sImplementer:   implementer,
 		sender:           sender,
 		approvalsManager: approvalsManager,
 		grc:              &t.GenericResourceCache,
+		helmReleaseCache: helmReleaseCache,
 		store:            sqlStore,
 		k8sClient:        implementer.Client(),
 		config:           implementer.Config(),
@@ -244,6 +263,15 @@ func main() {
 	ch := secretsCredentialsHelper.New(secretsGetter)
 	credentialshelper.RegisterCredentialsHelper("secrets", ch)
 
+	// bots are started before triggers as HTTP server serves bot interactions
+	botUsers, err := bot.ParseUserMapping(os.Getenv(constants.EnvBotUserMapping))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("main: failed to parse bot user mapping, bot users won't belong to approver groups")
+	}
+	bot.Run(implementer, approvalsManager, botUsers)
+
 	// trigger setup
 	// teardownTriggers := setupTriggers(ctx, providers, approvalsManager, &t.GenericResourceCache, implementer)
 	teardownTriggers := setupTriggers(ctx, &TriggerOpts{
@@ -252,11 +280,11 @@ func main() {
 		grc:              &t.GenericResourceCache,
 		k8sClient:        implementer,
 		store:            sqlStore,
+		helmReleases:     helmReleases,
+		updateQueue:      updateQueue,
 		uiDir:            *uiDir,
 	})
 
-	bot.Run(implementer, approvalsManager)
-
 	signalChan := make(chan os.Signal, 1)
 	cleanupDone := make(chan bool)
 	signal.Notify(signalChan, os.Interrupt)
@@ -291,6 +319,7 @@ type ProviderOpts struct {
 	sender           notification.Sender
 	approvalsManager approvals.Manager
 	grc              *k8s.GenericResourceCache
+	helmReleaseCache *helm3.ReleaseCache
 	store            store.Store
 
 	k8sClient kube.Interface
@@ -299,29 +328,40 @@ type ProviderOpts struct {
 
 // setupProviders - setting up available providers. New providers should be initialised here and added to
 // provider map
-func setupProviders(opts *ProviderOpts) (providers provider.Providers) {
+func setupProviders(opts *ProviderOpts) (providers provider.Providers, helmReleases helm3.ReleaseManager, updateQueue kubernetes.UpdateQueue) {
 	var enabledProviders []provider.Provider
 
+	// approvals are pinned to image digests resolved from registries
+	digests := registry.NewDigestResolver(registry.New())
+
 	k8sProvider, err := kubernetes.NewProvider(opts.k8sImplementer, opts.sender, opts.approvalsManager, opts.grc)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
 		}).Fatal("main.setupProviders: failed to create kubernetes provider")
 	}
-	go func() {
-		err := k8sProvider.Start()
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error": err,
-			}).Fatal("kubernetes provider stopped with an error")
-		}
-	}()
-
+	if opts.store != nil {
+		k8sProvider.SetGateStore(opts.store)
+		k8sProvider.SetVersionStore(opts.store)
+		k8sProvider.SetWaitingStore(opts.store)
+		k8sProvider.SetHistoryStore(opts.store)
+	}
+	k8sProvider.SetDigestResolver(digests)
+	k8sProvider.SetUpdateLimits(updateLimits())
+	k8sProvider.SetStatusWriteback(statusWriteback())
+	updateQueue = k8sProvider
 	enabledProviders = append(enabledProviders, k8sProvider)
 
-	if os.Getenv(EnvHelm3Provider) == "1" || os.Getenv(EnvHelm3Provider) == "true" {
+	if helm3Enabled() {
 		helm3Implementer := helm3.NewHelm3Implementer()
 		helm3Provider := helm3.NewProvider(helm3Implementer, opts.sender, opts.approvalsManager)
+		if opts.helmReleaseCache != nil {
+			helm3Provider.SetReleaseCache(opts.helmReleaseCache)
+		}
+		helm3Provider.SetDigestResolver(digests)
+		if opts.store != nil {
+			helm3Provider.SetHistoryStore(opts.store)
+		}
 
 		go func() {
 			err := helm3Provider.Start()
@@ -333,12 +373,139 @@ func setupProviders(opts *ProviderOpts) (providers provider.Providers) {
 		}()
 
 		enabledProviders = append(enabledProviders, helm3Provider)
+		helmReleases = helm3Provider
+	}
+
+	dp := provider.New(enabledProviders, opts.approvalsManager)
+
+	workers, _ := strconv.Atoi(os.Getenv(constants.EnvQueueWorkers))
+	maxAttempts, _ := strconv.Atoi(os.Getenv(constants.EnvQueueMaxAttempts))
+	err = dp.EnableQueue(&provider.QueueOpts{
+		Store:       opts.store,
+		Workers:     workers,
+		MaxAttempts: maxAttempts,
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Fatal("main.setupProviders: failed to start event queue")
+	}
+	// events created by the provider itself go through the queue as well
+	k8sProvider.SetEventSubmitter(dp)
+	go func() {
+		err := k8sProvider.Start()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Fatal("kubernetes provider stopped with an error")
+		}
+	}()
+
+	// approvals are submitted through the queue, subscribing once it's running
+	dp.Start()
+	providers = dp
+
+	return providers, helmReleases, updateQueue
+}
+
+// updateLimits - rollout concurrency limits and update budgets, invalid
+// values are ignored
+func updateLimits() kubernetes.UpdateLimits {
+	limits := kubernetes.UpdateLimits{}
+	limits.Concurrency, _ = strconv.Atoi(os.Getenv(constants.EnvUpdateConcurrency))
+	limits.UpdatesPerHour, _ = strconv.Atoi(os.Getenv(constants.EnvUpdatesPerHour))
+
+	if os.Getenv(constants.EnvNamespaceUpdateConcurrency) != "" {
+		namespaceLimits, err := kubernetes.ParseNamespaceLimits(os.Getenv(constants.EnvNamespaceUpdateConcurrency))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Error("main.updateLimits: failed to parse namespace update limits")
+		} else {
+			limits.NamespaceConcurrency = namespaceLimits
+		}
+	}
+	return limits
+}
+
+func statusWriteback() kubernetes.StatusWriteback {
+	opts := kubernetes.StatusWriteback{}
+
+	mode, err := kubernetes.ParseStatusMode(os.Getenv(constants.EnvStatusWriteback))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("main.statusWriteback: failed to parse status writeback mode, status won't be written")
+	}
+	opts.Mode = mode
+	opts.Events, _ = strconv.ParseBool(os.Getenv(constants.EnvStatusEvents))
+
+	if os.Getenv(constants.EnvStatusInterval) != "" {
+		interval, err := time.ParseDuration(os.Getenv(constants.EnvStatusInterval))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Error("main.statusWriteback: failed to parse status interval, using default")
+		} else {
+			opts.Interval = interval
+		}
+	}
+	return opts
+}
+
+// approvalLinkSigner - verifies approval links sent by email, nil if links aren't configured
+func approvalLinkSigner() *approvals.LinkSigner {
+	secret := os.Getenv(constants.EnvMailApprovalLinkSecret)
+	if secret == "" {
+		return nil
+	}
+
+	var ttl time.Duration
+	if os.Getenv(constants.EnvMailApprovalLinkTTL) != "" {
+		var err error
+		ttl, err = time.ParseDuration(os.Getenv(constants.EnvMailApprovalLinkTTL))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Error("main.approvalLinkSigner: failed to parse approval link TTL, using default")
+		}
+	}
+	return approvals.NewLinkSigner([]byte(secret), ttl)
+}
+
+func approvalReminders() approvals.Reminders {
+	reminders := approvals.Reminders{}
+
+	before, err := approvals.ParseReminders(os.Getenv(constants.EnvApprovalReminders))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("main.approvalReminders: failed to parse approval reminders, reminders won't be sent")
+	} else {
+		reminders.Before = before
+	}
 
+	if os.Getenv(constants.EnvApprovalEscalateBefore) != "" {
+		escalateBefore, err := time.ParseDuration(os.Getenv(constants.EnvApprovalEscalateBefore))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Error("main.approvalReminders: failed to parse approval escalation, approvals won't be escalated")
+		} else {
+			reminders.EscalateBefore = escalateBefore
+		}
 	}
 
-	providers = provider.New(enabledProviders, opts.approvalsManager)
+	for _, channel := range strings.Split(os.Getenv(constants.EnvApprovalEscalationChannels), ",") {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			reminders.EscalationChannels = append(reminders.EscalationChannels, channel)
+		}
+	}
+	return reminders
+}
 
-	return providers
+func helm3Enabled() bool {
+	return os.Getenv(EnvHelm3Provider) == "1" || os.Getenv(EnvHelm3Provider) == "true"
 }
 
 type TriggerOpts struct {
@@ -347,6 +514,8 @@ type TriggerOpts struct {
 	grc              *k8s.GenericResourceCache
 	k8sClient        kubernetes.Implementer
 	store            store.Store
+	helmReleases     helm3.ReleaseManager
+	updateQueue      kubernetes.UpdateQueue
 	uiDir            string
 }
 
@@ -388,10 +557,14 @@ func setupTriggers(ctx context.Context, opts *TriggerOpts) (teardown func()) {
 		Providers:             opts.providers,
 		ApprovalManager:       opts.approvalsManager,
 		Store:                 opts.store,
+		HelmReleases:          opts.helmReleases,
+		UpdateQueue:           opts.updateQueue,
 		Authenticator:         authenticator,
 		UIDir:                 opts.uiDir,
 		AuthenticatedWebhooks: os.Getenv(constants.EnvAuthenticatedWebhooks) == "true",
 		RBACEnabled:           err == nil && enabled,
+		BotHandlers:           bot.InteractionHandlers(),
+		ApprovalLinks:         approvalLinkSigner(),
 	})
 
 	go func() {
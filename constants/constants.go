@@ -15,6 +15,9 @@ const (
 	EnvSlackBotName          = "SLACK_BOT_NAME"
 	EnvSlackChannels         = "SLACK_CHANNELS"
 	EnvSlackApprovalsChannel = "SLACK_APPROVALS_CHANNEL"
+	// Slack app signing secret, enables approval buttons, interactivity request URL
+	// has to point to /v1/bots/slack/interactions
+	EnvSlackSigningSecret = "SLACK_SIGNING_SECRET"
 
 	EnvHipchatToken    = "HIPCHAT_TOKEN"
 	EnvHipchatBotName  = "HIPCHAT_BOT_NAME"
@@ -26,6 +29,10 @@ const (
 	EnvHipchatApprovalsPasswort  = "HIPCHAT_APPROVALS_PASSWORT"
 	EnvHipchatConnectionAttempts = "HIPCHAT_CONNECTION_ATTEMPTS"
 
+	// Maps bot users to voters and their approver groups, JSON encoded,
+	// ie: {"U024BE7LH": {"name": "alice", "groups": ["sre"]}}
+	EnvBotUserMapping = "BOT_USER_MAPPING"
+
 	// Mattermost webhook endpoint, see https://docs.mattermost.com/developer/webhooks-incoming.html
 	// for documentation on setting it up
 	EnvMattermostEndpoint = "MATTERMOST_ENDPOINT"
@@ -34,6 +41,16 @@ const (
 	// MS Teams webhook url, see https://docs.microsoft.com/en-us/microsoftteams/platform/webhooks-and-connectors/how-to/connectors-using#setting-up-a-custom-incoming-webhook
 	EnvTeamsWebhookUrl = "TEAMS_WEBHOOK_URL"
 
+	// MS Teams approvals bot, Bot Framework messaging endpoint has to point
+	// to /v1/bots/teams/interactions
+	EnvTeamsAppID                 = "TEAMS_APP_ID"
+	EnvTeamsAppPassword           = "TEAMS_APP_PASSWORD"
+	EnvTeamsBotName               = "TEAMS_BOT_NAME"
+	EnvTeamsApprovalsConversation = "TEAMS_APPROVALS_CONVERSATION"
+	// Bot Framework service URL approval requests are sent to before the bot
+	// receives first activity, defaults to https://smba.trafficmanager.net/teams/
+	EnvTeamsServiceURL = "TEAMS_SERVICE_URL"
+
 	// Discord webhook url, see https://support.discord.com/hc/en-us/articles/228383668-Intro-to-Webhooks
 	EnvDiscordWebhookUrl = "DISCORD_WEBHOOK_URL"
 
@@ -44,6 +61,14 @@ const (
 	EnvMailSmtpPort   = "MAIL_SMTP_PORT"
 	EnvMailSmtpUser   = "MAIL_SMTP_USER"
 	EnvMailSmtpPass   = "MAIL_SMTP_PASS"
+
+	// Mail approvals, approval requests are sent to comma separated MAIL_APPROVERS
+	// with approve/reject links pointing to MAIL_APPROVAL_BASE_URL (public quilla URL)
+	EnvMailApprovers          = "MAIL_APPROVERS"
+	EnvMailApprovalBaseURL    = "MAIL_APPROVAL_BASE_URL"
+	EnvMailApprovalLinkSecret = "MAIL_APPROVAL_LINK_SECRET"
+	// EnvMailApprovalLinkTTL - how long approval links are valid, defaults to 24h
+	EnvMailApprovalLinkTTL = "MAIL_APPROVAL_LINK_TTL"
 )
 
 // EnvNotificationLevel - minimum level for notifications, defaults to info
@@ -72,3 +97,30 @@ const QuillaLogoURL = "https://quilla.sh/img/logo.png"
 
 // Env var to define a namespace that quilla will scan - avoid scan over all the cluster -
 const EnvRestrictedNamespace = "RESTRICTED_NAMESPACE"
+
+// Event queue, number of workers processing events and how many times
+// failed events are retried
+const EnvQueueWorkers = "QUEUE_WORKERS"
+const EnvQueueMaxAttempts = "QUEUE_MAX_ATTEMPTS"
+
+// Update limits, cluster-wide number of rollouts in progress, per namespace
+// limits (ie: "5" or "production=2,5" where the plain number is the default) and
+// default number of updates per resource within an hour. Zero means no limit.
+const EnvUpdateConcurrency = "UPDATE_CONCURRENCY"
+const EnvNamespaceUpdateConcurrency = "NAMESPACE_UPDATE_CONCURRENCY"
+const EnvUpdatesPerHour = "UPDATES_PER_HOUR"
+
+// Status writeback, "annotations" or "configmap" keeps update status on tracked
+// resources, events are emitted on workloads when enabled. Interval is the minimum
+// time between status writes and repeated events of a resource (ie: "30s").
+const EnvStatusWriteback = "STATUS_WRITEBACK"
+const EnvStatusEvents = "STATUS_EVENTS"
+const EnvStatusInterval = "STATUS_INTERVAL"
+
+// Approval reminders, comma separated durations before the deadline when reminders
+// of pending approvals are sent (ie: "4h,1h"). Approvals are escalated to escalation
+// channels, or approval channels when not set, once deadline is closer than
+// escalate before duration.
+const EnvApprovalReminders = "APPROVAL_REMINDERS"
+const EnvApprovalEscalateBefore = "APPROVAL_ESCALATE_BEFORE"
+const EnvApprovalEscalationChannels = "APPROVAL_ESCALATION_CHANNELS"
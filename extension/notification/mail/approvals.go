@@ -0,0 +1,143 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/quilla-hq/quilla/approvals"
+	"github.com/quilla-hq/quilla/constants"
+	"github.com/quilla-hq/quilla/extension/approval"
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var approvalTemplate = template.Must(template.New("approval").Parse(`{{ .Approval.Message }}
+
+Identifier: {{ .Approval.Identifier }}
+Delta: {{ .Approval.Delta }}
+Votes: {{ .Approval.VotesReceived }}/{{ .Approval.VotesRequired }}
+{{- if .Diff }}
+
+Planned changes:
+{{ .Diff }}
+{{- end }}
+
+Approve: {{ .ApproveURL }}
+Reject: {{ .RejectURL }}
+
+Links can only be used by {{ .Recipient }}, once, until {{ .ExpiresAt }}.
+`))
+
+type approvalMail struct {
+	Approval   *types.Approval
+	Diff       string
+	Recipient  string
+	ApproveURL string
+	RejectURL  string
+	ExpiresAt  string
+}
+
+// collector - mails approval requests with personal approve/reject links
+type collector struct {
+	smtpConfig
+	approvers []string
+	baseURL   string
+	signer    *approvals.LinkSigner
+}
+
+func init() {
+	approval.RegisterCollector("mail", &collector{})
+}
+
+func (c *collector) Configure(approvalsManager approvals.Manager) (bool, error) {
+	if !c.smtpConfig.configure() {
+		return false, nil
+	}
+
+	for _, approver := range strings.Split(os.Getenv(constants.EnvMailApprovers), ",") {
+		if approver = strings.TrimSpace(approver); approver != "" {
+			c.approvers = append(c.approvers, approver)
+		}
+	}
+	c.baseURL = strings.TrimSuffix(os.Getenv(constants.EnvMailApprovalBaseURL), "/")
+	secret := os.Getenv(constants.EnvMailApprovalLinkSecret)
+	if len(c.approvers) == 0 || c.baseURL == "" || secret == "" {
+		return false, nil
+	}
+
+	var ttl time.Duration
+	if os.Getenv(constants.EnvMailApprovalLinkTTL) != "" {
+		var err error
+		ttl, err = time.ParseDuration(os.Getenv(constants.EnvMailApprovalLinkTTL))
+		if err != nil {
+			return false, fmt.Errorf("invalid %s: %s", constants.EnvMailApprovalLinkTTL, err)
+		}
+	}
+	c.signer = approvals.NewLinkSigner([]byte(secret), ttl)
+
+	approvalsCh, err := approvalsManager.Subscribe(context.Background(), "mail")
+	if err != nil {
+		return false, err
+	}
+	go c.run(approvalsCh)
+
+	return true, nil
+}
+
+func (c *collector) run(approvalsCh <-chan *approvals.Delivery) {
+	for a := range approvalsCh {
+		if a.Status() != types.ApprovalStatusPending {
+			a.Ack()
+			continue
+		}
+		for _, recipient := range c.approvers {
+			err := c.sendApproval(a.Approval, recipient)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error":      err,
+					"identifier": a.Identifier,
+					"recipient":  recipient,
+				}).Error("extension.notification.mail: failed to send approval request")
+			}
+		}
+		a.Ack()
+	}
+}
+
+// sendApproval - every recipient gets own links, votes are recorded under recipient's address
+func (c *collector) sendApproval(a *types.Approval, recipient string) error {
+	approveToken, err := c.signer.Sign(a, approvals.LinkActionApprove, recipient)
+	if err != nil {
+		return err
+	}
+	rejectToken, err := c.signer.Sign(a, approvals.LinkActionReject, recipient)
+	if err != nil {
+		return err
+	}
+	// both tokens expire at the same time
+	claims, err := c.signer.Verify(approveToken)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	err = approvalTemplate.Execute(&body, &approvalMail{
+		Approval:   a,
+		Diff:       a.Plan.Diff(),
+		Recipient:  recipient,
+		ApproveURL: c.baseURL + "/v1/approvals/links/" + approveToken,
+		RejectURL:  c.baseURL + "/v1/approvals/links/" + rejectToken,
+		ExpiresAt:  time.Unix(claims.ExpiresAt, 0).UTC().Format(time.RFC1123),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.send([]string{recipient}, "quilla approval required: "+a.Identifier, body.String())
+}
@@ -0,0 +1,217 @@
+package mail
+
+import (
+	"bufio"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+
+	"github.com/quilla-hq/quilla/approvals"
+	"github.com/quilla-hq/quilla/constants"
+	"github.com/quilla-hq/quilla/pkg/store/sql"
+	"github.com/quilla-hq/quilla/types"
+)
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTPServer - local SMTP stand-in, speaks just enough of the protocol for net/smtp
+type fakeSMTPServer struct {
+	listener net.Listener
+	messages chan *smtpMessage
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	s := &fakeSMTPServer{listener: l, messages: make(chan *smtpMessage, 10)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+	msg := &smtpMessage{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg.from = strings.Trim(strings.TrimSpace(line)[len("MAIL FROM:"):], "<>")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.to = append(msg.to, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.data = data.String()
+			s.messages <- msg
+			msg = &smtpMessage{}
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func newTestingUtils() (*sql.SQLStore, func()) {
+	dir, err := ioutil.TempDir("", "mailstoretest")
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := sql.New(sql.Opts{DatabaseType: "sqlite3", URI: filepath.Join(dir, "gorm.db")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return store, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+var linkRe = regexp.MustCompile(`(Approve|Reject): https://quilla\.example\.com/v1/approvals/links/(\S+)`)
+
+func TestApprovalRequestMailed(t *testing.T) {
+	smtpServer := newFakeSMTPServer(t)
+	defer smtpServer.listener.Close()
+
+	t.Setenv(constants.EnvMailSmtpServer, "127.0.0.1")
+	t.Setenv(constants.EnvMailSmtpPort, strconv.Itoa(smtpServer.port()))
+	t.Setenv(constants.EnvMailFrom, "quilla@example.com")
+	t.Setenv(constants.EnvMailApprovers, "jane@example.com, bob@example.com")
+	t.Setenv(constants.EnvMailApprovalBaseURL, "https://quilla.example.com/")
+	t.Setenv(constants.EnvMailApprovalLinkSecret, "link-secret")
+
+	store, teardown := newTestingUtils()
+	defer teardown()
+	am := approvals.New(&approvals.Opts{Store: store})
+
+	c := &collector{}
+	configured, err := c.Configure(am)
+	if err != nil || !configured {
+		t.Fatalf("expected collector to be configured, err: %v", err)
+	}
+
+	err = am.Create(&types.Approval{
+		Identifier:     "xxx/app-1",
+		Message:        "New image is available for deployment app-1",
+		VotesRequired:  2,
+		NewVersion:     "2.0.0",
+		CurrentVersion: "1.0.0",
+		Deadline:       time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	signer := approvals.NewLinkSigner([]byte("link-secret"), 0)
+	recipients := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		var msg *smtpMessage
+		select {
+		case msg = <-smtpServer.messages:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("approval request wasn't mailed")
+		}
+
+		if len(msg.to) != 1 {
+			t.Fatalf("expected single recipient per mail, got: %v", msg.to)
+		}
+		recipient := msg.to[0]
+		recipients[recipient] = true
+
+		if msg.from != "quilla@example.com" {
+			t.Errorf("unexpected sender: %s", msg.from)
+		}
+		if !strings.Contains(msg.data, "Subject: quilla approval required: xxx/app-1") {
+			t.Errorf("unexpected subject, mail: %s", msg.data)
+		}
+
+		links := linkRe.FindAllStringSubmatch(msg.data, -1)
+		if len(links) != 2 {
+			t.Fatalf("expected approve and reject links, mail: %s", msg.data)
+		}
+		for _, link := range links {
+			claims, err := signer.Verify(link[2])
+			if err != nil {
+				t.Fatalf("failed to verify %s link: %s", link[1], err)
+			}
+			if claims.Recipient() != recipient {
+				t.Errorf("expected link bound to %s, got: %s", recipient, claims.Recipient())
+			}
+			if claims.Action != strings.ToLower(link[1]) {
+				t.Errorf("expected %s link, got: %s", link[1], claims.Action)
+			}
+		}
+	}
+
+	if !recipients["jane@example.com"] || !recipients["bob@example.com"] {
+		t.Errorf("expected mail to every approver, got: %v", recipients)
+	}
+}
+
+func TestApprovalCollectorNotConfigured(t *testing.T) {
+	t.Setenv(constants.EnvMailSmtpServer, "127.0.0.1")
+	t.Setenv(constants.EnvMailFrom, "quilla@example.com")
+	t.Setenv(constants.EnvMailApprovers, "jane@example.com")
+	t.Setenv(constants.EnvMailApprovalBaseURL, "")
+	t.Setenv(constants.EnvMailApprovalLinkSecret, "link-secret")
+
+	store, teardown := newTestingUtils()
+	defer teardown()
+
+	configured, err := (&collector{}).Configure(approvals.New(&approvals.Opts{Store: store}))
+	if err != nil || configured {
+		t.Errorf("expected collector without base URL to stay disabled, err: %v", err)
+	}
+}
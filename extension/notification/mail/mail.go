@@ -4,6 +4,7 @@ import (
 	"net/smtp"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/quilla-hq/quilla/constants"
 	"github.com/quilla-hq/quilla/extension/notification"
@@ -13,8 +14,13 @@ import (
 )
 
 type sender struct {
+	smtpConfig
+	to string
+}
+
+// smtpConfig - SMTP server settings shared by notifications and approvals
+type smtpConfig struct {
 	from       string
-	to         string
 	smtpServer string
 	smtpPort   int
 	smtpUser   string
@@ -26,22 +32,36 @@ func init() {
 }
 
 func (s *sender) Configure(config *notification.Config) (bool, error) {
-	// Server, from and to are mandatory
-	if os.Getenv(constants.EnvMailSmtpServer) != "" {
-		s.smtpServer = os.Getenv(constants.EnvMailSmtpServer)
-	} else {
-		return false, nil
-	}
-	if os.Getenv(constants.EnvMailFrom) != "" {
-		s.from = os.Getenv(constants.EnvMailFrom)
-	} else {
+	if !s.smtpConfig.configure() {
 		return false, nil
 	}
+	// to is mandatory for notifications
 	if os.Getenv(constants.EnvMailTo) != "" {
 		s.to = os.Getenv(constants.EnvMailTo)
 	} else {
 		return false, nil
 	}
+
+	log.WithFields(log.Fields{
+		"name": "mail",
+	}).Info("extension.notification.mail: sender configured")
+
+	return true, nil
+}
+
+// configure - reads SMTP settings from environment, returns false if mail isn't configured
+func (c *smtpConfig) configure() bool {
+	// Server and from are mandatory
+	if os.Getenv(constants.EnvMailSmtpServer) != "" {
+		c.smtpServer = os.Getenv(constants.EnvMailSmtpServer)
+	} else {
+		return false
+	}
+	if os.Getenv(constants.EnvMailFrom) != "" {
+		c.from = os.Getenv(constants.EnvMailFrom)
+	} else {
+		return false
+	}
 	// Port, user and pass are optional
 	if os.Getenv(constants.EnvMailSmtpPort) != "" {
 		port, err := strconv.Atoi(os.Getenv(constants.EnvMailSmtpPort))
@@ -49,51 +69,52 @@ func (s *sender) Configure(config *notification.Config) (bool, error) {
 			log.WithFields(log.Fields{
 				"name": "mail",
 			}).Warn("extension.notification.mail: invalid SMTP port number")
-			return false, nil
+			return false
 		}
-		s.smtpPort = port
+		c.smtpPort = port
 	} else {
-		s.smtpPort = 25
+		c.smtpPort = 25
 	}
 	if os.Getenv(constants.EnvMailSmtpUser) != "" {
-		s.smtpUser = os.Getenv(constants.EnvMailSmtpUser)
+		c.smtpUser = os.Getenv(constants.EnvMailSmtpUser)
 	}
 	if os.Getenv(constants.EnvMailSmtpPass) != "" {
-		s.smtpPass = os.Getenv(constants.EnvMailSmtpPass)
+		c.smtpPass = os.Getenv(constants.EnvMailSmtpPass)
 	}
-
-	log.WithFields(log.Fields{
-		"name": "mail",
-	}).Info("extension.notification.mail: sender configured")
-
-	return true, nil
+	return true
 }
 
 func (s *sender) Send(event types.EventNotification) error {
 	body := event.CreatedAt.String() + "\n" + event.Level.String() + "-" +
 		event.Type.String() + "\n" + event.Message
-	msg := "From: " + s.from + "\n" +
-		"To: " + s.to + "\n" +
-		"Subject: quilla notification\n\n" +
+
+	err := s.send([]string{s.to}, "quilla notification", body)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("extension.notification.mail: failed to send notification")
+	}
+
+	return nil
+}
+
+// send - sends plain text mail
+func (c *smtpConfig) send(to []string, subject, body string) error {
+	msg := "From: " + c.from + "\n" +
+		"To: " + strings.Join(to, ", ") + "\n" +
+		"Subject: " + subject + "\n\n" +
 		body
 
 	// Support only plain auth
 	var auth smtp.Auth = nil
-	if s.smtpUser != "" {
+	if c.smtpUser != "" {
 		auth = smtp.PlainAuth(
 			"",
-			s.smtpUser,
-			s.smtpPass,
-			s.smtpServer,
+			c.smtpUser,
+			c.smtpPass,
+			c.smtpServer,
 		)
 	}
 
-	err := smtp.SendMail(s.smtpServer+":"+strconv.Itoa(s.smtpPort), auth, s.from, []string{s.to}, []byte(msg))
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Error("extension.notification.mail: failed to send notification")
-	}
-
-	return nil
+	return smtp.SendMail(c.smtpServer+":"+strconv.Itoa(c.smtpPort), auth, c.from, to, []byte(msg))
 }
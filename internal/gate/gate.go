@@ -2,6 +2,7 @@ package gate
 
 import (
 	"strings"
+	"time"
 
 	"github.com/quilla-hq/quilla/types"
 	log "github.com/sirupsen/logrus"
@@ -10,10 +11,25 @@ import (
 type GateType int
 
 const (
-	GateTypeNone = iota
+	GateTypeNone GateType = iota
 	GateTypeJob
+	GateTypeHTTP
+	GateTypeManual
 )
 
+func (t GateType) String() string {
+	switch t {
+	case GateTypeJob:
+		return "job"
+	case GateTypeHTTP:
+		return "http"
+	case GateTypeManual:
+		return "manual"
+	default:
+		return "none"
+	}
+}
+
 type GateStatus int
 
 const (
@@ -22,6 +38,17 @@ const (
 	GateStatusSucceeded
 )
 
+// DefaultTimeout - default gate timeout
+const DefaultTimeout = time.Hour
+
+// environment variables passed to gate jobs
+const (
+	EnvIdentifier     = "QUILLA_IDENTIFIER"
+	EnvImage          = "QUILLA_IMAGE"
+	EnvVersion        = "QUILLA_VERSION"
+	EnvCurrentVersion = "QUILLA_CURRENT_VERSION"
+)
+
 type Gate interface {
 	ShouldPass(obj interface{}) bool
 	Name() string
@@ -39,12 +66,12 @@ func (ng *NilGate) Status() GateStatus              { return GateStatusPending }
 func GetGateFromLabelsOrAnnotations(identifier string, labels map[string]string, annotations map[string]string) Gate {
 	gateName, ok := getGateFromMetadata(labels)
 	if ok {
-		return GetGate(identifier, gateName, &Options{Secret: getSecretTag(labels)})
+		return GetGate(identifier, gateName, &Options{Secret: getSecretTag(labels), Timeout: getTimeout(labels)})
 	}
 
 	gateName, ok = getGateFromMetadata(annotations)
 	if ok {
-		return GetGate(identifier, gateName, &Options{Secret: getSecretTag(annotations)})
+		return GetGate(identifier, gateName, &Options{Secret: getSecretTag(annotations), Timeout: getTimeout(annotations)})
 	}
 
 	return &NilGate{}
@@ -68,8 +95,45 @@ func getSecretTag(meta map[string]string) string {
 	return ""
 }
 
+func getTimeout(meta map[string]string) time.Duration {
+	timeout, ok := meta[types.QuillaGateTimeoutAnnotation]
+	if !ok {
+		return DefaultTimeout
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil || d <= 0 {
+		log.WithFields(log.Fields{
+			"error":   err,
+			"timeout": timeout,
+		}).Warn("failed to parse gate timeout, using default value")
+		return DefaultTimeout
+	}
+	return d
+}
+
 type Options struct {
-	Secret string
+	Secret  string
+	Timeout time.Duration
+}
+
+func (o *Options) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return o.Timeout
+}
+
+// GetTimeout - returns gate timeout, gate fails if it doesn't pass in time
+func GetTimeout(g Gate) time.Duration {
+	switch g := g.(type) {
+	case *JobGate:
+		return g.Timeout
+	case *HTTPGate:
+		return g.Timeout
+	case *ManualGate:
+		return g.Timeout
+	}
+	return DefaultTimeout
 }
 
 func GetGate(identifier string, gateName string, options *Options) Gate {
@@ -83,6 +147,22 @@ func GetGate(identifier string, gateName string, options *Options) Gate {
 			}).Error("failed to parse job gate, check your config")
 			return &NilGate{}
 		}
+		g.Timeout = options.timeout()
+		return g
+	case strings.HasPrefix(gateName, "http:"):
+		g, err := NewHTTPGate(gateName)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+				"gate":  gateName,
+			}).Error("failed to parse http gate, check your config")
+			return &NilGate{}
+		}
+		g.Timeout = options.timeout()
+		return g
+	case gateName == "manual":
+		g := NewManualGate()
+		g.Timeout = options.timeout()
 		return g
 	}
 
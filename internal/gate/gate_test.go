@@ -0,0 +1,100 @@
+package gate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/types"
+)
+
+func TestGetGate(t *testing.T) {
+	tests := []struct {
+		name     string
+		gate     string
+		wantType GateType
+	}{
+		{name: "job", gate: "job:gcr.io/v2-namespace/verify:1.0.0", wantType: GateTypeJob},
+		{name: "http", gate: "http:https://qa.example.com/hooks/quilla", wantType: GateTypeHTTP},
+		{name: "invalid http", gate: "http:qa.example.com", wantType: GateTypeNone},
+		{name: "manual", gate: "manual", wantType: GateTypeManual},
+		{name: "unknown", gate: "foo", wantType: GateTypeNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := GetGate("deployment/default/app", tt.gate, &Options{})
+			if g.Type() != tt.wantType {
+				t.Errorf("GetGate() type = %s, want %s", g.Type(), tt.wantType)
+			}
+		})
+	}
+}
+
+func TestGateTimeoutAnnotation(t *testing.T) {
+	g := GetGateFromLabelsOrAnnotations("deployment/default/app", nil, map[string]string{
+		types.QuillaGateLabel:             "manual",
+		types.QuillaGateTimeoutAnnotation: "15m",
+	})
+	if GetTimeout(g) != 15*time.Minute {
+		t.Errorf("unexpected timeout: %s", GetTimeout(g))
+	}
+
+	g = GetGateFromLabelsOrAnnotations("deployment/default/app", nil, map[string]string{
+		types.QuillaGateLabel:             "manual",
+		types.QuillaGateTimeoutAnnotation: "soon",
+	})
+	if GetTimeout(g) != DefaultTimeout {
+		t.Errorf("expected default timeout, got: %s", GetTimeout(g))
+	}
+}
+
+func TestHTTPGate(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	var received PendingUpdate
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	g, err := NewHTTPGate("http:" + srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create gate: %s", err)
+	}
+
+	update := &PendingUpdate{
+		Identifier: "deployment/default/app",
+		Image:      "gcr.io/v2-namespace/hello-world:1.1.2",
+		NewVersion: "1.1.2",
+	}
+
+	if g.ShouldPass(update) {
+		t.Errorf("gate shouldn't pass on 503")
+	}
+	if g.Status() != GateStatusPending {
+		t.Errorf("gate should stay pending after failed request")
+	}
+	if received.Image != update.Image {
+		t.Errorf("unexpected payload: %+v", received)
+	}
+
+	status = http.StatusAccepted
+	if !g.ShouldPass(update) {
+		t.Errorf("gate should pass on 2xx")
+	}
+}
+
+func TestManualGate(t *testing.T) {
+	g := NewManualGate()
+	if g.ShouldPass(&types.Gate{Status: types.GateStatusInProgress}) {
+		t.Errorf("gate shouldn't pass while in progress")
+	}
+	if g.ShouldPass(&types.Gate{Status: types.GateStatusFailed}) || g.Status() != GateStatusFailed {
+		t.Errorf("expected failed gate")
+	}
+	if !NewManualGate().ShouldPass(&types.Gate{Status: types.GateStatusSucceeded}) {
+		t.Errorf("expected gate to pass")
+	}
+}
@@ -0,0 +1,97 @@
+package gate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HTTPRequestTimeout - timeout of a single gate request
+var HTTPRequestTimeout = 10 * time.Second
+
+// PendingUpdate - payload sent to HTTP gates
+type PendingUpdate struct {
+	GateID         string                 `json:"gateId"`
+	Identifier     string                 `json:"identifier"`
+	Image          string                 `json:"image"`
+	CurrentVersion string                 `json:"currentVersion"`
+	NewVersion     string                 `json:"newVersion"`
+	Changes        types.ContainerChanges `json:"changes"`
+	Deadline       time.Time              `json:"deadline"`
+}
+
+// HTTPGate - posts pending update to the URL, passes on 2xx response.
+// Failed requests are retried until the gate deadline.
+type HTTPGate struct {
+	URL     string
+	Timeout time.Duration
+	client  *http.Client
+	status  GateStatus
+}
+
+func NewHTTPGate(gate string) (*HTTPGate, error) {
+	target := strings.TrimPrefix(gate, "http:")
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid http gate: %s", gate)
+	}
+
+	return &HTTPGate{
+		URL:     target,
+		Timeout: DefaultTimeout,
+		client:  &http.Client{Timeout: HTTPRequestTimeout},
+		status:  GateStatusPending,
+	}, nil
+}
+
+func (hg *HTTPGate) Name() string       { return "HTTP Gate" }
+func (hg *HTTPGate) Type() GateType     { return GateTypeHTTP }
+func (hg *HTTPGate) Status() GateStatus { return hg.status }
+
+// ShouldPass - sends pending update, gate stays pending when request fails
+// so it's retried on the next check
+func (hg *HTTPGate) ShouldPass(obj interface{}) bool {
+	update := obj.(*PendingUpdate)
+
+	bts, err := json.Marshal(update)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("gate.HTTPGate: failed to encode pending update")
+		return false
+	}
+
+	resp, err := hg.client.Post(hg.URL, "application/json", bytes.NewReader(bts))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"url":        hg.URL,
+			"identifier": update.Identifier,
+		}).Warn("gate.HTTPGate: request failed")
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.WithFields(log.Fields{
+			"status":     resp.StatusCode,
+			"url":        hg.URL,
+			"identifier": update.Identifier,
+		}).Info("gate.HTTPGate: gate didn't pass")
+		return false
+	}
+
+	hg.status = GateStatusSucceeded
+	return true
+}
@@ -3,26 +3,30 @@ package gate
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/quilla-hq/quilla/types"
 	v1 "k8s.io/api/batch/v1"
 )
 
 type JobGate struct {
-	Image  string
-	secret string
-	status GateStatus
-	gate   types.Gate
+	Image   string
+	Timeout time.Duration
+	secret  string
+	status  GateStatus
+	gate    types.Gate
 }
 
 func NewJobGate(gate string, secret string, identifier string) (*JobGate, error) {
 	if strings.Contains(gate, ":") {
-		parts := strings.Split(gate, ":")
-		if len(parts) == 2 {
+		// image can contain a tag, ie: job:org/verify:1.0.0
+		parts := strings.SplitN(gate, ":", 2)
+		if len(parts) == 2 && parts[1] != "" {
 			return &JobGate{
-				Image:  parts[1],
-				secret: secret,
-				status: GateStatusPending,
+				Image:   parts[1],
+				Timeout: DefaultTimeout,
+				secret:  secret,
+				status:  GateStatusPending,
 				gate: types.Gate{
 					Identifier: identifier,
 				},
@@ -33,6 +37,9 @@ func NewJobGate(gate string, secret string, identifier string) (*JobGate, error)
 	return nil, fmt.Errorf("invalid job gate: %s", gate)
 }
 
+// Secret - secret that is passed to the job as environment variables
+func (jg *JobGate) Secret() string { return jg.secret }
+
 func (jg *JobGate) Name() string       { return "Job Gate" }
 func (jg *JobGate) Type() GateType     { return GateTypeJob }
 func (jg *JobGate) Status() GateStatus { return jg.status }
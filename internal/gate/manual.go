@@ -0,0 +1,36 @@
+package gate
+
+import (
+	"time"
+
+	"github.com/quilla-hq/quilla/types"
+)
+
+// ManualGate - passed or failed through the API
+type ManualGate struct {
+	Timeout time.Duration
+	status  GateStatus
+}
+
+func NewManualGate() *ManualGate {
+	return &ManualGate{
+		Timeout: DefaultTimeout,
+		status:  GateStatusPending,
+	}
+}
+
+func (mg *ManualGate) Name() string       { return "Manual Gate" }
+func (mg *ManualGate) Type() GateType     { return GateTypeManual }
+func (mg *ManualGate) Status() GateStatus { return mg.status }
+
+// ShouldPass - checks gate run that is updated through the API
+func (mg *ManualGate) ShouldPass(obj interface{}) bool {
+	run := obj.(*types.Gate)
+	switch run.Status {
+	case types.GateStatusSucceeded:
+		mg.status = GateStatusSucceeded
+	case types.GateStatusFailed:
+		mg.status = GateStatusFailed
+	}
+	return mg.status == GateStatusSucceeded
+}
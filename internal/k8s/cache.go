@@ -8,6 +8,8 @@ import (
 type genericResourceCache struct {
 	sync.Mutex
 	values []*GenericResource
+
+	index resourceIndex
 }
 
 // GenericResourceCache - storage for generic resources with a rendezvous point for goroutines
@@ -45,6 +47,8 @@ func (cc *genericResourceCache) Add(grs ...*GenericResource) {
 // add adds c to the cache. If c is already present, the cached value of c is overwritten.
 // invariant: cc.values should be sorted on entry.
 func (cc *genericResourceCache) add(c *GenericResource) {
+	cc.index.add(c)
+
 	i := sort.Search(len(cc.values), func(i int) bool { return cc.values[i].Identifier >= c.Identifier })
 	if i < len(cc.values) && cc.values[i].Identifier == c.Identifier {
 		// c is already present, replace
@@ -74,6 +78,8 @@ func (cc *genericResourceCache) Remove(identifiers ...string) {
 // remove removes the named entry from the cache.
 // invariant: cc.values should be sorted on entry.
 func (cc *genericResourceCache) remove(identifier string) {
+	cc.index.remove(identifier)
+
 	i := sort.Search(len(cc.values), func(i int) bool { return cc.values[i].Identifier >= identifier })
 	if i < len(cc.values) && cc.values[i].Identifier == identifier {
 		// c is present, remove
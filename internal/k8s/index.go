@@ -0,0 +1,189 @@
+package k8s
+
+import (
+	"sort"
+
+	"github.com/quilla-hq/quilla/internal/policy"
+	"github.com/quilla-hq/quilla/util/image"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IndexedContainer - container (or init container) with already parsed image
+// and policy
+type IndexedContainer struct {
+	Name   string
+	Image  string
+	Ref    *image.Reference
+	Init   bool
+	Policy policy.Policy
+}
+
+// IndexedResource - resource together with its parsed policy and container images.
+// Policy and containers are parsed once per resource version.
+type IndexedResource struct {
+	Resource   *GenericResource
+	Policy     policy.Policy
+	Containers []IndexedContainer
+
+	resourceVersion string
+}
+
+func newIndexedResource(gr *GenericResource, previous *IndexedResource) *IndexedResource {
+	ir := &IndexedResource{
+		Resource:        gr,
+		resourceVersion: gr.GetResourceVersion(),
+	}
+
+	// policy only changes together with the resource
+	if previous != nil && ir.resourceVersion != "" && previous.resourceVersion == ir.resourceVersion {
+		ir.Policy = previous.Policy
+		ir.Containers = previous.Containers
+		return ir
+	}
+
+	ir.Policy = policy.GetPolicyFromLabelsOrAnnotations(gr.GetLabels(), gr.GetAnnotations())
+
+	for _, c := range gr.Containers() {
+		ir.addContainer(c.Name, c.Image, false)
+	}
+	for _, c := range gr.InitContainers() {
+		ir.addContainer(c.Name, c.Image, true)
+	}
+
+	return ir
+}
+
+func (ir *IndexedResource) addContainer(name, img string, init bool) {
+	ref, err := image.Parse(img)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error":     err,
+			"image":     img,
+			"namespace": ir.Resource.Namespace,
+			"name":      ir.Resource.Name,
+		}).Error("k8s.index: failed to parse image")
+		return
+	}
+	ir.Containers = append(ir.Containers, IndexedContainer{
+		Name:   name,
+		Image:  img,
+		Ref:    ref,
+		Init:   init,
+		Policy: policy.GetContainerPolicy(name, ir.Policy, ir.Resource.GetLabels(), ir.Resource.GetAnnotations()),
+	})
+}
+
+// Tracked - checks whether any of the containers has a policy
+func (ir *IndexedResource) Tracked() bool {
+	for _, c := range ir.Containers {
+		if c.Policy.Type() != policy.PolicyTypeNone {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceIndex - secondary index of the cache, repository -> resource identifiers
+type resourceIndex struct {
+	resources    map[string]*IndexedResource
+	repositories map[string]map[string]bool
+}
+
+func (idx *resourceIndex) add(gr *GenericResource) {
+	if idx.resources == nil {
+		idx.resources = make(map[string]*IndexedResource)
+		idx.repositories = make(map[string]map[string]bool)
+	}
+
+	previous := idx.resources[gr.Identifier]
+	if previous != nil {
+		idx.unlink(previous)
+	}
+
+	ir := newIndexedResource(gr, previous)
+	idx.resources[gr.Identifier] = ir
+
+	for _, c := range ir.Containers {
+		repository := c.Ref.Repository()
+		if idx.repositories[repository] == nil {
+			idx.repositories[repository] = make(map[string]bool)
+		}
+		idx.repositories[repository][gr.Identifier] = true
+	}
+}
+
+func (idx *resourceIndex) remove(identifier string) {
+	ir, ok := idx.resources[identifier]
+	if !ok {
+		return
+	}
+	idx.unlink(ir)
+	delete(idx.resources, identifier)
+}
+
+func (idx *resourceIndex) unlink(ir *IndexedResource) {
+	for _, c := range ir.Containers {
+		repository := c.Ref.Repository()
+		delete(idx.repositories[repository], ir.Resource.Identifier)
+		if len(idx.repositories[repository]) == 0 {
+			delete(idx.repositories, repository)
+		}
+	}
+}
+
+// Lookup returns copies of resources that have containers with given repository,
+// sorted by identifier. Returned resources can be modified.
+func (cc *genericResourceCache) Lookup(repository string) []*IndexedResource {
+	cc.Lock()
+	defer cc.Unlock()
+
+	identifiers := make([]string, 0, len(cc.index.repositories[repository]))
+	for identifier := range cc.index.repositories[repository] {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+
+	result := make([]*IndexedResource, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		ir := cc.index.resources[identifier]
+		result = append(result, &IndexedResource{
+			Resource:        ir.Resource.DeepCopy(),
+			Policy:          ir.Policy,
+			Containers:      ir.Containers,
+			resourceVersion: ir.resourceVersion,
+		})
+	}
+
+	return result
+}
+
+// Identifiers returns sorted identifiers of resources that have containers with
+// given repository
+func (cc *genericResourceCache) Identifiers(repository string) []string {
+	cc.Lock()
+	defer cc.Unlock()
+
+	identifiers := make([]string, 0, len(cc.index.repositories[repository]))
+	for identifier := range cc.index.repositories[repository] {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+	return identifiers
+}
+
+// Indexed returns all indexed resources, sorted by identifier. Resources are
+// not copied and must be treated as read-only.
+func (cc *genericResourceCache) Indexed() []*IndexedResource {
+	cc.Lock()
+	defer cc.Unlock()
+
+	result := make([]*IndexedResource, 0, len(cc.values))
+	for _, v := range cc.values {
+		if ir, ok := cc.index.resources[v.Identifier]; ok {
+			result = append(result, ir)
+		}
+	}
+
+	return result
+}
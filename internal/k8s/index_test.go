@@ -0,0 +1,160 @@
+package k8s
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/quilla-hq/quilla/types"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testingDeployment(name, resourceVersion, policy string, images ...string) *GenericResource {
+	var containers []core_v1.Container
+	for idx, img := range images {
+		containers = append(containers, core_v1.Container{
+			Name:  fmt.Sprintf("container-%d", idx),
+			Image: img,
+		})
+	}
+
+	gr, _ := NewGenericResource(&apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			ResourceVersion: resourceVersion,
+			Annotations:     map[string]string{types.QuillaPolicyLabel: policy},
+			Labels:          map[string]string{},
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Template: core_v1.PodTemplateSpec{
+				Spec: core_v1.PodSpec{
+					Containers: containers,
+				},
+			},
+		},
+	})
+	return gr
+}
+
+func TestLookup(t *testing.T) {
+	cc := &GenericResourceCache{}
+
+	cc.Add(
+		testingDeployment("dep-1", "1", "all", "gcr.io/v2-namespace/hi-world:1.1.1"),
+		testingDeployment("dep-2", "1", "all", "gcr.io/v2-namespace/hi-world:1.1.1", "karolisr/webhook-demo:0.0.1"),
+		testingDeployment("dep-3", "1", "all", "karolisr/webhook-demo:0.0.1"),
+	)
+
+	found := cc.Lookup("gcr.io/v2-namespace/hi-world")
+	if len(found) != 2 {
+		t.Fatalf("expected 2 resources, got: %d", len(found))
+	}
+	if found[0].Resource.Name != "dep-1" || found[1].Resource.Name != "dep-2" {
+		t.Errorf("unexpected resources: %s, %s", found[0].Resource.Name, found[1].Resource.Name)
+	}
+
+	found = cc.Lookup("index.docker.io/karolisr/webhook-demo")
+	if len(found) != 2 {
+		t.Fatalf("expected 2 resources, got: %d", len(found))
+	}
+	if identifiers := cc.Identifiers("index.docker.io/karolisr/webhook-demo"); len(identifiers) != 2 || identifiers[0] != found[0].Resource.Identifier {
+		t.Errorf("unexpected identifiers: %v", identifiers)
+	}
+
+	// dep-2 stops using hi-world
+	cc.Add(testingDeployment("dep-2", "2", "all", "karolisr/webhook-demo:0.0.1"))
+	found = cc.Lookup("gcr.io/v2-namespace/hi-world")
+	if len(found) != 1 || found[0].Resource.Name != "dep-1" {
+		t.Errorf("expected only dep-1 to use hi-world")
+	}
+
+	cc.Remove(found[0].Resource.Identifier)
+	if len(cc.Lookup("gcr.io/v2-namespace/hi-world")) != 0 {
+		t.Errorf("expected no resources to use hi-world")
+	}
+	if len(cc.Indexed()) != 2 {
+		t.Errorf("expected 2 indexed resources, got: %d", len(cc.Indexed()))
+	}
+}
+
+func TestLookupReturnsCopies(t *testing.T) {
+	cc := &GenericResourceCache{}
+	cc.Add(testingDeployment("dep-1", "1", "all", "gcr.io/v2-namespace/hi-world:1.1.1"))
+
+	found := cc.Lookup("gcr.io/v2-namespace/hi-world")
+	found[0].Resource.UpdateContainer(0, "gcr.io/v2-namespace/hi-world:2.2.2")
+
+	stored := cc.Lookup("gcr.io/v2-namespace/hi-world")[0]
+	if stored.Resource.Containers()[0].Image != "gcr.io/v2-namespace/hi-world:1.1.1" {
+		t.Errorf("cached entry got modified: %s", stored.Resource.Containers()[0].Image)
+	}
+}
+
+func TestIndexedPolicyPerResourceVersion(t *testing.T) {
+	cc := &GenericResourceCache{}
+	cc.Add(testingDeployment("dep-1", "1", "major", "gcr.io/v2-namespace/hi-world:1.1.1"))
+
+	first := cc.Indexed()[0].Policy
+	if first.Name() != "major" {
+		t.Fatalf("unexpected policy: %s", first.Name())
+	}
+
+	// resync, same resource version
+	cc.Add(testingDeployment("dep-1", "1", "major", "gcr.io/v2-namespace/hi-world:1.1.1"))
+	if cc.Indexed()[0].Policy != first {
+		t.Errorf("expected policy to be reused for the same resource version")
+	}
+
+	cc.Add(testingDeployment("dep-1", "2", "minor", "gcr.io/v2-namespace/hi-world:1.1.1"))
+	if cc.Indexed()[0].Policy.Name() != "minor" {
+		t.Errorf("expected policy to be parsed again, got: %s", cc.Indexed()[0].Policy.Name())
+	}
+}
+
+func benchmarkCache(size int) *GenericResourceCache {
+	cc := &GenericResourceCache{}
+	var grs []*GenericResource
+	for i := 0; i < size; i++ {
+		grs = append(grs, testingDeployment(
+			fmt.Sprintf("dep-%d", i), "1", "all",
+			fmt.Sprintf("gcr.io/v2-namespace/app-%d:1.0.0", i),
+			"gcr.io/v2-namespace/sidecar:1.0.0",
+		))
+	}
+	cc.Add(grs...)
+	return cc
+}
+
+func BenchmarkLookup(b *testing.B) {
+	cc := benchmarkCache(5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cc.Lookup("gcr.io/v2-namespace/app-42")
+	}
+}
+
+func BenchmarkValuesScan(b *testing.B) {
+	cc := benchmarkCache(5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range cc.Values() {
+			for _, img := range v.GetImages() {
+				if img == "gcr.io/v2-namespace/app-42:1.0.0" {
+					break
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkAddResync(b *testing.B) {
+	cc := benchmarkCache(5000)
+	gr := testingDeployment("dep-42", "1", "all", "gcr.io/v2-namespace/app-42:1.0.0")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cc.Add(gr)
+	}
+}
@@ -0,0 +1,140 @@
+package k8s
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/quilla-hq/quilla/types"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	batch_v1 "k8s.io/api/batch/v1"
+	core_v1 "k8s.io/api/core/v1"
+)
+
+// quilla labels that can be moved to annotations through the API,
+// they are removed when they are not present on the resource
+var removableLabels = []string{
+	types.QuillaPolicyLabel,
+	types.QuillaTriggerLabel,
+	types.QuillaMinimumApprovalsLabel,
+}
+
+// changeCauseAnnotation - annotation used by kubectl rollout history
+const changeCauseAnnotation = "kubernetes.io/change-cause"
+
+func isQuillaKey(key string) bool {
+	return strings.HasPrefix(key, "quilla.sh/") || strings.HasPrefix(key, "quilla.observer/")
+}
+
+// StrategicMergePatch returns a strategic merge patch that only touches
+// images of the changed containers, quilla labels and quilla annotations so fields
+// owned by other controllers (ie: replicas managed by HPA, resources managed by
+// VPA) and images changed by others since the resource was read are left untouched
+func (r *GenericResource) StrategicMergePatch(changes types.ContainerChanges) ([]byte, error) {
+	labels := map[string]interface{}{}
+	for k, v := range r.GetLabels() {
+		if isQuillaKey(k) {
+			labels[k] = v
+		}
+	}
+	for _, k := range removableLabels {
+		if _, ok := labels[k]; !ok {
+			labels[k] = nil
+		}
+	}
+
+	annotations := map[string]interface{}{}
+	for k, v := range r.GetAnnotations() {
+		if isQuillaKey(k) || k == changeCauseAnnotation {
+			annotations[k] = v
+		}
+	}
+	for _, k := range r.quillaAnnotations {
+		if _, ok := annotations[k]; !ok {
+			annotations[k] = nil
+		}
+	}
+
+	templateAnnotations := map[string]interface{}{}
+	for k, v := range r.GetSpecAnnotations() {
+		if isQuillaKey(k) {
+			templateAnnotations[k] = v
+		}
+	}
+
+	podSpec := map[string]interface{}{}
+	if containers := containerImages(r.Containers(), changes, false); len(containers) > 0 {
+		podSpec["containers"] = containers
+	}
+	if initContainers := containerImages(r.InitContainers(), changes, true); len(initContainers) > 0 {
+		podSpec["initContainers"] = initContainers
+	}
+
+	template := map[string]interface{}{}
+	if len(podSpec) > 0 {
+		template["spec"] = podSpec
+	}
+	if len(templateAnnotations) > 0 {
+		template["metadata"] = map[string]interface{}{
+			"annotations": templateAnnotations,
+		}
+	}
+
+	if len(template) == 0 {
+		return json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels":      labels,
+				"annotations": annotations,
+			},
+		})
+	}
+
+	var spec map[string]interface{}
+	switch r.obj.(type) {
+	case *apps_v1.Deployment, *apps_v1.StatefulSet, *apps_v1.DaemonSet, *batch_v1.Job:
+		spec = map[string]interface{}{
+			"template": template,
+		}
+	case *batch_v1.CronJob:
+		spec = map[string]interface{}{
+			"jobTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"template": template,
+				},
+			},
+		}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      labels,
+			"annotations": annotations,
+		},
+		"spec": spec,
+	})
+}
+
+// containerImages - containers are merged by name, only images of the changed
+// containers are set
+func containerImages(containers []core_v1.Container, changes types.ContainerChanges, init bool) []map[string]interface{} {
+	var patched []map[string]interface{}
+	for _, c := range containers {
+		if !changed(changes, c.Name, init) {
+			continue
+		}
+		patched = append(patched, map[string]interface{}{
+			"name":  c.Name,
+			"image": c.Image,
+		})
+	}
+	return patched
+}
+
+func changed(changes types.ContainerChanges, container string, init bool) bool {
+	for _, change := range changes {
+		if change.Container == container && change.Init == init {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package k8s
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	apps_v1 "k8s.io/api/apps/v1"
@@ -19,6 +20,10 @@ type GenericResource struct {
 	Identifier string
 	Namespace  string
 	Name       string
+
+	// quilla annotations of the resource when it was read, the ones
+	// that were removed since are removed by the patch
+	quillaAnnotations []string
 }
 
 type genericResource []*GenericResource
@@ -54,6 +59,12 @@ func NewGenericResource(obj interface{}) (*GenericResource, error) {
 	gr.Identifier = gr.GetIdentifier()
 	gr.Namespace = gr.GetNamespace()
 	gr.Name = gr.GetName()
+	for k := range gr.GetAnnotations() {
+		if isQuillaKey(k) {
+			gr.quillaAnnotations = append(gr.quillaAnnotations, k)
+		}
+	}
+	sort.Strings(gr.quillaAnnotations)
 
 	return gr, nil
 }
@@ -71,6 +82,7 @@ func (r *GenericResource) DeepCopy() *GenericResource {
 	gr.Identifier = r.Identifier
 	gr.Namespace = r.Namespace
 	gr.Name = r.Name
+	gr.quillaAnnotations = append([]string(nil), r.quillaAnnotations...)
 
 	switch obj := r.obj.(type) {
 	case *apps_v1.Deployment:
@@ -139,6 +151,23 @@ func (r *GenericResource) GetNamespace() string {
 	return ""
 }
 
+// GetResourceVersion returns resource version
+func (r *GenericResource) GetResourceVersion() string {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		return obj.GetResourceVersion()
+	case *apps_v1.StatefulSet:
+		return obj.GetResourceVersion()
+	case *apps_v1.DaemonSet:
+		return obj.GetResourceVersion()
+	case *batch_v1.CronJob:
+		return obj.GetResourceVersion()
+	case *batch_v1.Job:
+		return obj.GetResourceVersion()
+	}
+	return ""
+}
+
 // Kind returns a type of resource that this structure represents
 func (r *GenericResource) Kind() string {
 	switch r.obj.(type) {
@@ -453,3 +482,32 @@ func (r *GenericResource) GetStatus() Status {
 	}
 	return Status{}
 }
+
+// RolloutComplete - returns true once controller observed the latest spec and
+// all replicas were updated and are available. Jobs and CronJobs have no rollout.
+func (r *GenericResource) RolloutComplete() bool {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		replicas := int32(1)
+		if obj.Spec.Replicas != nil {
+			replicas = *obj.Spec.Replicas
+		}
+		return obj.Status.ObservedGeneration >= obj.Generation &&
+			obj.Status.UpdatedReplicas == replicas &&
+			obj.Status.Replicas == replicas &&
+			obj.Status.AvailableReplicas == replicas
+	case *apps_v1.StatefulSet:
+		replicas := int32(1)
+		if obj.Spec.Replicas != nil {
+			replicas = *obj.Spec.Replicas
+		}
+		return obj.Status.ObservedGeneration >= obj.Generation &&
+			obj.Status.UpdatedReplicas == replicas &&
+			obj.Status.ReadyReplicas == replicas
+	case *apps_v1.DaemonSet:
+		return obj.Status.ObservedGeneration >= obj.Generation &&
+			obj.Status.UpdatedNumberScheduled == obj.Status.DesiredNumberScheduled &&
+			obj.Status.NumberAvailable == obj.Status.DesiredNumberScheduled
+	}
+	return true
+}
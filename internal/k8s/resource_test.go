@@ -235,3 +235,38 @@ func TestDaemonsetlSetMultipleContainers(t *testing.T) {
 		t.Errorf("unexpected image: %s", updated.Spec.Template.Spec.Containers[0].Image)
 	}
 }
+
+func TestDeploymentRolloutComplete(t *testing.T) {
+	replicas := int32(2)
+	d := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "dep-1", Namespace: "xxxx", Generation: 2},
+		Spec:       apps_v1.DeploymentSpec{Replicas: &replicas},
+		Status: apps_v1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           2,
+			UpdatedReplicas:    2,
+			AvailableReplicas:  2,
+		},
+	}
+
+	gr, err := NewGenericResource(d)
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+	if gr.RolloutComplete() {
+		t.Errorf("rollout shouldn't be complete before new generation is observed")
+	}
+
+	d.Status.ObservedGeneration = 2
+	d.Status.UpdatedReplicas = 1
+	d.Status.Replicas = 3
+	if gr.RolloutComplete() {
+		t.Errorf("rollout shouldn't be complete while old replicas are running")
+	}
+
+	d.Status.UpdatedReplicas = 2
+	d.Status.Replicas = 2
+	if !gr.RolloutComplete() {
+		t.Errorf("expected rollout to be complete")
+	}
+}
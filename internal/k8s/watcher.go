@@ -12,7 +12,9 @@ import (
 	batch_v1 "k8s.io/api/batch/v1"
 
 	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
@@ -38,7 +40,21 @@ func WatchCronJobs(g *workgroup.Group, client *kubernetes.Clientset, log logrus.
 	watch(g, client.BatchV1().RESTClient(), log, "cronjobs", new(batch_v1.CronJob), rs...)
 }
 
+// WatchHelmReleaseSecrets creates a SharedInformer for v1.Secrets that are created by helm secrets storage driver
+// and registers it with g. Returned function reports whether informer has synced.
+func WatchHelmReleaseSecrets(g *workgroup.Group, client *kubernetes.Clientset, log logrus.FieldLogger, rs ...cache.ResourceEventHandler) cache.InformerSynced {
+	return watchFiltered(g, client.CoreV1().RESTClient(), log, "secrets", new(v1.Secret), func(options *meta_v1.ListOptions) {
+		options.LabelSelector = labels.Set{"owner": "helm"}.String()
+	}, rs...)
+}
+
 func watch(g *workgroup.Group, c cache.Getter, log logrus.FieldLogger, resource string, objType runtime.Object, rs ...cache.ResourceEventHandler) {
+	watchFiltered(g, c, log, resource, objType, func(options *meta_v1.ListOptions) {
+		options.FieldSelector = fields.Everything().String()
+	}, rs...)
+}
+
+func watchFiltered(g *workgroup.Group, c cache.Getter, log logrus.FieldLogger, resource string, objType runtime.Object, optionsModifier func(options *meta_v1.ListOptions), rs ...cache.ResourceEventHandler) cache.InformerSynced {
 	//Check if the env var RESTRICTED_NAMESPACE is empty or equal to quilla
 	// If equal to quilla or empty, the scan will be over all the cluster
 	// If RESTRICTED_NAMESPACE is different than quilla or empty, quilla will scan in the defined namespace
@@ -51,7 +67,7 @@ func watch(g *workgroup.Group, c cache.Getter, log logrus.FieldLogger, resource
 		namespaceScan = os.Getenv(constants.EnvRestrictedNamespace)
 	}
 
-	lw := cache.NewListWatchFromClient(c, resource, namespaceScan, fields.Everything())
+	lw := cache.NewFilteredListWatchFromClient(c, resource, namespaceScan, optionsModifier)
 	sw := cache.NewSharedInformer(lw, objType, 30*time.Minute)
 	for _, r := range rs {
 		sw.AddEventHandler(r)
@@ -62,6 +78,8 @@ func watch(g *workgroup.Group, c cache.Getter, log logrus.FieldLogger, resource
 		defer log.Println("stopped")
 		sw.Run(stop)
 	})
+
+	return sw.HasSynced
 }
 
 type buffer struct {
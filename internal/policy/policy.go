@@ -45,6 +45,42 @@ func GetPolicyFromLabelsOrAnnotations(labels map[string]string, annotations map[
 	return GetPolicy(policyNameL, &Options{MatchTag: getMatchTag(labels), MatchPreRelease: getMatchPreRelease(labels)})
 }
 
+// GetContainerPolicy - gets policy for a specific container. Per container policy
+// (quilla.sh/policy.<container>) overrides resource policy, containers that are not
+// listed in quilla.sh/containers (when it's set) are never updated.
+func GetContainerPolicy(container string, resourcePolicy Policy, labels map[string]string, annotations map[string]string) Policy {
+	containers := types.ParseContainers(annotations)
+	if len(containers) == 0 {
+		containers = types.ParseContainers(labels)
+	}
+	if len(containers) > 0 && !contains(containers, container) {
+		return &NilPolicy{}
+	}
+
+	key := types.QuillaContainerPolicyPrefix + container
+
+	policyNameA, ok := annotations[key]
+	if ok {
+		return GetPolicy(policyNameA, &Options{MatchTag: getMatchTag(annotations), MatchPreRelease: getMatchPreRelease(annotations)})
+	}
+
+	policyNameL, ok := labels[key]
+	if ok {
+		return GetPolicy(policyNameL, &Options{MatchTag: getMatchTag(labels), MatchPreRelease: getMatchPreRelease(labels)})
+	}
+
+	return resourcePolicy
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // Options - additional options when parsing policy
 type Options struct {
 	MatchTag        bool
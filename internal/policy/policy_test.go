@@ -146,3 +146,83 @@ func TestGetPolicyFromLabelsOrAnnotations(t *testing.T) {
 		})
 	}
 }
+
+func TestGetContainerPolicy(t *testing.T) {
+	resourcePolicy := NewSemverPolicy(SemverPolicyTypeAll, true)
+
+	type args struct {
+		container   string
+		labels      map[string]string
+		annotations map[string]string
+	}
+	tests := []struct {
+		name string
+		args args
+		want Policy
+	}{
+		{
+			name: "resource policy",
+			args: args{
+				container:   "app",
+				annotations: map[string]string{types.QuillaPolicyLabel: "all"},
+			},
+			want: resourcePolicy,
+		},
+		{
+			name: "container policy overrides resource policy",
+			args: args{
+				container: "worker",
+				annotations: map[string]string{
+					types.QuillaPolicyLabel:                      "all",
+					types.QuillaContainerPolicyPrefix + "worker": "minor",
+				},
+			},
+			want: NewSemverPolicy(SemverPolicyTypeMinor, true),
+		},
+		{
+			name: "container policy in labels",
+			args: args{
+				container: "worker",
+				labels:    map[string]string{types.QuillaContainerPolicyPrefix + "worker": "patch"},
+			},
+			want: NewSemverPolicy(SemverPolicyTypePatch, true),
+		},
+		{
+			name: "other container policy",
+			args: args{
+				container:   "app",
+				annotations: map[string]string{types.QuillaContainerPolicyPrefix + "worker": "minor"},
+			},
+			want: resourcePolicy,
+		},
+		{
+			name: "container not listed",
+			args: args{
+				container: "sidecar",
+				annotations: map[string]string{
+					types.QuillaPolicyLabel:          "all",
+					types.QuillaContainersAnnotation: "app, worker",
+				},
+			},
+			want: &NilPolicy{},
+		},
+		{
+			name: "container listed",
+			args: args{
+				container: "worker",
+				annotations: map[string]string{
+					types.QuillaPolicyLabel:          "all",
+					types.QuillaContainersAnnotation: "app, worker",
+				},
+			},
+			want: resourcePolicy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetContainerPolicy(tt.args.container, resourcePolicy, tt.args.labels, tt.args.annotations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetContainerPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
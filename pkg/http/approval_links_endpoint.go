@@ -0,0 +1,215 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/quilla-hq/quilla/approvals"
+	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// approvalLinkTemplate - confirmation page of approval links, votes are only cast
+// with POST so links opened by mail scanners don't vote
+var approvalLinkTemplate = template.Must(template.New("approval-link").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{ .Title }}</title></head>
+<body>
+<h2>{{ .Title }}</h2>
+<p>{{ .Message }}</p>
+{{- if .Approval }}
+<table>
+<tr><td>Identifier</td><td>{{ .Approval.Identifier }}</td></tr>
+<tr><td>Delta</td><td>{{ .Approval.Delta }}</td></tr>
+<tr><td>Votes</td><td>{{ .Approval.VotesReceived }}/{{ .Approval.VotesRequired }}</td></tr>
+</table>
+{{- if .Diff }}
+<pre>{{ .Diff }}</pre>
+{{- end }}
+{{- end }}
+{{- if .Confirm }}
+<form method="POST">
+<p><label for="comment">Comment (optional)</label></p>
+<p><textarea id="comment" name="comment" rows="3" cols="60" maxlength="500"></textarea></p>
+<button type="submit">{{ .Confirm }}</button>
+</form>
+{{- end }}
+</body>
+</html>
+`))
+
+type approvalLinkPage struct {
+	Title    string
+	Message  string
+	Approval *types.Approval
+	Diff     string
+	Confirm  string
+}
+
+// approvalLinkHandler - confirmation page of the approval link
+func (s *TriggerServer) approvalLinkHandler(resp http.ResponseWriter, req *http.Request) {
+	claims, approval, ok := s.verifyApprovalLink(resp, req)
+	if !ok {
+		return
+	}
+
+	confirm := "Approve update"
+	if claims.Action == approvals.LinkActionReject {
+		confirm = "Reject update"
+	}
+	renderApprovalLinkPage(resp, http.StatusOK, &approvalLinkPage{
+		Title:    confirm + "?",
+		Message:  fmt.Sprintf("%s, you are voting on: %s", claims.Recipient(), approval.Message),
+		Approval: approval,
+		Diff:     approval.Plan.Diff(),
+		Confirm:  confirm,
+	})
+}
+
+// approvalLinkVoteHandler - records vote of the link recipient, link can't be used again once the vote is recorded
+func (s *TriggerServer) approvalLinkVoteHandler(resp http.ResponseWriter, req *http.Request) {
+	claims, approval, ok := s.verifyApprovalLink(resp, req)
+	if !ok {
+		return
+	}
+
+	comment := strings.TrimSpace(req.FormValue("comment"))
+	if len(comment) > maxCommentLength {
+		renderApprovalLinkError(resp, http.StatusBadRequest, fmt.Sprintf("Comment can't be longer than %d characters.", maxCommentLength))
+		return
+	}
+
+	// token is recorded together with the vote, link stays usable if voting fails
+	token := &types.LinkToken{
+		ID:         claims.Id,
+		ApprovalID: claims.ApprovalID,
+		Action:     claims.Action,
+		Recipient:  claims.Recipient(),
+		ExpiresAt:  time.Unix(claims.ExpiresAt, 0),
+	}
+	voter := &types.Voter{Name: claims.Recipient()}
+	var err error
+	switch claims.Action {
+	case approvals.LinkActionReject:
+		approval, err = s.approvalsManager.RejectWithLinkToken(approval.Identifier, voter, comment, token)
+	default:
+		approval, err = s.approvalsManager.ApproveWithLinkToken(approval.Identifier, voter, comment, token)
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrTokenUsed) {
+			renderApprovalLinkError(resp, http.StatusGone, "This link was already used.")
+			return
+		}
+		if errors.Is(err, approvals.ErrIneligibleVoter) {
+			renderApprovalLinkError(resp, http.StatusForbidden, fmt.Sprintf("Your vote was rejected: %s.", err))
+			return
+		}
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": claims.ApprovalID,
+		}).Error("http.approvalLinkVoteHandler: failed to vote")
+		renderApprovalLinkError(resp, http.StatusInternalServerError, "Failed to record the vote, try again later.")
+		return
+	}
+
+	s.auditApprovalLink(claims, approval)
+
+	renderApprovalLinkPage(resp, http.StatusOK, &approvalLinkPage{
+		Title:    "Vote recorded",
+		Message:  fmt.Sprintf("Thanks %s, update is %s.", claims.Recipient(), approval.Status()),
+		Approval: approval,
+	})
+}
+
+// verifyApprovalLink - link has to be signed, unused and the approval still pending
+func (s *TriggerServer) verifyApprovalLink(resp http.ResponseWriter, req *http.Request) (*approvals.LinkClaims, *types.Approval, bool) {
+	claims, err := s.approvalLinks.Verify(mux.Vars(req)["token"])
+	if err != nil {
+		if err == approvals.ErrExpiredLink {
+			renderApprovalLinkError(resp, http.StatusGone, "This link has expired.")
+			return nil, nil, false
+		}
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("http.verifyApprovalLink: invalid approval link")
+		renderApprovalLinkError(resp, http.StatusForbidden, "This link is not valid.")
+		return nil, nil, false
+	}
+
+	_, err = s.store.GetLinkToken(claims.Id)
+	if err == nil {
+		renderApprovalLinkError(resp, http.StatusGone, "This link was already used.")
+		return nil, nil, false
+	}
+	if err != store.ErrRecordNotFound {
+		renderApprovalLinkError(resp, http.StatusInternalServerError, "Failed to verify the link, try again later.")
+		return nil, nil, false
+	}
+
+	approval, err := s.store.GetApproval(&types.GetApprovalQuery{ID: claims.ApprovalID})
+	if err != nil {
+		if err == store.ErrRecordNotFound {
+			renderApprovalLinkError(resp, http.StatusNotFound, "Approval doesn't exist anymore.")
+			return nil, nil, false
+		}
+		renderApprovalLinkError(resp, http.StatusInternalServerError, "Failed to get the approval, try again later.")
+		return nil, nil, false
+	}
+	if approval.Archived || approval.Status() != types.ApprovalStatusPending {
+		renderApprovalLinkError(resp, http.StatusConflict, fmt.Sprintf("Update %s is already %s.", approval.Identifier, approval.Status()))
+		return nil, nil, false
+	}
+
+	return claims, approval, true
+}
+
+func (s *TriggerServer) auditApprovalLink(claims *approvals.LinkClaims, approval *types.Approval) {
+	entry := &types.AuditLog{
+		AccountID:    claims.Recipient(),
+		Username:     claims.Recipient(),
+		Action:       types.AuditActionApprovalLinkUsed,
+		ResourceKind: types.AuditResourceKindApproval,
+		Identifier:   approval.Identifier,
+		Message:      fmt.Sprintf("%s via email link", claims.Action),
+	}
+
+	entry.SetMetadata(map[string]string{
+		"approval_id": approval.ID,
+		"action":      claims.Action,
+		"token_id":    claims.Id,
+	})
+
+	_, err := s.store.CreateAuditLog(entry)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": approval.Identifier,
+		}).Error("http.auditApprovalLink: failed to create audit log")
+	}
+}
+
+func renderApprovalLinkError(resp http.ResponseWriter, status int, message string) {
+	renderApprovalLinkPage(resp, status, &approvalLinkPage{
+		Title:   "Can't vote",
+		Message: message,
+	})
+}
+
+func renderApprovalLinkPage(resp http.ResponseWriter, status int, page *approvalLinkPage) {
+	resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	resp.WriteHeader(status)
+	err := approvalLinkTemplate.Execute(resp, page)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("http.renderApprovalLinkPage: failed to render page")
+	}
+}
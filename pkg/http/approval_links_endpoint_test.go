@@ -0,0 +1,283 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/approvals"
+	"github.com/quilla-hq/quilla/pkg/auth"
+	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/provider"
+	"github.com/quilla-hq/quilla/types"
+)
+
+func newApprovalLinksServer(t *testing.T) (*TriggerServer, *approvals.LinkSigner, func()) {
+	store, teardown := NewTestingUtils()
+
+	am := approvals.New(&approvals.Opts{
+		Store: store,
+	})
+
+	authenticator := auth.New(&auth.Opts{
+		Username: "admin",
+		Password: "pass",
+	}, DefaultIssuerMap())
+
+	signer := approvals.NewLinkSigner([]byte("link-secret"), time.Hour)
+	srv := NewTriggerServer(&Opts{
+		Providers:       provider.New([]provider.Provider{&fakeProvider{}}, am),
+		ApprovalManager: am,
+		Authenticator:   authenticator,
+		Store:           store,
+		ApprovalLinks:   signer,
+	})
+	srv.registerRoutes(srv.router)
+
+	err := am.Create(&types.Approval{
+		Identifier:     "xxx/app-1",
+		VotesRequired:  2,
+		NewVersion:     "2.0.0",
+		CurrentVersion: "1.0.0",
+		Deadline:       time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	return srv, signer, teardown
+}
+
+func serveApprovalLink(srv *TriggerServer, method, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/v1/approvals/links/"+token, nil)
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestApprovalLinkApprove(t *testing.T) {
+	srv, signer, teardown := newApprovalLinksServer(t)
+	defer teardown()
+
+	approval, err := srv.approvalsManager.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+
+	token, err := signer.Sign(approval, approvals.LinkActionApprove, "jane@example.com")
+	if err != nil {
+		t.Fatalf("failed to sign link: %s", err)
+	}
+
+	// opening the link doesn't vote
+	rec := serveApprovalLink(srv, "GET", token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `<form method="POST">`) {
+		t.Errorf("expected confirmation form, got: %s", rec.Body.String())
+	}
+
+	approval, err = srv.approvalsManager.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.VotesReceived != 0 {
+		t.Fatalf("expected no votes after opening the link, got: %d", approval.VotesReceived)
+	}
+
+	rec = serveApprovalLink(srv, "POST", token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	approval, err = srv.approvalsManager.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.VotesReceived != 1 {
+		t.Errorf("expected 1 vote, got: %d", approval.VotesReceived)
+	}
+	voters := approval.GetVoters()
+	if len(voters) != 1 || voters[0] != "jane@example.com" {
+		t.Errorf("expected vote from recipient, got: %v", voters)
+	}
+
+	logs, err := srv.store.GetAuditLogs(&types.AuditLogQuery{
+		Username:           "jane@example.com",
+		ResourceKindFilter: []string{types.AuditResourceKindApproval},
+	})
+	if err != nil {
+		t.Fatalf("failed to get audit logs: %s", err)
+	}
+	var audited bool
+	for _, entry := range logs {
+		if entry.Action == types.AuditActionApprovalLinkUsed && entry.Identifier == "xxx/app-1" {
+			audited = true
+		}
+	}
+	if !audited {
+		t.Errorf("expected link use to be audited under recipient")
+	}
+
+	// links are single-use
+	rec = serveApprovalLink(srv, "POST", token)
+	if rec.Code != http.StatusGone {
+		t.Errorf("expected reused link to be refused, got: %d", rec.Code)
+	}
+	rec = serveApprovalLink(srv, "GET", token)
+	if rec.Code != http.StatusGone {
+		t.Errorf("expected reused link to be refused, got: %d", rec.Code)
+	}
+}
+
+func TestApprovalLinkReject(t *testing.T) {
+	srv, signer, teardown := newApprovalLinksServer(t)
+	defer teardown()
+
+	approval, err := srv.approvalsManager.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+
+	rejectToken, err := signer.Sign(approval, approvals.LinkActionReject, "bob@example.com")
+	if err != nil {
+		t.Fatalf("failed to sign link: %s", err)
+	}
+	approveToken, err := signer.Sign(approval, approvals.LinkActionApprove, "bob@example.com")
+	if err != nil {
+		t.Fatalf("failed to sign link: %s", err)
+	}
+
+	req := httptest.NewRequest("POST", "/v1/approvals/links/"+rejectToken, strings.NewReader(url.Values{"comment": {"breaks checkout"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	approval, err = srv.approvalsManager.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if !approval.Rejected {
+		t.Errorf("expected approval to be rejected")
+	}
+	if len(approval.Rejections) != 1 || approval.Rejections[0].Voter != "bob@example.com" || approval.Rejections[0].Comment != "breaks checkout" {
+		t.Errorf("expected rejection by link recipient with comment, got: %v", approval.Rejections)
+	}
+
+	// approval isn't pending anymore
+	rec = serveApprovalLink(srv, "POST", approveToken)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected link of rejected approval to be refused, got: %d", rec.Code)
+	}
+}
+
+func TestApprovalLinkIneligibleVoteKeepsLink(t *testing.T) {
+	srv, signer, teardown := newApprovalLinksServer(t)
+	defer teardown()
+
+	approval, err := srv.approvalsManager.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	approval.Author = "jane@example.com"
+	err = srv.approvalsManager.Update(approval)
+	if err != nil {
+		t.Fatalf("failed to update approval: %s", err)
+	}
+
+	token, err := signer.Sign(approval, approvals.LinkActionApprove, "jane@example.com")
+	if err != nil {
+		t.Fatalf("failed to sign link: %s", err)
+	}
+
+	rec := serveApprovalLink(srv, "POST", token)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected author's vote to be refused, got: %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	// refused vote doesn't use up the link
+	claims, err := signer.Verify(token)
+	if err != nil {
+		t.Fatalf("failed to verify link: %s", err)
+	}
+	_, err = srv.store.GetLinkToken(claims.Id)
+	if err != store.ErrRecordNotFound {
+		t.Fatalf("expected link token to stay unused, got: %v", err)
+	}
+
+	approval, err = srv.approvalsManager.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	approval.Author = "bob@example.com"
+	err = srv.approvalsManager.Update(approval)
+	if err != nil {
+		t.Fatalf("failed to update approval: %s", err)
+	}
+
+	rec = serveApprovalLink(srv, "POST", token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+	}
+	rec = serveApprovalLink(srv, "POST", token)
+	if rec.Code != http.StatusGone {
+		t.Errorf("expected reused link to be refused, got: %d", rec.Code)
+	}
+}
+
+func TestApprovalLinkInvalid(t *testing.T) {
+	srv, _, teardown := newApprovalLinksServer(t)
+	defer teardown()
+
+	approval, err := srv.approvalsManager.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+
+	token, err := approvals.NewLinkSigner([]byte("other-secret"), time.Hour).Sign(approval, approvals.LinkActionApprove, "jane@example.com")
+	if err != nil {
+		t.Fatalf("failed to sign link: %s", err)
+	}
+
+	rec := serveApprovalLink(srv, "POST", token)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected tampered link to be refused, got: %d", rec.Code)
+	}
+
+	approval, err = srv.approvalsManager.Get("xxx/app-1")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.VotesReceived != 0 {
+		t.Errorf("expected no votes, got: %d", approval.VotesReceived)
+	}
+}
+
+func TestApprovalLinksDisabled(t *testing.T) {
+	fp := &fakeProvider{}
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := approvals.New(&approvals.Opts{
+		Store: store,
+	})
+	srv := NewTriggerServer(&Opts{
+		Providers:       provider.New([]provider.Provider{fp}, am),
+		ApprovalManager: am,
+		Authenticator:   auth.New(&auth.Opts{Username: "admin", Password: "pass"}, DefaultIssuerMap()),
+		Store:           store,
+	})
+	srv.registerRoutes(srv.router)
+
+	rec := serveApprovalLink(srv, "POST", "token")
+	if rec.Code == http.StatusOK {
+		t.Errorf("expected approval links to be disabled")
+	}
+}
@@ -2,12 +2,18 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/quilla-hq/quilla/approvals"
 	"github.com/quilla-hq/quilla/pkg/auth"
 	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/provider/helm3"
 	"github.com/quilla-hq/quilla/types"
 )
 
@@ -15,8 +21,13 @@ type approveRequest struct {
 	ID         string `json:"id"`
 	Identifier string `json:"identifier"`
 	Action     string `json:"action"` // defaults to approve
+	// Comment - optional reason of approval or rejection
+	Comment string `json:"comment"`
 }
 
+// maxCommentLength - longer approval and rejection comments are refused
+const maxCommentLength = 500
+
 // available API actions
 const (
 	actionApprove = "approve"
@@ -25,10 +36,29 @@ const (
 	actionArchive = "archive"
 )
 
+// approvals listing defaults
+const (
+	defaultApprovalsLimit = 100
+	maxApprovalsLimit     = 1000
+)
+
+// approvalSorts - sort query parameter values and columns they sort by,
+// prefixed with "-" for descending order
+var approvalSorts = map[string]string{
+	"updatedAt":  "updated_at",
+	"createdAt":  "created_at",
+	"deadline":   "deadline",
+	"identifier": "identifier",
+}
+
 func (s *TriggerServer) approvalsHandler(resp http.ResponseWriter, req *http.Request) {
+	query, err := approvalsQuery(req.URL.Query())
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// lists all (both archived)
-	approvals, err := s.store.ListApprovals(&types.GetApprovalQuery{})
+	approvals, err := s.store.ListApprovals(query)
 	if err != nil {
 		fmt.Fprintf(resp, "%s", err)
 		resp.WriteHeader(http.StatusInternalServerError)
@@ -39,6 +69,13 @@ func (s *TriggerServer) approvalsHandler(resp http.ResponseWriter, req *http.Req
 		approvals = make([]*types.Approval, 0)
 	}
 
+	total, err := s.store.ApprovalsCount(query)
+	if err != nil {
+		fmt.Fprintf(resp, "%s", err)
+		resp.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	bts, err := json.Marshal(&approvals)
 	if err != nil {
 		fmt.Fprintf(resp, "%s", err)
@@ -46,9 +83,138 @@ func (s *TriggerServer) approvalsHandler(resp http.ResponseWriter, req *http.Req
 		return
 	}
 
+	resp.Header().Set("X-Total-Count", strconv.Itoa(total))
 	resp.Write(bts)
 }
 
+// approvalsQuery - builds approvals query from query parameters, both archived
+// and not archived approvals are listed unless archived is set
+func approvalsQuery(values url.Values) (*types.GetApprovalQuery, error) {
+	query := &types.GetApprovalQuery{
+		Namespace:        values.Get("namespace"),
+		IdentifierPrefix: values.Get("identifierPrefix"),
+		Voter:            values.Get("voter"),
+		Limit:            defaultApprovalsLimit,
+	}
+
+	var err error
+	if v := values.Get("status"); v != "" {
+		query.Status, err = types.ParseApprovalStatus(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if v := values.Get("provider"); v != "" {
+		query.Provider, err = types.ParseProviderType(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if v := values.Get("archived"); v != "" {
+		archived, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid archived %q, expected true or false", v)
+		}
+		query.Archived = archived
+		query.ExcludeArchived = !archived
+	}
+	if v := values.Get("since"); v != "" {
+		query.CreatedAfter, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since %q, expected RFC3339 time", v)
+		}
+	}
+	if v := values.Get("until"); v != "" {
+		query.CreatedBefore, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid until %q, expected RFC3339 time", v)
+		}
+	}
+	if v := values.Get("limit"); v != "" {
+		query.Limit, err = strconv.Atoi(v)
+		if err != nil || query.Limit < 1 || query.Limit > maxApprovalsLimit {
+			return nil, fmt.Errorf("invalid limit %q, expected number between 1 and %d", v, maxApprovalsLimit)
+		}
+	}
+	if v := values.Get("offset"); v != "" {
+		query.Offset, err = strconv.Atoi(v)
+		if err != nil || query.Offset < 0 {
+			return nil, fmt.Errorf("invalid offset %q", v)
+		}
+	}
+	if v := values.Get("sort"); v != "" {
+		column, ok := approvalSorts[strings.TrimPrefix(v, "-")]
+		if !ok {
+			return nil, fmt.Errorf("invalid sort %q, expected updatedAt, createdAt, deadline or identifier", v)
+		}
+		query.Order = column
+		if strings.HasPrefix(v, "-") {
+			query.Order += " desc"
+		}
+	}
+
+	return query, nil
+}
+
+// approvalTimelineHandler - approval history assembled from audit logs
+func (s *TriggerServer) approvalTimelineHandler(resp http.ResponseWriter, req *http.Request) {
+	approval, err := s.store.GetApproval(&types.GetApprovalQuery{ID: getID(req)})
+	if err != nil {
+		if err == store.ErrRecordNotFound {
+			http.Error(resp, fmt.Sprintf("approval '%s' not found", getID(req)), http.StatusNotFound)
+			return
+		}
+		response(nil, http.StatusInternalServerError, err, resp, req)
+		return
+	}
+
+	logs, err := s.store.GetAuditLogs(&types.AuditLogQuery{
+		Identifier:         approval.Identifier,
+		ResourceKindFilter: []string{types.AuditResourceKindApproval},
+		Order:              "created_at",
+	})
+	if err != nil {
+		response(nil, http.StatusInternalServerError, err, resp, req)
+		return
+	}
+
+	response(approvalTimeline(approval, logs), http.StatusOK, nil, resp, req)
+}
+
+// approvalTimeline - identifiers are reused by approvals of the same version,
+// only entries of the approval are kept
+func approvalTimeline(approval *types.Approval, logs []*types.AuditLog) *types.ApprovalTimeline {
+	timeline := &types.ApprovalTimeline{
+		Approval: approval,
+		Entries: []types.ApprovalTimelineEntry{{
+			At:      approval.CreatedAt,
+			Action:  types.AuditActionCreated,
+			Actor:   approval.Author,
+			Message: approval.Message,
+		}},
+	}
+
+	for _, l := range logs {
+		if l.Metadata["approval_id"] != approval.ID {
+			continue
+		}
+		entry := types.ApprovalTimelineEntry{
+			At:     l.CreatedAt,
+			Action: l.Action,
+			Actor:  l.Username,
+		}
+		if comment, ok := l.Metadata["comment"].(string); ok {
+			entry.Comment = comment
+		}
+		if l.Message != entry.Comment {
+			entry.Message = l.Message
+		}
+		timeline.Entries = append(timeline.Entries, entry)
+	}
+
+	return timeline
+}
+
 type resourceApprovalsUpdateRequest struct {
 	Identifier    string `json:"identifier"`
 	Provider      string `json:"provider"`
@@ -75,7 +241,7 @@ func (s *TriggerServer) approvalSetHandler(resp http.ResponseWriter, req *http.R
 	}
 
 	switch approvalUpdateRequest.Provider {
-	case types.ProviderTypeKubernetes.String():
+	case types.ProviderTypeKubernetes.String(), types.ProviderTypeHelm.String():
 		// ok
 	default:
 		http.Error(resp, "unsupported provider", http.StatusBadRequest)
@@ -87,6 +253,15 @@ func (s *TriggerServer) approvalSetHandler(resp http.ResponseWriter, req *http.R
 		return
 	}
 
+	message := fmt.Sprintf("required approvals set to %d", approvalUpdateRequest.VotesRequired)
+
+	if approvalUpdateRequest.Provider == types.ProviderTypeHelm.String() {
+		s.updateReleaseConfig(resp, req, approvalUpdateRequest.Identifier, &helm3.ReleaseConfigUpdate{
+			Approvals: &approvalUpdateRequest.VotesRequired,
+		}, message)
+		return
+	}
+
 	for _, v := range s.grc.Values() {
 		if v.Identifier == approvalUpdateRequest.Identifier {
 
@@ -99,7 +274,10 @@ func (s *TriggerServer) approvalSetHandler(resp http.ResponseWriter, req *http.R
 
 			v.SetAnnotations(ann)
 
-			err := s.kubernetesClient.Update(v)
+			err := s.kubernetesClient.Update(v, nil)
+			if err == nil {
+				s.auditResourceUpdate(req, types.ProviderTypeKubernetes.String(), v.Kind(), v.Identifier, message)
+			}
 
 			response(&APIResponse{Status: "updated"}, 200, err, resp, req)
 			return
@@ -129,17 +307,26 @@ func (s *TriggerServer) approvalApproveHandler(resp http.ResponseWriter, req *ht
 		return
 	}
 
+	if len(ar.Comment) > maxCommentLength {
+		http.Error(resp, fmt.Sprintf("comment can't be longer than %d characters", maxCommentLength), http.StatusBadRequest)
+		return
+	}
+
 	var approval *types.Approval
 
 	// checking action
 	switch ar.Action {
 	case actionReject:
-		approval, err = s.approvalsManager.Reject(ar.Identifier)
+		approval, err = s.approvalsManager.Reject(ar.Identifier, &types.Voter{Name: user.Username, Groups: user.Roles}, ar.Comment)
 		if err != nil {
 			if err == store.ErrRecordNotFound {
 				http.Error(resp, fmt.Sprintf("approval '%s' not found", ar.Identifier), http.StatusNotFound)
 				return
 			}
+			if errors.Is(err, approvals.ErrIneligibleVoter) {
+				http.Error(resp, err.Error(), http.StatusForbidden)
+				return
+			}
 			resp.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprintf(resp, "%s", err)
 			return
@@ -184,12 +371,16 @@ func (s *TriggerServer) approvalApproveHandler(resp http.ResponseWriter, req *ht
 
 	default:
 		// "" or "approve"
-		approval, err = s.approvalsManager.Approve(ar.Identifier, user.Username)
+		approval, err = s.approvalsManager.Approve(ar.Identifier, &types.Voter{Name: user.Username, Groups: user.Roles}, ar.Comment)
 		if err != nil {
 			if err == store.ErrRecordNotFound {
 				http.Error(resp, fmt.Sprintf("approval '%s' not found", ar.Identifier), http.StatusNotFound)
 				return
 			}
+			if errors.Is(err, approvals.ErrIneligibleVoter) {
+				http.Error(resp, err.Error(), http.StatusForbidden)
+				return
+			}
 			resp.WriteHeader(http.StatusInternalServerError)
 			fmt.Fprintf(resp, "%s", err)
 			return
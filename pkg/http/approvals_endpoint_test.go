@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 
@@ -98,6 +99,202 @@ func TestListApprovals(t *testing.T) {
 	}
 }
 
+func newApprovalsServer(t *testing.T) (*TriggerServer, *approvals.DefaultManager, func()) {
+	store, teardown := NewTestingUtils()
+
+	am := approvals.New(&approvals.Opts{
+		Store: store,
+	})
+	authenticator := auth.New(&auth.Opts{
+		Username: "admin",
+		Password: "pass",
+	}, DefaultIssuerMap())
+
+	srv := NewTriggerServer(&Opts{
+		Providers:       provider.New([]provider.Provider{&fakeProvider{}}, am),
+		ApprovalManager: am,
+		Authenticator:   authenticator,
+		Store:           store,
+	})
+	srv.registerRoutes(srv.router)
+
+	return srv, am, teardown
+}
+
+func listApprovals(t *testing.T, srv *TriggerServer, query string) ([]*types.Approval, *httptest.ResponseRecorder) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/v1/approvals"+query, nil)
+	req.SetBasicAuth("admin", "pass")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		return nil, rec
+	}
+
+	var approvals []*types.Approval
+	err := json.Unmarshal(rec.Body.Bytes(), &approvals)
+	if err != nil {
+		t.Fatalf("failed to unmarshal response into approvals: %s", err)
+	}
+	return approvals, rec
+}
+
+func TestListApprovalsFilters(t *testing.T) {
+	srv, am, teardown := newApprovalsServer(t)
+	defer teardown()
+
+	for _, a := range []*types.Approval{
+		{Provider: types.ProviderTypeKubernetes, Identifier: "deployment/default/app-1:1.0.0", Namespace: "default", VotesRequired: 1},
+		{Provider: types.ProviderTypeKubernetes, Identifier: "deployment/default/app-2:1.0.0", Namespace: "default", VotesRequired: 1},
+		{Provider: types.ProviderTypeHelm, Identifier: "payments/api:2.0.0", Namespace: "payments", VotesRequired: 1},
+		{Provider: types.ProviderTypeKubernetes, Identifier: "deployment/default_x/app-3:1.0.0", Namespace: "default_x", VotesRequired: 1},
+		{Provider: types.ProviderTypeHelm, Identifier: "default/web:3.0.0", Namespace: "default", VotesRequired: 1},
+	} {
+		a.NewVersion = "2.0.0"
+		a.CurrentVersion = "1.0.0"
+		a.Deadline = time.Now().Add(time.Hour)
+		err := am.Create(a)
+		if err != nil {
+			t.Fatalf("failed to create approval: %s", err)
+		}
+	}
+	_, err := am.Approve("deployment/default/app-1:1.0.0", &types.Voter{Name: "jane"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+	_, err = am.Reject("payments/api:2.0.0", &types.Voter{Name: "jane_doe"}, "")
+	if err != nil {
+		t.Fatalf("failed to reject: %s", err)
+	}
+	err = am.Archive("deployment/default/app-2:1.0.0")
+	if err != nil {
+		t.Fatalf("failed to archive: %s", err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "?sort=identifier", want: []string{"default/web:3.0.0", "deployment/default/app-1:1.0.0", "deployment/default/app-2:1.0.0", "deployment/default_x/app-3:1.0.0", "payments/api:2.0.0"}},
+		{query: "?status=approved", want: []string{"deployment/default/app-1:1.0.0"}},
+		{query: "?status=rejected", want: []string{"payments/api:2.0.0"}},
+		{query: "?status=pending&sort=identifier", want: []string{"default/web:3.0.0", "deployment/default/app-2:1.0.0", "deployment/default_x/app-3:1.0.0"}},
+		{query: "?provider=helm&sort=identifier", want: []string{"default/web:3.0.0", "payments/api:2.0.0"}},
+		{query: "?namespace=default&sort=identifier", want: []string{"default/web:3.0.0", "deployment/default/app-1:1.0.0", "deployment/default/app-2:1.0.0"}},
+		{query: "?namespace=default_x", want: []string{"deployment/default_x/app-3:1.0.0"}},
+		{query: "?identifierPrefix=deployment/default_&sort=-identifier", want: []string{"deployment/default_x/app-3:1.0.0"}},
+		{query: "?voter=jane", want: []string{"deployment/default/app-1:1.0.0"}},
+		{query: "?voter=jane_doe", want: []string{"payments/api:2.0.0"}},
+		{query: "?archived=true", want: []string{"deployment/default/app-2:1.0.0"}},
+		{query: "?archived=false&namespace=default&sort=identifier", want: []string{"default/web:3.0.0", "deployment/default/app-1:1.0.0"}},
+		{query: "?since=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339), want: []string{}},
+		{query: "?sort=identifier&limit=2&offset=2", want: []string{"deployment/default/app-2:1.0.0", "deployment/default_x/app-3:1.0.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			approvals, rec := listApprovals(t, srv, tt.query)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+			}
+			got := []string{}
+			for _, a := range approvals {
+				got = append(got, a.Identifier)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	_, rec := listApprovals(t, srv, "?limit=1")
+	if rec.Header().Get("X-Total-Count") != "5" {
+		t.Errorf("expected total of all approvals, got: %s", rec.Header().Get("X-Total-Count"))
+	}
+
+	for _, query := range []string{"?status=done", "?provider=nomad", "?limit=0", "?limit=5000", "?sort=votes", "?since=yesterday", "?archived=maybe"} {
+		_, rec := listApprovals(t, srv, query)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected bad request, got: %d", query, rec.Code)
+		}
+	}
+}
+
+func TestApprovalTimeline(t *testing.T) {
+	srv, am, teardown := newApprovalsServer(t)
+	defer teardown()
+
+	for i := 0; i < 2; i++ {
+		err := am.Create(&types.Approval{
+			Provider:       types.ProviderTypeKubernetes,
+			Identifier:     "default/app-1:2.0.0",
+			VotesRequired:  2,
+			NewVersion:     "2.0.0",
+			CurrentVersion: "1.0.0",
+			Deadline:       time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("failed to create approval: %s", err)
+		}
+		if i == 0 {
+			// previous approval of the same version
+			err = am.Archive("default/app-1:2.0.0")
+			if err != nil {
+				t.Fatalf("failed to archive: %s", err)
+			}
+		}
+	}
+
+	_, err := am.Approve("default/app-1:2.0.0", &types.Voter{Name: "jane"}, "looks good")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+	approval, err := am.Reject("default/app-1:2.0.0", &types.Voter{Name: "bob"}, "breaks checkout")
+	if err != nil {
+		t.Fatalf("failed to reject: %s", err)
+	}
+
+	req := httptest.NewRequest("GET", "/v1/approvals/"+approval.ID+"/timeline", nil)
+	req.SetBasicAuth("admin", "pass")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	var timeline types.ApprovalTimeline
+	err = json.Unmarshal(rec.Body.Bytes(), &timeline)
+	if err != nil {
+		t.Fatalf("failed to unmarshal timeline: %s", err)
+	}
+	if timeline.Approval == nil || timeline.Approval.ID != approval.ID {
+		t.Fatalf("unexpected approval: %v", timeline.Approval)
+	}
+
+	want := []types.ApprovalTimelineEntry{
+		{Action: types.AuditActionCreated},
+		{Action: types.AuditActionApprovalApproved, Actor: "jane", Comment: "looks good"},
+		{Action: types.AuditActionApprovalRejected, Actor: "bob", Comment: "breaks checkout"},
+	}
+	if len(timeline.Entries) != len(want) {
+		t.Fatalf("expected %d entries, got: %v", len(want), timeline.Entries)
+	}
+	for i, entry := range timeline.Entries {
+		entry.At = time.Time{}
+		entry.Message = ""
+		if entry != want[i] {
+			t.Errorf("entry %d: expected %v, got %v", i, want[i], entry)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/v1/approvals/missing/timeline", nil)
+	req.SetBasicAuth("admin", "pass")
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected missing approval to be not found, got: %d", rec.Code)
+	}
+}
+
 func TestDeleteApproval(t *testing.T) {
 	fp := &fakeProvider{}
 	store, teardown := NewTestingUtils()
@@ -399,7 +596,7 @@ func TestReject(t *testing.T) {
 	}
 
 	// listing
-	req, err := http.NewRequest("POST", "/v1/approvals", bytes.NewBufferString(`{"voter": "foo", "action": "reject", "identifier":"dev/12345"}`))
+	req, err := http.NewRequest("POST", "/v1/approvals", bytes.NewBufferString(`{"voter": "foo", "action": "reject", "identifier":"dev/12345", "comment": "breaks checkout"}`))
 	if err != nil {
 		t.Fatalf("failed to create req: %s", err)
 	}
@@ -424,6 +621,9 @@ func TestReject(t *testing.T) {
 		t.Errorf("expected to find approval rejected")
 	}
 
+	if len(approved.Rejections) != 1 || approved.Rejections[0].Voter != "admin" || approved.Rejections[0].Comment != "breaks checkout" {
+		t.Errorf("expected rejection by admin with comment, got: %v", approved.Rejections)
+	}
 }
 
 func TestAuthListApprovalsA(t *testing.T) {
@@ -549,3 +749,99 @@ func TestAuthListApprovalsB(t *testing.T) {
 		t.Errorf("unexpected current version: %s", approvals[0].CurrentVersion)
 	}
 }
+
+func TestIneligibleVoter(t *testing.T) {
+	fp := &fakeProvider{}
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := approvals.New(&approvals.Opts{
+		Store: store,
+	})
+	authenticator := auth.New(&auth.Opts{
+		Username: "admin",
+		Password: "pass",
+	}, DefaultIssuerMap())
+
+	providers := provider.New([]provider.Provider{fp}, am)
+	srv := NewTriggerServer(&Opts{
+		Providers:       providers,
+		ApprovalManager: am,
+		Authenticator:   authenticator,
+		Store:           store,
+	})
+	srv.registerRoutes(srv.router)
+
+	err := am.Create(&types.Approval{
+		Identifier:     "dev/12345",
+		VotesRequired:  1,
+		NewVersion:     "2.0.0",
+		CurrentVersion: "1.0.0",
+		Approvers:      types.ApproverRules{{Group: "sre"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	req, err := http.NewRequest("POST", "/v1/approvals", bytes.NewBufferString(`{"identifier": "dev/12345"}`))
+	if err != nil {
+		t.Fatalf("failed to create req: %s", err)
+	}
+	req.SetBasicAuth("admin", "pass")
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("unexpected status code: %d", rec.Code)
+		t.Log(rec.Body.String())
+	}
+
+	approval, err := am.Get("dev/12345")
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.VotesReceived != 0 {
+		t.Errorf("ineligible vote shouldn't be counted")
+	}
+}
+
+func TestBotInteractionRoute(t *testing.T) {
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := approvals.New(&approvals.Opts{
+		Store: store,
+	})
+
+	authenticator := auth.New(&auth.Opts{
+		Username: "admin",
+		Password: "pass",
+	}, DefaultIssuerMap())
+
+	var handled bool
+	srv := NewTriggerServer(&Opts{
+		Providers:       provider.New([]provider.Provider{&fakeProvider{}}, am),
+		ApprovalManager: am,
+		Authenticator:   authenticator,
+		Store:           store,
+		BotHandlers: map[string]http.Handler{
+			"slack": http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				handled = true
+				resp.WriteHeader(http.StatusOK)
+			}),
+		},
+	})
+	srv.registerRoutes(srv.router)
+
+	// bots verify interactions themselves, no basic auth
+	req, err := http.NewRequest("POST", "/v1/bots/slack/interactions", bytes.NewBufferString("payload={}"))
+	if err != nil {
+		t.Fatalf("failed to create req: %s", err)
+	}
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || !handled {
+		t.Errorf("expected interaction to be handled by the bot, got status %d", rec.Code)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/quilla-hq/quilla/pkg/auth"
 	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
 )
 
 func (s *TriggerServer) adminAuditLogHandler(resp http.ResponseWriter, req *http.Request) {
@@ -64,3 +67,34 @@ type auditLogsResponse struct {
 	Limit  int               `json:"limit"`
 	Offset int               `json:"offset"`
 }
+
+// auditResourceUpdate - records configuration changes made through the API
+func (s *TriggerServer) auditResourceUpdate(req *http.Request, provider, kind, identifier, message string) {
+	if s.store == nil {
+		return
+	}
+
+	entry := &types.AuditLog{
+		Action:       types.AuditActionUpdated,
+		ResourceKind: kind,
+		Identifier:   identifier,
+		Message:      message,
+	}
+
+	if user := auth.GetAccountFromCtx(req.Context()); user != nil {
+		entry.AccountID = user.Username
+		entry.Username = user.Username
+	}
+
+	entry.SetMetadata(map[string]string{
+		"provider": provider,
+	})
+
+	_, err := s.store.CreateAuditLog(entry)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": identifier,
+		}).Error("http.auditResourceUpdate: failed to create audit log")
+	}
+}
@@ -108,6 +108,7 @@ func (s *TriggerServer) dockerHubHandler(resp http.ResponseWriter, req *http.Req
 	event.TriggerName = "dockerhub"
 	event.Repository.Name = dw.Repository.RepoName
 	event.Repository.Tag = dw.PushData.Tag
+	event.Author = dw.PushData.Pusher
 
 	s.trigger(event)
 
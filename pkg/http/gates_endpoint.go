@@ -0,0 +1,164 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/quilla-hq/quilla/internal/gate"
+	"github.com/quilla-hq/quilla/pkg/auth"
+	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// gatesHandler - lists gate runs, optionally filtered by identifier, version
+// and pending (not complete) runs
+func (s *TriggerServer) gatesHandler(resp http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+
+	gates, err := s.store.ListGates(&types.GetGateQuery{
+		Identifier: q.Get("identifier"),
+		Version:    q.Get("version"),
+		Pending:    q.Get("pending") == "true",
+	})
+	if err != nil {
+		response(nil, http.StatusInternalServerError, err, resp, req)
+		return
+	}
+
+	if len(gates) == 0 {
+		gates = make([]*types.Gate, 0)
+	}
+
+	response(gates, http.StatusOK, nil, resp, req)
+}
+
+// gateHandler - returns gate run by ID
+func (s *TriggerServer) gateHandler(resp http.ResponseWriter, req *http.Request) {
+	gate, err := s.store.GetGate(&types.GetGateQuery{ID: getID(req)})
+	if err != nil {
+		if err == store.ErrRecordNotFound {
+			http.Error(resp, "gate not found", http.StatusNotFound)
+			return
+		}
+		response(nil, http.StatusInternalServerError, err, resp, req)
+		return
+	}
+
+	response(gate, http.StatusOK, nil, resp, req)
+}
+
+type gateActionRequest struct {
+	Message string `json:"message"`
+}
+
+// gatePassHandler - passes manual gate
+func (s *TriggerServer) gatePassHandler(resp http.ResponseWriter, req *http.Request) {
+	s.completeGate(resp, req, types.GateStatusSucceeded)
+}
+
+// gateFailHandler - fails manual gate
+func (s *TriggerServer) gateFailHandler(resp http.ResponseWriter, req *http.Request) {
+	s.completeGate(resp, req, types.GateStatusFailed)
+}
+
+func (s *TriggerServer) completeGate(resp http.ResponseWriter, req *http.Request, status int) {
+	var actionReq gateActionRequest
+	if req.Body != nil && req.ContentLength != 0 {
+		err := json.NewDecoder(req.Body).Decode(&actionReq)
+		if err != nil {
+			http.Error(resp, fmt.Sprintf("failed to decode request: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	run, err := s.store.GetGate(&types.GetGateQuery{ID: getID(req)})
+	if err != nil {
+		if err == store.ErrRecordNotFound {
+			http.Error(resp, "gate not found", http.StatusNotFound)
+			return
+		}
+		response(nil, http.StatusInternalServerError, err, resp, req)
+		return
+	}
+
+	if run.Type != gate.GateTypeManual.String() {
+		http.Error(resp, "only manual gates can be passed or failed", http.StatusBadRequest)
+		return
+	}
+	if run.Complete {
+		http.Error(resp, fmt.Sprintf("gate is already complete (%s)", run.StatusString()), http.StatusConflict)
+		return
+	}
+
+	action := types.AuditActionGatePassed
+	if status == types.GateStatusFailed {
+		action = types.AuditActionGateFailed
+	}
+
+	var username string
+	if user := auth.GetAccountFromCtx(req.Context()); user != nil {
+		username = user.Username
+	}
+
+	run.Complete = true
+	run.Status = status
+	run.Message = fmt.Sprintf("%s by %s", action, username)
+	if actionReq.Message != "" {
+		run.Message += ": " + actionReq.Message
+	}
+
+	err = s.store.UpdateGate(run)
+	if err != nil {
+		response(nil, http.StatusInternalServerError, err, resp, req)
+		return
+	}
+
+	s.auditGate(req, action, run)
+
+	// re-evaluating update so it's applied without waiting for the next check
+	if status == types.GateStatusSucceeded && run.Event != nil {
+		event := *run.Event
+		event.TriggerName = types.TriggerTypeGate.String()
+		event.CreatedAt = time.Now()
+		err = s.trigger(event)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"identifier": run.Identifier,
+			}).Error("http.completeGate: failed to submit gate event")
+		}
+	}
+
+	response(run, http.StatusOK, nil, resp, req)
+}
+
+func (s *TriggerServer) auditGate(req *http.Request, action string, run *types.Gate) {
+	entry := &types.AuditLog{
+		Action:       action,
+		ResourceKind: types.AuditResourceKindGate,
+		Identifier:   run.Identifier,
+		Message:      run.Message,
+	}
+
+	if user := auth.GetAccountFromCtx(req.Context()); user != nil {
+		entry.AccountID = user.Username
+		entry.Username = user.Username
+	}
+
+	entry.SetMetadata(map[string]string{
+		"gate":    run.ID,
+		"version": run.Version,
+	})
+
+	_, err := s.store.CreateAuditLog(entry)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": run.Identifier,
+		}).Error("http.auditGate: failed to create audit log")
+	}
+}
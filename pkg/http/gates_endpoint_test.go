@@ -0,0 +1,201 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quilla-hq/quilla/approvals"
+	"github.com/quilla-hq/quilla/pkg/auth"
+	"github.com/quilla-hq/quilla/pkg/store/sql"
+	"github.com/quilla-hq/quilla/provider"
+	"github.com/quilla-hq/quilla/types"
+)
+
+func newGatesTestServer(t *testing.T) (*TriggerServer, *sql.SQLStore, func()) {
+	store, teardown := NewTestingUtils()
+
+	am := approvals.New(&approvals.Opts{
+		Store: store,
+	})
+
+	authenticator := auth.New(&auth.Opts{
+		Username: "admin",
+		Password: "pass",
+	}, DefaultIssuerMap())
+
+	providers := provider.New([]provider.Provider{&fakeProvider{}}, am)
+	srv := NewTriggerServer(&Opts{
+		Providers:       providers,
+		ApprovalManager: am,
+		Authenticator:   authenticator,
+		Store:           store,
+	})
+	srv.registerRoutes(srv.router)
+
+	return srv, store, teardown
+}
+
+func TestListGates(t *testing.T) {
+	srv, store, teardown := newGatesTestServer(t)
+	defer teardown()
+
+	for _, g := range []*types.Gate{
+		{Identifier: "deployment/default/app", Version: "1.0.0", Complete: true, Status: types.GateStatusSucceeded},
+		{Identifier: "deployment/default/app", Version: "1.1.0", Status: types.GateStatusInProgress},
+		{Identifier: "deployment/default/other", Version: "2.0.0", Status: types.GateStatusInProgress},
+	} {
+		_, err := store.CreateGate(g)
+		if err != nil {
+			t.Fatalf("failed to create gate: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{name: "all", query: "", want: 3},
+		{name: "identifier", query: "?identifier=deployment/default/app", want: 2},
+		{name: "pending", query: "?identifier=deployment/default/app&pending=true", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/v1/gates"+tt.query, nil)
+			if err != nil {
+				t.Fatalf("failed to create req: %s", err)
+			}
+			req.SetBasicAuth("admin", "pass")
+
+			rec := httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, req)
+			if rec.Code != 200 {
+				t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+			}
+
+			var gates []*types.Gate
+			err = json.Unmarshal(rec.Body.Bytes(), &gates)
+			if err != nil {
+				t.Fatalf("failed to unmarshal response: %s", err)
+			}
+			if len(gates) != tt.want {
+				t.Errorf("expected %d gates, got: %d", tt.want, len(gates))
+			}
+		})
+	}
+}
+
+func TestGetGate(t *testing.T) {
+	srv, store, teardown := newGatesTestServer(t)
+	defer teardown()
+
+	created, err := store.CreateGate(&types.Gate{Identifier: "deployment/default/app", Version: "1.0.0"})
+	if err != nil {
+		t.Fatalf("failed to create gate: %s", err)
+	}
+
+	req, _ := http.NewRequest("GET", "/v1/gates/"+created.ID, nil)
+	req.SetBasicAuth("admin", "pass")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("unexpected status code: %d", rec.Code)
+	}
+
+	var gate types.Gate
+	err = json.Unmarshal(rec.Body.Bytes(), &gate)
+	if err != nil {
+		t.Fatalf("failed to unmarshal response: %s", err)
+	}
+	if gate.Version != "1.0.0" {
+		t.Errorf("unexpected gate version: %s", gate.Version)
+	}
+
+	req, _ = http.NewRequest("GET", "/v1/gates/missing", nil)
+	req.SetBasicAuth("admin", "pass")
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != 404 {
+		t.Errorf("expected 404, got: %d", rec.Code)
+	}
+}
+
+func TestPassManualGate(t *testing.T) {
+	srv, store, teardown := newGatesTestServer(t)
+	defer teardown()
+
+	created, err := store.CreateGate(&types.Gate{
+		Identifier: "deployment/default/app",
+		Version:    "1.1.0",
+		Type:       "manual",
+		Status:     types.GateStatusInProgress,
+		Event: &types.Event{
+			Repository: types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.0"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create gate: %s", err)
+	}
+
+	req, _ := http.NewRequest("POST", "/v1/gates/"+created.ID+"/pass", bytes.NewBufferString(`{"message": "qa done"}`))
+	req.SetBasicAuth("admin", "pass")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	stored, err := store.GetGate(&types.GetGateQuery{ID: created.ID})
+	if err != nil {
+		t.Fatalf("failed to get gate: %s", err)
+	}
+	if !stored.Complete || stored.Status != types.GateStatusSucceeded {
+		t.Errorf("expected gate to be passed, got: %+v", stored)
+	}
+	if stored.Message != "passed by admin: qa done" {
+		t.Errorf("unexpected message: %s", stored.Message)
+	}
+
+	logs, err := store.GetAuditLogs(&types.AuditLogQuery{ResourceKindFilter: []string{types.AuditResourceKindGate}})
+	if err != nil {
+		t.Fatalf("failed to get audit logs: %s", err)
+	}
+	if len(logs) != 1 || logs[0].Action != types.AuditActionGatePassed {
+		t.Errorf("expected a single gate audit entry, got: %+v", logs)
+	}
+
+	// already complete
+	req, _ = http.NewRequest("POST", "/v1/gates/"+created.ID+"/fail", nil)
+	req.SetBasicAuth("admin", "pass")
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected 409, got: %d", rec.Code)
+	}
+}
+
+func TestFailNonManualGate(t *testing.T) {
+	srv, store, teardown := newGatesTestServer(t)
+	defer teardown()
+
+	created, err := store.CreateGate(&types.Gate{
+		Identifier: "deployment/default/app",
+		Version:    "1.1.0",
+		Type:       "job",
+		Status:     types.GateStatusInProgress,
+	})
+	if err != nil {
+		t.Fatalf("failed to create gate: %s", err)
+	}
+
+	req, _ := http.NewRequest("POST", "/v1/gates/"+created.ID+"/fail", nil)
+	req.SetBasicAuth("admin", "pass")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got: %d", rec.Code)
+	}
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/quilla-hq/quilla/pkg/auth"
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// rollbackRequest - history entry to roll back to, resource is reverted to
+// the version before its latest update when empty
+type rollbackRequest struct {
+	EntryID string `json:"entryId"`
+}
+
+func (s *TriggerServer) resourceHistoryHandler(resp http.ResponseWriter, req *http.Request) {
+	limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+
+	entries, err := s.store.ListHistory(&types.GetHistoryQuery{
+		Identifier: getID(req),
+		Limit:      limit,
+	})
+	if err != nil {
+		response(nil, http.StatusInternalServerError, err, resp, req)
+		return
+	}
+
+	response(entries, http.StatusOK, nil, resp, req)
+}
+
+func (s *TriggerServer) resourceRollbackHandler(resp http.ResponseWriter, req *http.Request) {
+	var rollbackReq rollbackRequest
+	if req.Body != nil && req.ContentLength != 0 {
+		err := json.NewDecoder(req.Body).Decode(&rollbackReq)
+		if err != nil {
+			http.Error(resp, fmt.Sprintf("failed to decode request: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	identifier := getID(req)
+	entries, err := s.store.ListHistory(&types.GetHistoryQuery{Identifier: identifier})
+	if err != nil {
+		response(nil, http.StatusInternalServerError, err, resp, req)
+		return
+	}
+
+	target, err := rollbackTarget(entries, rollbackReq.EntryID)
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	target.Identifier = identifier
+
+	if user := auth.GetAccountFromCtx(req.Context()); user != nil {
+		target.User = user.Username
+	}
+
+	err = s.providers.Rollback(entries[0].Provider, target)
+	if err != nil {
+		response(nil, http.StatusInternalServerError, err, resp, req)
+		return
+	}
+
+	s.auditRollback(req, target)
+
+	if target.Queued {
+		response(target, http.StatusAccepted, nil, resp, req)
+		return
+	}
+	response(target, http.StatusOK, nil, resp, req)
+}
+
+// rollbackTarget - resolves version to roll back to from the history, newest entries first
+func rollbackTarget(entries []*types.HistoryEntry, entryID string) (*types.RollbackRequest, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("resource doesn't have version history")
+	}
+
+	var target *types.RollbackRequest
+	if entryID != "" {
+		for _, entry := range entries {
+			if entry.ID != entryID {
+				continue
+			}
+			if entry.Outcome != types.HistoryOutcomeSucceeded {
+				return nil, fmt.Errorf("only successfully applied versions can be restored")
+			}
+			target = &types.RollbackRequest{Image: entry.Image, Version: entry.To, Digest: entry.Digest}
+		}
+		if target == nil {
+			return nil, fmt.Errorf("history entry %s not found", entryID)
+		}
+		return target, nil
+	}
+
+	// version before the current one, rollbacks are skipped so a rollback
+	// isn't undone by the next one
+	var current string
+	for _, entry := range entries {
+		if entry.Outcome != types.HistoryOutcomeSucceeded {
+			continue
+		}
+		if current == "" {
+			current = entry.To
+		}
+		if entry.Trigger == types.TriggerTypeRollback.String() || entry.From == current {
+			continue
+		}
+		target = &types.RollbackRequest{Image: entry.Image, Version: entry.From}
+		break
+	}
+	if target == nil {
+		return nil, fmt.Errorf("resource doesn't have successful updates to roll back")
+	}
+	for _, entry := range entries {
+		if entry.Outcome == types.HistoryOutcomeSucceeded && entry.To == target.Version {
+			target.Digest = entry.Digest
+			break
+		}
+	}
+	return target, nil
+}
+
+func (s *TriggerServer) auditRollback(req *http.Request, target *types.RollbackRequest) {
+	entry := &types.AuditLog{
+		Action:       types.AuditActionRollback,
+		ResourceKind: types.AuditResourceKindResource,
+		Identifier:   target.Identifier,
+		Message:      fmt.Sprintf("rolled back to %s", target.Version),
+	}
+
+	if user := auth.GetAccountFromCtx(req.Context()); user != nil {
+		entry.AccountID = user.Username
+		entry.Username = user.Username
+	}
+
+	entry.SetMetadata(map[string]string{
+		"image":   target.Image,
+		"version": target.Version,
+	})
+
+	_, err := s.store.CreateAuditLog(entry)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": target.Identifier,
+		}).Error("http.auditRollback: failed to create audit log")
+	}
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/pkg/store/sql"
+	"github.com/quilla-hq/quilla/provider"
+	"github.com/quilla-hq/quilla/types"
+)
+
+func newHistoryTestServer(t *testing.T) (*TriggerServer, *fakeProvider, *sql.SQLStore, func()) {
+	srv, store, teardown := newGatesTestServer(t)
+	fp := &fakeProvider{}
+	srv.providers = provider.New([]provider.Provider{fp}, srv.approvalsManager)
+
+	created := time.Now().Add(-time.Hour)
+	for _, entry := range []*types.HistoryEntry{
+		{ID: "first", Provider: "fp", Identifier: "deployment/default/app", Container: "app", Image: "quilla/app", From: "1.0.0", To: "1.1.0", Digest: "sha256:110", Outcome: types.HistoryOutcomeSucceeded},
+		{ID: "second", Provider: "fp", Identifier: "deployment/default/app", Container: "app", Image: "quilla/app", From: "1.1.0", To: "1.2.0", Digest: "sha256:120", Outcome: types.HistoryOutcomeSucceeded},
+		{ID: "third", Provider: "fp", Identifier: "deployment/default/app", Container: "app", Image: "quilla/app", From: "1.2.0", To: "1.3.0", Outcome: types.HistoryOutcomeFailed},
+		{ID: "other", Provider: "fp", Identifier: "deployment/default/other", Container: "other", Image: "quilla/other", From: "2.0.0", To: "2.1.0", Outcome: types.HistoryOutcomeSucceeded},
+	} {
+		entry.CreatedAt = created
+		created = created.Add(time.Minute)
+		_, err := store.CreateHistoryEntry(entry)
+		if err != nil {
+			t.Fatalf("failed to create history entry: %s", err)
+		}
+	}
+
+	return srv, fp, store, teardown
+}
+
+func TestResourceHistory(t *testing.T) {
+	srv, _, _, teardown := newHistoryTestServer(t)
+	defer teardown()
+
+	req, err := http.NewRequest("GET", "/v1/resources/deployment/default/app/history?limit=2", nil)
+	if err != nil {
+		t.Fatalf("failed to create req: %s", err)
+	}
+	req.SetBasicAuth("admin", "pass")
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	var entries []*types.HistoryEntry
+	err = json.Unmarshal(rec.Body.Bytes(), &entries)
+	if err != nil {
+		t.Fatalf("failed to unmarshal response: %s", err)
+	}
+	if len(entries) != 2 || entries[0].ID != "third" || entries[1].ID != "second" {
+		t.Errorf("expected latest two entries of the resource, got: %+v", entries)
+	}
+}
+
+func TestResourceRollback(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    int
+		wantVersion string
+		wantDigest  string
+	}{
+		{name: "previous version", wantCode: 200, wantVersion: "1.1.0", wantDigest: "sha256:110"},
+		{name: "history entry", body: `{"entryId": "first"}`, wantCode: 200, wantVersion: "1.1.0", wantDigest: "sha256:110"},
+		{name: "failed entry", body: `{"entryId": "third"}`, wantCode: 400},
+		{name: "entry of another resource", body: `{"entryId": "other"}`, wantCode: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fp, store, teardown := newHistoryTestServer(t)
+			defer teardown()
+
+			req, err := http.NewRequest("POST", "/v1/resources/deployment/default/app/rollback", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatalf("failed to create req: %s", err)
+			}
+			req.SetBasicAuth("admin", "pass")
+
+			rec := httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+			}
+			if tt.wantCode != 200 {
+				if len(fp.rollbacks) != 0 {
+					t.Errorf("resource shouldn't be rolled back")
+				}
+				return
+			}
+
+			if len(fp.rollbacks) != 1 {
+				t.Fatalf("expected a single rollback, got: %d", len(fp.rollbacks))
+			}
+			got := fp.rollbacks[0]
+			if got.Identifier != "deployment/default/app" || got.Image != "quilla/app" || got.Version != tt.wantVersion || got.Digest != tt.wantDigest || got.User != "admin" {
+				t.Errorf("unexpected rollback: %+v", got)
+			}
+
+			logs, err := store.GetAuditLogs(&types.AuditLogQuery{ResourceKindFilter: []string{types.AuditResourceKindResource}})
+			if err != nil {
+				t.Fatalf("failed to get audit logs: %s", err)
+			}
+			if len(logs) != 1 || logs[0].Action != types.AuditActionRollback {
+				t.Errorf("expected rollback to be audited, got: %+v", logs)
+			}
+		})
+	}
+}
+
+func TestResourceRollbackAfterRollback(t *testing.T) {
+	srv, fp, store, teardown := newHistoryTestServer(t)
+	defer teardown()
+
+	_, err := store.CreateHistoryEntry(&types.HistoryEntry{
+		ID:         "rollback",
+		CreatedAt:  time.Now(),
+		Provider:   "fp",
+		Identifier: "deployment/default/app",
+		Container:  "app",
+		Image:      "quilla/app",
+		From:       "1.2.0",
+		To:         "1.1.0",
+		Trigger:    types.TriggerTypeRollback.String(),
+		Outcome:    types.HistoryOutcomeSucceeded,
+	})
+	if err != nil {
+		t.Fatalf("failed to create history entry: %s", err)
+	}
+
+	req, err := http.NewRequest("POST", "/v1/resources/deployment/default/app/rollback", nil)
+	if err != nil {
+		t.Fatalf("failed to create req: %s", err)
+	}
+	req.SetBasicAuth("admin", "pass")
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	// rollback isn't undone, resource goes back to the version before 1.1.0
+	if len(fp.rollbacks) != 1 || fp.rollbacks[0].Version != "1.0.0" {
+		t.Errorf("unexpected rollback: %+v", fp.rollbacks)
+	}
+}
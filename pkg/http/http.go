@@ -25,6 +25,7 @@ import (
 	"github.com/quilla-hq/quilla/pkg/auth"
 	"github.com/quilla-hq/quilla/pkg/store"
 	"github.com/quilla-hq/quilla/provider"
+	"github.com/quilla-hq/quilla/provider/helm3"
 	"github.com/quilla-hq/quilla/provider/kubernetes"
 	"github.com/quilla-hq/quilla/types"
 	"github.com/quilla-hq/quilla/version"
@@ -47,6 +48,12 @@ type Opts struct {
 
 	KubernetesClient kubernetes.Implementer
 
+	// HelmReleases is optional, set when helm3 provider is enabled
+	HelmReleases helm3.ReleaseManager
+
+	// UpdateQueue is optional, exposes updates held back by update limits
+	UpdateQueue kubernetes.UpdateQueue
+
 	Store store.Store
 
 	UIDir string
@@ -54,12 +61,21 @@ type Opts struct {
 	AuthenticatedWebhooks bool
 
 	RBACEnabled bool
+
+	// BotHandlers - interaction handlers of chat bots by bot name, requests
+	// are authenticated by the bots
+	BotHandlers map[string]http.Handler
+
+	// ApprovalLinks - optional, verifies approval links sent by email
+	ApprovalLinks *approvals.LinkSigner
 }
 
 // TriggerServer - webhook trigger & healthcheck server
 type TriggerServer struct {
 	grc              *k8s.GenericResourceCache
 	kubernetesClient kubernetes.Implementer
+	helmReleases     helm3.ReleaseManager
+	updateQueue      kubernetes.UpdateQueue
 
 	providers        provider.Providers
 	approvalsManager approvals.Manager
@@ -74,6 +90,10 @@ type TriggerServer struct {
 
 	authenticatedWebhooks bool
 
+	botHandlers map[string]http.Handler
+
+	approvalLinks *approvals.LinkSigner
+
 	e *casbin.Enforcer
 }
 
@@ -112,6 +132,8 @@ m = g(r.sub, p.sub) && regexMatch(r.act, p.act)
 		port:                  opts.Port,
 		grc:                   opts.GRC,
 		kubernetesClient:      opts.KubernetesClient,
+		helmReleases:          opts.HelmReleases,
+		updateQueue:           opts.UpdateQueue,
 		providers:             opts.Providers,
 		approvalsManager:      opts.ApprovalManager,
 		router:                mux.NewRouter(),
@@ -119,6 +141,8 @@ m = g(r.sub, p.sub) && regexMatch(r.act, p.act)
 		store:                 opts.Store,
 		uiDir:                 opts.UIDir,
 		authenticatedWebhooks: opts.AuthenticatedWebhooks,
+		botHandlers:           opts.BotHandlers,
+		approvalLinks:         opts.ApprovalLinks,
 		e:                     e,
 	}
 }
@@ -163,6 +187,17 @@ func (s *TriggerServer) registerRoutes(mux *mux.Router) {
 
 	s.registerWebhookRoutes(mux)
 
+	// chat bot interactions, ie: approval buttons
+	for name, handler := range s.botHandlers {
+		mux.Handle(fmt.Sprintf("/v1/bots/%s/interactions", name), handler).Methods("POST")
+	}
+
+	// approval links sent by email, link token authenticates the recipient
+	if s.approvalLinks != nil {
+		mux.HandleFunc("/v1/approvals/links/{token}", s.approvalLinkHandler).Methods("GET")
+		mux.HandleFunc("/v1/approvals/links/{token}", s.approvalLinkVoteHandler).Methods("POST")
+	}
+
 	// health endpoint for k8s to be happy
 	mux.HandleFunc("/healthz", s.healthHandler).Methods("GET", "OPTIONS")
 	// version handler
@@ -183,11 +218,25 @@ func (s *TriggerServer) registerRoutes(mux *mux.Router) {
 		mux.HandleFunc("/v1/approvals", s.requireAdminAuthorization(s.requireRBAC(s.approvalsHandler, "approvals", "read"))).Methods("GET", "OPTIONS")
 		// approving/rejecting
 		mux.HandleFunc("/v1/approvals", s.requireAdminAuthorization(s.requireRBAC(s.approvalApproveHandler, "approvals", "write"))).Methods("POST", "OPTIONS")
+		// approval history
+		mux.HandleFunc("/v1/approvals/{id}/timeline", s.requireAdminAuthorization(s.requireRBAC(s.approvalTimelineHandler, "approvals", "read"))).Methods("GET", "OPTIONS")
 		// updating required approvals count
 		mux.HandleFunc("/v1/approvals", s.requireAdminAuthorization(s.requireRBAC(s.approvalSetHandler, "approvals", "write"))).Methods("PUT", "OPTIONS")
 
+		// gate runs
+		mux.HandleFunc("/v1/gates", s.requireAdminAuthorization(s.requireRBAC(s.gatesHandler, "gates", "read"))).Methods("GET", "OPTIONS")
+		mux.HandleFunc("/v1/gates/{id}", s.requireAdminAuthorization(s.requireRBAC(s.gateHandler, "gates", "read"))).Methods("GET", "OPTIONS")
+		// passing/failing manual gates
+		mux.HandleFunc("/v1/gates/{id}/pass", s.requireAdminAuthorization(s.requireRBAC(s.gatePassHandler, "gates", "write"))).Methods("POST", "OPTIONS")
+		mux.HandleFunc("/v1/gates/{id}/fail", s.requireAdminAuthorization(s.requireRBAC(s.gateFailHandler, "gates", "write"))).Methods("POST", "OPTIONS")
+
 		// available resources
 		mux.HandleFunc("/v1/resources", s.requireAdminAuthorization(s.requireRBAC(s.resourcesHandler, "resources", "read"))).Methods("GET", "OPTIONS")
+		// version history, resource identifiers contain slashes
+		mux.HandleFunc("/v1/resources/{id:.+}/history", s.requireAdminAuthorization(s.requireRBAC(s.resourceHistoryHandler, "resources", "read"))).Methods("GET", "OPTIONS")
+		mux.HandleFunc("/v1/resources/{id:.+}/rollback", s.requireAdminAuthorization(s.requireRBAC(s.resourceRollbackHandler, "resources", "write"))).Methods("POST", "OPTIONS")
+		// updates held back by concurrency limits and update budgets
+		mux.HandleFunc("/v1/updates/queue", s.requireAdminAuthorization(s.requireRBAC(s.updateQueueHandler, "resources", "read"))).Methods("GET", "OPTIONS")
 
 		mux.HandleFunc("/v1/policies", s.requireAdminAuthorization(s.requireRBAC(s.policyUpdateHandler, "policies", "write"))).Methods("PUT", "OPTIONS")
 
@@ -24,10 +24,16 @@ func init() {
 	prometheus.MustRegister(newNativeWebhooksCounter)
 }
 
+// nativeRequest - repository with optional author of the image
+type nativeRequest struct {
+	types.Repository
+	Author string `json:"author"`
+}
+
 // nativeHandler - used to trigger event directly
 func (s *TriggerServer) nativeHandler(resp http.ResponseWriter, req *http.Request) {
-	repo := types.Repository{}
-	if err := json.NewDecoder(req.Body).Decode(&repo); err != nil {
+	nr := nativeRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&nr); err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
 		}).Error("failed to decode request")
@@ -35,6 +41,7 @@ func (s *TriggerServer) nativeHandler(resp http.ResponseWriter, req *http.Reques
 		return
 	}
 
+	repo := nr.Repository
 	event := types.Event{}
 
 	if repo.Name == "" {
@@ -50,6 +57,7 @@ func (s *TriggerServer) nativeHandler(resp http.ResponseWriter, req *http.Reques
 	}
 
 	event.Repository = repo
+	event.Author = nr.Author
 	event.CreatedAt = time.Now()
 	event.TriggerName = "native"
 	s.trigger(event)
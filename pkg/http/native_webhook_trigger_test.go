@@ -69,6 +69,12 @@ func NewTestingServer(fp provider.Provider) (*TriggerServer, func()) {
 type fakeProvider struct {
 	submitted []types.Event
 	images    []*types.TrackedImage
+	rollbacks []*types.RollbackRequest
+}
+
+func (p *fakeProvider) Rollback(req *types.RollbackRequest) error {
+	p.rollbacks = append(p.rollbacks, req)
+	return nil
 }
 
 func (p *fakeProvider) Submit(event types.Event) error {
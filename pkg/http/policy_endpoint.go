@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/quilla-hq/quilla/provider/helm3"
 	"github.com/quilla-hq/quilla/types"
 )
 
@@ -31,6 +32,13 @@ func (s *TriggerServer) policyUpdateHandler(resp http.ResponseWriter, req *http.
 		return
 	}
 
+	if policyRequest.Provider == types.ProviderTypeHelm.String() {
+		s.updateReleaseConfig(resp, req, policyRequest.Identifier, &helm3.ReleaseConfigUpdate{
+			Policy: &policyRequest.Policy,
+		}, fmt.Sprintf("policy set to '%s'", policyRequest.Policy))
+		return
+	}
+
 	for _, v := range s.grc.Values() {
 		if v.Identifier == policyRequest.Identifier {
 
@@ -43,7 +51,10 @@ func (s *TriggerServer) policyUpdateHandler(resp http.ResponseWriter, req *http.
 
 			v.SetAnnotations(ann)
 
-			err := s.kubernetesClient.Update(v)
+			err := s.kubernetesClient.Update(v, nil)
+			if err == nil {
+				s.auditResourceUpdate(req, types.ProviderTypeKubernetes.String(), v.Kind(), v.Identifier, fmt.Sprintf("policy set to '%s'", policyRequest.Policy))
+			}
 
 			response(&APIResponse{Status: "updated"}, 200, err, resp, req)
 			return
@@ -52,5 +63,4 @@ func (s *TriggerServer) policyUpdateHandler(resp http.ResponseWriter, req *http.
 
 	resp.WriteHeader(http.StatusNotFound)
 	fmt.Fprintf(resp, "resource with identifier '%s' not found", policyRequest.Identifier)
-	return
 }
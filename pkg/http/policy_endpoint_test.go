@@ -0,0 +1,112 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quilla-hq/quilla/approvals"
+	"github.com/quilla-hq/quilla/pkg/auth"
+	"github.com/quilla-hq/quilla/provider"
+	"github.com/quilla-hq/quilla/provider/helm3"
+	"github.com/quilla-hq/quilla/types"
+)
+
+type fakeReleaseManager struct {
+	releases []*helm3.TrackedRelease
+
+	updatedIdentifier string
+	updatedConfig     *helm3.ReleaseConfigUpdate
+}
+
+func (m *fakeReleaseManager) Releases() ([]*helm3.TrackedRelease, error) {
+	return m.releases, nil
+}
+
+func (m *fakeReleaseManager) UpdateReleaseConfig(identifier string, cfg *helm3.ReleaseConfigUpdate) error {
+	for _, r := range m.releases {
+		if r.Identifier == identifier {
+			m.updatedIdentifier = identifier
+			m.updatedConfig = cfg
+			return nil
+		}
+	}
+	return helm3.ErrReleaseNotFound
+}
+
+func TestUpdateHelmReleasePolicy(t *testing.T) {
+	fp := &fakeProvider{}
+	store, teardown := NewTestingUtils()
+	defer teardown()
+
+	am := approvals.New(&approvals.Opts{
+		Store: store,
+	})
+
+	authenticator := auth.New(&auth.Opts{
+		Username: "admin",
+		Password: "pass",
+	}, DefaultIssuerMap())
+
+	rm := &fakeReleaseManager{
+		releases: []*helm3.TrackedRelease{
+			{Identifier: "chart/default/release-1", Namespace: "default", Name: "release-1"},
+		},
+	}
+
+	providers := provider.New([]provider.Provider{fp}, am)
+	srv := NewTriggerServer(&Opts{
+		Providers:       providers,
+		ApprovalManager: am,
+		Authenticator:   authenticator,
+		Store:           store,
+		HelmReleases:    rm,
+	})
+	srv.registerRoutes(srv.router)
+
+	reqData := []byte(`{"identifier": "chart/default/release-1", "provider": "helm", "policy": "minor"}`)
+	req, err := http.NewRequest("PUT", "/v1/policies", bytes.NewBuffer(reqData))
+	if err != nil {
+		t.Fatalf("failed to create req: %s", err)
+	}
+	req.SetBasicAuth("admin", "pass")
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	if rm.updatedIdentifier != "chart/default/release-1" {
+		t.Errorf("unexpected release updated: %s", rm.updatedIdentifier)
+	}
+	if rm.updatedConfig == nil || rm.updatedConfig.Policy == nil || *rm.updatedConfig.Policy != "minor" {
+		t.Errorf("unexpected config update: %+v", rm.updatedConfig)
+	}
+
+	logs, err := store.GetAuditLogs(&types.AuditLogQuery{ResourceKindFilter: []string{"*"}})
+	if err != nil {
+		t.Fatalf("failed to get audit logs: %s", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 audit log, got: %d", len(logs))
+	}
+	if logs[0].Identifier != "chart/default/release-1" || logs[0].Username != "admin" {
+		t.Errorf("unexpected audit log: %+v", logs[0])
+	}
+
+	// unknown release
+	reqData = []byte(`{"identifier": "chart/default/missing", "provider": "helm", "policy": "minor"}`)
+	req, err = http.NewRequest("PUT", "/v1/policies", bytes.NewBuffer(reqData))
+	if err != nil {
+		t.Fatalf("failed to create req: %s", err)
+	}
+	req.SetBasicAuth("admin", "pass")
+
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != 404 {
+		t.Errorf("unexpected status code: %d", rec.Code)
+	}
+}
@@ -120,6 +120,7 @@ func (s *TriggerServer) registryNotificationHandler(resp http.ResponseWriter, re
 		event.TriggerName = "registry-notification"
 		event.Repository.Tag = e.Target.Tag
 		event.Repository.Digest = e.Target.Digest
+		event.Author = e.Actor.Name
 
 		log.WithFields(log.Fields{
 			"action":     e.Action,
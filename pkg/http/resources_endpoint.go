@@ -1,10 +1,16 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/quilla-hq/quilla/internal/k8s"
 	"github.com/quilla-hq/quilla/internal/policy"
+	"github.com/quilla-hq/quilla/provider/helm3"
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
 )
 
 type resource struct {
@@ -18,6 +24,22 @@ type resource struct {
 	Labels      map[string]string `json:"labels"`
 	Annotations map[string]string `json:"annotations"`
 	Status      k8s.Status        `json:"status"`
+
+	// Config is set for helm releases, kubernetes resources
+	// keep their configuration in labels and annotations
+	Config *releaseConfig `json:"config,omitempty"`
+}
+
+// releaseConfig - quilla configuration of the helm release
+type releaseConfig struct {
+	Chart                string   `json:"chart"`
+	Status               string   `json:"status"`
+	Policy               string   `json:"policy"`
+	Trigger              string   `json:"trigger"`
+	PollSchedule         string   `json:"pollSchedule"`
+	Approvals            int      `json:"approvals"`
+	ApprovalDeadline     int      `json:"approvalDeadline"`
+	NotificationChannels []string `json:"notificationChannels"`
 }
 
 func (s *TriggerServer) resourcesHandler(resp http.ResponseWriter, req *http.Request) {
@@ -44,5 +66,83 @@ func (s *TriggerServer) resourcesHandler(resp http.ResponseWriter, req *http.Req
 		})
 	}
 
+	res = append(res, s.releaseResources()...)
+
 	response(res, 200, nil, resp, req)
 }
+
+// releaseResources - helm releases with quilla configuration. Configuration
+// is also exposed as quilla annotations so clients can treat releases the same
+// way as kubernetes resources
+func (s *TriggerServer) releaseResources() []resource {
+	if s.helmReleases == nil {
+		return nil
+	}
+
+	releases, err := s.helmReleases.Releases()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("http.releaseResources: failed to list helm releases")
+		return nil
+	}
+
+	var res []resource
+	for _, r := range releases {
+		annotations := map[string]string{
+			types.QuillaPolicyLabel:           r.Policy,
+			types.QuillaTriggerLabel:          r.Trigger.String(),
+			types.QuillaMinimumApprovalsLabel: strconv.Itoa(r.Approvals),
+		}
+		if r.PollSchedule != "" {
+			annotations[types.QuillaPollScheduleAnnotation] = r.PollSchedule
+		}
+
+		res = append(res, resource{
+			Provider:    types.ProviderTypeHelm.String(),
+			Identifier:  r.Identifier,
+			Name:        r.Name,
+			Namespace:   r.Namespace,
+			Kind:        "chart",
+			Policy:      r.Plc.Name(),
+			Images:      r.Images,
+			Labels:      map[string]string{},
+			Annotations: annotations,
+			Config: &releaseConfig{
+				Chart:                r.Chart,
+				Status:               r.Status,
+				Policy:               r.Policy,
+				Trigger:              r.Trigger.String(),
+				PollSchedule:         r.PollSchedule,
+				Approvals:            r.Approvals,
+				ApprovalDeadline:     r.ApprovalDeadline,
+				NotificationChannels: r.NotificationChannels,
+			},
+		})
+	}
+
+	return res
+}
+
+// updateReleaseConfig - upgrades helm release with modified quilla configuration
+func (s *TriggerServer) updateReleaseConfig(resp http.ResponseWriter, req *http.Request, identifier string, cfg *helm3.ReleaseConfigUpdate, message string) {
+	if s.helmReleases == nil {
+		http.Error(resp, "helm provider is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	err := s.helmReleases.UpdateReleaseConfig(identifier, cfg)
+	if err != nil {
+		if err == helm3.ErrReleaseNotFound {
+			resp.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(resp, "resource with identifier '%s' not found", identifier)
+			return
+		}
+		response(nil, 500, err, resp, req)
+		return
+	}
+
+	s.auditResourceUpdate(req, types.ProviderTypeHelm.String(), "chart", identifier, message)
+
+	response(&APIResponse{Status: "updated"}, 200, nil, resp, req)
+}
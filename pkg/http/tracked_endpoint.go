@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/quilla-hq/quilla/provider/helm3"
 	"github.com/quilla-hq/quilla/types"
 )
 
@@ -60,10 +61,10 @@ func (s *TriggerServer) trackSetHandler(resp http.ResponseWriter, req *http.Requ
 	}
 
 	switch trackReq.Provider {
-	case types.ProviderTypeKubernetes.String():
+	case types.ProviderTypeKubernetes.String(), types.ProviderTypeHelm.String():
 		// ok
 	default:
-		http.Error(resp, "unsupported provider, supported: 'kubernetes'", http.StatusBadRequest)
+		http.Error(resp, "unsupported provider, supported: 'kubernetes', 'helm'", http.StatusBadRequest)
 		return
 	}
 
@@ -86,6 +87,17 @@ func (s *TriggerServer) trackSetHandler(resp http.ResponseWriter, req *http.Requ
 		trackReq.Schedule = types.QuillaPollDefaultSchedule
 	}
 
+	message := fmt.Sprintf("trigger set to '%s', schedule '%s'", trackReq.Trigger, trackReq.Schedule)
+
+	if trackReq.Provider == types.ProviderTypeHelm.String() {
+		trigger := types.ParseTrigger(trackReq.Trigger)
+		s.updateReleaseConfig(resp, req, trackReq.Identifier, &helm3.ReleaseConfigUpdate{
+			Trigger:      &trigger,
+			PollSchedule: &trackReq.Schedule,
+		}, message)
+		return
+	}
+
 	for _, v := range s.grc.Values() {
 		if v.Identifier == trackReq.Identifier {
 
@@ -99,7 +111,10 @@ func (s *TriggerServer) trackSetHandler(resp http.ResponseWriter, req *http.Requ
 
 			v.SetAnnotations(ann)
 
-			err := s.kubernetesClient.Update(v)
+			err := s.kubernetesClient.Update(v, nil)
+			if err == nil {
+				s.auditResourceUpdate(req, types.ProviderTypeKubernetes.String(), v.Kind(), v.Identifier, message)
+			}
 
 			response(&APIResponse{Status: "updated"}, 200, err, resp, req)
 			return
@@ -0,0 +1,16 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/quilla-hq/quilla/types"
+)
+
+func (s *TriggerServer) updateQueueHandler(resp http.ResponseWriter, req *http.Request) {
+	if s.updateQueue == nil {
+		// no limits without kubernetes provider, nothing is queued
+		response(&types.UpdateQueue{Updates: []*types.QueuedUpdate{}}, 200, nil, resp, req)
+		return
+	}
+	response(s.updateQueue.UpdateQueue(), 200, nil, resp, req)
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quilla-hq/quilla/types"
+)
+
+type fakeUpdateQueue struct {
+	queue *types.UpdateQueue
+}
+
+func (q *fakeUpdateQueue) UpdateQueue() *types.UpdateQueue {
+	return q.queue
+}
+
+func TestUpdateQueue(t *testing.T) {
+	srv, _, teardown := newGatesTestServer(t)
+	defer teardown()
+
+	srv.updateQueue = &fakeUpdateQueue{queue: &types.UpdateQueue{
+		InFlight: 2,
+		Depth:    1,
+		Updates: []*types.QueuedUpdate{
+			{Identifier: "deployment/default/app", Namespace: "default", NewVersion: "1.1.0", Reason: "cluster limit of 2 rollouts reached"},
+		},
+	}}
+
+	req, err := http.NewRequest("GET", "/v1/updates/queue", nil)
+	if err != nil {
+		t.Fatalf("failed to create req: %s", err)
+	}
+	req.SetBasicAuth("admin", "pass")
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("unexpected status code: %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	var queue types.UpdateQueue
+	err = json.Unmarshal(rec.Body.Bytes(), &queue)
+	if err != nil {
+		t.Fatalf("failed to unmarshal response: %s", err)
+	}
+	if queue.InFlight != 2 || queue.Depth != 1 || queue.Updates[0].Identifier != "deployment/default/app" {
+		t.Errorf("unexpected queue: %+v", queue)
+	}
+}
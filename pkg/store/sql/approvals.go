@@ -1,7 +1,9 @@
 package sql
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/jinzhu/gorm"
@@ -57,13 +59,114 @@ func (s *SQLStore) GetApproval(q *types.GetApprovalQuery) (*types.Approval, erro
 	return &result, err
 }
 
+// ListApprovals - lists approvals matching the query, most recently updated first
 func (s *SQLStore) ListApprovals(q *types.GetApprovalQuery) ([]*types.Approval, error) {
 	var approvals []*types.Approval
-	err := s.db.Order("updated_at desc").Where(&types.Approval{
+
+	order := "updated_at desc"
+	for _, o := range types.ApprovalOrders {
+		if q.Order == o {
+			order = o
+		}
+	}
+
+	tx := approvalsQuery(s.db, q).Order(order)
+	if q.Limit > 0 {
+		tx = tx.Limit(q.Limit)
+	}
+	if q.Offset > 0 {
+		tx = tx.Offset(q.Offset)
+	}
+	err := tx.Find(&approvals).Error
+	return approvals, err
+}
+
+// ApprovalsCount - number of approvals matching the query, limit and offset are ignored
+func (s *SQLStore) ApprovalsCount(q *types.GetApprovalQuery) (int, error) {
+	var count int
+	err := approvalsQuery(s.db.Model(&types.Approval{}), q).Count(&count).Error
+	return count, err
+}
+
+// approvalsQuery - applies list filters, every filter except voter is
+// backed by an index
+func approvalsQuery(tx *gorm.DB, q *types.GetApprovalQuery) *gorm.DB {
+	tx = tx.Where(&types.Approval{
 		Identifier: q.Identifier,
+		Namespace:  q.Namespace,
 		Archived:   q.Archived,
-	}).Find(&approvals).Error
-	return approvals, err
+		Provider:   q.Provider,
+		State:      q.Status,
+	})
+	if q.ExcludeArchived {
+		tx = tx.Where("archived = ?", false)
+	}
+	if q.IdentifierPrefix != "" {
+		tx = tx.Where("identifier LIKE ? ESCAPE '\\'", escapeLike(q.IdentifierPrefix)+"%")
+	}
+	if !q.CreatedAfter.IsZero() {
+		tx = tx.Where("created_at >= ?", q.CreatedAfter)
+	}
+	if !q.CreatedBefore.IsZero() {
+		tx = tx.Where("created_at < ?", q.CreatedBefore)
+	}
+	if q.Voter != "" {
+		// votes are stored as JSON, same encoding on SQLite and Postgres
+		name, _ := json.Marshal(q.Voter)
+		voter := "%" + escapeLike(`"voter":`+string(name)) + "%"
+		tx = tx.Where("(CAST(votes AS TEXT) LIKE ? ESCAPE '\\' OR CAST(rejections AS TEXT) LIKE ? ESCAPE '\\')", voter, voter)
+	}
+	return tx
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// migrateApprovalNamespaces - sets namespace of approvals stored before it was
+// tracked, namespace is parsed from the identifier: <kind>/<namespace>/<name>:<version>
+// for kubernetes resources and <namespace>/<release>:<version> for helm releases
+func (s *SQLStore) migrateApprovalNamespaces() error {
+	var approvals []*types.Approval
+	err := s.db.Where("namespace = ? OR namespace IS NULL", "").Find(&approvals).Error
+	if err != nil {
+		return err
+	}
+	for _, approval := range approvals {
+		parts := strings.Split(approval.Identifier, "/")
+		var namespace string
+		switch {
+		case approval.Provider == types.ProviderTypeKubernetes && len(parts) == 3:
+			namespace = parts[1]
+		case approval.Provider == types.ProviderTypeHelm && len(parts) == 2:
+			namespace = parts[0]
+		default:
+			continue
+		}
+		err = s.db.Model(approval).UpdateColumn("namespace", namespace).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateApprovalStates - sets state of approvals stored before it was tracked
+func (s *SQLStore) migrateApprovalStates() error {
+	var approvals []*types.Approval
+	err := s.db.Where("state = ?", types.ApprovalStatusUnknown).Find(&approvals).Error
+	if err != nil {
+		return err
+	}
+	for _, approval := range approvals {
+		err = s.db.Model(approval).UpdateColumn("state", approval.Status()).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *SQLStore) DeleteApproval(approval *types.Approval) error {
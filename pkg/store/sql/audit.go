@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
 	"github.com/quilla-hq/quilla/types"
 )
 
@@ -41,29 +42,28 @@ func (s *SQLStore) GetAuditLogs(query *types.AuditLogQuery) (logs []*types.Audit
 		query.Order = "created_at desc"
 	}
 
-	if len(query.ResourceKindFilter) == 1 && query.ResourceKindFilter[0] == "*" {
-		err = s.db.Order(query.Order).Limit(query.Limit).Offset(query.Offset).Find(&logs).Error
-	} else if query.Username != "" {
-		err = s.db.Order(query.Order).Where("resource_kind in (?)", query.ResourceKindFilter).Limit(query.Limit).Offset(query.Offset).Where("username = ?", query.Username).Find(&logs).Error
-	} else {
-		err = s.db.Order(query.Order).Where("resource_kind in (?)", query.ResourceKindFilter).Limit(query.Limit).Offset(query.Offset).Find(&logs).Error
-	}
+	err = auditLogsQuery(s.db, query).Order(query.Order).Limit(query.Limit).Offset(query.Offset).Find(&logs).Error
 
 	return logs, err
 }
 
 func (s *SQLStore) AuditLogsCount(query *types.AuditLogQuery) (int, error) {
-	var err error
 	var count int
+	err := auditLogsQuery(s.db.Model(&types.AuditLog{}), query).Count(&count).Error
+	return count, err
+}
 
-	if len(query.ResourceKindFilter) == 1 && query.ResourceKindFilter[0] == "*" {
-		err = s.db.Model(&types.AuditLog{}).Count(&count).Error
-	} else if query.Username != "" {
-		err = s.db.Model(&types.AuditLog{}).Where("resource_kind in (?)", query.ResourceKindFilter).Where("username = ?", query.Username).Count(&count).Error
-	} else {
-		err = s.db.Model(&types.AuditLog{}).Where("resource_kind in (?)", query.ResourceKindFilter).Count(&count).Error
+func auditLogsQuery(tx *gorm.DB, query *types.AuditLogQuery) *gorm.DB {
+	if len(query.ResourceKindFilter) != 1 || query.ResourceKindFilter[0] != "*" {
+		tx = tx.Where("resource_kind in (?)", query.ResourceKindFilter)
+		if query.Username != "" {
+			tx = tx.Where("username = ?", query.Username)
+		}
 	}
-	return count, err
+	if query.Identifier != "" {
+		tx = tx.Where("identifier = ?", query.Identifier)
+	}
+	return tx
 }
 
 var logsWeeklyStats = `SELECT day, COALESCE(updates, 0) AS updates, COALESCE(approved, 0) as approved
@@ -37,7 +37,8 @@ func (s *SQLStore) GetGate(q *types.GetGateQuery) (*types.Gate, error) {
 	err := s.db.Where(&types.Gate{
 		ID:         q.ID,
 		Identifier: q.Identifier,
-	}).First(&result).Error
+		Version:    q.Version,
+	}).Order("created_at desc").First(&result).Error
 
 	if err == gorm.ErrRecordNotFound {
 
@@ -46,3 +47,16 @@ func (s *SQLStore) GetGate(q *types.GetGateQuery) (*types.Gate, error) {
 
 	return &result, err
 }
+
+func (s *SQLStore) ListGates(q *types.GetGateQuery) ([]*types.Gate, error) {
+	var gates []*types.Gate
+	db := s.db
+	if q.Pending {
+		db = db.Where("complete = ?", false)
+	}
+	err := db.Order("created_at desc").Where(&types.Gate{
+		Identifier: q.Identifier,
+		Version:    q.Version,
+	}).Find(&gates).Error
+	return gates, err
+}
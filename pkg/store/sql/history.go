@@ -0,0 +1,46 @@
+package sql
+
+import (
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/types"
+)
+
+func (s *SQLStore) CreateHistoryEntry(entry *types.HistoryEntry) (*types.HistoryEntry, error) {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+
+	tx := s.db.Begin()
+	if err := tx.Create(entry).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.Commit()
+
+	return entry, nil
+}
+
+func (s *SQLStore) GetHistoryEntry(id string) (*types.HistoryEntry, error) {
+	var result types.HistoryEntry
+	err := s.db.Where(&types.HistoryEntry{ID: id}).First(&result).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, store.ErrRecordNotFound
+	}
+	return &result, err
+}
+
+// ListHistory - lists history of a resource, newest first
+func (s *SQLStore) ListHistory(q *types.GetHistoryQuery) ([]*types.HistoryEntry, error) {
+	var entries []*types.HistoryEntry
+	tx := s.db.Order("created_at desc").Where(&types.HistoryEntry{
+		Identifier: q.Identifier,
+	})
+	if q.Limit > 0 {
+		tx = tx.Limit(q.Limit)
+	}
+	err := tx.Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,90 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mattn/go-sqlite3"
+	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/types"
+)
+
+// UseLinkToken - records link token as used, fails with store.ErrTokenUsed
+// if it was already used
+func (s *SQLStore) UseLinkToken(token *types.LinkToken) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return useLinkToken(tx, token)
+	})
+}
+
+// UpdateApprovalWithLinkToken - saves approval voted through the link and records
+// link token as used in a single transaction, approval is not saved if the token
+// was already used (store.ErrTokenUsed). Event is optional, it's appended to the
+// outbox when the vote approves the update.
+func (s *SQLStore) UpdateApprovalWithLinkToken(approval *types.Approval, event *types.ApprovalEvent, token *types.LinkToken) error {
+	if approval.ID == "" {
+		return fmt.Errorf("ID not specified")
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := useLinkToken(tx, token)
+		if err != nil {
+			return err
+		}
+		err = tx.Save(approval).Error
+		if err != nil {
+			return err
+		}
+		if event == nil {
+			return nil
+		}
+		return createApprovalEvent(tx, event)
+	})
+}
+
+func useLinkToken(tx *gorm.DB, token *types.LinkToken) error {
+	if token.ID == "" {
+		return fmt.Errorf("ID not specified")
+	}
+
+	var existing types.LinkToken
+	err := tx.Where("id = ?", token.ID).First(&existing).Error
+	if err == nil {
+		return store.ErrTokenUsed
+	}
+	if err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	// concurrent use of the same token passes the check above, the
+	// primary key makes sure that only one of them is recorded
+	err = tx.Create(token).Error
+	if err != nil && isUniqueViolation(err) {
+		return store.ErrTokenUsed
+	}
+	return err
+}
+
+// isUniqueViolation - reports whether the insert failed on a primary key or
+// unique constraint
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	// postgres unique_violation (23505)
+	return strings.Contains(err.Error(), "duplicate key value violates unique constraint")
+}
+
+// GetLinkToken - gets used link token, store.ErrRecordNotFound is returned
+// while the token is unused
+func (s *SQLStore) GetLinkToken(id string) (*types.LinkToken, error) {
+	var result types.LinkToken
+	err := s.db.Where("id = ?", id).First(&result).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, store.ErrRecordNotFound
+	}
+	return &result, err
+}
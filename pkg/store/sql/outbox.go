@@ -0,0 +1,110 @@
+package sql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/types"
+)
+
+// outboxLockKey - postgres advisory lock that serializes outbox writers. Sequence
+// numbers are then committed in order, otherwise a subscriber could move its
+// cursor past an event that isn't visible yet and never see it
+const outboxLockKey = 7134001
+
+// CreateApprovalEvent - appends event to the outbox, Seq is assigned by the database
+func (s *SQLStore) CreateApprovalEvent(event *types.ApprovalEvent) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return createApprovalEvent(tx, event)
+	})
+}
+
+// CreateApprovalWithEvent - creates approval and appends event to the outbox
+// in a single transaction
+func (s *SQLStore) CreateApprovalWithEvent(approval *types.Approval, event *types.ApprovalEvent) error {
+	if approval.ID == "" {
+		return fmt.Errorf("ID not specified")
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(approval).Error
+		if err != nil {
+			return err
+		}
+		return createApprovalEvent(tx, event)
+	})
+}
+
+// UpdateApprovalWithEvent - saves approval and appends event to the outbox in
+// a single transaction
+func (s *SQLStore) UpdateApprovalWithEvent(approval *types.Approval, event *types.ApprovalEvent) error {
+	if approval.ID == "" {
+		return fmt.Errorf("ID not specified")
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Save(approval).Error
+		if err != nil {
+			return err
+		}
+		return createApprovalEvent(tx, event)
+	})
+}
+
+func createApprovalEvent(tx *gorm.DB, event *types.ApprovalEvent) error {
+	if event.Kind == "" {
+		return fmt.Errorf("kind not specified")
+	}
+	if tx.Dialect().GetName() == "postgres" {
+		err := tx.Exec("SELECT pg_advisory_xact_lock(?)", outboxLockKey).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Create(event).Error
+}
+
+// ListApprovalEvents - lists events after the sequence number, oldest first
+func (s *SQLStore) ListApprovalEvents(q *types.GetApprovalEventsQuery) ([]*types.ApprovalEvent, error) {
+	limit := q.Limit
+	if limit == 0 {
+		limit = -1
+	}
+	var events []*types.ApprovalEvent
+	err := s.db.Where("kind = ? AND seq > ?", q.Kind, q.After).Order("seq").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// LastApprovalEventSeq - sequence number of the newest event, 0 if outbox is empty
+func (s *SQLStore) LastApprovalEventSeq() (uint64, error) {
+	var last types.ApprovalEvent
+	err := s.db.Order("seq desc").First(&last).Error
+	if err == gorm.ErrRecordNotFound {
+		return 0, nil
+	}
+	return last.Seq, err
+}
+
+// DeleteApprovalEvents - prunes events published before the given time
+func (s *SQLStore) DeleteApprovalEvents(before time.Time) error {
+	return s.db.Where("created_at < ?", before).Delete(&types.ApprovalEvent{}).Error
+}
+
+// GetOutboxCursor - gets subscriber's cursor, store.ErrRecordNotFound is returned
+// for new subscribers
+func (s *SQLStore) GetOutboxCursor(subscriber string) (*types.OutboxCursor, error) {
+	var result types.OutboxCursor
+	err := s.db.Where("subscriber = ?", subscriber).First(&result).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, store.ErrRecordNotFound
+	}
+	return &result, err
+}
+
+// SaveOutboxCursor - creates or moves subscriber's cursor
+func (s *SQLStore) SaveOutboxCursor(cursor *types.OutboxCursor) error {
+	if cursor.Subscriber == "" {
+		return fmt.Errorf("subscriber not specified")
+	}
+	return s.db.Save(cursor).Error
+}
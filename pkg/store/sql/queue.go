@@ -0,0 +1,62 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/quilla-hq/quilla/types"
+)
+
+// SaveQueuedEvent - creates or replaces queued event with the same key
+func (s *SQLStore) SaveQueuedEvent(event *types.QueuedEvent) error {
+	if event.ID == "" {
+		return fmt.Errorf("ID not specified")
+	}
+	return s.db.Save(event).Error
+}
+
+// DeleteQueuedEvent - removes queued event once it's processed
+func (s *SQLStore) DeleteQueuedEvent(id string) error {
+	return s.db.Where("id = ?", id).Delete(&types.QueuedEvent{}).Error
+}
+
+// migrateQueuedEvents - queued events used to be keyed by repository, table is
+// recreated keyed by queue key keeping pending events
+func migrateQueuedEvents(db *gorm.DB) error {
+	if !db.HasTable(&types.QueuedEvent{}) || db.Dialect().HasColumn("queued_events", "id") {
+		return nil
+	}
+
+	var events []*types.QueuedEvent
+	err := db.Table("queued_events").Select("repository, created_at, updated_at, event, attempts").Find(&events).Error
+	if err != nil {
+		return err
+	}
+	err = db.DropTable(&types.QueuedEvent{}).Error
+	if err != nil {
+		return err
+	}
+	err = db.CreateTable(&types.QueuedEvent{}).Error
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if event.Event == nil {
+			continue
+		}
+		event.ID = types.QueueKey(event.Event)
+		err = db.Save(event).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListQueuedEvents - lists pending events, oldest first
+func (s *SQLStore) ListQueuedEvents() ([]*types.QueuedEvent, error) {
+	var events []*types.QueuedEvent
+	err := s.db.Order("created_at").Find(&events).Error
+	return events, err
+}
@@ -31,9 +31,25 @@ func New(opts Opts) (*SQLStore, error) {
 		return nil, err
 	}
 
+	err = migrateQueuedEvents(db)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("queued events migration failed")
+		return nil, err
+	}
+
 	err = db.AutoMigrate(
 		&types.Approval{},
 		&types.AuditLog{},
+		&types.QueuedEvent{},
+		&types.Gate{},
+		&types.ResourceVersion{},
+		&types.WaitingUpdate{},
+		&types.HistoryEntry{},
+		&types.LinkToken{},
+		&types.ApprovalEvent{},
+		&types.OutboxCursor{},
 	).Error
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -42,9 +58,27 @@ func New(opts Opts) (*SQLStore, error) {
 		return nil, err
 	}
 
-	return &SQLStore{
+	s := &SQLStore{
 		db: db,
-	}, nil
+	}
+
+	err = s.migrateApprovalStates()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("approval states migration failed")
+		return nil, err
+	}
+
+	err = s.migrateApprovalNamespaces()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("approval namespaces migration failed")
+		return nil, err
+	}
+
+	return s, nil
 }
 
 // Close - closes database connection
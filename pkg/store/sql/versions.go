@@ -0,0 +1,57 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/types"
+)
+
+func (s *SQLStore) CreateResourceVersion(version *types.ResourceVersion) (*types.ResourceVersion, error) {
+	if version.ID == "" {
+		version.ID = uuid.New().String()
+	}
+
+	tx := s.db.Begin()
+	if err := tx.Create(version).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.Commit()
+
+	return version, nil
+}
+
+func (s *SQLStore) UpdateResourceVersion(version *types.ResourceVersion) error {
+	if version.ID == "" {
+		return fmt.Errorf("ID not specified")
+	}
+	return s.db.Save(version).Error
+}
+
+func (s *SQLStore) GetResourceVersion(q *types.GetResourceVersionQuery) (*types.ResourceVersion, error) {
+	var result types.ResourceVersion
+	err := s.db.Where(&types.ResourceVersion{
+		Identifier: q.Identifier,
+		Version:    q.Version,
+	}).Order("created_at desc").First(&result).Error
+
+	if err == gorm.ErrRecordNotFound {
+		return nil, store.ErrRecordNotFound
+	}
+
+	return &result, err
+}
+
+// ListResourceVersions - lists resource versions, newest first
+func (s *SQLStore) ListResourceVersions(q *types.GetResourceVersionQuery) ([]*types.ResourceVersion, error) {
+	var versions []*types.ResourceVersion
+	err := s.db.Order("created_at desc").Where(&types.ResourceVersion{
+		Identifier: q.Identifier,
+		Version:    q.Version,
+	}).Find(&versions).Error
+	return versions, err
+}
@@ -0,0 +1,27 @@
+package sql
+
+import (
+	"fmt"
+
+	"github.com/quilla-hq/quilla/types"
+)
+
+// SaveWaitingUpdate - creates or replaces waiting update of the resource
+func (s *SQLStore) SaveWaitingUpdate(update *types.WaitingUpdate) error {
+	if update.Identifier == "" {
+		return fmt.Errorf("identifier not specified")
+	}
+	return s.db.Save(update).Error
+}
+
+// DeleteWaitingUpdate - removes waiting update once it's applied
+func (s *SQLStore) DeleteWaitingUpdate(identifier string) error {
+	return s.db.Where("identifier = ?", identifier).Delete(&types.WaitingUpdate{}).Error
+}
+
+// ListWaitingUpdates - lists waiting updates, oldest first
+func (s *SQLStore) ListWaitingUpdates() ([]*types.WaitingUpdate, error) {
+	var updates []*types.WaitingUpdate
+	err := s.db.Order("created_at").Find(&updates).Error
+	return updates, err
+}
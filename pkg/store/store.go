@@ -2,6 +2,7 @@ package store
 
 import (
 	"errors"
+	"time"
 
 	"github.com/quilla-hq/quilla/types"
 )
@@ -16,8 +17,44 @@ type Store interface {
 	UpdateApproval(approval *types.Approval) error
 	GetApproval(q *types.GetApprovalQuery) (*types.Approval, error)
 	ListApprovals(q *types.GetApprovalQuery) ([]*types.Approval, error)
+	ApprovalsCount(q *types.GetApprovalQuery) (int, error)
 	DeleteApproval(approval *types.Approval) error
 
+	CreateGate(gate *types.Gate) (*types.Gate, error)
+	UpdateGate(gate *types.Gate) error
+	GetGate(q *types.GetGateQuery) (*types.Gate, error)
+	ListGates(q *types.GetGateQuery) ([]*types.Gate, error)
+
+	CreateResourceVersion(version *types.ResourceVersion) (*types.ResourceVersion, error)
+	UpdateResourceVersion(version *types.ResourceVersion) error
+	GetResourceVersion(q *types.GetResourceVersionQuery) (*types.ResourceVersion, error)
+	ListResourceVersions(q *types.GetResourceVersionQuery) ([]*types.ResourceVersion, error)
+
+	SaveQueuedEvent(event *types.QueuedEvent) error
+	DeleteQueuedEvent(id string) error
+	ListQueuedEvents() ([]*types.QueuedEvent, error)
+
+	SaveWaitingUpdate(update *types.WaitingUpdate) error
+	DeleteWaitingUpdate(identifier string) error
+	ListWaitingUpdates() ([]*types.WaitingUpdate, error)
+
+	CreateHistoryEntry(entry *types.HistoryEntry) (*types.HistoryEntry, error)
+	GetHistoryEntry(id string) (*types.HistoryEntry, error)
+	ListHistory(q *types.GetHistoryQuery) ([]*types.HistoryEntry, error)
+
+	UseLinkToken(token *types.LinkToken) error
+	UpdateApprovalWithLinkToken(approval *types.Approval, event *types.ApprovalEvent, token *types.LinkToken) error
+	GetLinkToken(id string) (*types.LinkToken, error)
+
+	CreateApprovalEvent(event *types.ApprovalEvent) error
+	CreateApprovalWithEvent(approval *types.Approval, event *types.ApprovalEvent) error
+	UpdateApprovalWithEvent(approval *types.Approval, event *types.ApprovalEvent) error
+	ListApprovalEvents(q *types.GetApprovalEventsQuery) ([]*types.ApprovalEvent, error)
+	LastApprovalEventSeq() (uint64, error)
+	DeleteApprovalEvents(before time.Time) error
+	GetOutboxCursor(subscriber string) (*types.OutboxCursor, error)
+	SaveOutboxCursor(cursor *types.OutboxCursor) error
+
 	OK() bool
 	Close() error
 }
@@ -25,4 +62,5 @@ type Store interface {
 // errors
 var (
 	ErrRecordNotFound = errors.New("record not found")
+	ErrTokenUsed      = errors.New("token already used")
 )
@@ -2,14 +2,23 @@ package helm3
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/registry"
 	"github.com/quilla-hq/quilla/types"
+	"github.com/quilla-hq/quilla/util/image"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// SetDigestResolver - sets resolver that looks up image digests, approvals of
+// events without a digest can't be pinned to the image without it
+func (p *Provider) SetDigestResolver(digests registry.DigestResolver) {
+	p.digests = digests
+}
+
 // namespace/release name:version
 func getIdentifier(plan *UpdatePlan) string {
 	return fmt.Sprintf("%s/%s:%s", plan.Namespace, plan.Name, plan.NewVersion)
@@ -45,6 +54,15 @@ func (p *Provider) isApproved(event *types.Event, plan *UpdatePlan) (bool, error
 		return true, nil
 	}
 
+	approvers, err := types.ParseApproverRules(strings.Join(plan.Config.Approvers, ","))
+	if err != nil {
+		return false, err
+	}
+	veto, err := types.ParseVetoRule(plan.Config.Veto)
+	if err != nil {
+		return false, err
+	}
+
 	identifier := getIdentifier(plan)
 
 	// checking for existing approval
@@ -67,13 +85,35 @@ func (p *Provider) isApproved(event *types.Event, plan *UpdatePlan) (bool, error
 			approval := &types.Approval{
 				Provider:       types.ProviderTypeHelm,
 				Identifier:     identifier,
+				Namespace:      plan.Namespace,
 				Event:          event,
 				CurrentVersion: plan.CurrentVersion,
 				NewVersion:     plan.NewVersion,
 				VotesRequired:  plan.Config.Approvals,
 				VotesReceived:  0,
+				Approvers:      approvers,
+				Veto:           veto,
 				Rejected:       false,
 				Deadline:       time.Now().Add(time.Duration(plan.Config.ApprovalDeadline) * time.Hour),
+				Channels:       plan.Config.NotificationChannels,
+			}
+			if plan.Config.AutoApproveAfter != "" {
+				after, err := time.ParseDuration(plan.Config.AutoApproveAfter)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"error":        err,
+						"release_name": plan.Name,
+						"namespace":    plan.Namespace,
+					}).Warn("provider.helm3: failed to parse auto-approve duration, approval won't be auto-approved")
+				} else {
+					approval.AutoApproveAt = time.Now().Add(after)
+					approval.AutoApproveAfter = after
+				}
+			}
+			approval.Digest = p.approvalDigest(event, plan)
+			approval.Plan = approvalPlan(plan)
+			if plan.Config.SeparationOfDuties {
+				approval.Author = event.Author
 			}
 
 			approval.Message = fmt.Sprintf("New image is available for release %s/%s (%s).",
@@ -88,5 +128,157 @@ func (p *Provider) isApproved(event *types.Event, plan *UpdatePlan) (bool, error
 		return false, err
 	}
 
-	return existing.Status() == types.ApprovalStatusApproved, nil
+	verified, err := p.verifyDigest(event, plan, existing)
+	if !verified {
+		return false, err
+	}
+
+	if existing.Status() != types.ApprovalStatusApproved {
+		return false, nil
+	}
+
+	// approved plan is applied only if nothing changed since it was requested
+	if !existing.Plan.Empty() {
+		if drift := existing.Plan.Drift(approvalPlan(plan)); len(drift) > 0 {
+			p.notifyDrift(plan, existing, drift)
+			return false, fmt.Errorf("update changed since approval %s was requested: %s", existing.Identifier, strings.Join(drift, "; "))
+		}
+	}
+	plan.Approvers = existing.GetVoters()
+	return true, nil
+}
+
+// resolveDigest - digest the event tag currently points to, empty when
+// digest resolver isn't set
+func (p *Provider) resolveDigest(repo *types.Repository, plan *UpdatePlan) (string, error) {
+	if p.digests == nil {
+		return "", nil
+	}
+	ref, err := image.Parse(repo.String())
+	if err != nil {
+		return "", err
+	}
+	ti := &types.TrackedImage{
+		Image:     ref,
+		Provider:  ProviderName,
+		Namespace: plan.Namespace,
+	}
+	for _, imageDetails := range plan.Config.Images {
+		if imageDetails.ImagePullSecret != "" {
+			ti.Secrets = append(ti.Secrets, imageDetails.ImagePullSecret)
+		}
+	}
+	return p.digests.Digest(ti)
+}
+
+// approvalDigest - digest of the image approval is requested for, resolved
+// from registry when the event doesn't have it
+func (p *Provider) approvalDigest(event *types.Event, plan *UpdatePlan) string {
+	if event.Repository.Digest != "" {
+		return event.Repository.Digest
+	}
+	digest, err := p.resolveDigest(&event.Repository, plan)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":        err,
+			"release_name": plan.Name,
+			"namespace":    plan.Namespace,
+			"image":        event.Repository.String(),
+		}).Warn("provider.helm3: failed to resolve image digest, approval isn't pinned to the image")
+	}
+	pinDigest(plan, digest)
+	return digest
+}
+
+// pinDigest - release is updated to the approved digest
+func pinDigest(plan *UpdatePlan, digest string) {
+	plan.Digest = digest
+	for _, imageDetails := range plan.Config.Images {
+		if _, ok := plan.Values[imageDetails.DigestPath]; ok && imageDetails.DigestPath != "" {
+			plan.Values[imageDetails.DigestPath] = digest
+		}
+	}
+}
+
+// verifyDigest - checks that the tag still points to the image that is being
+// approved, votes are reset when it doesn't. Digest of approved updates is
+// resolved from registry as re-submitted approval events carry the original one.
+func (p *Provider) verifyDigest(event *types.Event, plan *UpdatePlan, existing *types.Approval) (bool, error) {
+	if existing.Digest == "" {
+		return true, nil
+	}
+
+	digest := event.Repository.Digest
+	if existing.Status() == types.ApprovalStatusApproved {
+		resolved, err := p.resolveDigest(&event.Repository, plan)
+		if err != nil {
+			return false, fmt.Errorf("failed to verify digest of approved image: %s", err)
+		}
+		if resolved != "" {
+			digest = resolved
+		}
+	}
+
+	if digest == "" || digest == existing.Digest {
+		pinDigest(plan, existing.Digest)
+		return true, nil
+	}
+
+	_, err := p.approvalManager.ResetVotes(existing.Identifier, digest)
+	if err != nil {
+		return false, fmt.Errorf("failed to reset approval after changed digest: %s", err)
+	}
+
+	p.sender.Send(types.EventNotification{
+		ResourceKind: "chart",
+		Identifier:   getReleaseIdentifier(plan.Namespace, plan.Name),
+		Name:         "approval reset",
+		Message: fmt.Sprintf("Image %s was pushed again after approval was requested, votes for release %s/%s %s -> %s were reset (digest %s -> %s)",
+			event.Repository.String(), plan.Namespace, plan.Name, plan.CurrentVersion, plan.NewVersion, existing.Digest, digest),
+		CreatedAt: time.Now(),
+		Type:      types.NotificationPreReleaseUpdate,
+		Level:     types.LevelWarn,
+		Channels:  plan.Config.NotificationChannels,
+		Metadata: map[string]string{
+			"provider":  p.GetName(),
+			"namespace": plan.Namespace,
+			"name":      plan.Name,
+		},
+	})
+	return false, nil
+}
+
+// approvalPlan - values paths updated by the plan
+func approvalPlan(plan *UpdatePlan) *types.ApprovalPlan {
+	approvalPlan := &types.ApprovalPlan{Digest: plan.Digest}
+	for path, value := range plan.Values {
+		approvalPlan.Changes = append(approvalPlan.Changes, types.PlannedChange{
+			Resource: getReleaseIdentifier(plan.Namespace, plan.Name),
+			Target:   path,
+			From:     plan.Previous[path],
+			To:       value,
+		})
+	}
+	approvalPlan.Sort()
+	return approvalPlan
+}
+
+// notifyDrift - approved update isn't applied as release changed since approval was requested
+func (p *Provider) notifyDrift(plan *UpdatePlan, approval *types.Approval, drift []string) {
+	p.sender.Send(types.EventNotification{
+		ResourceKind: "chart",
+		Identifier:   getReleaseIdentifier(plan.Namespace, plan.Name),
+		Name:         "approved update not applied",
+		Message: fmt.Sprintf("Approved update %s -> %s of release %s/%s wasn't applied, it changed since approval %s was requested: %s. Reject the approval or revert the changes.",
+			plan.CurrentVersion, plan.NewVersion, plan.Namespace, plan.Name, approval.Identifier, strings.Join(drift, "; ")),
+		CreatedAt: time.Now(),
+		Type:      types.NotificationPreReleaseUpdate,
+		Level:     types.LevelError,
+		Channels:  plan.Config.NotificationChannels,
+		Metadata: map[string]string{
+			"provider":  p.GetName(),
+			"namespace": plan.Namespace,
+			"name":      plan.Name,
+		},
+	})
 }
@@ -0,0 +1,113 @@
+package helm3
+
+import (
+	"testing"
+
+	"github.com/quilla-hq/quilla/types"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+type fakeDigestResolver struct {
+	digest string
+}
+
+func (r *fakeDigestResolver) Digest(image *types.TrackedImage) (string, error) {
+	return r.digest, nil
+}
+
+func TestApprovalPinnedToDigest(t *testing.T) {
+	chartVals := `
+image:
+  repository: karolisr/webhook-demo
+  tag: 0.0.10
+  digest: sha256:000
+
+quilla:
+  policy: all
+  approvals: 1
+  images:
+    - repository: image.repository
+      tag: image.tag
+      digest: image.digest
+`
+	myChart, err := testingStringToChart(chartVals)
+	if err != nil {
+		t.Fatalf("chartutil.ReadValues error = %v", err)
+	}
+
+	fakeImpl := &fakeImplementer{
+		listReleasesResponse: []*release.Release{
+			{
+				Name:      "release-1",
+				Namespace: "default",
+				Chart:     myChart,
+				Config:    make(map[string]interface{}),
+			},
+		},
+	}
+
+	approver, teardown := approver()
+	defer teardown()
+	sender := &fakeSender{}
+	provider := NewProvider(fakeImpl, sender, approver)
+	digests := &fakeDigestResolver{digest: "sha256:aaa"}
+	provider.SetDigestResolver(digests)
+
+	repo := types.Repository{Name: "karolisr/webhook-demo", Tag: "0.0.11"}
+	identifier := "default/release-1:0.0.11"
+
+	err = provider.processEvent(&types.Event{Repository: repo})
+	if err != nil {
+		t.Fatalf("failed to process event, error: %s", err)
+	}
+	approval, err := approver.Get(identifier)
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.Digest != "sha256:aaa" {
+		t.Errorf("expected approval to be pinned to resolved digest, got: %s", approval.Digest)
+	}
+
+	_, err = approver.Approve(identifier, &types.Voter{Name: "alice"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+
+	// tag was pushed again after the approval
+	digests.digest = "sha256:bbb"
+	approvalEvent := &types.Event{Repository: repo, TriggerName: types.TriggerTypeApproval.String()}
+	err = provider.processEvent(approvalEvent)
+	if err != nil {
+		t.Fatalf("failed to process event, error: %s", err)
+	}
+	if fakeImpl.updatedRlsName != "" {
+		t.Fatalf("re-pushed image shouldn't be released")
+	}
+
+	approval, err = approver.Get(identifier)
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.VotesReceived != 0 || approval.Digest != "sha256:bbb" {
+		t.Errorf("expected votes to be reset for new digest, got votes %d, digest %s", approval.VotesReceived, approval.Digest)
+	}
+	if sender.sentEvent.Name != "approval reset" {
+		t.Errorf("expected approval reset notification, got: %+v", sender.sentEvent)
+	}
+
+	_, err = approver.Approve(identifier, &types.Voter{Name: "alice"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+	err = provider.processEvent(approvalEvent)
+	if err != nil {
+		t.Fatalf("failed to process event, error: %s", err)
+	}
+	if fakeImpl.updatedRlsName != "release-1" {
+		t.Fatalf("expected approved release to be updated")
+	}
+	if fakeImpl.overriddenValues["image.digest"] != "sha256:bbb" {
+		t.Errorf("expected release to be pinned to approved digest, got: %v", fakeImpl.overriddenValues)
+	}
+}
@@ -0,0 +1,264 @@
+package helm3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+
+	log "github.com/sirupsen/logrus"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+var magicGzip = []byte{0x1f, 0x8b, 0x08}
+
+// cachedRelease - latest release revision together with its coalesced values
+// and parsed quilla configuration
+type cachedRelease struct {
+	release *release.Release
+	values  chartutil.Values
+	// config is nil when release doesn't have quilla configuration
+	config *quillaChartConfig
+}
+
+// identifier - chart/<namespace>/<release name>
+func (r *cachedRelease) identifier() string {
+	return getReleaseIdentifier(r.release.Namespace, r.release.Name)
+}
+
+func newCachedRelease(rls *release.Release) (*cachedRelease, error) {
+	vals, err := values(rls.Chart, rls.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := &cachedRelease{
+		release: rls,
+		values:  vals,
+	}
+
+	cfg, err := getquillaConfig(vals)
+	if err != nil {
+		if err != ErrPolicyNotSpecified {
+			log.WithFields(log.Fields{
+				"error":     err,
+				"release":   rls.Name,
+				"namespace": rls.Namespace,
+			}).Debug("provider.helm3: failed to get config for release")
+		}
+		return cr, nil
+	}
+	cr.config = cfg
+
+	return cr, nil
+}
+
+// ReleaseCache - keeps parsed helm releases up to date based on release
+// secrets events. Secrets are only decoded when a release changes.
+type ReleaseCache struct {
+	mu sync.Mutex
+
+	// release secrets, grouped by <namespace>/<release name> and keyed by secret name
+	secrets map[string]map[string]*v1.Secret
+	// parsed latest revisions, entries are removed when release secrets change,
+	// nil entry means that release is not listed (ie: uninstalled)
+	parsed map[string]*cachedRelease
+
+	synced cache.InformerSynced
+}
+
+// NewReleaseCache - create new release cache
+func NewReleaseCache() *ReleaseCache {
+	return &ReleaseCache{
+		secrets: make(map[string]map[string]*v1.Secret),
+		parsed:  make(map[string]*cachedRelease),
+	}
+}
+
+// SetSynced - sets function that reports whether release secrets informer
+// has synced, cache is not used until then
+func (c *ReleaseCache) SetSynced(synced cache.InformerSynced) {
+	c.mu.Lock()
+	c.synced = synced
+	c.mu.Unlock()
+}
+
+// HasSynced - returns true once cache holds all release secrets
+func (c *ReleaseCache) HasSynced() bool {
+	c.mu.Lock()
+	synced := c.synced
+	c.mu.Unlock()
+
+	return synced != nil && synced()
+}
+
+// OnAdd - adds release secret
+func (c *ReleaseCache) OnAdd(obj interface{}) {
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		return
+	}
+	key, ok := releaseSecretKey(secret)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.secrets[key]; !ok {
+		c.secrets[key] = make(map[string]*v1.Secret)
+	}
+	c.secrets[key][secret.Name] = secret
+	delete(c.parsed, key)
+}
+
+// OnUpdate - updates release secret, helm updates revision status (ie: deployed -> superseded)
+func (c *ReleaseCache) OnUpdate(oldObj, newObj interface{}) {
+	c.OnAdd(newObj)
+}
+
+// OnDelete - removes release secret
+func (c *ReleaseCache) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		return
+	}
+	key, ok := releaseSecretKey(secret)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.secrets[key], secret.Name)
+	if len(c.secrets[key]) == 0 {
+		delete(c.secrets, key)
+	}
+	delete(c.parsed, key)
+}
+
+// list - returns latest deployed or failed revision of every release,
+// same as helm list
+func (c *ReleaseCache) list() []*cachedRelease {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.secrets))
+	for key := range c.secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var releases []*cachedRelease
+	for _, key := range keys {
+		cr, ok := c.parsed[key]
+		if !ok {
+			cr = parseReleaseSecrets(c.secrets[key])
+			c.parsed[key] = cr
+		}
+		if cr != nil {
+			releases = append(releases, cr)
+		}
+	}
+
+	return releases
+}
+
+// parseReleaseSecrets - decodes the latest revision of the release
+func parseReleaseSecrets(secrets map[string]*v1.Secret) *cachedRelease {
+	var latest *v1.Secret
+	latestVersion := -1
+	for _, secret := range secrets {
+		version, err := strconv.Atoi(secret.Labels["version"])
+		if err != nil {
+			continue
+		}
+		if version > latestVersion {
+			latest = secret
+			latestVersion = version
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+
+	switch release.Status(latest.Labels["status"]) {
+	case release.StatusDeployed, release.StatusFailed:
+		// ok
+	default:
+		return nil
+	}
+
+	rls, err := decodeRelease(string(latest.Data["release"]))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":     err,
+			"secret":    latest.Name,
+			"namespace": latest.Namespace,
+		}).Error("provider.helm3: failed to decode release secret")
+		return nil
+	}
+
+	cr, err := newCachedRelease(rls)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":     err,
+			"release":   rls.Name,
+			"namespace": rls.Namespace,
+		}).Error("provider.helm3: failed to get values.yaml for release")
+		return nil
+	}
+
+	return cr
+}
+
+// releaseSecretKey - <namespace>/<release name> for secrets created by helm
+// secrets storage driver
+func releaseSecretKey(secret *v1.Secret) (string, bool) {
+	if secret.Labels["owner"] != "helm" || secret.Labels["name"] == "" {
+		return "", false
+	}
+	return fmt.Sprintf("%s/%s", secret.Namespace, secret.Labels["name"]), true
+}
+
+// decodeRelease - decodes release stored by helm secrets driver, base64
+// encoded and optionally gzipped json
+func decodeRelease(data string) (*release.Release, error) {
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > 3 && bytes.Equal(b[0:3], magicGzip) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		b, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rls release.Release
+	if err := json.Unmarshal(b, &rls); err != nil {
+		return nil, err
+	}
+	return &rls, nil
+}
@@ -0,0 +1,222 @@
+package helm3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/quilla-hq/quilla/types"
+
+	"helm.sh/helm/v3/pkg/release"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// testingReleaseSecret - encodes release the same way as helm secrets driver
+func testingReleaseSecret(t *testing.T, rls *release.Release) *v1.Secret {
+	b, err := json.Marshal(rls)
+	if err != nil {
+		t.Fatalf("failed to marshal release: %s", err)
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		t.Fatalf("failed to create gzip writer: %s", err)
+	}
+	w.Write(b)
+	w.Close()
+
+	return &v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", rls.Name, rls.Version),
+			Namespace: rls.Namespace,
+			Labels: map[string]string{
+				"owner":   "helm",
+				"name":    rls.Name,
+				"status":  rls.Info.Status.String(),
+				"version": strconv.Itoa(rls.Version),
+			},
+		},
+		Type: "helm.sh/release.v1",
+		Data: map[string][]byte{
+			"release": []byte(base64.StdEncoding.EncodeToString(buf.Bytes())),
+		},
+	}
+}
+
+func testingRelease(t *testing.T, name string, version int, status release.Status, tag string) *release.Release {
+	chartVals := fmt.Sprintf(`
+image:
+  repository: gcr.io/v2-namespace/hello-world
+  tag: %s
+
+quilla:
+  policy: all
+  trigger: poll
+  images:
+    - repository: image.repository
+      tag: image.tag
+`, tag)
+
+	myChart, err := testingStringToChart(chartVals)
+	if err != nil {
+		t.Fatalf("failed to create chart: %s", err)
+	}
+
+	return &release.Release{
+		Name:      name,
+		Namespace: "default",
+		Version:   version,
+		Chart:     myChart,
+		Config:    map[string]interface{}{},
+		Info:      &release.Info{Status: status},
+	}
+}
+
+func TestReleaseCacheLatestRevision(t *testing.T) {
+	c := NewReleaseCache()
+
+	v1Secret := testingReleaseSecret(t, testingRelease(t, "release-1", 1, release.StatusDeployed, "1.0.0"))
+	c.OnAdd(v1Secret)
+
+	releases := c.list()
+	if len(releases) != 1 {
+		t.Fatalf("expected 1 release, got: %d", len(releases))
+	}
+	if releases[0].config == nil {
+		t.Fatalf("expected quilla config to be parsed")
+	}
+	if releases[0].identifier() != "chart/default/release-1" {
+		t.Errorf("unexpected identifier: %s", releases[0].identifier())
+	}
+
+	// upgrade, previous revision is superseded
+	c.OnUpdate(v1Secret, testingReleaseSecret(t, testingRelease(t, "release-1", 1, release.StatusSuperseded, "1.0.0")))
+	c.OnAdd(testingReleaseSecret(t, testingRelease(t, "release-1", 2, release.StatusDeployed, "1.1.0")))
+
+	releases = c.list()
+	if len(releases) != 1 {
+		t.Fatalf("expected 1 release, got: %d", len(releases))
+	}
+	if releases[0].release.Version != 2 {
+		t.Errorf("expected revision 2, got: %d", releases[0].release.Version)
+	}
+	tag, _ := getValueAsString(releases[0].values, "image.tag")
+	if tag != "1.1.0" {
+		t.Errorf("unexpected image tag: %s", tag)
+	}
+}
+
+func TestReleaseCacheUninstalled(t *testing.T) {
+	c := NewReleaseCache()
+
+	deployed := testingReleaseSecret(t, testingRelease(t, "release-1", 1, release.StatusDeployed, "1.0.0"))
+	c.OnAdd(deployed)
+	c.OnAdd(testingReleaseSecret(t, testingRelease(t, "release-2", 1, release.StatusDeployed, "1.0.0")))
+
+	if len(c.list()) != 2 {
+		t.Fatalf("expected 2 releases")
+	}
+
+	// uninstalled with --keep-history
+	c.OnUpdate(deployed, testingReleaseSecret(t, testingRelease(t, "release-1", 1, release.StatusUninstalled, "1.0.0")))
+	releases := c.list()
+	if len(releases) != 1 || releases[0].release.Name != "release-2" {
+		t.Fatalf("expected only release-2 to be listed")
+	}
+
+	c.OnDelete(cache.DeletedFinalStateUnknown{
+		Key: "default/sh.helm.release.v1.release-2.v1",
+		Obj: testingReleaseSecret(t, testingRelease(t, "release-2", 1, release.StatusDeployed, "1.0.0")),
+	})
+	if len(c.list()) != 0 {
+		t.Errorf("expected no releases")
+	}
+}
+
+func TestReleaseCacheIgnoresOtherSecrets(t *testing.T) {
+	c := NewReleaseCache()
+	c.OnAdd(&v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "registry", Namespace: "default"},
+	})
+
+	if len(c.list()) != 0 {
+		t.Errorf("expected no releases")
+	}
+}
+
+func TestTrackedImagesFromCache(t *testing.T) {
+	// implementer doesn't have any releases, images have to come from the cache
+	fakeImpl := &fakeImplementer{}
+
+	approver, teardown := approver()
+	defer teardown()
+	prov := NewProvider(fakeImpl, &fakeSender{}, approver)
+
+	c := NewReleaseCache()
+	c.OnAdd(testingReleaseSecret(t, testingRelease(t, "release-1", 1, release.StatusDeployed, "1.0.0")))
+	prov.SetReleaseCache(c)
+
+	tracked, err := prov.TrackedImages()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tracked) != 0 {
+		t.Fatalf("cache is not synced yet, expected no images, got: %d", len(tracked))
+	}
+
+	c.SetSynced(func() bool { return true })
+
+	tracked, err = prov.TrackedImages()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tracked) != 1 {
+		t.Fatalf("expected 1 image, got: %d", len(tracked))
+	}
+	if tracked[0].Image.Remote() != "gcr.io/v2-namespace/hello-world:1.0.0" {
+		t.Errorf("unexpected image: %s", tracked[0].Image.Remote())
+	}
+}
+
+func TestUpdatePlansFromCachedValues(t *testing.T) {
+	fakeImpl := &fakeImplementer{}
+
+	approver, teardown := approver()
+	defer teardown()
+	prov := NewProvider(fakeImpl, &fakeSender{}, approver)
+
+	c := NewReleaseCache()
+	c.OnAdd(testingReleaseSecret(t, testingRelease(t, "release-1", 1, release.StatusDeployed, "1.0.0")))
+	c.SetSynced(func() bool { return true })
+	prov.SetReleaseCache(c)
+
+	// values are parsed once, events must not parse the release again
+	cached := c.list()
+	if len(cached) != 1 {
+		t.Fatalf("expected 1 cached release, got: %d", len(cached))
+	}
+	cached[0].release.Chart.Values = nil
+
+	plans, err := prov.createUpdatePlans(&types.Event{
+		Repository: types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("expected 1 plan, got: %d", len(plans))
+	}
+	if plans[0].CurrentVersion != "1.0.0" {
+		t.Errorf("unexpected current version: %s", plans[0].CurrentVersion)
+	}
+	if plans[0].Values["image.tag"] != "1.1.0" {
+		t.Errorf("unexpected new tag: %s", plans[0].Values["image.tag"])
+	}
+}
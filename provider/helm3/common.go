@@ -32,6 +32,13 @@ func getImages(vals chartutil.Values) ([]*types.TrackedImage, error) {
 		return nil, ErrquillaConfigNotFound
 	}
 
+	return imagesFromConfig(vals, quillaCfg), nil
+}
+
+// imagesFromConfig - get images from already parsed quilla configuration
+func imagesFromConfig(vals chartutil.Values, quillaCfg *quillaChartConfig) []*types.TrackedImage {
+	var images []*types.TrackedImage
+
 	for _, imageDetails := range quillaCfg.Images {
 		imageRef, err := parseImage(vals, &imageDetails)
 		if err != nil {
@@ -57,7 +64,7 @@ func getImages(vals chartutil.Values) ([]*types.TrackedImage, error) {
 		images = append(images, trackedImage)
 	}
 
-	return images, nil
+	return images
 }
 
 func getPlanValues(newVersion *types.Version, ref *image.Reference, imageDetails *ImageDetails) (path, value string) {
@@ -8,8 +8,10 @@ import (
 
 	"github.com/quilla-hq/quilla/approvals"
 	"github.com/quilla-hq/quilla/internal/policy"
+	"github.com/quilla-hq/quilla/registry"
 	"github.com/quilla-hq/quilla/types"
 	"github.com/quilla-hq/quilla/util/image"
+	"github.com/quilla-hq/quilla/util/keylock"
 
 	"github.com/prometheus/client_golang/prometheus"
 
@@ -77,6 +79,8 @@ type UpdatePlan struct {
 
 	// values to update path=value
 	Values map[string]string
+	// values before the update path=value
+	Previous map[string]string
 
 	// Current (last seen cluster version)
 	CurrentVersion string
@@ -88,6 +92,15 @@ type UpdatePlan struct {
 
 	// used as fix to bug in chartutil.coalesce v3.1.2
 	EmptyConfig bool
+
+	// Image - updated repository, Paths - updated values paths
+	Image string
+	Paths []string
+
+	// Trigger, Digest and Approvers are recorded in version history
+	Trigger   string
+	Digest    string
+	Approvers types.Users
 }
 
 // quilla:
@@ -113,8 +126,12 @@ type quillaChartConfig struct {
 	MatchPreRelease      bool              `json:"matchPreRelease"`
 	Trigger              types.TriggerType `json:"trigger"`
 	PollSchedule         string            `json:"pollSchedule"`
-	Approvals            int               `json:"approvals"`        // Minimum required approvals
-	ApprovalDeadline     int               `json:"approvalDeadline"` // Deadline in hours
+	Approvals            int               `json:"approvals"`          // Minimum required approvals
+	ApprovalDeadline     int               `json:"approvalDeadline"`   // Deadline in hours
+	Approvers            []string          `json:"approvers"`          // Eligible approvers, ie: group:sre=1
+	Veto                 string            `json:"veto"`               // Rejections that veto the update, ie: 2,group:sre
+	SeparationOfDuties   bool              `json:"separationOfDuties"` // Image author can't approve
+	AutoApproveAfter     string            `json:"autoApproveAfter"`   // Approve when nobody rejects in time, ie: 4h
 	Images               []ImageDetails    `json:"images"`
 	NotificationChannels []string          `json:"notificationChannels"` // optional notification channels
 
@@ -138,8 +155,19 @@ type Provider struct {
 
 	approvalManager approvals.Manager
 
-	events chan *types.Event
-	stop   chan struct{}
+	// optional, when set releases are read from the cache instead
+	// of listing them on every event
+	cache *ReleaseCache
+
+	// serialises upgrades of the same release, events are processed
+	// by multiple queue workers
+	locks keylock.KeyLock
+
+	history HistoryStore
+
+	digests registry.DigestResolver
+
+	stop chan struct{}
 }
 
 // NewProvider - create new Helm provider
@@ -148,20 +176,24 @@ func NewProvider(implementer Implementer, sender notification.Sender, approvalMa
 		implementer:     implementer,
 		approvalManager: approvalManager,
 		sender:          sender,
-		events:          make(chan *types.Event, 100),
 		stop:            make(chan struct{}),
 	}
 }
 
+// SetReleaseCache - use release cache, maintained by release secrets informer,
+// instead of listing releases through the implementer
+func (p *Provider) SetReleaseCache(cache *ReleaseCache) {
+	p.cache = cache
+}
+
 // GetName - get provider name
 func (p *Provider) GetName() string {
 	return ProviderName
 }
 
-// Submit - submit event to provider
+// Submit - process event, safe to call concurrently
 func (p *Provider) Submit(event types.Event) error {
-	p.events <- &event
-	return nil
+	return p.processEvent(&event)
 }
 
 // Start - starts kubernetes provider, waits for events
@@ -178,54 +210,28 @@ func (p *Provider) Stop() {
 func (p *Provider) TrackedImages() ([]*types.TrackedImage, error) {
 	var trackedImages []*types.TrackedImage
 
-	releases, err := p.implementer.ListReleases()
+	releases, err := p.releases()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, release := range releases {
-		// getting configuration
-		vals, err := values(release.Chart, release.Config)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error":     err,
-				"release":   release.Name,
-				"namespace": release.Namespace,
-			}).Error("provider.helm3: failed to get values.yaml for release")
+	for _, cr := range releases {
+		if cr.config == nil {
 			continue
 		}
+		release := cr.release
 
-		cfg, err := getquillaConfig(vals)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error":     err,
-				"release":   release.Name,
-				"namespace": release.Namespace,
-			}).Debug("provider.helm3: failed to get config for release")
-			continue
-		}
-
-		if cfg.PollSchedule == "" {
-			cfg.PollSchedule = types.QuillaPollDefaultSchedule
-		}
 		// used to check pod secrets
 		selector := fmt.Sprintf("app=%s,release=%s", release.Chart.Metadata.Name, release.Name)
 
-		releaseImages, err := getImages(vals)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error":     err,
-				"release":   release.Name,
-				"namespace": release.Namespace,
-			}).Error("provider.helm3: failed to get images for release")
-			continue
-		}
-
-		for _, img := range releaseImages {
+		for _, img := range imagesFromConfig(cr.values, cr.config) {
 			img.Meta = map[string]string{
 				"selector":      selector,
 				"helm.sh/chart": fmt.Sprintf("%s-%s", release.Chart.Metadata.Name, release.Chart.Metadata.Version),
 			}
+			if img.PollSchedule == "" {
+				img.PollSchedule = types.QuillaPollDefaultSchedule
+			}
 			img.Namespace = release.Namespace
 			img.Provider = ProviderName
 			trackedImages = append(trackedImages, img)
@@ -236,23 +242,39 @@ func (p *Provider) TrackedImages() ([]*types.TrackedImage, error) {
 	return trackedImages, nil
 }
 
-func (p *Provider) startInternal() error {
-	for {
-		select {
-		case event := <-p.events:
-			err := p.processEvent(event)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"error": err,
-					"image": event.Repository.Name,
-					"tag":   event.Repository.Tag,
-				}).Error("provider.helm3: failed to process event")
-			}
-		case <-p.stop:
-			log.Info("provider.helm3: got shutdown signal, stopping...")
-			return nil
+// releases - returns parsed releases from the cache once it's synced,
+// otherwise lists and parses releases through the implementer
+func (p *Provider) releases() ([]*cachedRelease, error) {
+	if p.cache != nil && p.cache.HasSynced() {
+		return p.cache.list(), nil
+	}
+
+	releases, err := p.implementer.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed []*cachedRelease
+	for _, release := range releases {
+		cr, err := newCachedRelease(release)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":     err,
+				"release":   release.Name,
+				"namespace": release.Namespace,
+			}).Error("provider.helm3: failed to get values.yaml for release")
+			continue
 		}
+		parsed = append(parsed, cr)
 	}
+
+	return parsed, nil
+}
+
+func (p *Provider) startInternal() error {
+	<-p.stop
+	log.Info("provider.helm3: got shutdown signal, stopping...")
+	return nil
 }
 
 func (p *Provider) processEvent(event *types.Event) (err error) {
@@ -261,6 +283,11 @@ func (p *Provider) processEvent(event *types.Event) (err error) {
 		return err
 	}
 
+	for _, plan := range plans {
+		plan.Trigger = event.TriggerName
+		plan.Digest = event.Repository.Digest
+	}
+
 	approved := p.checkForApprovals(event, plans)
 
 	return p.applyPlans(approved)
@@ -269,14 +296,19 @@ func (p *Provider) processEvent(event *types.Event) (err error) {
 func (p *Provider) createUpdatePlans(event *types.Event) ([]*UpdatePlan, error) {
 	var plans []*UpdatePlan
 
-	releases, err := p.implementer.ListReleases()
+	releases, err := p.releases()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, release := range releases {
+	for _, cr := range releases {
+		if cr.config == nil {
+			// no quilla configuration
+			continue
+		}
+		release := cr.release
 
-		plan, update, err := checkRelease(&event.Repository, release.Namespace, release.Name, release.Chart, release.Config)
+		plan, update, err := checkReleaseValues(&event.Repository, release.Namespace, release.Name, release.Chart, release.Config == nil, cr.values, cr.config)
 		if err != nil {
 			log.WithFields(log.Fields{
 				"error":     err,
@@ -286,6 +318,15 @@ func (p *Provider) createUpdatePlans(event *types.Event) ([]*UpdatePlan, error)
 			continue
 		}
 
+		if update && p.heldBack(plan) {
+			log.WithFields(log.Fields{
+				"name":      release.Name,
+				"namespace": release.Namespace,
+				"version":   plan.NewVersion,
+			}).Debug("provider.helm3: version was rolled back, ignoring")
+			continue
+		}
+
 		if update {
 			helm3VersionedUpdatesCounter.With(prometheus.Labels{"chart": fmt.Sprintf("%s/%s", release.Namespace, release.Name)}).Inc()
 			plans = append(plans, plan)
@@ -296,75 +337,65 @@ func (p *Provider) createUpdatePlans(event *types.Event) ([]*UpdatePlan, error)
 }
 
 func (p *Provider) applyPlans(plans []*UpdatePlan) error {
+	var failed []string
 	for _, plan := range plans {
+		key := getReleaseIdentifier(plan.Namespace, plan.Name)
 
-		p.sender.Send(types.EventNotification{
-			ResourceKind: "chart",
-			Identifier:   fmt.Sprintf("%s/%s/%s", "chart", plan.Namespace, plan.Name),
-			Name:         "update release",
-			Message:      fmt.Sprintf("Preparing to update release %s/%s %s->%s (%s)", plan.Namespace, plan.Name, plan.CurrentVersion, plan.NewVersion, strings.Join(mapToSlice(plan.Values), ", ")),
-			CreatedAt:    time.Now(),
-			Type:         types.NotificationPreReleaseUpdate,
-			Level:        types.LevelDebug,
-			Channels:     plan.Config.NotificationChannels,
-			Metadata: map[string]string{
-				"provider":  p.GetName(),
-				"namespace": plan.Namespace,
-				"name":      plan.Name,
-			},
-		})
+		p.locks.Lock(key)
+		ok := p.applyPlan(plan)
+		p.locks.Unlock(key)
 
-		// err := updateHelmRelease(p.implementer, plan.Name, plan.Chart, plan.Values)
-		err := updateHelmRelease(p.implementer, plan.Name, plan.Chart, plan.Values, plan.Namespace, plan.EmptyConfig)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error":     err,
-				"name":      plan.Name,
-				"namespace": plan.Namespace,
-			}).Error("provider.helm3: failed to apply plan")
-
-			p.sender.Send(types.EventNotification{
-				ResourceKind: "chart",
-				Identifier:   fmt.Sprintf("%s/%s/%s", "chart", plan.Namespace, plan.Name),
-				Name:         "update release",
-				Message:      fmt.Sprintf("Release update failed %s/%s %s->%s (%s), error: %s", plan.Namespace, plan.Name, plan.CurrentVersion, plan.NewVersion, strings.Join(mapToSlice(plan.Values), ", "), err),
-				CreatedAt:    time.Now(),
-				Type:         types.NotificationReleaseUpdate,
-				Level:        types.LevelError,
-				Channels:     plan.Config.NotificationChannels,
-				Metadata: map[string]string{
-					"provider":  p.GetName(),
-					"namespace": plan.Namespace,
-					"name":      plan.Name,
-				},
-			})
-			continue
+		if !ok {
+			failed = append(failed, key)
 		}
+	}
 
-		err = p.updateComplete(plan)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error":     err,
-				"name":      plan.Name,
-				"namespace": plan.Namespace,
-			}).Debug("provider.helm3: got error while resetting approvals counter after successful update")
-		}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to update: %s", strings.Join(failed, ", "))
+	}
 
-		var msg string
-		if len(plan.ReleaseNotes) == 0 {
-			msg = fmt.Sprintf("Successfully updated release %s/%s %s->%s (%s)", plan.Namespace, plan.Name, plan.CurrentVersion, plan.NewVersion, strings.Join(mapToSlice(plan.Values), ", "))
-		} else {
-			msg = fmt.Sprintf("Successfully updated release %s/%s %s->%s (%s). Release notes: %s", plan.Namespace, plan.Name, plan.CurrentVersion, plan.NewVersion, strings.Join(mapToSlice(plan.Values), ", "), strings.Join(plan.ReleaseNotes, ", "))
-		}
+	return nil
+}
+
+// applyPlan - upgrades release, returns false if upgrade failed
+func (p *Provider) applyPlan(plan *UpdatePlan) (ok bool) {
+	defer func() {
+		p.recordHistory(plan, ok)
+	}()
+
+	p.sender.Send(types.EventNotification{
+		ResourceKind: "chart",
+		Identifier:   fmt.Sprintf("%s/%s/%s", "chart", plan.Namespace, plan.Name),
+		Name:         "update release",
+		Message:      fmt.Sprintf("Preparing to update release %s/%s %s->%s (%s)", plan.Namespace, plan.Name, plan.CurrentVersion, plan.NewVersion, strings.Join(mapToSlice(plan.Values), ", ")),
+		CreatedAt:    time.Now(),
+		Type:         types.NotificationPreReleaseUpdate,
+		Level:        types.LevelDebug,
+		Channels:     plan.Config.NotificationChannels,
+		Metadata: map[string]string{
+			"provider":  p.GetName(),
+			"namespace": plan.Namespace,
+			"name":      plan.Name,
+		},
+	})
+
+	// err := updateHelmRelease(p.implementer, plan.Name, plan.Chart, plan.Values)
+	err := updateHelmRelease(p.implementer, plan.Name, plan.Chart, plan.Values, plan.Namespace, plan.EmptyConfig)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":     err,
+			"name":      plan.Name,
+			"namespace": plan.Namespace,
+		}).Error("provider.helm3: failed to apply plan")
 
 		p.sender.Send(types.EventNotification{
 			ResourceKind: "chart",
 			Identifier:   fmt.Sprintf("%s/%s/%s", "chart", plan.Namespace, plan.Name),
 			Name:         "update release",
-			Message:      msg,
+			Message:      fmt.Sprintf("Release update failed %s/%s %s->%s (%s), error: %s", plan.Namespace, plan.Name, plan.CurrentVersion, plan.NewVersion, strings.Join(mapToSlice(plan.Values), ", "), err),
 			CreatedAt:    time.Now(),
 			Type:         types.NotificationReleaseUpdate,
-			Level:        types.LevelSuccess,
+			Level:        types.LevelError,
 			Channels:     plan.Config.NotificationChannels,
 			Metadata: map[string]string{
 				"provider":  p.GetName(),
@@ -372,10 +403,42 @@ func (p *Provider) applyPlans(plans []*UpdatePlan) error {
 				"name":      plan.Name,
 			},
 		})
+		return false
+	}
+
+	err = p.updateComplete(plan)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":     err,
+			"name":      plan.Name,
+			"namespace": plan.Namespace,
+		}).Debug("provider.helm3: got error while resetting approvals counter after successful update")
+	}
 
+	var msg string
+	if len(plan.ReleaseNotes) == 0 {
+		msg = fmt.Sprintf("Successfully updated release %s/%s %s->%s (%s)", plan.Namespace, plan.Name, plan.CurrentVersion, plan.NewVersion, strings.Join(mapToSlice(plan.Values), ", "))
+	} else {
+		msg = fmt.Sprintf("Successfully updated release %s/%s %s->%s (%s). Release notes: %s", plan.Namespace, plan.Name, plan.CurrentVersion, plan.NewVersion, strings.Join(mapToSlice(plan.Values), ", "), strings.Join(plan.ReleaseNotes, ", "))
 	}
 
-	return nil
+	p.sender.Send(types.EventNotification{
+		ResourceKind: "chart",
+		Identifier:   fmt.Sprintf("%s/%s/%s", "chart", plan.Namespace, plan.Name),
+		Name:         "update release",
+		Message:      msg,
+		CreatedAt:    time.Now(),
+		Type:         types.NotificationReleaseUpdate,
+		Level:        types.LevelSuccess,
+		Channels:     plan.Config.NotificationChannels,
+		Metadata: map[string]string{
+			"provider":  p.GetName(),
+			"namespace": plan.Namespace,
+			"name":      plan.Name,
+		},
+	})
+
+	return true
 }
 
 func updateHelmRelease(implementer Implementer, releaseName string, chart *hapi_chart.Chart, overrideValues map[string]string, namespace string, opts ...bool) error {
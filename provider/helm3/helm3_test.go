@@ -63,8 +63,10 @@ type fakeImplementer struct {
 	listReleasesResponse []*release.Release
 
 	// updated info
-	updatedRlsName string
-	updatedChart   *chart.Chart
+	updatedRlsName   string
+	updatedChart     *chart.Chart
+	updatedValues    map[string]interface{}
+	overriddenValues map[string]string
 	// updatedOptions []helm.UpdateOption
 }
 
@@ -76,6 +78,7 @@ func (i *fakeImplementer) UpdateReleaseFromChart(rlsName string, chart *chart.Ch
 	// func (i *fakeImplementer) UpdateReleaseFromChart(rlsName string, chart *chart.Chart, opts ...helm.UpdateOption) (*rls.UpdateReleaseResponse, error) {
 	i.updatedRlsName = rlsName
 	i.updatedChart = chart
+	i.overriddenValues = vals
 	// i.updatedOptions = opts
 
 	return &release.Release{
@@ -85,6 +88,18 @@ func (i *fakeImplementer) UpdateReleaseFromChart(rlsName string, chart *chart.Ch
 	}, nil
 }
 
+func (i *fakeImplementer) UpdateReleaseValues(rlsName string, chart *chart.Chart, vals map[string]interface{}, namespace string, opts ...bool) (*release.Release, error) {
+	i.updatedRlsName = rlsName
+	i.updatedChart = chart
+	i.updatedValues = vals
+
+	return &release.Release{
+		Name:    rlsName,
+		Chart:   chart,
+		Version: 2,
+	}, nil
+}
+
 // helper function to generate quilla configuration
 func testingConfigYaml(cfg *quillaChartConfig) (vals chartutil.Values, err error) {
 	root := &Root{Quilla: *cfg}
@@ -0,0 +1,149 @@
+package helm3
+
+import (
+	"fmt"
+
+	"github.com/quilla-hq/quilla/types"
+	"github.com/quilla-hq/quilla/util/image"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HistoryStore - persists version history of releases
+type HistoryStore interface {
+	CreateHistoryEntry(entry *types.HistoryEntry) (*types.HistoryEntry, error)
+	ListHistory(q *types.GetHistoryQuery) ([]*types.HistoryEntry, error)
+}
+
+// SetHistoryStore - sets store that keeps version history, releases
+// can't be rolled back without it
+func (p *Provider) SetHistoryStore(history HistoryStore) {
+	p.history = history
+}
+
+// recordHistory - records every values path updated by the plan
+func (p *Provider) recordHistory(plan *UpdatePlan, ok bool) {
+	if p.history == nil {
+		return
+	}
+
+	outcome := types.HistoryOutcomeSucceeded
+	if !ok {
+		outcome = types.HistoryOutcomeFailed
+	}
+
+	trigger := plan.Trigger
+	if trigger == "" {
+		trigger = types.TriggerTypeDefault.String()
+	}
+
+	for _, path := range plan.Paths {
+		_, err := p.history.CreateHistoryEntry(&types.HistoryEntry{
+			Provider:   p.GetName(),
+			Identifier: getReleaseIdentifier(plan.Namespace, plan.Name),
+			Container:  path,
+			Image:      plan.Image,
+			From:       plan.CurrentVersion,
+			To:         plan.NewVersion,
+			Digest:     plan.Digest,
+			Trigger:    trigger,
+			Approvers:  plan.Approvers,
+			Outcome:    outcome,
+		})
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":     err,
+				"name":      plan.Name,
+				"namespace": plan.Namespace,
+			}).Error("provider.helm3: failed to record version history")
+		}
+	}
+}
+
+// heldBack - version was rolled back by the latest change of the release
+func (p *Provider) heldBack(plan *UpdatePlan) bool {
+	if p.history == nil {
+		return false
+	}
+	latest, err := p.history.ListHistory(&types.GetHistoryQuery{
+		Identifier: getReleaseIdentifier(plan.Namespace, plan.Name),
+		Limit:      1,
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":     err,
+			"name":      plan.Name,
+			"namespace": plan.Namespace,
+		}).Error("provider.helm3: failed to get version history")
+		return false
+	}
+	return len(latest) > 0 && latest[0].Holds(plan.NewVersion)
+}
+
+// Rollback - upgrades release with images of the repository set to the
+// requested version, rollback skips approvals
+func (p *Provider) Rollback(req *types.RollbackRequest) error {
+	target, err := image.Parse(req.Image)
+	if err != nil {
+		return err
+	}
+
+	releases, err := p.releases()
+	if err != nil {
+		return err
+	}
+
+	for _, cr := range releases {
+		if cr.identifier() != req.Identifier {
+			continue
+		}
+		if cr.config == nil {
+			return fmt.Errorf("release %s doesn't have quilla configuration", req.Identifier)
+		}
+		release := cr.release
+
+		plan := &UpdatePlan{
+			Namespace:   release.Namespace,
+			Name:        release.Name,
+			Chart:       release.Chart,
+			Config:      cr.config,
+			Values:      make(map[string]string),
+			NewVersion:  req.Version,
+			EmptyConfig: release.Config == nil,
+			Image:       target.Repository(),
+			Trigger:     types.TriggerTypeRollback.String(),
+			Digest:      req.Digest,
+		}
+		if req.User != "" {
+			plan.Approvers = types.Users{req.User}
+		}
+
+		for _, imageDetails := range cr.config.Images {
+			ref, err := parseImage(cr.values, &imageDetails)
+			if err != nil || ref.Repository() != target.Repository() || ref.Tag() == req.Version {
+				continue
+			}
+			if imageDetails.DigestPath != "" {
+				plan.Values[imageDetails.DigestPath] = req.Digest
+			}
+			path, value := getUnversionedPlanValues(req.Version, ref, &imageDetails)
+			plan.Values[path] = value
+			plan.Paths = append(plan.Paths, path)
+			plan.CurrentVersion = ref.Tag()
+		}
+		if len(plan.Paths) == 0 {
+			return fmt.Errorf("no images of %s use %s at a version other than %s", req.Identifier, target.Repository(), req.Version)
+		}
+
+		p.locks.Lock(req.Identifier)
+		ok := p.applyPlan(plan)
+		p.locks.Unlock(req.Identifier)
+
+		if !ok {
+			return fmt.Errorf("failed to roll back %s to %s", req.Identifier, req.Version)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("release %s not found", req.Identifier)
+}
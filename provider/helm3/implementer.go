@@ -29,6 +29,7 @@ type Implementer interface {
 	// ListReleases(opts ...helm.ReleaseListOption) ([]*release.Release, error)
 	ListReleases() ([]*release.Release, error)
 	UpdateReleaseFromChart(rlsName string, chart *chart.Chart, vals map[string]string, namespace string, opts ...bool) (*release.Release, error)
+	UpdateReleaseValues(rlsName string, chart *chart.Chart, vals map[string]interface{}, namespace string, opts ...bool) (*release.Release, error)
 }
 
 // Helm3Implementer - actual helm3 implementer
@@ -66,6 +67,12 @@ func (i *Helm3Implementer) ListReleases() ([]*release.Release, error) {
 
 // UpdateReleaseFromChart - update release from chart
 func (i *Helm3Implementer) UpdateReleaseFromChart(rlsName string, chart *chart.Chart, vals map[string]string, namespace string, opts ...bool) (*release.Release, error) {
+	return i.UpdateReleaseValues(rlsName, chart, convertToInterface(vals), namespace, opts...)
+}
+
+// UpdateReleaseValues - update release from chart with already structured values,
+// used when values have to keep their types (ie: quilla.approvals)
+func (i *Helm3Implementer) UpdateReleaseValues(rlsName string, chart *chart.Chart, vals map[string]interface{}, namespace string, opts ...bool) (*release.Release, error) {
 	actionConfig := i.generateConfig(namespace)
 	client := action.NewUpgrade(actionConfig)
 	client.Namespace = namespace
@@ -78,14 +85,12 @@ func (i *Helm3Implementer) UpdateReleaseFromChart(rlsName string, chart *chart.C
 		client.ReuseValues = false
 	}
 
-	convertedVals := convertToInterface(vals)
-
 	// returns the new release
-	results, err := client.Run(rlsName, chart, convertedVals)
+	results, err := client.Run(rlsName, chart, vals)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
-		}).Fatal("helm3: failed to update release from chart")
+		}).Error("helm3: failed to update release from chart")
 		return nil, err
 	}
 	return results, err
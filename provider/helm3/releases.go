@@ -0,0 +1,153 @@
+package helm3
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/quilla-hq/quilla/internal/policy"
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrReleaseNotFound - returned when release with given identifier is not found
+var ErrReleaseNotFound = errors.New("release not found")
+
+// ReleaseManager - provides access to quilla managed releases, used by the
+// HTTP API to list releases and change their quilla configuration
+type ReleaseManager interface {
+	Releases() ([]*TrackedRelease, error)
+	UpdateReleaseConfig(identifier string, cfg *ReleaseConfigUpdate) error
+}
+
+// TrackedRelease - helm release that has quilla configuration
+type TrackedRelease struct {
+	Identifier string
+	Namespace  string
+	Name       string
+	Chart      string
+	Status     string
+	Images     []string
+
+	Policy               string
+	Trigger              types.TriggerType
+	PollSchedule         string
+	Approvals            int
+	ApprovalDeadline     int
+	NotificationChannels []string
+
+	Plc policy.Policy
+}
+
+// ReleaseConfigUpdate - quilla configuration fields to change on the release,
+// nil fields are left untouched
+type ReleaseConfigUpdate struct {
+	Policy       *string
+	Trigger      *types.TriggerType
+	PollSchedule *string
+	Approvals    *int
+}
+
+// values - converts config update into quilla.* release values
+func (u *ReleaseConfigUpdate) values() map[string]interface{} {
+	cfg := make(map[string]interface{})
+	if u.Policy != nil {
+		cfg["policy"] = *u.Policy
+	}
+	if u.Trigger != nil {
+		cfg["trigger"] = u.Trigger.String()
+	}
+	if u.PollSchedule != nil {
+		cfg["pollSchedule"] = *u.PollSchedule
+	}
+	if u.Approvals != nil {
+		cfg["approvals"] = *u.Approvals
+	}
+
+	return map[string]interface{}{
+		"quilla": cfg,
+	}
+}
+
+// chart/<namespace>/<release name>
+func getReleaseIdentifier(namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", "chart", namespace, name)
+}
+
+// Releases - returns all releases that have quilla configuration
+func (p *Provider) Releases() ([]*TrackedRelease, error) {
+	var tracked []*TrackedRelease
+
+	releases, err := p.releases()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cr := range releases {
+		if cr.config == nil {
+			continue
+		}
+		release, cfg := cr.release, cr.config
+
+		tr := &TrackedRelease{
+			Identifier:           cr.identifier(),
+			Namespace:            release.Namespace,
+			Name:                 release.Name,
+			Policy:               cfg.Policy,
+			Trigger:              cfg.Trigger,
+			PollSchedule:         cfg.PollSchedule,
+			Approvals:            cfg.Approvals,
+			ApprovalDeadline:     cfg.ApprovalDeadline,
+			NotificationChannels: cfg.NotificationChannels,
+			Plc:                  cfg.Plc,
+		}
+
+		if release.Chart != nil && release.Chart.Metadata != nil {
+			tr.Chart = fmt.Sprintf("%s-%s", release.Chart.Metadata.Name, release.Chart.Metadata.Version)
+		}
+		if release.Info != nil {
+			tr.Status = release.Info.Status.String()
+		}
+
+		for _, imageDetails := range cfg.Images {
+			ref, err := parseImage(cr.values, &imageDetails)
+			if err != nil {
+				continue
+			}
+			tr.Images = append(tr.Images, ref.Remote())
+		}
+
+		tracked = append(tracked, tr)
+	}
+
+	return tracked, nil
+}
+
+// UpdateReleaseConfig - upgrades release with modified quilla configuration
+func (p *Provider) UpdateReleaseConfig(identifier string, cfg *ReleaseConfigUpdate) error {
+	releases, err := p.releases()
+	if err != nil {
+		return err
+	}
+
+	for _, cr := range releases {
+		if cr.identifier() != identifier {
+			continue
+		}
+		release := cr.release
+
+		_, err = p.implementer.UpdateReleaseValues(release.Name, release.Chart, cfg.values(), release.Namespace, release.Config == nil)
+		if err != nil {
+			return err
+		}
+
+		log.WithFields(log.Fields{
+			"release":   release.Name,
+			"namespace": release.Namespace,
+		}).Info("provider.helm3: release quilla configuration updated")
+
+		return nil
+	}
+
+	return ErrReleaseNotFound
+}
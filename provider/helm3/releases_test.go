@@ -0,0 +1,132 @@
+package helm3
+
+import (
+	"testing"
+
+	"github.com/quilla-hq/quilla/types"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const releasesTestChartVals = `
+name: chart-x
+image:
+  repository: gcr.io/v2-namespace/bye-world
+  tag: 1.1.0
+
+quilla:
+  policy: minor
+  trigger: poll
+  pollSchedule: "@every 2m"
+  approvals: 1
+  images:
+    - repository: image.repository
+      tag: image.tag
+
+`
+
+func TestReleases(t *testing.T) {
+	myChart, err := testingStringToChart(releasesTestChartVals)
+	if err != nil {
+		t.Fatalf("chartutil.ReadValues error = %v", err)
+	}
+
+	fakeImpl := &fakeImplementer{
+		listReleasesResponse: []*release.Release{
+			{
+				Name:      "release-1",
+				Namespace: "default",
+				Chart:     myChart,
+				Config:    make(map[string]interface{}),
+			},
+		},
+	}
+
+	approver, teardown := approver()
+	defer teardown()
+	prov := NewProvider(fakeImpl, &fakeSender{}, approver)
+
+	releases, err := prov.Releases()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(releases) != 1 {
+		t.Fatalf("expected 1 release, got: %d", len(releases))
+	}
+
+	r := releases[0]
+	if r.Identifier != "chart/default/release-1" {
+		t.Errorf("unexpected identifier: %s", r.Identifier)
+	}
+	if r.Policy != "minor" {
+		t.Errorf("unexpected policy: %s", r.Policy)
+	}
+	if r.Trigger != types.TriggerTypePoll {
+		t.Errorf("unexpected trigger: %s", r.Trigger)
+	}
+	if r.PollSchedule != "@every 2m" {
+		t.Errorf("unexpected poll schedule: %s", r.PollSchedule)
+	}
+	if r.Approvals != 1 {
+		t.Errorf("unexpected approvals: %d", r.Approvals)
+	}
+	if len(r.Images) != 1 || r.Images[0] != "gcr.io/v2-namespace/bye-world:1.1.0" {
+		t.Errorf("unexpected images: %v", r.Images)
+	}
+}
+
+func TestUpdateReleaseConfig(t *testing.T) {
+	myChart, err := testingStringToChart(releasesTestChartVals)
+	if err != nil {
+		t.Fatalf("chartutil.ReadValues error = %v", err)
+	}
+
+	fakeImpl := &fakeImplementer{
+		listReleasesResponse: []*release.Release{
+			{
+				Name:      "release-1",
+				Namespace: "default",
+				Chart:     myChart,
+				Config:    make(map[string]interface{}),
+			},
+		},
+	}
+
+	approver, teardown := approver()
+	defer teardown()
+	prov := NewProvider(fakeImpl, &fakeSender{}, approver)
+
+	policy := "major"
+	approvals := 3
+	err = prov.UpdateReleaseConfig("chart/default/release-1", &ReleaseConfigUpdate{
+		Policy:    &policy,
+		Approvals: &approvals,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if fakeImpl.updatedRlsName != "release-1" {
+		t.Errorf("unexpected release updated: %s", fakeImpl.updatedRlsName)
+	}
+
+	cfg, ok := fakeImpl.updatedValues["quilla"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("quilla values not set: %v", fakeImpl.updatedValues)
+	}
+	if cfg["policy"] != "major" {
+		t.Errorf("unexpected policy: %v", cfg["policy"])
+	}
+	if cfg["approvals"] != 3 {
+		t.Errorf("unexpected approvals: %v", cfg["approvals"])
+	}
+	if _, ok := cfg["trigger"]; ok {
+		t.Errorf("trigger should not be set")
+	}
+
+	err = prov.UpdateReleaseConfig("chart/default/missing", &ReleaseConfigUpdate{Policy: &policy})
+	if err != ErrReleaseNotFound {
+		t.Errorf("expected ErrReleaseNotFound, got: %v", err)
+	}
+}
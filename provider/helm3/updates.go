@@ -6,18 +6,58 @@ import (
 	"github.com/quilla-hq/quilla/util/image"
 
 	hapi_chart "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
 
 	log "github.com/sirupsen/logrus"
 )
 
 func checkRelease(repo *types.Repository, namespace, name string, chart *hapi_chart.Chart, config map[string]interface{}) (plan *UpdatePlan, shouldUpdateRelease bool, err error) {
+	// getting configuration
+	vals, err := values(chart, config)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("provider.helm3: failed to get values.yaml for release")
+		return &UpdatePlan{
+			Chart:       chart,
+			Namespace:   namespace,
+			Name:        name,
+			Values:      make(map[string]string),
+			Previous:    make(map[string]string),
+			EmptyConfig: config == nil,
+		}, false, err
+	}
+
+	quillaCfg, err := getquillaConfig(vals)
+	if err != nil {
+		if err != ErrPolicyNotSpecified {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Error("provider.helm3: failed to get quilla configuration for release")
+		}
+		// ignoring this release, no quilla config found
+		quillaCfg = nil
+	}
+
+	return checkReleaseValues(repo, namespace, name, chart, config == nil, vals, quillaCfg)
+}
+
+// checkReleaseValues - checks already parsed release values and quilla configuration
+// against the event repository, quillaCfg is nil when release has no quilla configuration
+func checkReleaseValues(repo *types.Repository, namespace, name string, chart *hapi_chart.Chart, emptyConfig bool, vals chartutil.Values, quillaCfg *quillaChartConfig) (plan *UpdatePlan, shouldUpdateRelease bool, err error) {
 
 	plan = &UpdatePlan{
 		Chart:       chart,
 		Namespace:   namespace,
 		Name:        name,
 		Values:      make(map[string]string),
-		EmptyConfig: config == nil,
+		Previous:    make(map[string]string),
+		EmptyConfig: emptyConfig,
+	}
+
+	if quillaCfg == nil {
+		// nothing to do
+		return plan, false, nil
 	}
 
 	eventRepoRef, err := image.Parse(repo.String())
@@ -29,28 +69,7 @@ func checkRelease(repo *types.Repository, namespace, name string, chart *hapi_ch
 		return
 	}
 
-	// getting configuration
-	vals, err := values(chart, config)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Error("provider.helm3: failed to get values.yaml for release")
-		return
-	}
-
-	quillaCfg, err := getquillaConfig(vals)
-	if err != nil {
-		if err == ErrPolicyNotSpecified {
-			// nothing to do
-			return plan, false, nil
-		}
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Error("provider.helm3: failed to get quilla configuration for release")
-		// ignoring this release, no quilla config found
-		return plan, false, nil
-	}
-	log.Infof("policy for release %s/%s parsed: %s", namespace, name, quillaCfg.Plc.Name())
+	log.Infof("policy for release %s/%s: %s", namespace, name, quillaCfg.Plc.Name())
 
 	if quillaCfg.Plc.Type() == policy.PolicyTypeNone {
 		// policy is not set, ignoring release
@@ -98,6 +117,7 @@ func checkRelease(repo *types.Repository, namespace, name string, chart *hapi_ch
 
 		if imageDetails.DigestPath != "" {
 			plan.Values[imageDetails.DigestPath] = repo.Digest
+			plan.Previous[imageDetails.DigestPath], _ = getValueAsString(vals, imageDetails.DigestPath)
 			log.WithFields(log.Fields{
 				"image_details_digestPath": imageDetails.DigestPath,
 				"target_image_digest":      repo.Digest,
@@ -106,6 +126,9 @@ func checkRelease(repo *types.Repository, namespace, name string, chart *hapi_ch
 
 		path, value := getUnversionedPlanValues(repo.Tag, imageRef, &imageDetails)
 		plan.Values[path] = value
+		_, plan.Previous[path] = getUnversionedPlanValues(imageRef.Tag(), imageRef, &imageDetails)
+		plan.Image = imageRef.Repository()
+		plan.Paths = append(plan.Paths, path)
 		plan.NewVersion = repo.Tag
 		plan.CurrentVersion = imageRef.Tag()
 		plan.Config = quillaCfg
@@ -125,6 +125,9 @@ quilla:
 				Name:           "release-1",
 				Chart:          helloWorldChart,
 				Values:         map[string]string{"image.tag": "latest"},
+				Previous:       map[string]string{"image.tag": "1.1.0"},
+				Image:          "gcr.io/v2-namespace/hello-world",
+				Paths:          []string{"image.tag"},
 				CurrentVersion: "1.1.0",
 				NewVersion:     "latest",
 				Config: &quillaChartConfig{
@@ -157,6 +160,9 @@ quilla:
 				Name:           "release-1",
 				Chart:          helloWorldChartPolicyMajorReleaseNotes,
 				Values:         map[string]string{"image.tag": "1.2.0"},
+				Previous:       map[string]string{"image.tag": "1.1.0"},
+				Image:          "gcr.io/v2-namespace/hello-world",
+				Paths:          []string{"image.tag"},
 				CurrentVersion: "1.1.0",
 				NewVersion:     "1.2.0",
 				ReleaseNotes:   []string{"https://github.com/quilla-hq/quilla/releases"},
@@ -190,7 +196,7 @@ quilla:
 				Namespace: "default",
 				Name:      "release-1",
 				Chart:     helloWorldChartPolicyMajor,
-				Values:    map[string]string{}},
+				Values:    map[string]string{}, Previous: map[string]string{}},
 			wantShouldUpdateRelease: false,
 			wantErr:                 false,
 		},
@@ -364,6 +370,9 @@ image:
 				Name:           "release-1",
 				Chart:          helloWorldChart,
 				Values:         map[string]string{"image.tag": "1.1.2"},
+				Previous:       map[string]string{"image.tag": "1.1.0"},
+				Image:          "gcr.io/v2-namespace/hello-world",
+				Paths:          []string{"image.tag"},
 				NewVersion:     "1.1.2",
 				CurrentVersion: "1.1.0",
 				Config: &quillaChartConfig{
@@ -388,7 +397,7 @@ image:
 				chart:     helloWorldChart,
 				config:    make(map[string]interface{}),
 			},
-			wantPlan:                &UpdatePlan{Namespace: "default", Name: "release-1", Chart: helloWorldChart, Values: map[string]string{}},
+			wantPlan:                &UpdatePlan{Namespace: "default", Name: "release-1", Chart: helloWorldChart, Values: map[string]string{}, Previous: map[string]string{}},
 			wantShouldUpdateRelease: false,
 			wantErr:                 false,
 		},
@@ -402,7 +411,7 @@ image:
 				chart:     helloWorldChart,
 				config:    make(map[string]interface{}),
 			},
-			wantPlan:                &UpdatePlan{Namespace: "default", Name: "release-1", Chart: helloWorldChart, Values: map[string]string{}},
+			wantPlan:                &UpdatePlan{Namespace: "default", Name: "release-1", Chart: helloWorldChart, Values: map[string]string{}, Previous: map[string]string{}},
 			wantShouldUpdateRelease: false,
 			wantErr:                 false,
 		},
@@ -421,6 +430,9 @@ image:
 				Name:           "release-1",
 				Chart:          helloWorldNonSemverChart,
 				Values:         map[string]string{"image.tag": "1.1.0"},
+				Previous:       map[string]string{"image.tag": "alpha"},
+				Image:          "gcr.io/v2-namespace/hello-world",
+				Paths:          []string{"image.tag"},
 				NewVersion:     "1.1.0",
 				CurrentVersion: "alpha",
 				Config: &quillaChartConfig{
@@ -446,7 +458,7 @@ image:
 				chart:     helloWorldNonSemverNoForceChart,
 				config:    make(map[string]interface{}),
 			},
-			wantPlan:                &UpdatePlan{Namespace: "default", Name: "release-1", Chart: helloWorldNonSemverNoForceChart, Values: map[string]string{}},
+			wantPlan:                &UpdatePlan{Namespace: "default", Name: "release-1", Chart: helloWorldNonSemverNoForceChart, Values: map[string]string{}, Previous: map[string]string{}},
 			wantShouldUpdateRelease: false,
 			wantErr:                 false,
 		},
@@ -465,6 +477,9 @@ image:
 				Name:           "release-1-no-tag",
 				Chart:          helloWorldNoTagChart,
 				Values:         map[string]string{"image.repository": "gcr.io/v2-namespace/hello-world:1.1.0"},
+				Previous:       map[string]string{"image.repository": "gcr.io/v2-namespace/hello-world:1.0.0"},
+				Image:          "gcr.io/v2-namespace/hello-world",
+				Paths:          []string{"image.repository"},
 				NewVersion:     "1.1.0",
 				CurrentVersion: "1.0.0",
 				Config: &quillaChartConfig{
@@ -490,7 +505,7 @@ image:
 				chart:     helloWorldNoquillaCfg,
 				config:    make(map[string]interface{}),
 			},
-			wantPlan:                &UpdatePlan{Namespace: "default", Name: "release-1-no-tag", Chart: helloWorldNoquillaCfg, Values: map[string]string{}},
+			wantPlan:                &UpdatePlan{Namespace: "default", Name: "release-1-no-tag", Chart: helloWorldNoquillaCfg, Values: map[string]string{}, Previous: map[string]string{}},
 			wantShouldUpdateRelease: false,
 			wantErr:                 false,
 		},
@@ -3,14 +3,26 @@ package kubernetes
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/quilla-hq/quilla/internal/k8s"
 	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/registry"
 	"github.com/quilla-hq/quilla/types"
+	"github.com/quilla-hq/quilla/util/image"
+
+	v1 "k8s.io/api/core/v1"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// SetDigestResolver - sets resolver that looks up image digests, approvals of
+// events without a digest can't be pinned to the image without it
+func (p *Provider) SetDigestResolver(digests registry.DigestResolver) {
+	p.digests = digests
+}
+
 func getApprovalIdentifier(resourceIdentifier, version string) string {
 	return resourceIdentifier + ":" + version
 }
@@ -35,8 +47,12 @@ func (p *Provider) checkForApprovals(event *types.Event, plans []*UpdatePlan) (a
 	return approvedPlans
 }
 
-// updateComplete is called after we successfully update resource
+// updateComplete is called after we successfully update resource,
+// group approvals are archived once all members are updated
 func (p *Provider) updateComplete(plan *UpdatePlan) error {
+	if plan.group != nil {
+		return nil
+	}
 	return p.approvalManager.Archive(getApprovalIdentifier(plan.Resource.Identifier, plan.NewVersion))
 }
 
@@ -91,6 +107,15 @@ func (p *Provider) isApproved(event *types.Event, plan *UpdatePlan) (bool, error
 		deadline = d
 	}
 
+	approvers, err := types.ParseApproverRules(plan.Resource.GetAnnotations()[types.QuillaApproversAnnotation])
+	if err != nil {
+		return false, err
+	}
+	veto, err := types.ParseVetoRule(plan.Resource.GetAnnotations()[types.QuillaVetoAnnotation])
+	if err != nil {
+		return false, err
+	}
+
 	identifier := getApprovalIdentifier(plan.Resource.Identifier, plan.NewVersion)
 
 	// checking for existing approval
@@ -109,13 +134,29 @@ func (p *Provider) isApproved(event *types.Event, plan *UpdatePlan) (bool, error
 			approval := &types.Approval{
 				Provider:       types.ProviderTypeKubernetes,
 				Identifier:     identifier,
+				Namespace:      plan.Resource.Namespace,
 				Event:          event,
 				CurrentVersion: plan.CurrentVersion,
 				NewVersion:     plan.NewVersion,
 				VotesRequired:  minApprovals,
 				VotesReceived:  0,
+				Approvers:      approvers,
+				Veto:           veto,
 				Rejected:       false,
 				Deadline:       time.Now().Add(time.Duration(deadline) * time.Hour),
+				Channels:       types.ParseEventNotificationChannels(plan.Resource.GetAnnotations()),
+			}
+			if after := autoApproveAfter(plan.Resource); after > 0 {
+				approval.AutoApproveAt = time.Now().Add(after)
+				approval.AutoApproveAfter = after
+			}
+			approval.Digest = p.approvalDigest(event, plan)
+			approval.Plan = approvalPlan(plan)
+			if separationOfDuties(plan.Resource.GetAnnotations()) {
+				approval.Author = event.Author
+			}
+			if len(plan.Changes) > 1 {
+				approval.Changes = plan.Changes
 			}
 
 			approval.Message = fmt.Sprintf("New image is available for resource %s/%s (%s).",
@@ -124,23 +165,193 @@ func (p *Provider) isApproved(event *types.Event, plan *UpdatePlan) (bool, error
 				approval.Delta(),
 			)
 
-			return false, p.approvalManager.Create(approval)
+			if plan.group != nil {
+				approval.Group = plan.group.name
+				approval.Members = plan.group.members()
+				approval.Message = fmt.Sprintf("New version is available for update group %s (%s), members: %s.",
+					plan.group.name,
+					approval.Delta(),
+					approval.Members,
+				)
+			}
+
+			err = p.approvalManager.Create(approval)
+			if err == nil {
+				p.approvalStatus(plan, approval)
+			}
+			return false, err
 		}
 
 		return false, err
 	}
 
-	// if event.Repository.Digest != "" && event.Repository.Digest != existing.Digest {
-	// 	err = p.approvalManager.Reset(existing)
-	// 	if err != nil {
-	// 		return false, fmt.Errorf("failed to reset approval after changed digest, error %s", err)
-	// 	}
-	// 	return false, nil
-	// }
-	// log.WithFields(log.Fields{
-	// 	"previous": existing.Digest,
-	// 	"new":      event.Repository.Digest,
-	// }).Info("digests match")
+	verified, err := p.verifyDigest(event, plan, existing)
+	if !verified {
+		return false, err
+	}
+
+	p.approvalStatus(plan, existing)
+
+	if existing.Status() != types.ApprovalStatusApproved {
+		return false, nil
+	}
+
+	// approved plan is applied only if nothing changed since it was requested
+	if !existing.Plan.Empty() {
+		if drift := existing.Plan.Drift(approvalPlan(plan)); len(drift) > 0 {
+			p.notifyDrift(plan, existing, drift)
+			return false, fmt.Errorf("update changed since approval %s was requested: %s", existing.Identifier, strings.Join(drift, "; "))
+		}
+	}
+	plan.Approvers = existing.GetVoters()
+	return true, nil
+}
+
+// approvalPlan - changes of the plan, or of every member when plan
+// belongs to an update group
+func approvalPlan(plan *UpdatePlan) *types.ApprovalPlan {
+	plans := []*UpdatePlan{plan}
+	if plan.group != nil {
+		plans = plan.group.plans
+	}
+
+	approvalPlan := &types.ApprovalPlan{Digest: plan.Digest}
+	for _, p := range plans {
+		for _, c := range p.Changes {
+			approvalPlan.Changes = append(approvalPlan.Changes, types.PlannedChange{
+				Resource: p.Resource.Identifier,
+				Target:   c.Container,
+				Init:     c.Init,
+				From:     c.PreviousImage,
+				To:       c.Image,
+			})
+		}
+	}
+	approvalPlan.Sort()
+	return approvalPlan
+}
+
+// notifyDrift - approved update isn't applied as resources changed since approval was requested
+func (p *Provider) notifyDrift(plan *UpdatePlan, approval *types.Approval, drift []string) {
+	resource := plan.Resource
+	p.recordEvent(resource, v1.EventTypeWarning, eventReasonApprovalDrifted,
+		fmt.Sprintf("Approved update %s wasn't applied, resource changed since approval %s was requested", plan.delta(), approval.Identifier))
+	p.sender.Send(types.EventNotification{
+		ResourceKind: resource.Kind(),
+		Identifier:   resource.Identifier,
+		Name:         "approved update not applied",
+		Message: fmt.Sprintf("Approved update %s of %s %s/%s wasn't applied, it changed since approval %s was requested: %s. Reject the approval or revert the changes.",
+			plan.delta(), resource.Kind(), resource.Namespace, resource.Name, approval.Identifier, strings.Join(drift, "; ")),
+		CreatedAt: time.Now(),
+		Type:      types.NotificationPreDeploymentUpdate,
+		Level:     types.LevelError,
+		Channels:  types.ParseEventNotificationChannels(resource.GetAnnotations()),
+		Metadata:  plan.metadata(p.GetName()),
+	})
+}
+
+// autoApproveAfter - how long approval waits for a rejection before it's
+// approved, zero when lazy consensus isn't enabled
+func autoApproveAfter(resource *k8s.GenericResource) time.Duration {
+	value, ok := resource.GetAnnotations()[types.QuillaAutoApproveAfterAnnotation]
+	if !ok {
+		return 0
+	}
+	after, err := time.ParseDuration(value)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":    err,
+			"resource": resource.GetName(),
+		}).Warn("failed to parse auto-approve duration, approval won't be auto-approved")
+		return 0
+	}
+	return after
+}
+
+// separationOfDuties - author of the image can't approve its update
+func separationOfDuties(annotations map[string]string) bool {
+	enabled, _ := strconv.ParseBool(annotations[types.QuillaSeparationOfDutiesAnnotation])
+	return enabled
+}
+
+// resolveDigest - digest the event tag currently points to, empty when
+// digest resolver isn't set
+func (p *Provider) resolveDigest(repo *types.Repository, plan *UpdatePlan) (string, error) {
+	if p.digests == nil {
+		return "", nil
+	}
+	ref, err := image.Parse(repo.String())
+	if err != nil {
+		return "", err
+	}
+	return p.digests.Digest(&types.TrackedImage{
+		Image:     ref,
+		Provider:  ProviderName,
+		Namespace: plan.Resource.Namespace,
+		Secrets:   getImagePullSecrets(plan.Resource),
+	})
+}
+
+// approvalDigest - digest of the image approval is requested for, resolved
+// from registry when the event doesn't have it
+func (p *Provider) approvalDigest(event *types.Event, plan *UpdatePlan) string {
+	if event.Repository.Digest != "" {
+		return event.Repository.Digest
+	}
+	digest, err := p.resolveDigest(&event.Repository, plan)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": plan.Resource.Identifier,
+			"image":      event.Repository.String(),
+		}).Warn("provider.kubernetes: failed to resolve image digest, approval isn't pinned to the image")
+	}
+	plan.Digest = digest
+	return digest
+}
+
+// verifyDigest - checks that the tag still points to the image that is being
+// approved, votes are reset when it doesn't. Digest of approved updates is
+// resolved from registry as re-submitted approval events carry the original one.
+func (p *Provider) verifyDigest(event *types.Event, plan *UpdatePlan, existing *types.Approval) (bool, error) {
+	if existing.Digest == "" {
+		return true, nil
+	}
+
+	digest := event.Repository.Digest
+	if existing.Status() == types.ApprovalStatusApproved {
+		resolved, err := p.resolveDigest(&event.Repository, plan)
+		if err != nil {
+			return false, fmt.Errorf("failed to verify digest of approved image: %s", err)
+		}
+		if resolved != "" {
+			digest = resolved
+		}
+	}
+
+	if digest == "" || digest == existing.Digest {
+		plan.Digest = existing.Digest
+		return true, nil
+	}
+
+	reset, err := p.approvalManager.ResetVotes(existing.Identifier, digest)
+	if err != nil {
+		return false, fmt.Errorf("failed to reset approval after changed digest: %s", err)
+	}
+	p.approvalStatus(plan, reset)
 
-	return existing.Status() == types.ApprovalStatusApproved, nil
+	resource := plan.Resource
+	p.sender.Send(types.EventNotification{
+		ResourceKind: resource.Kind(),
+		Identifier:   resource.Identifier,
+		Name:         "approval reset",
+		Message: fmt.Sprintf("Image %s was pushed again after approval was requested, votes for %s %s/%s %s were reset (digest %s -> %s)",
+			event.Repository.String(), resource.Kind(), resource.Namespace, resource.Name, plan.delta(), existing.Digest, digest),
+		CreatedAt: time.Now(),
+		Type:      types.NotificationPreDeploymentUpdate,
+		Level:     types.LevelWarn,
+		Channels:  types.ParseEventNotificationChannels(resource.GetAnnotations()),
+		Metadata:  plan.metadata(p.GetName()),
+	})
+	return false, nil
 }
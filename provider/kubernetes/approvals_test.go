@@ -340,3 +340,144 @@ func TestApprovalsCleanup(t *testing.T) {
 		t.Logf("approval status: %v, identifier: %s", approvals[0].Archived, approvals[0].Identifier)
 	}
 }
+
+type fakeDigestResolver struct {
+	digest string
+}
+
+func (r *fakeDigestResolver) Digest(image *types.TrackedImage) (string, error) {
+	return r.digest, nil
+}
+
+func TestApprovalPinnedToDigest(t *testing.T) {
+	fi := &fakeImplementer{}
+	sender := &fakeSender{}
+	provider, teardown := newTestingProvider(t, fi, sender,
+		limitedDeployment("shop", "a", map[string]string{types.QuillaMinimumApprovalsLabel: "1"}, true),
+	)
+	defer teardown()
+	digests := &fakeDigestResolver{digest: "sha256:aaa"}
+	provider.SetDigestResolver(digests)
+
+	repo := types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.2"}
+	identifier := getApprovalIdentifier("deployment/shop/a", "1.1.2")
+
+	updated, err := provider.processEvent(&types.Event{Repository: repo})
+	if err != nil || len(updated) != 0 {
+		t.Fatalf("expected approval to be requested, updated: %d, err: %v", len(updated), err)
+	}
+	approval, err := provider.approvalManager.Get(identifier)
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.Digest != "sha256:aaa" {
+		t.Errorf("expected approval to be pinned to resolved digest, got: %s", approval.Digest)
+	}
+
+	_, err = provider.approvalManager.Approve(identifier, &types.Voter{Name: "alice"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+
+	// tag was pushed again after the approval
+	digests.digest = "sha256:bbb"
+	approvalEvent := &types.Event{Repository: repo, TriggerName: types.TriggerTypeApproval.String()}
+	updated, err = provider.processEvent(approvalEvent)
+	if err != nil || len(updated) != 0 {
+		t.Fatalf("re-pushed image shouldn't be updated, updated: %d, err: %v", len(updated), err)
+	}
+
+	approval, err = provider.approvalManager.Get(identifier)
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.VotesReceived != 0 || len(approval.GetVoters()) != 0 || approval.Digest != "sha256:bbb" {
+		t.Errorf("expected votes to be reset for new digest, got votes %d, digest %s", approval.VotesReceived, approval.Digest)
+	}
+	if sender.sentEvent.Name != "approval reset" || sender.sentEvent.Level != types.LevelWarn {
+		t.Errorf("expected approval reset notification, got: %+v", sender.sentEvent)
+	}
+
+	_, err = provider.approvalManager.Approve(identifier, &types.Voter{Name: "alice"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+	updated, err = provider.processEvent(approvalEvent)
+	if err != nil || len(updated) != 1 {
+		t.Fatalf("expected approved image to be updated, updated: %d, err: %v", len(updated), err)
+	}
+}
+
+func TestApprovalAutoApproveAfter(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		limitedDeployment("shop", "a", map[string]string{
+			types.QuillaMinimumApprovalsLabel:      "1",
+			types.QuillaAutoApproveAfterAnnotation: "4h",
+			types.QuillaNotificationChanAnnotation: "deployments",
+		}, true),
+	)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+
+	approval, err := provider.approvalManager.Get(getApprovalIdentifier("deployment/shop/a", "1.1.2"))
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if until := time.Until(approval.AutoApproveAt); until < 3*time.Hour || until > 4*time.Hour {
+		t.Errorf("unexpected auto-approve time: %s", approval.AutoApproveAt)
+	}
+	if len(approval.Channels) != 1 || approval.Channels[0] != "deployments" {
+		t.Errorf("unexpected approval channels: %v", approval.Channels)
+	}
+}
+
+func TestApprovedPlanDrift(t *testing.T) {
+	fi := &fakeImplementer{}
+	sender := &fakeSender{}
+	dep := limitedDeployment("shop", "a", map[string]string{types.QuillaMinimumApprovalsLabel: "1"}, true)
+	provider, teardown := newTestingProvider(t, fi, sender, dep)
+	defer teardown()
+
+	repo := types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.2"}
+	identifier := getApprovalIdentifier("deployment/shop/a", "1.1.2")
+
+	updated, err := provider.processEvent(&types.Event{Repository: repo})
+	if err != nil || len(updated) != 0 {
+		t.Fatalf("expected approval to be requested, updated: %d, err: %v", len(updated), err)
+	}
+	approval, err := provider.approvalManager.Get(identifier)
+	if err != nil {
+		t.Fatalf("failed to get approval: %s", err)
+	}
+	if approval.Plan.Diff() != "deployment/shop/a a: gcr.io/v2-namespace/hello-world:1.1.1 -> gcr.io/v2-namespace/hello-world:1.1.2" {
+		t.Errorf("unexpected approval plan: %s", approval.Plan.Diff())
+	}
+
+	_, err = provider.approvalManager.Approve(identifier, &types.Voter{Name: "alice"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+
+	// deployment was changed manually after approval was requested
+	changed := dep.DeepCopy()
+	changed.Spec.Template.Spec.Containers[0].Image = "gcr.io/v2-namespace/hello-world:1.1.0"
+	provider.cache.(*k8s.GenericResourceCache).Add(MustParseGR(changed))
+
+	approvalEvent := &types.Event{Repository: repo, TriggerName: types.TriggerTypeApproval.String()}
+	updated, err = provider.processEvent(approvalEvent)
+	if err != nil || len(updated) != 0 {
+		t.Fatalf("drifted update shouldn't be applied, updated: %d, err: %v", len(updated), err)
+	}
+	if sender.sentEvent.Name != "approved update not applied" || sender.sentEvent.Level != types.LevelError {
+		t.Errorf("expected drift notification, got: %+v", sender.sentEvent)
+	}
+
+	// reverting the change applies the approved plan
+	provider.cache.(*k8s.GenericResourceCache).Add(MustParseGR(dep))
+	updated, err = provider.processEvent(approvalEvent)
+	if err != nil || len(updated) != 1 {
+		t.Fatalf("expected approved plan to be applied, updated: %d, err: %v", len(updated), err)
+	}
+}
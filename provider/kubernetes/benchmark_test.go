@@ -0,0 +1,75 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func benchmarkProvider(b *testing.B, size int) *Provider {
+	out := log.StandardLogger().Out
+	log.SetOutput(ioutil.Discard)
+	b.Cleanup(func() { log.SetOutput(out) })
+
+	grc := &k8s.GenericResourceCache{}
+	for i := 0; i < size; i++ {
+		grc.Add(MustParseGR(&apps_v1.Deployment{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:            fmt.Sprintf("dep-%d", i),
+				Namespace:       "xxxx",
+				ResourceVersion: "1",
+				Annotations:     map[string]string{types.QuillaPolicyLabel: "all"},
+			},
+			Spec: apps_v1.DeploymentSpec{
+				Template: v1.PodTemplateSpec{
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{
+							{Image: fmt.Sprintf("gcr.io/v2-namespace/app-%d:1.0.0", i)},
+							{Image: "gcr.io/v2-namespace/sidecar:1.0.0"},
+						},
+					},
+				},
+			},
+		}))
+	}
+
+	provider, err := NewProvider(&fakeImplementer{}, &fakeSender{}, nil, grc)
+	if err != nil {
+		b.Fatalf("failed to get provider: %s", err)
+	}
+	return provider
+}
+
+func BenchmarkCreateUpdatePlans(b *testing.B) {
+	provider := benchmarkProvider(b, 5000)
+	repo := &types.Repository{Name: "gcr.io/v2-namespace/app-42", Tag: "1.1.0"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		plans, err := provider.createUpdatePlans(repo)
+		if err != nil || len(plans) != 1 {
+			b.Fatalf("unexpected plans: %d, error: %v", len(plans), err)
+		}
+	}
+}
+
+func BenchmarkTrackedImages(b *testing.B) {
+	provider := benchmarkProvider(b, 5000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tracked, err := provider.TrackedImages()
+		if err != nil || len(tracked) != 10000 {
+			b.Fatalf("unexpected tracked images: %d, error: %v", len(tracked), err)
+		}
+	}
+}
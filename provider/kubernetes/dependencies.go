@@ -0,0 +1,299 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dependencyCheckInterval - how often waiting updates are checked
+var dependencyCheckInterval = 30 * time.Second
+
+// WaitingStore - persists updates that wait for their dependencies or update hooks
+type WaitingStore interface {
+	SaveWaitingUpdate(update *types.WaitingUpdate) error
+	DeleteWaitingUpdate(identifier string) error
+	ListWaitingUpdates() ([]*types.WaitingUpdate, error)
+}
+
+// SetWaitingStore - sets store that keeps updates waiting for dependencies and
+// update hooks, without it waiting updates are only retried by new events and
+// post update hooks that don't finish right away aren't checked
+func (p *Provider) SetWaitingStore(waiting WaitingStore) {
+	p.waiting = waiting
+}
+
+// getDependencies - parses dependencies into resource identifiers,
+// ie: ns/deployment/db-migrator becomes deployment/ns/db-migrator
+func getDependencies(resource *k8s.GenericResource) ([]string, error) {
+	value := strings.TrimSpace(resource.GetAnnotations()[types.QuillaDependsOnAnnotation])
+	if value == "" {
+		return nil, nil
+	}
+
+	var dependencies []string
+	for _, dep := range strings.Split(value, ",") {
+		dep = strings.TrimSpace(dep)
+		if dep == "" {
+			continue
+		}
+		parts := strings.Split(dep, "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid dependency %s, expected namespace/kind/name", dep)
+		}
+		dependencies = append(dependencies, strings.ToLower(parts[1])+"/"+parts[0]+"/"+parts[2])
+	}
+	return dependencies, nil
+}
+
+// sortPlans - orders plans so dependencies from the same event are updated
+// first, plans that form or depend on a cycle are returned separately
+func sortPlans(plans []*UpdatePlan) (sorted []*UpdatePlan, cyclic []*UpdatePlan) {
+	byIdentifier := make(map[string]*UpdatePlan, len(plans))
+	for _, plan := range plans {
+		byIdentifier[plan.Resource.Identifier] = plan
+	}
+
+	// number of unresolved dependencies and reverse edges
+	pending := make(map[string]int, len(plans))
+	dependents := map[string][]string{}
+	for _, plan := range plans {
+		deps, _ := getDependencies(plan.Resource)
+		for _, dep := range deps {
+			if _, ok := byIdentifier[dep]; !ok {
+				continue
+			}
+			pending[plan.Resource.Identifier]++
+			dependents[dep] = append(dependents[dep], plan.Resource.Identifier)
+		}
+	}
+
+	var queue []string
+	for _, plan := range plans {
+		if pending[plan.Resource.Identifier] == 0 {
+			queue = append(queue, plan.Resource.Identifier)
+		}
+	}
+
+	done := map[string]bool{}
+	for len(queue) > 0 {
+		identifier := queue[0]
+		queue = queue[1:]
+		done[identifier] = true
+		sorted = append(sorted, byIdentifier[identifier])
+
+		for _, dependent := range dependents[identifier] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	for _, plan := range plans {
+		if !done[plan.Resource.Identifier] {
+			cyclic = append(cyclic, plan)
+		}
+	}
+	return sorted, cyclic
+}
+
+// dependenciesReady - checks that dependencies run the new version and finished
+// rolling out, returns dependencies that aren't ready. Rollouts aren't waited for,
+// update waits in the store until dependencies are ready.
+func (p *Provider) dependenciesReady(plan *UpdatePlan, deps []string, planned map[string]*UpdatePlan, applied map[string]bool) []string {
+	var notReady []string
+	for _, dep := range deps {
+		if depPlan, ok := planned[dep]; ok {
+			if !applied[dep] {
+				notReady = append(notReady, dep)
+				continue
+			}
+			current, err := p.implementer.Get(depPlan.Resource)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error":      err,
+					"identifier": plan.Resource.Identifier,
+					"dependency": dep,
+				}).Warn("provider.kubernetes: failed to get dependency rollout status")
+			}
+			if err != nil || !current.RolloutComplete() {
+				notReady = append(notReady, dep)
+			}
+			continue
+		}
+
+		if !p.runsVersion(dep, plan.NewVersion) {
+			notReady = append(notReady, dep)
+		}
+	}
+	return notReady
+}
+
+// runsVersion - checks whether cached resource runs the version and finished
+// rolling out
+func (p *Provider) runsVersion(identifier, version string) bool {
+	for _, ir := range p.cache.Indexed() {
+		if ir.Resource.Identifier != identifier {
+			continue
+		}
+		if !ir.Resource.RolloutComplete() {
+			return false
+		}
+		for _, c := range ir.Containers {
+			if c.Ref.Tag() == version {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// waitForDependencies - stores update so it's retried until dependencies are ready
+func (p *Provider) waitForDependencies(event *types.Event, plan *UpdatePlan, notReady []string) {
+	log.WithFields(log.Fields{
+		"identifier":   plan.Resource.Identifier,
+		"version":      plan.NewVersion,
+		"dependencies": strings.Join(notReady, ", "),
+	}).Info("provider.kubernetes: update is waiting for dependencies")
+
+	if p.waiting == nil {
+		return
+	}
+
+	err := p.waiting.SaveWaitingUpdate(&types.WaitingUpdate{
+		Identifier: plan.Resource.Identifier,
+		Version:    plan.NewVersion,
+		DependsOn:  strings.Join(notReady, ","),
+		Event:      event,
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": plan.Resource.Identifier,
+		}).Error("provider.kubernetes: failed to save waiting update")
+	}
+}
+
+func (p *Provider) doneWaiting(identifier string) {
+	if p.waiting == nil {
+		return
+	}
+	err := p.waiting.DeleteWaitingUpdate(identifier)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": identifier,
+		}).Error("provider.kubernetes: failed to delete waiting update")
+	}
+}
+
+func (p *Provider) notifyCycle(cyclic []*UpdatePlan) {
+	identifiers := make([]string, 0, len(cyclic))
+	for _, plan := range cyclic {
+		identifiers = append(identifiers, plan.Resource.Identifier)
+	}
+
+	log.WithFields(log.Fields{
+		"resources": strings.Join(identifiers, ", "),
+	}).Error("provider.kubernetes: dependency cycle detected, resources won't be updated")
+
+	plan := cyclic[0]
+	p.sender.Send(types.EventNotification{
+		ResourceKind: plan.Resource.Kind(),
+		Identifier:   plan.Resource.Identifier,
+		Name:         "dependency cycle",
+		Message:      fmt.Sprintf("Dependency cycle detected, resources won't be updated to %s: %s", plan.NewVersion, strings.Join(identifiers, ", ")),
+		CreatedAt:    time.Now(),
+		Type:         types.NotificationDeploymentUpdate,
+		Level:        types.LevelError,
+		Channels:     types.ParseEventNotificationChannels(plan.Resource.GetAnnotations()),
+		Metadata: map[string]string{
+			"provider":  p.GetName(),
+			"resources": strings.Join(identifiers, ","),
+		},
+	})
+}
+
+// watchDependencies - periodically submits events of waiting updates
+func (p *Provider) watchDependencies() {
+	ticker := time.NewTicker(dependencyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.checkWaitingUpdates()
+		}
+	}
+}
+
+func (p *Provider) checkWaitingUpdates() {
+	waiting, err := p.waiting.ListWaitingUpdates()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("provider.kubernetes: failed to list waiting updates")
+		return
+	}
+
+	for _, update := range waiting {
+		if update.Hook == string(hookStagePostUpdate) {
+			p.resumePostUpdateHook(update)
+			continue
+		}
+		if update.Event == nil || p.staleWaitingUpdate(update) {
+			p.doneWaiting(update.Identifier)
+			continue
+		}
+
+		event := *update.Event
+		event.TriggerName = types.TriggerTypeDependency.String()
+		event.CreatedAt = time.Now()
+
+		err = p.submit(&event)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"identifier": update.Identifier,
+				"version":    update.Version,
+			}).Error("provider.kubernetes: failed to process waiting update")
+		}
+	}
+}
+
+// staleWaitingUpdate - resource was removed, no longer has dependencies or
+// the hook it waits for, or already runs the version
+func (p *Provider) staleWaitingUpdate(update *types.WaitingUpdate) bool {
+	for _, ir := range p.cache.Indexed() {
+		if ir.Resource.Identifier != update.Identifier {
+			continue
+		}
+		if update.Hook != "" {
+			hook, err := getUpdateHook(hookStage(update.Hook), ir.Resource.GetAnnotations())
+			if err != nil || hook == nil {
+				return true
+			}
+		} else {
+			deps, err := getDependencies(ir.Resource)
+			if err != nil || len(deps) == 0 {
+				return true
+			}
+		}
+		for _, c := range ir.Containers {
+			if c.Ref.Tag() == update.Version {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
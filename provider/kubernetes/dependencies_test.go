@@ -0,0 +1,230 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func dependentDeployment(name, image, dependsOn string) *apps_v1.Deployment {
+	replicas := int32(1)
+	annotations := map[string]string{
+		types.QuillaPolicyLabel: "all",
+	}
+	if dependsOn != "" {
+		annotations[types.QuillaDependsOnAnnotation] = dependsOn
+	}
+	return &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        name,
+			Namespace:   "shop",
+			Annotations: annotations,
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: name, Image: image},
+					},
+				},
+			},
+		},
+		Status: apps_v1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+	}
+}
+
+func TestGetDependencies(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "none", value: ""},
+		{name: "single", value: "shop/deployment/db-migrator", want: []string{"deployment/shop/db-migrator"}},
+		{name: "multiple", value: "shop/Deployment/db, other/statefulset/cache ", want: []string{"deployment/shop/db", "statefulset/other/cache"}},
+		{name: "invalid", value: "shop/db", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := MustParseGR(dependentDeployment("app", "gcr.io/v2-namespace/hello-world:1.1.1", tt.value))
+			got, err := getDependencies(resource)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getDependencies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("getDependencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateOrderFollowsDependencies(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		dependentDeployment("app", "gcr.io/v2-namespace/hello-world:1.1.1", "shop/deployment/db-migrator"),
+		dependentDeployment("worker", "gcr.io/v2-namespace/hello-world:1.1.1", "shop/deployment/app"),
+		dependentDeployment("db-migrator", "gcr.io/v2-namespace/hello-world:1.1.1", ""),
+	)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+
+	var order []string
+	for _, updated := range fi.updates {
+		order = append(order, updated.Identifier)
+	}
+	want := "deployment/shop/db-migrator,deployment/shop/app,deployment/shop/worker"
+	if strings.Join(order, ",") != want {
+		t.Errorf("unexpected update order: %v", order)
+	}
+}
+
+func TestDependencyCycleReported(t *testing.T) {
+	fi := &fakeImplementer{}
+	sender := &fakeSender{}
+	provider, teardown := newTestingProvider(t, fi, sender,
+		dependentDeployment("api", "gcr.io/v2-namespace/hello-world:1.1.1", "shop/deployment/worker"),
+		dependentDeployment("worker", "gcr.io/v2-namespace/hello-world:1.1.1", "shop/deployment/api"),
+		dependentDeployment("frontend", "gcr.io/v2-namespace/hello-world:1.1.1", ""),
+	)
+	defer teardown()
+
+	err := provider.Submit(types.Event{
+		Repository: types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.2"},
+	})
+	if err == nil {
+		t.Errorf("expected cycle to be reported as an error")
+	}
+
+	if len(fi.updates) != 1 || fi.updates[0].Identifier != "deployment/shop/frontend" {
+		t.Fatalf("expected only resource outside of the cycle to be updated, got: %d updates", len(fi.updates))
+	}
+
+	var reported bool
+	for _, sent := range sender.sent {
+		if sent.Name == "dependency cycle" && sent.Level == types.LevelError {
+			reported = strings.Contains(sent.Message, "deployment/shop/api") && strings.Contains(sent.Message, "deployment/shop/worker")
+		}
+	}
+	if !reported {
+		t.Errorf("expected dependency cycle notification")
+	}
+}
+
+func TestWaitingUpdateSurvivesRestart(t *testing.T) {
+	store, storeTeardown := NewTestingUtils()
+	defer storeTeardown()
+
+	// migrator runs a different image, it's updated by another event
+	migrator := dependentDeployment("db-migrator", "gcr.io/v2-namespace/migrator:1.1.1", "")
+	app := dependentDeployment("app", "gcr.io/v2-namespace/hello-world:1.1.1", "shop/deployment/db-migrator")
+
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, migrator, app)
+	provider.SetWaitingStore(store)
+
+	submitVersion(t, provider, "1.1.2")
+	teardown()
+
+	if len(fi.updates) != 0 {
+		t.Fatalf("app shouldn't be updated before its dependency")
+	}
+
+	waiting, err := store.ListWaitingUpdates()
+	if err != nil {
+		t.Fatalf("failed to list waiting updates: %s", err)
+	}
+	if len(waiting) != 1 || waiting[0].Identifier != "deployment/shop/app" || waiting[0].DependsOn != "deployment/shop/db-migrator" {
+		t.Fatalf("expected app to wait for migrator, got: %+v", waiting)
+	}
+
+	// quilla restarts after the migrator rolled out the version
+	fi = &fakeImplementer{}
+	provider, teardown = newTestingProvider(t, fi, &fakeSender{},
+		dependentDeployment("db-migrator", "gcr.io/v2-namespace/migrator:1.1.2", ""),
+		app,
+	)
+	defer teardown()
+	provider.SetWaitingStore(store)
+
+	provider.checkWaitingUpdates()
+
+	if len(fi.updates) != 1 || fi.updates[0].Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.2" {
+		t.Fatalf("expected app to be updated once dependency rolled out, got: %d updates", len(fi.updates))
+	}
+
+	waiting, err = store.ListWaitingUpdates()
+	if err != nil {
+		t.Fatalf("failed to list waiting updates: %s", err)
+	}
+	if len(waiting) != 0 {
+		t.Errorf("expected waiting update to be removed, got: %d", len(waiting))
+	}
+}
+
+func TestSortPlansKeepsOrderWithoutDependencies(t *testing.T) {
+	var plans []*UpdatePlan
+	for _, name := range []string{"c", "a", "b"} {
+		plans = append(plans, &UpdatePlan{
+			Resource: MustParseGR(dependentDeployment(name, "gcr.io/v2-namespace/hello-world:1.1.1", "")),
+		})
+	}
+
+	sorted, cyclic := sortPlans(plans)
+	if len(cyclic) != 0 {
+		t.Fatalf("unexpected cycle: %d", len(cyclic))
+	}
+	for i := range plans {
+		if sorted[i] != plans[i] {
+			t.Errorf("expected original order, got %s at %d", sorted[i].Resource.Identifier, i)
+		}
+	}
+}
+
+func TestDependencyRolloutIsNotWaitedFor(t *testing.T) {
+	store, storeTeardown := NewTestingUtils()
+	defer storeTeardown()
+
+	rolling := dependentDeployment("db-migrator", "gcr.io/v2-namespace/hello-world:1.1.2", "")
+	rolling.Status = apps_v1.DeploymentStatus{Replicas: 1}
+	migrator := MustParseGR(rolling)
+
+	fi := &fakeImplementer{current: map[string]*k8s.GenericResource{migrator.Identifier: migrator}}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		dependentDeployment("app", "gcr.io/v2-namespace/hello-world:1.1.1", "shop/deployment/db-migrator"),
+		dependentDeployment("db-migrator", "gcr.io/v2-namespace/hello-world:1.1.1", ""),
+	)
+	defer teardown()
+	provider.SetWaitingStore(store)
+
+	done := make(chan struct{})
+	go func() {
+		submitVersion(t, provider, "1.1.2")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("event processing blocked on dependency rollout")
+	}
+
+	if len(fi.updates) != 1 || fi.updates[0].Identifier != migrator.Identifier {
+		t.Fatalf("expected only dependency to be updated, got: %d updates", len(fi.updates))
+	}
+	waiting, err := store.ListWaitingUpdates()
+	if err != nil {
+		t.Fatalf("failed to list waiting updates: %s", err)
+	}
+	if len(waiting) != 1 || waiting[0].Identifier != "deployment/shop/app" {
+		t.Fatalf("expected app to wait for the rollout, got: %+v", waiting)
+	}
+}
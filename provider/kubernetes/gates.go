@@ -0,0 +1,378 @@
+package kubernetes
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/quilla-hq/quilla/internal/gate"
+	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/types"
+
+	batch_v1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// gateCheckInterval - how often gates that are in progress are checked
+var gateCheckInterval = 30 * time.Second
+
+// GateStore - persists gate runs
+type GateStore interface {
+	CreateGate(gate *types.Gate) (*types.Gate, error)
+	UpdateGate(gate *types.Gate) error
+	GetGate(q *types.GetGateQuery) (*types.Gate, error)
+	ListGates(q *types.GetGateQuery) ([]*types.Gate, error)
+}
+
+// SetGateStore - sets store that keeps gate runs, gates never pass without it
+func (p *Provider) SetGateStore(gates GateStore) {
+	p.gates = gates
+}
+
+func gateNamespace() string {
+	ns := os.Getenv("NAMESPACE")
+	if ns == "" {
+		ns = "default"
+	}
+	return ns
+}
+
+// gateJobName - job name is unique per resource and target version
+func gateJobName(identifier, name, version string) string {
+	return jobName("gate-job-", identifier, name, version)
+}
+
+// jobName - builds job name that fits into a label value
+func jobName(prefix, identifier, name, version string) string {
+	sum := sha1.Sum([]byte(prefix + identifier + ":" + version))
+	suffix := hex.EncodeToString(sum[:])[:10]
+
+	// job name is used as a pod label value, it has to fit 63 characters
+	prefix = prefix + strings.ToLower(name)
+	if len(prefix) > 52 {
+		prefix = prefix[:52]
+	}
+	return strings.TrimRight(prefix, "-.") + "-" + suffix
+}
+
+// watchGates - periodically submits events of gates that are in progress
+// so updates are applied once gates pass
+func (p *Provider) watchGates() {
+	ticker := time.NewTicker(gateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.checkPendingGates()
+		}
+	}
+}
+
+func (p *Provider) checkPendingGates() {
+	pending, err := p.gates.ListGates(&types.GetGateQuery{Pending: true})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("provider.kubernetes: failed to list pending gates")
+		return
+	}
+
+	for _, run := range pending {
+		if run.Event != nil {
+			event := *run.Event
+			event.TriggerName = types.TriggerTypeGate.String()
+			event.CreatedAt = time.Now()
+
+			err = p.submit(&event)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error":      err,
+					"identifier": run.Identifier,
+					"version":    run.Version,
+				}).Error("provider.kubernetes: failed to process gate event")
+			}
+		}
+
+		if !time.Now().After(run.Deadline) {
+			continue
+		}
+		// resource could have changed so the event no longer produces an update plan
+		current, err := p.gates.GetGate(&types.GetGateQuery{ID: run.ID})
+		if err == nil && !current.Complete {
+			p.finishGate(current, types.GateStatusFailed, "gate timed out")
+		}
+	}
+}
+
+// checkGate - checks whether update plan passed resource gate, gate run is started
+// when there's no run for the target version yet
+func (p *Provider) checkGate(repo *types.Repository, plan *UpdatePlan) bool {
+	resource := plan.Resource
+
+	g := gate.GetGateFromLabelsOrAnnotations(resource.Identifier, resource.GetLabels(), resource.GetAnnotations())
+	if g.Type() == gate.GateTypeNone {
+		return true
+	}
+
+	if p.gates == nil {
+		log.WithFields(log.Fields{
+			"identifier": resource.Identifier,
+		}).Error("provider.kubernetes: gate store is not configured, gate can't pass")
+		return false
+	}
+
+	// gates of the same resource are checked by a single worker
+	p.locks.Lock("gate:" + resource.Identifier)
+	defer p.locks.Unlock("gate:" + resource.Identifier)
+
+	run, err := p.gates.GetGate(&types.GetGateQuery{
+		Identifier: resource.Identifier,
+		Version:    plan.NewVersion,
+	})
+	started := err == store.ErrRecordNotFound
+	switch {
+	case err == store.ErrRecordNotFound:
+		run = p.startGate(g, repo, plan)
+		if run == nil {
+			return false
+		}
+	case err != nil:
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": resource.Identifier,
+			"version":    plan.NewVersion,
+		}).Error("provider.kubernetes: failed to get gate")
+		return false
+	}
+
+	if run.Complete {
+		return run.Status == types.GateStatusSucceeded
+	}
+	if started {
+		p.gateStatus(plan, run)
+	}
+
+	return p.updateGate(g, plan, run)
+}
+
+// startGate - supersedes runs for other versions and starts a new one
+func (p *Provider) startGate(g gate.Gate, repo *types.Repository, plan *UpdatePlan) *types.Gate {
+	resource := plan.Resource
+
+	existing, err := p.gates.ListGates(&types.GetGateQuery{Identifier: resource.Identifier, Pending: true})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": resource.Identifier,
+		}).Error("provider.kubernetes: failed to list gates")
+	}
+	for _, run := range existing {
+		p.finishGate(run, types.GateStatusFailed, fmt.Sprintf("superseded by version %s", plan.NewVersion))
+	}
+
+	var candidate string
+	if len(plan.Changes) > 0 {
+		candidate = plan.Changes[len(plan.Changes)-1].Image
+	}
+
+	run := &types.Gate{
+		Identifier: resource.Identifier,
+		Type:       g.Type().String(),
+		Version:    plan.NewVersion,
+		Image:      candidate,
+		Event:      &types.Event{Repository: *repo},
+		Status:     types.GateStatusInProgress,
+		Deadline:   time.Now().Add(gate.GetTimeout(g)),
+	}
+
+	if jg, ok := g.(*gate.JobGate); ok {
+		run.Job = gateJobName(resource.Identifier, resource.Name, plan.NewVersion)
+		err = p.implementer.CreateJob(newGateJob(jg, run, plan.CurrentVersion))
+		if err != nil && !errors.IsAlreadyExists(err) {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"identifier": resource.Identifier,
+				"version":    plan.NewVersion,
+			}).Error("provider.kubernetes: failed to create gate job")
+			return nil
+		}
+	}
+
+	_, err = p.gates.CreateGate(run)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": resource.Identifier,
+			"version":    plan.NewVersion,
+		}).Error("provider.kubernetes: failed to save gate")
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"identifier": resource.Identifier,
+		"version":    plan.NewVersion,
+		"gate":       g.Name(),
+	}).Info("provider.kubernetes: gate started")
+
+	return run
+}
+
+// updateGate - checks gate, returns true once it passes
+func (p *Provider) updateGate(g gate.Gate, plan *UpdatePlan, run *types.Gate) bool {
+	var passed bool
+	switch g := g.(type) {
+	case *gate.JobGate:
+		job, err := p.implementer.Job(gateNamespace(), run.Job)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				p.finishGate(run, types.GateStatusFailed, "gate job not found")
+				p.notifyGateFailed(plan, run)
+				return false
+			}
+			log.WithFields(log.Fields{
+				"error": err,
+				"job":   run.Job,
+			}).Error("provider.kubernetes: failed to get gate job")
+			return false
+		}
+		passed = g.ShouldPass(job)
+	case *gate.HTTPGate:
+		passed = g.ShouldPass(&gate.PendingUpdate{
+			GateID:         run.ID,
+			Identifier:     run.Identifier,
+			Image:          run.Image,
+			CurrentVersion: plan.CurrentVersion,
+			NewVersion:     plan.NewVersion,
+			Changes:        plan.Changes,
+			Deadline:       run.Deadline,
+		})
+	case *gate.ManualGate:
+		passed = g.ShouldPass(run)
+	}
+
+	if passed {
+		p.finishGate(run, types.GateStatusSucceeded, fmt.Sprintf("%s passed", strings.ToLower(g.Name())))
+		p.gateStatus(plan, run)
+		log.WithFields(log.Fields{
+			"identifier": run.Identifier,
+			"version":    run.Version,
+		}).Info("provider.kubernetes: gate passed")
+		return true
+	}
+
+	switch {
+	case g.Status() == gate.GateStatusFailed:
+		p.finishGate(run, types.GateStatusFailed, fmt.Sprintf("%s failed", strings.ToLower(g.Name())))
+	case time.Now().After(run.Deadline):
+		p.finishGate(run, types.GateStatusFailed, "gate timed out")
+	default:
+		return false
+	}
+
+	p.notifyGateFailed(plan, run)
+	return false
+}
+
+// finishGate - completes gate run and deletes its job
+func (p *Provider) finishGate(run *types.Gate, status int, message string) {
+	run.Complete = true
+	run.Status = status
+	run.Message = message
+
+	err := p.gates.UpdateGate(run)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": run.Identifier,
+		}).Error("provider.kubernetes: failed to update gate")
+	}
+
+	if run.Job == "" {
+		return
+	}
+	err = p.implementer.DeleteJob(gateNamespace(), run.Job)
+	if err != nil && !errors.IsNotFound(err) {
+		log.WithFields(log.Fields{
+			"error": err,
+			"job":   run.Job,
+		}).Warn("provider.kubernetes: failed to delete finished gate job")
+	}
+}
+
+func (p *Provider) notifyGateFailed(plan *UpdatePlan, run *types.Gate) {
+	resource := plan.Resource
+	p.gateStatus(plan, run)
+	p.sender.Send(types.EventNotification{
+		ResourceKind: resource.Kind(),
+		Identifier:   resource.Identifier,
+		Name:         "gate failed",
+		Message:      fmt.Sprintf("Gate for %s %s/%s %s failed: %s", resource.Kind(), resource.Namespace, resource.Name, plan.delta(), run.Message),
+		CreatedAt:    time.Now(),
+		Type:         types.NotificationDeploymentUpdate,
+		Level:        types.LevelError,
+		Channels:     types.ParseEventNotificationChannels(resource.GetAnnotations()),
+		Metadata: map[string]string{
+			"provider":  p.GetName(),
+			"namespace": resource.GetNamespace(),
+			"name":      resource.GetName(),
+		},
+	})
+}
+
+func newGateJob(jg *gate.JobGate, run *types.Gate, currentVersion string) *batch_v1.Job {
+	var backoff int32 = 1
+	deadline := int64(jg.Timeout.Seconds())
+
+	job := &batch_v1.Job{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      run.Job,
+			Namespace: gateNamespace(),
+		},
+		Spec: batch_v1.JobSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Name:            "job",
+							Image:           jg.Image,
+							ImagePullPolicy: v1.PullIfNotPresent,
+							Env: []v1.EnvVar{
+								{Name: gate.EnvIdentifier, Value: run.Identifier},
+								{Name: gate.EnvImage, Value: run.Image},
+								{Name: gate.EnvVersion, Value: run.Version},
+								{Name: gate.EnvCurrentVersion, Value: currentVersion},
+							},
+						},
+					},
+					RestartPolicy: v1.RestartPolicyNever,
+				},
+			},
+			BackoffLimit:          &backoff,
+			ActiveDeadlineSeconds: &deadline,
+		},
+	}
+
+	if jg.Secret() != "" {
+		job.Spec.Template.Spec.Containers[0].EnvFrom = []v1.EnvFromSource{
+			{
+				SecretRef: &v1.SecretEnvSource{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: jg.Secret(),
+					},
+				},
+			},
+		}
+	}
+
+	return job
+}
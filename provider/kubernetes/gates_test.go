@@ -0,0 +1,312 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/internal/gate"
+	"github.com/quilla-hq/quilla/types"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func gatedDeployment(annotations map[string]string) *apps_v1.Deployment {
+	ann := map[string]string{
+		types.QuillaPolicyLabel: "all",
+		types.QuillaGateLabel:   "job:gcr.io/v2-namespace/verify:1.0.0",
+	}
+	for k, v := range annotations {
+		ann[k] = v
+	}
+	return &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        "dep-1",
+			Namespace:   "xxxx",
+			Annotations: ann,
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func submitVersion(t *testing.T, provider *Provider, tag string) {
+	err := provider.Submit(types.Event{
+		Repository: types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: tag},
+	})
+	if err != nil {
+		t.Fatalf("failed to submit event: %s", err)
+	}
+}
+
+func envValue(env []v1.EnvVar, name string) string {
+	for _, e := range env {
+		if e.Name == name {
+			return e.Value
+		}
+	}
+	return ""
+}
+
+func TestGateJobPasses(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, gatedDeployment(nil))
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+
+	if fi.updated != nil {
+		t.Fatalf("resource shouldn't be updated before gate passes")
+	}
+	if len(fi.jobs) != 1 {
+		t.Fatalf("expected 1 gate job, got: %d", len(fi.jobs))
+	}
+
+	run, err := provider.gates.GetGate(&types.GetGateQuery{Identifier: "deployment/xxxx/dep-1", Version: "1.1.2"})
+	if err != nil {
+		t.Fatalf("failed to get gate: %s", err)
+	}
+	if run.Status != types.GateStatusInProgress {
+		t.Errorf("unexpected gate status: %s", run.StatusString())
+	}
+
+	job := fi.jobs[run.Job]
+	env := job.Spec.Template.Spec.Containers[0].Env
+	if envValue(env, gate.EnvImage) != "gcr.io/v2-namespace/hello-world:1.1.2" {
+		t.Errorf("unexpected candidate image: %s", envValue(env, gate.EnvImage))
+	}
+	if envValue(env, gate.EnvVersion) != "1.1.2" {
+		t.Errorf("unexpected candidate version: %s", envValue(env, gate.EnvVersion))
+	}
+	if job.Spec.Template.Spec.Containers[0].Image != "gcr.io/v2-namespace/verify:1.0.0" {
+		t.Errorf("unexpected job image: %s", job.Spec.Template.Spec.Containers[0].Image)
+	}
+	if *job.Spec.ActiveDeadlineSeconds != int64(gate.DefaultTimeout.Seconds()) {
+		t.Errorf("unexpected job deadline: %d", *job.Spec.ActiveDeadlineSeconds)
+	}
+
+	// job is still running
+	submitVersion(t, provider, "1.1.2")
+	if fi.updated != nil {
+		t.Fatalf("resource shouldn't be updated while job is running")
+	}
+
+	job.Status.Succeeded = 1
+	provider.checkPendingGates()
+
+	if fi.updated == nil {
+		t.Fatalf("expected resource to be updated after gate passed")
+	}
+	if fi.updated.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.2" {
+		t.Errorf("unexpected image: %s", fi.updated.Containers()[0].Image)
+	}
+	if len(fi.jobs) != 0 || len(fi.deletedJobs) != 1 {
+		t.Errorf("expected finished job to be deleted")
+	}
+
+	run, err = provider.gates.GetGate(&types.GetGateQuery{ID: run.ID})
+	if err != nil {
+		t.Fatalf("failed to get gate: %s", err)
+	}
+	if !run.Complete || run.Status != types.GateStatusSucceeded {
+		t.Errorf("unexpected gate status: %s", run.StatusString())
+	}
+}
+
+type fakeSubmitter struct {
+	events []types.Event
+}
+
+func (s *fakeSubmitter) Submit(event types.Event) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestGateEventSubmittedThroughQueue(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, gatedDeployment(nil))
+	defer teardown()
+
+	submitter := &fakeSubmitter{}
+	provider.SetEventSubmitter(submitter)
+
+	submitVersion(t, provider, "1.1.2")
+	run, err := provider.gates.GetGate(&types.GetGateQuery{Identifier: "deployment/xxxx/dep-1", Version: "1.1.2"})
+	if err != nil {
+		t.Fatalf("failed to get gate: %s", err)
+	}
+	fi.jobs[run.Job].Status.Succeeded = 1
+
+	provider.checkPendingGates()
+
+	// update is applied by the queue worker, not by the gate watcher
+	if fi.updated != nil {
+		t.Fatalf("resource shouldn't be updated by the gate watcher")
+	}
+	if len(submitter.events) != 1 {
+		t.Fatalf("expected 1 submitted event, got: %d", len(submitter.events))
+	}
+	event := submitter.events[0]
+	if event.Provider != ProviderName || event.TriggerName != types.TriggerTypeGate.String() || event.Repository.Tag != "1.1.2" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
+
+func TestGateRerunOnVersionChange(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, gatedDeployment(nil))
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+	submitVersion(t, provider, "1.1.3")
+
+	if len(fi.jobs) != 1 {
+		t.Fatalf("expected 1 running gate job, got: %d", len(fi.jobs))
+	}
+	if len(fi.deletedJobs) != 1 {
+		t.Errorf("expected superseded job to be deleted")
+	}
+
+	old, err := provider.gates.GetGate(&types.GetGateQuery{Identifier: "deployment/xxxx/dep-1", Version: "1.1.2"})
+	if err != nil {
+		t.Fatalf("failed to get gate: %s", err)
+	}
+	if !old.Complete || old.Status != types.GateStatusFailed {
+		t.Errorf("expected superseded gate to fail, got: %s", old.StatusString())
+	}
+
+	pending, err := provider.gates.ListGates(&types.GetGateQuery{Pending: true})
+	if err != nil {
+		t.Fatalf("failed to list gates: %s", err)
+	}
+	if len(pending) != 1 || pending[0].Version != "1.1.3" {
+		t.Errorf("expected pending gate for 1.1.3, got: %v", pending)
+	}
+}
+
+func TestGateTimeout(t *testing.T) {
+	fi := &fakeImplementer{}
+	sender := &fakeSender{}
+	dep := gatedDeployment(map[string]string{types.QuillaGateTimeoutAnnotation: "1ms"})
+	provider, teardown := newTestingProvider(t, fi, sender, dep)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+	time.Sleep(5 * time.Millisecond)
+	provider.checkPendingGates()
+
+	if fi.updated != nil {
+		t.Fatalf("resource shouldn't be updated after gate timed out")
+	}
+	if len(fi.jobs) != 0 {
+		t.Errorf("expected timed out job to be deleted")
+	}
+
+	run, err := provider.gates.GetGate(&types.GetGateQuery{Identifier: "deployment/xxxx/dep-1", Version: "1.1.2"})
+	if err != nil {
+		t.Fatalf("failed to get gate: %s", err)
+	}
+	if !run.Complete || run.Status != types.GateStatusFailed {
+		t.Errorf("unexpected gate status: %s", run.StatusString())
+	}
+	if sender.sentEvent.Level != types.LevelError {
+		t.Errorf("expected gate failure notification, got: %s", sender.sentEvent.Message)
+	}
+}
+
+func TestGateJobName(t *testing.T) {
+	name := gateJobName("deployment/xxxx/a-very-long-deployment-name-that-does-not-fit-into-job-name", "a-very-long-deployment-name-that-does-not-fit-into-job-name", "1.0.0")
+	if len(name) > 63 {
+		t.Errorf("job name is too long: %s", name)
+	}
+	if gateJobName("deployment/xxxx/dep-1", "dep-1", "1.0.0") == gateJobName("deployment/xxxx/dep-1", "dep-1", "1.0.1") {
+		t.Errorf("expected different job names for different versions")
+	}
+}
+
+func TestHTTPGatePasses(t *testing.T) {
+	status := http.StatusInternalServerError
+	var received gate.PendingUpdate
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	fi := &fakeImplementer{}
+	dep := gatedDeployment(map[string]string{types.QuillaGateLabel: "http:" + srv.URL})
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, dep)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+
+	if fi.updated != nil {
+		t.Fatalf("resource shouldn't be updated before gate passes")
+	}
+	if len(fi.jobs) != 0 {
+		t.Errorf("http gate shouldn't create jobs")
+	}
+	if received.Identifier != "deployment/xxxx/dep-1" || received.NewVersion != "1.1.2" || received.CurrentVersion != "1.1.1" {
+		t.Errorf("unexpected pending update: %+v", received)
+	}
+
+	status = http.StatusOK
+	provider.checkPendingGates()
+
+	if fi.updated == nil {
+		t.Fatalf("expected resource to be updated after gate passed")
+	}
+
+	run, err := provider.gates.GetGate(&types.GetGateQuery{Identifier: "deployment/xxxx/dep-1", Version: "1.1.2"})
+	if err != nil {
+		t.Fatalf("failed to get gate: %s", err)
+	}
+	if !run.Complete || run.Status != types.GateStatusSucceeded || run.Type != "http" {
+		t.Errorf("unexpected gate run: %+v", run)
+	}
+}
+
+func TestManualGate(t *testing.T) {
+	fi := &fakeImplementer{}
+	dep := gatedDeployment(map[string]string{types.QuillaGateLabel: "manual"})
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, dep)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+	provider.checkPendingGates()
+
+	if fi.updated != nil {
+		t.Fatalf("resource shouldn't be updated before gate is passed")
+	}
+
+	run, err := provider.gates.GetGate(&types.GetGateQuery{Identifier: "deployment/xxxx/dep-1", Version: "1.1.2"})
+	if err != nil {
+		t.Fatalf("failed to get gate: %s", err)
+	}
+	if run.Type != "manual" || run.Complete {
+		t.Fatalf("unexpected gate run: %+v", run)
+	}
+
+	run.Status = types.GateStatusSucceeded
+	run.Complete = true
+	err = provider.gates.UpdateGate(run)
+	if err != nil {
+		t.Fatalf("failed to update gate: %s", err)
+	}
+
+	submitVersion(t, provider, "1.1.2")
+	if fi.updated == nil {
+		t.Fatalf("expected resource to be updated after gate passed")
+	}
+}
@@ -0,0 +1,346 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quilla-hq/quilla/approvals"
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/internal/policy"
+	"github.com/quilla-hq/quilla/types"
+	"github.com/quilla-hq/quilla/util/image"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// groupRolloutTimeout - how long group members are waited for to roll out
+// before the group is reverted
+var groupRolloutTimeout = 10 * time.Minute
+
+// rolloutPollInterval - how often rollouts of group members are checked
+var rolloutPollInterval = 5 * time.Second
+
+// updateGroup - plans of resources that are approved, gated and updated together
+type updateGroup struct {
+	name  string
+	plans []*UpdatePlan
+}
+
+func getGroup(resource *k8s.GenericResource) string {
+	return strings.TrimSpace(resource.GetAnnotations()[types.QuillaGroupAnnotation])
+}
+
+func (g *updateGroup) identifier() string {
+	return approvals.GroupPrefix + g.name
+}
+
+func (g *updateGroup) members() types.Members {
+	members := make(types.Members, 0, len(g.plans))
+	for _, plan := range g.plans {
+		members = append(members, plan.Resource.Identifier)
+	}
+	return members
+}
+
+// representative - plan that stands for the whole group in gates and approvals,
+// group requires the highest number of approvals of its members
+func (g *updateGroup) representative() *UpdatePlan {
+	first := g.plans[0]
+
+	var minApprovals int
+	for _, plan := range g.plans {
+		n, err := getInt(types.QuillaMinimumApprovalsLabel, plan.Resource.GetLabels(), plan.Resource.GetAnnotations())
+		if err == nil && n > minApprovals {
+			minApprovals = n
+		}
+	}
+
+	resource := first.Resource.DeepCopy()
+	resource.Identifier = g.identifier()
+	resource.Name = g.name
+
+	labels := resource.GetLabels()
+	delete(labels, types.QuillaMinimumApprovalsLabel)
+	resource.SetLabels(labels)
+	annotations := resource.GetAnnotations()
+	annotations[types.QuillaMinimumApprovalsLabel] = fmt.Sprintf("%d", minApprovals)
+	resource.SetAnnotations(annotations)
+
+	return &UpdatePlan{
+		Resource:       resource,
+		CurrentVersion: first.CurrentVersion,
+		NewVersion:     first.NewVersion,
+		group:          g,
+	}
+}
+
+// representedGroup - returns group when plan is its representative, members
+// of the group return nil
+func (plan *UpdatePlan) representedGroup() *updateGroup {
+	if plan.group != nil && plan.Resource.Identifier == plan.group.identifier() {
+		return plan.group
+	}
+	return nil
+}
+
+// groupPlans - splits plans into ungrouped plans and update groups
+func groupPlans(plans []*UpdatePlan) (ungrouped []*UpdatePlan, groups []*updateGroup) {
+	byName := map[string]*updateGroup{}
+	for _, plan := range plans {
+		name := getGroup(plan.Resource)
+		if name == "" {
+			ungrouped = append(ungrouped, plan)
+			continue
+		}
+		g, ok := byName[name]
+		if !ok {
+			g = &updateGroup{name: name}
+			byName[name] = g
+			groups = append(groups, g)
+		}
+		plan.group = g
+		g.plans = append(g.plans, plan)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].name < groups[j].name })
+	for _, g := range groups {
+		sort.Slice(g.plans, func(i, j int) bool {
+			return g.plans[i].Resource.Identifier < g.plans[j].Resource.Identifier
+		})
+	}
+	return
+}
+
+// groupComplete - checks that every member that should receive the version has
+// an update plan, group waits while any of them is held back
+func (p *Provider) groupComplete(g *updateGroup, repo *types.Repository) bool {
+	eventRepoRef, err := image.Parse(repo.String())
+	if err != nil {
+		return false
+	}
+
+	planned := map[string]bool{}
+	for _, plan := range g.plans {
+		planned[plan.Resource.Identifier] = true
+	}
+
+	for _, ir := range p.cache.Indexed() {
+		if getGroup(ir.Resource) != g.name || planned[ir.Resource.Identifier] {
+			continue
+		}
+		for _, c := range ir.Containers {
+			if c.Policy.Type() == policy.PolicyTypeNone {
+				continue
+			}
+			if c.Ref.Repository() == eventRepoRef.Repository() && c.Ref.Tag() != repo.Tag {
+				log.WithFields(log.Fields{
+					"group":   g.name,
+					"member":  ir.Resource.Identifier,
+					"version": repo.Tag,
+				}).Info("provider.kubernetes: update group member is not ready for the update, holding group")
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// updateGroup - gates and approves group once, then updates all members. If any
+// member fails to update or roll out, members that were already updated are reverted.
+func (p *Provider) updateGroup(event *types.Event, g *updateGroup) ([]*k8s.GenericResource, error) {
+	if !p.groupComplete(g, &event.Repository) {
+		return nil, nil
+	}
+
+	rep := g.representative()
+	if !p.checkGate(&event.Repository, rep) {
+		return nil, nil
+	}
+
+	approved, err := p.isApproved(event, rep)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"group": g.name,
+		}).Error("provider.kubernetes: failed to check approval status for update group")
+		return nil, nil
+	}
+	if !approved {
+		return nil, nil
+	}
+
+	// members are updated once pre update hooks of all of them pass
+	var pending bool
+	for _, plan := range g.plans {
+		plan.Approvers = rep.Approvers
+		switch p.preUpdateHook(event, plan) {
+		case hookPending:
+			pending = true
+		case hookFailed:
+			log.WithFields(log.Fields{
+				"group":      g.name,
+				"identifier": plan.Resource.Identifier,
+			}).Warn("provider.kubernetes: pre-update hook failed, update group aborted")
+			return nil, nil
+		}
+	}
+	if pending {
+		return nil, nil
+	}
+
+	// members count against rollout limits and budgets, group that doesn't
+	// fit is queued as a whole
+	if !p.limiter.acquire(rep) {
+		return nil, nil
+	}
+	return p.applyGroup(g, rep)
+}
+
+// applyQueuedGroup - applies group that was queued by rollout limits
+func (p *Provider) applyQueuedGroup(rep *UpdatePlan) {
+	for _, plan := range rep.group.plans {
+		err := p.refresh(plan)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":  err,
+				"group":  rep.group.name,
+				"member": plan.Resource.Identifier,
+			}).Error("provider.kubernetes: failed to get queued update group member, group dropped")
+			for _, member := range rep.group.plans {
+				p.limiter.release(member, true)
+			}
+			return
+		}
+	}
+
+	_, err := p.applyGroup(rep.group, rep)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"group": rep.group.name,
+		}).Error("provider.kubernetes: failed to apply queued update group")
+	}
+}
+
+// applyGroup - updates members of the group that acquired rollout slots and
+// waits for them to roll out
+func (p *Provider) applyGroup(g *updateGroup, rep *UpdatePlan) ([]*k8s.GenericResource, error) {
+	// members are locked only while they are patched, not while waiting for
+	// the rollout
+	var applied []*UpdatePlan
+	for i, plan := range g.plans {
+		p.locks.Lock(plan.Resource.Identifier)
+		ok := p.updateDeployment(plan)
+		p.locks.Unlock(plan.Resource.Identifier)
+		if !ok {
+			for _, skipped := range g.plans[i:] {
+				p.limiter.release(skipped, true)
+			}
+			return nil, p.revertGroup(g, applied, fmt.Sprintf("%s failed to update", plan.Resource.Identifier))
+		}
+		applied = append(applied, plan)
+	}
+
+	for _, plan := range applied {
+		err := p.waitForRollout(plan.Resource, groupRolloutTimeout)
+		if err != nil {
+			return nil, p.revertGroup(g, applied, fmt.Sprintf("%s failed to roll out: %s", plan.Resource.Identifier, err))
+		}
+	}
+
+	err := p.approvalManager.Archive(approvals.GroupIdentifier(g.name, rep.NewVersion))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"group": g.name,
+		}).Debug("provider.kubernetes: group approval not archived")
+	}
+
+	p.notifyGroup(g, rep, types.LevelSuccess, fmt.Sprintf("Successfully updated group %s %s (%s)", g.name, rep.delta(), g.members()))
+
+	updated := make([]*k8s.GenericResource, 0, len(applied))
+	for _, plan := range applied {
+		updated = append(updated, plan.Resource)
+	}
+	return updated, nil
+}
+
+// revertGroup - restores previous images of updated members. Failed version is
+// recorded on reverted members and group approval is archived so the group
+// isn't applied again, group stays held until a newer version arrives
+func (p *Provider) revertGroup(g *updateGroup, applied []*UpdatePlan, reason string) error {
+	var reverted, failed []string
+	for _, plan := range applied {
+		p.locks.Lock(plan.Resource.Identifier)
+		err := p.rollback(plan)
+		p.locks.Unlock(plan.Resource.Identifier)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":  err,
+				"group":  g.name,
+				"member": plan.Resource.Identifier,
+			}).Error("provider.kubernetes: failed to revert update group member")
+			failed = append(failed, plan.Resource.Identifier)
+			continue
+		}
+		reverted = append(reverted, plan.Resource.Identifier)
+	}
+
+	rep := g.plans[0]
+	err := p.approvalManager.Archive(approvals.GroupIdentifier(g.name, rep.NewVersion))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"group": g.name,
+		}).Debug("provider.kubernetes: group approval not archived")
+	}
+
+	msg := fmt.Sprintf("Update group %s %s->%s failed: %s", g.name, rep.CurrentVersion, rep.NewVersion, reason)
+	if len(reverted) > 0 {
+		msg = fmt.Sprintf("%s, reverted: %s", msg, strings.Join(reverted, ", "))
+	}
+	if len(failed) > 0 {
+		msg = fmt.Sprintf("%s, failed to revert: %s", msg, strings.Join(failed, ", "))
+	}
+	p.notifyGroup(g, rep, types.LevelError, msg)
+
+	return fmt.Errorf("update group %s failed: %s", g.name, reason)
+}
+
+// waitForRollout - waits until all replicas run the updated spec
+func (p *Provider) waitForRollout(resource *k8s.GenericResource, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		current, err := p.implementer.Get(resource)
+		if err != nil {
+			return fmt.Errorf("failed to get rollout status: %s", err)
+		}
+		if current.RolloutComplete() {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("rollout timed out")
+		}
+		time.Sleep(rolloutPollInterval)
+	}
+}
+
+func (p *Provider) notifyGroup(g *updateGroup, plan *UpdatePlan, level types.Level, message string) {
+	p.sender.Send(types.EventNotification{
+		ResourceKind: "group",
+		Identifier:   g.identifier(),
+		Name:         "update group",
+		Message:      message,
+		CreatedAt:    time.Now(),
+		Type:         types.NotificationDeploymentUpdate,
+		Level:        level,
+		Channels:     types.ParseEventNotificationChannels(plan.Resource.GetAnnotations()),
+		Metadata: map[string]string{
+			"provider": p.GetName(),
+			"group":    g.name,
+			"members":  g.members().String(),
+		},
+	})
+}
@@ -0,0 +1,185 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/quilla-hq/quilla/approvals"
+	"github.com/quilla-hq/quilla/types"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func groupMember(name string, annotations map[string]string) *apps_v1.Deployment {
+	replicas := int32(1)
+	ann := map[string]string{
+		types.QuillaPolicyLabel:     "all",
+		types.QuillaGroupAnnotation: "shop",
+	}
+	for k, v := range annotations {
+		ann[k] = v
+	}
+	return &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        name,
+			Namespace:   "shop",
+			Annotations: ann,
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: name, Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+					},
+				},
+			},
+		},
+		Status: apps_v1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+	}
+}
+
+func TestUpdateGroupSingleApproval(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		groupMember("frontend", nil),
+		groupMember("api", map[string]string{types.QuillaMinimumApprovalsLabel: "1"}),
+		groupMember("worker", nil),
+	)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+	if len(fi.updates) != 0 {
+		t.Fatalf("group shouldn't be updated before approval")
+	}
+
+	list, err := provider.approvalManager.List()
+	if err != nil {
+		t.Fatalf("failed to list approvals: %s", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected a single approval for the group, got: %d", len(list))
+	}
+	approval := list[0]
+	if approval.Identifier != approvals.GroupIdentifier("shop", "1.1.2") {
+		t.Errorf("unexpected approval identifier: %s", approval.Identifier)
+	}
+	if approval.Group != "shop" || len(approval.Members) != 3 {
+		t.Errorf("expected group members in approval, got: %v", approval.Members)
+	}
+	if !strings.Contains(approval.Message, "deployment/shop/api") {
+		t.Errorf("expected members in approval message, got: %s", approval.Message)
+	}
+
+	_, err = provider.approvalManager.Approve(approval.Identifier, &types.Voter{Name: "bob"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+
+	err = provider.Submit(types.Event{
+		Repository:  types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.2"},
+		TriggerName: types.TriggerTypeApproval.String(),
+	})
+	if err != nil {
+		t.Fatalf("failed to submit event: %s", err)
+	}
+
+	if len(fi.updates) != 3 {
+		t.Fatalf("expected all members to be updated, got: %d", len(fi.updates))
+	}
+	for _, updated := range fi.updates {
+		if updated.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.2" {
+			t.Errorf("unexpected image for %s: %s", updated.Identifier, updated.Containers()[0].Image)
+		}
+	}
+
+	_, err = provider.approvalManager.Get(approval.Identifier)
+	if err == nil {
+		t.Errorf("expected group approval to be archived")
+	}
+}
+
+func TestUpdateGroupRevertsOnFailure(t *testing.T) {
+	fi := &fakeImplementer{failUpdate: "deployment/shop/frontend"}
+	sender := &fakeSender{}
+	provider, teardown := newTestingProvider(t, fi, sender,
+		groupMember("api", nil),
+		groupMember("frontend", nil),
+		groupMember("worker", nil),
+	)
+	defer teardown()
+
+	err := provider.Submit(types.Event{
+		Repository: types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.2"},
+	})
+	if err == nil {
+		t.Errorf("expected group update to fail")
+	}
+
+	// api updated, frontend failed, api reverted, worker never touched
+	if len(fi.updates) != 2 {
+		t.Fatalf("expected update and revert, got: %d updates", len(fi.updates))
+	}
+	if fi.updates[0].Identifier != "deployment/shop/api" || fi.updates[0].Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.2" {
+		t.Errorf("expected api to be updated first, got: %s", fi.updates[0])
+	}
+	if fi.updates[1].Identifier != "deployment/shop/api" || fi.updates[1].Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.1" {
+		t.Errorf("expected api to be reverted, got: %s", fi.updates[1])
+	}
+	if sender.sentEvent.Level != types.LevelError || !strings.Contains(sender.sentEvent.Message, "reverted: deployment/shop/api") {
+		t.Errorf("expected group failure notification, got: %s", sender.sentEvent.Message)
+	}
+}
+
+func TestUpdateGroupFailureIsNotRetried(t *testing.T) {
+	fi := &fakeImplementer{failUpdate: "deployment/shop/frontend"}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		groupMember("api", map[string]string{types.QuillaMinimumApprovalsLabel: "1"}),
+		groupMember("frontend", nil),
+	)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+	identifier := approvals.GroupIdentifier("shop", "1.1.2")
+	_, err := provider.approvalManager.Approve(identifier, &types.Voter{Name: "bob"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+
+	err = provider.Submit(types.Event{
+		Repository:  types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.2"},
+		TriggerName: types.TriggerTypeApproval.String(),
+	})
+	if err == nil {
+		t.Fatalf("expected group update to fail")
+	}
+	if len(fi.updates) != 2 {
+		t.Fatalf("expected update and revert, got: %d updates", len(fi.updates))
+	}
+
+	// reverted member carries the failed version so it's not planned again
+	if fi.updates[1].GetAnnotations()[types.QuillaHookFailedAnnotation] != "1.1.2" {
+		t.Errorf("expected failed version to be recorded on reverted member, got: %v", fi.updates[1].GetAnnotations())
+	}
+	_, err = provider.approvalManager.Get(identifier)
+	if err == nil {
+		t.Errorf("expected group approval to be archived")
+	}
+}
+
+func TestUpdateGroupWaitsForAllMembers(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		groupMember("api", nil),
+		// version was rolled back in this member, group can't move
+		groupMember("worker", map[string]string{types.QuillaHookFailedAnnotation: "1.1.2"}),
+	)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+	if len(fi.updates) != 0 {
+		t.Fatalf("group shouldn't be updated while a member is held back")
+	}
+}
@@ -0,0 +1,204 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
+	"github.com/quilla-hq/quilla/util/image"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HistoryStore - persists version history of resources
+type HistoryStore interface {
+	CreateHistoryEntry(entry *types.HistoryEntry) (*types.HistoryEntry, error)
+	ListHistory(q *types.GetHistoryQuery) ([]*types.HistoryEntry, error)
+}
+
+// SetHistoryStore - sets store that keeps version history, resources
+// can't be rolled back without it
+func (p *Provider) SetHistoryStore(history HistoryStore) {
+	p.history = history
+}
+
+// recordHistory - records every container change of the plan
+func (p *Provider) recordHistory(plan *UpdatePlan, ok bool) {
+	if p.history == nil {
+		return
+	}
+
+	outcome := types.HistoryOutcomeSucceeded
+	if !ok {
+		outcome = types.HistoryOutcomeFailed
+	}
+
+	trigger := plan.Trigger
+	if trigger == "" {
+		trigger = types.TriggerTypeDefault.String()
+	}
+
+	for _, change := range plan.Changes {
+		_, err := p.history.CreateHistoryEntry(&types.HistoryEntry{
+			Provider:   p.GetName(),
+			Identifier: plan.Resource.Identifier,
+			Container:  change.Container,
+			Image:      change.Image,
+			From:       change.CurrentVersion,
+			To:         change.NewVersion,
+			Digest:     plan.Digest,
+			Trigger:    trigger,
+			Approvers:  plan.Approvers,
+			Outcome:    outcome,
+		})
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"identifier": plan.Resource.Identifier,
+				"container":  change.Container,
+			}).Error("provider.kubernetes: failed to record version history")
+		}
+	}
+}
+
+// heldBack - version was rolled back by the latest change of a container the
+// plan updates, updates of other containers and images don't release the hold
+func (p *Provider) heldBack(plan *UpdatePlan) bool {
+	if p.history == nil {
+		return false
+	}
+	entries, err := p.history.ListHistory(&types.GetHistoryQuery{
+		Identifier: plan.Resource.Identifier,
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": plan.Resource.Identifier,
+		}).Error("provider.kubernetes: failed to get version history")
+		return false
+	}
+	for _, change := range plan.Changes {
+		latest := latestChange(entries, change)
+		if latest != nil && latest.Holds(change.NewVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// latestChange - latest applied change of the container image, failed updates
+// didn't change the container so they are skipped
+func latestChange(entries []*types.HistoryEntry, change types.ContainerChange) *types.HistoryEntry {
+	repository := imageRepository(change.Image)
+	for _, entry := range entries {
+		if entry.Outcome != types.HistoryOutcomeSucceeded || entry.Container != change.Container {
+			continue
+		}
+		if imageRepository(entry.Image) == repository {
+			return entry
+		}
+	}
+	return nil
+}
+
+func imageRepository(name string) string {
+	ref, err := image.Parse(name)
+	if err != nil {
+		return name
+	}
+	return ref.Repository()
+}
+
+// Rollback - reverts containers that run the image to the requested version,
+// rollback skips approvals and gates but runs update hooks. Rollback counts
+// against rollout limits, rollback that doesn't fit is queued (req.Queued).
+func (p *Provider) Rollback(req *types.RollbackRequest) error {
+	var resource *k8s.GenericResource
+	for _, ir := range p.cache.Indexed() {
+		if ir.Resource.Identifier == req.Identifier {
+			resource = ir.Resource.DeepCopy()
+			break
+		}
+	}
+	if resource == nil {
+		return fmt.Errorf("resource %s not found", req.Identifier)
+	}
+
+	plan, err := rollbackPlan(resource, req)
+	if err != nil {
+		return err
+	}
+
+	switch p.preUpdateHook(nil, plan) {
+	case hookPending:
+		return fmt.Errorf("pre-update hook of %s is still running, retry the rollback once it completes", req.Identifier)
+	case hookFailed:
+		return fmt.Errorf("failed to roll back %s to %s: pre-update hook failed", req.Identifier, req.Version)
+	}
+
+	started, ok := p.applyLimited(plan)
+	if !started {
+		req.Queued = true
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("failed to roll back %s to %s", req.Identifier, req.Version)
+	}
+	return nil
+}
+
+func rollbackPlan(resource *k8s.GenericResource, req *types.RollbackRequest) (*UpdatePlan, error) {
+	target, err := image.Parse(req.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &UpdatePlan{
+		Resource:   resource,
+		NewVersion: req.Version,
+		Trigger:    types.TriggerTypeRollback.String(),
+		Digest:     req.Digest,
+	}
+	if req.User != "" {
+		plan.Approvers = types.Users{req.User}
+	}
+
+	revert := func(c *types.ContainerChange, current string) string {
+		ref, err := image.Parse(current)
+		if err != nil || ref.Repository() != target.Repository() || ref.Tag() == req.Version {
+			return ""
+		}
+		newImage := fmt.Sprintf("%s:%s", ref.Repository(), req.Version)
+		if ref.Registry() == image.DefaultRegistryHostname {
+			newImage = fmt.Sprintf("%s:%s", ref.ShortName(), req.Version)
+		}
+		c.Image = newImage
+		c.PreviousImage = current
+		c.CurrentVersion = ref.Tag()
+		c.NewVersion = req.Version
+		plan.CurrentVersion = ref.Tag()
+		return newImage
+	}
+
+	for idx, c := range resource.InitContainers() {
+		change := types.ContainerChange{Container: c.Name, Init: true}
+		if newImage := revert(&change, c.Image); newImage != "" {
+			resource.UpdateInitContainer(idx, newImage)
+			plan.Changes = append(plan.Changes, change)
+		}
+	}
+	for idx, c := range resource.Containers() {
+		change := types.ContainerChange{Container: c.Name}
+		if newImage := revert(&change, c.Image); newImage != "" {
+			resource.UpdateContainer(idx, newImage)
+			plan.Changes = append(plan.Changes, change)
+		}
+	}
+
+	if len(plan.Changes) == 0 {
+		return nil, fmt.Errorf("no containers of %s run %s at a version other than %s", req.Identifier, target.Repository(), req.Version)
+	}
+	setUpdateTime(resource)
+
+	return plan, nil
+}
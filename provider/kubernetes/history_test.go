@@ -0,0 +1,191 @@
+package kubernetes
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+type fakeHistoryStore struct {
+	mu      sync.Mutex
+	entries []*types.HistoryEntry
+}
+
+func (s *fakeHistoryStore) CreateHistoryEntry(entry *types.HistoryEntry) (*types.HistoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return entry, nil
+}
+
+func (s *fakeHistoryStore) ListHistory(q *types.GetHistoryQuery) ([]*types.HistoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []*types.HistoryEntry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if s.entries[i].Identifier != q.Identifier {
+			continue
+		}
+		entries = append(entries, s.entries[i])
+		if q.Limit > 0 && len(entries) == q.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+func TestHistoryRecorded(t *testing.T) {
+	fi := &fakeImplementer{failUpdate: "deployment/shop/b"}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		limitedDeployment("shop", "a", nil, true),
+		limitedDeployment("shop", "b", nil, true),
+	)
+	defer teardown()
+	history := &fakeHistoryStore{}
+	provider.SetHistoryStore(history)
+
+	err := provider.Submit(types.Event{
+		Repository: types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.2"},
+	})
+	if err == nil {
+		t.Fatalf("expected failed update to be reported")
+	}
+
+	outcomes := map[string]types.HistoryOutcome{}
+	for _, entry := range history.entries {
+		if entry.From != "1.1.1" || entry.To != "1.1.2" || entry.Image != "gcr.io/v2-namespace/hello-world:1.1.2" || entry.Provider != ProviderName {
+			t.Errorf("unexpected history entry: %+v", entry)
+		}
+		outcomes[entry.Identifier] = entry.Outcome
+	}
+	if outcomes["deployment/shop/a"] != types.HistoryOutcomeSucceeded {
+		t.Errorf("expected successful update to be recorded, got: %s", outcomes["deployment/shop/a"])
+	}
+	if outcomes["deployment/shop/b"] != types.HistoryOutcomeFailed {
+		t.Errorf("expected failed update to be recorded, got: %s", outcomes["deployment/shop/b"])
+	}
+}
+
+func TestRollbackHoldsNewerVersion(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		limitedDeployment("shop", "a", nil, true),
+	)
+	defer teardown()
+	history := &fakeHistoryStore{}
+	provider.SetHistoryStore(history)
+
+	submitVersion(t, provider, "1.1.2")
+	if len(fi.updates) != 1 {
+		t.Fatalf("expected update, got: %d", len(fi.updates))
+	}
+	provider.cache.(*k8s.GenericResourceCache).Add(fi.updated)
+
+	err := provider.Rollback(&types.RollbackRequest{
+		Identifier: "deployment/shop/a",
+		Image:      "gcr.io/v2-namespace/hello-world",
+		Version:    "1.1.1",
+		User:       "admin",
+	})
+	if err != nil {
+		t.Fatalf("failed to roll back: %s", err)
+	}
+	if len(fi.updates) != 2 {
+		t.Fatalf("expected rollback update, got: %d", len(fi.updates))
+	}
+	if image := fi.updates[1].Containers()[0].Image; image != "gcr.io/v2-namespace/hello-world:1.1.1" {
+		t.Errorf("unexpected image after rollback: %s", image)
+	}
+	latest := history.entries[len(history.entries)-1]
+	if latest.Trigger != types.TriggerTypeRollback.String() || latest.From != "1.1.2" || latest.To != "1.1.1" || len(latest.Approvers) != 1 {
+		t.Errorf("unexpected rollback history entry: %+v", latest)
+	}
+	provider.cache.(*k8s.GenericResourceCache).Add(fi.updated)
+
+	submitVersion(t, provider, "1.1.2")
+	if len(fi.updates) != 2 {
+		t.Fatalf("rolled back version shouldn't be re-applied")
+	}
+
+	submitVersion(t, provider, "1.1.3")
+	if len(fi.updates) != 3 {
+		t.Errorf("newer version should be applied after rollback, got: %d", len(fi.updates))
+	}
+}
+
+func TestRollbackHoldIsPerContainer(t *testing.T) {
+	fi := &fakeImplementer{}
+	dep := limitedDeployment("shop", "a", nil, true)
+	dep.Spec.Template.Spec.Containers = append(dep.Spec.Template.Spec.Containers, v1.Container{Name: "proxy", Image: "gcr.io/v2-namespace/envoy:1.0.0"})
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, dep)
+	defer teardown()
+	history := &fakeHistoryStore{}
+	provider.SetHistoryStore(history)
+	cache := provider.cache.(*k8s.GenericResourceCache)
+
+	submitVersion(t, provider, "1.1.2")
+	cache.Add(fi.updated)
+	err := provider.Rollback(&types.RollbackRequest{
+		Identifier: "deployment/shop/a",
+		Image:      "gcr.io/v2-namespace/hello-world",
+		Version:    "1.1.1",
+	})
+	if err != nil {
+		t.Fatalf("failed to roll back: %s", err)
+	}
+	cache.Add(fi.updated)
+
+	// update of another image doesn't release the hold
+	err = provider.Submit(types.Event{
+		Repository: types.Repository{Name: "gcr.io/v2-namespace/envoy", Tag: "1.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("failed to submit event: %s", err)
+	}
+	if len(fi.updates) != 3 {
+		t.Fatalf("expected proxy to be updated, got: %d updates", len(fi.updates))
+	}
+	cache.Add(fi.updated)
+
+	submitVersion(t, provider, "1.1.2")
+	if len(fi.updates) != 3 {
+		t.Errorf("rolled back version shouldn't be re-applied after an update of another container")
+	}
+}
+
+func TestRollbackQueuedByLimits(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		limitedDeployment("shop", "a", map[string]string{types.QuillaUpdatesPerHourAnnotation: "1"}, true),
+	)
+	defer teardown()
+	provider.SetHistoryStore(&fakeHistoryStore{})
+
+	submitVersion(t, provider, "1.1.2")
+	provider.cache.(*k8s.GenericResourceCache).Add(fi.updated)
+	// newer version waits for the budget
+	submitVersion(t, provider, "1.1.3")
+
+	req := &types.RollbackRequest{
+		Identifier: "deployment/shop/a",
+		Image:      "gcr.io/v2-namespace/hello-world",
+		Version:    "1.1.1",
+	}
+	err := provider.Rollback(req)
+	if err != nil {
+		t.Fatalf("failed to roll back: %s", err)
+	}
+	if !req.Queued || len(fi.updates) != 1 {
+		t.Fatalf("expected rollback to be queued, got %d updates", len(fi.updates))
+	}
+
+	queue := provider.UpdateQueue()
+	if queue.Depth != 1 || queue.Updates[0].NewVersion != "1.1.1" {
+		t.Errorf("expected rollback to replace queued update, got: %+v", queue.Updates)
+	}
+}
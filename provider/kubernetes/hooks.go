@@ -0,0 +1,556 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quilla-hq/quilla/internal/gate"
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
+	"github.com/quilla-hq/quilla/util/image"
+
+	batch_v1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultHookTimeout - how long hook jobs and rollouts are waited for
+const DefaultHookTimeout = 10 * time.Minute
+
+// defaultHookTemplateKey - config map key that holds job template
+const defaultHookTemplateKey = "job.yaml"
+
+// hookStage - pre or post update
+type hookStage string
+
+const (
+	hookStagePreUpdate  hookStage = "pre-update"
+	hookStagePostUpdate hookStage = "post-update"
+)
+
+// hookResult - outcome of an update hook check
+type hookResult int
+
+const (
+	hookPending hookResult = iota
+	hookPassed
+	hookFailed
+)
+
+// updateHook - job template reference, ie: configmap:migrations/job.yaml or job:migrate
+type updateHook struct {
+	stage   hookStage
+	source  string // configmap or job
+	name    string
+	key     string
+	timeout time.Duration
+}
+
+func (h *updateHook) String() string {
+	if h.source == "configmap" {
+		return fmt.Sprintf("%s hook (configmap %s/%s)", h.stage, h.name, h.key)
+	}
+	return fmt.Sprintf("%s hook (job %s)", h.stage, h.name)
+}
+
+// getUpdateHook - parses hook annotation, returns nil when hook isn't configured
+func getUpdateHook(stage hookStage, annotations map[string]string) (*updateHook, error) {
+	key := types.QuillaPreUpdateHookAnnotation
+	if stage == hookStagePostUpdate {
+		key = types.QuillaPostUpdateHookAnnotation
+	}
+
+	value := strings.TrimSpace(annotations[key])
+	if value == "" {
+		return nil, nil
+	}
+
+	hook := &updateHook{
+		stage:   stage,
+		timeout: DefaultHookTimeout,
+	}
+
+	if timeout, ok := annotations[types.QuillaHookTimeoutAnnotation]; ok {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			log.WithFields(log.Fields{
+				"error":   err,
+				"timeout": timeout,
+			}).Warn("provider.kubernetes: invalid hook timeout, using default")
+		} else {
+			hook.timeout = d
+		}
+	}
+
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid %s hook: %s", stage, value)
+	}
+
+	switch parts[0] {
+	case "configmap":
+		hook.source = parts[0]
+		hook.name = parts[1]
+		hook.key = defaultHookTemplateKey
+		if idx := strings.Index(parts[1], "/"); idx > 0 {
+			hook.name = parts[1][:idx]
+			hook.key = parts[1][idx+1:]
+		}
+	case "job":
+		hook.source = parts[0]
+		hook.name = parts[1]
+	default:
+		return nil, fmt.Errorf("invalid %s hook source: %s", stage, parts[0])
+	}
+
+	return hook, nil
+}
+
+// hookTemplate - loads job template from a config map or a suspended job
+// in the resource namespace
+func (p *Provider) hookTemplate(namespace string, hook *updateHook) (*batch_v1.Job, error) {
+	if hook.source == "job" {
+		job, err := p.implementer.Job(namespace, hook.name)
+		if err != nil {
+			return nil, err
+		}
+		if job.Spec.Suspend == nil || !*job.Spec.Suspend {
+			return nil, fmt.Errorf("job %s/%s is not suspended", namespace, hook.name)
+		}
+		return job, nil
+	}
+
+	cm, err := p.implementer.ConfigMaps(namespace).Get(context.TODO(), hook.name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[hook.key]
+	if !ok {
+		return nil, fmt.Errorf("config map %s/%s has no key %s", namespace, hook.name, hook.key)
+	}
+
+	job := &batch_v1.Job{}
+	err = yaml.NewYAMLOrJSONDecoder(bytes.NewBufferString(data), 4096).Decode(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode job template %s/%s: %s", hook.name, hook.key, err)
+	}
+	return job, nil
+}
+
+// newHookJob - creates job from the template, images of updated repositories are
+// replaced with the new ones
+func newHookJob(template *batch_v1.Job, hook *updateHook, plan *UpdatePlan) *batch_v1.Job {
+	resource := plan.Resource
+
+	spec := template.Spec.DeepCopy()
+	// selector and its labels are generated by the job controller
+	spec.Selector = nil
+	spec.ManualSelector = nil
+	spec.Suspend = nil
+	for _, label := range []string{"controller-uid", "job-name", "batch.kubernetes.io/controller-uid", "batch.kubernetes.io/job-name"} {
+		delete(spec.Template.Labels, label)
+	}
+	if spec.ActiveDeadlineSeconds == nil {
+		deadline := int64(hook.timeout.Seconds())
+		spec.ActiveDeadlineSeconds = &deadline
+	}
+
+	var candidate string
+	if len(plan.Changes) > 0 {
+		candidate = plan.Changes[len(plan.Changes)-1].Image
+	}
+
+	env := []v1.EnvVar{
+		{Name: gate.EnvIdentifier, Value: resource.Identifier},
+		{Name: gate.EnvImage, Value: candidate},
+		{Name: gate.EnvVersion, Value: plan.NewVersion},
+		{Name: gate.EnvCurrentVersion, Value: plan.CurrentVersion},
+	}
+
+	update := func(containers []v1.Container) {
+		for idx := range containers {
+			containers[idx].Image = hookImage(containers[idx].Image, plan.Changes)
+			containers[idx].Env = append(containers[idx].Env, env...)
+		}
+	}
+	update(spec.Template.Spec.InitContainers)
+	update(spec.Template.Spec.Containers)
+
+	labels := map[string]string{}
+	for k, v := range template.Labels {
+		labels[k] = v
+	}
+	labels["quilla.sh/hook"] = string(hook.stage)
+
+	return &batch_v1.Job{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      jobName("hook-"+string(hook.stage)+"-", resource.Identifier, resource.Name, plan.NewVersion),
+			Namespace: resource.Namespace,
+			Labels:    labels,
+		},
+		Spec: *spec,
+	}
+}
+
+// hookImage - returns updated image if template image uses one of the
+// updated repositories
+func hookImage(current string, changes types.ContainerChanges) string {
+	ref, err := image.Parse(current)
+	if err != nil {
+		return current
+	}
+	for _, change := range changes {
+		changed, err := image.Parse(change.Image)
+		if err != nil {
+			continue
+		}
+		if changed.Repository() == ref.Repository() {
+			return change.Image
+		}
+	}
+	return current
+}
+
+// checkHookJob - creates hook job unless it exists and checks whether it finished.
+// Jobs are named after the target version so a hook that is still running is picked
+// up by the next check, failed jobs are kept for inspection and have to be deleted
+// to retry the hook.
+func (p *Provider) checkHookJob(hook *updateHook, plan *UpdatePlan) (hookResult, error) {
+	resource := plan.Resource
+	name := jobName("hook-"+string(hook.stage)+"-", resource.Identifier, resource.Name, plan.NewVersion)
+
+	current, err := p.implementer.Job(resource.Namespace, name)
+	if errors.IsNotFound(err) {
+		var template *batch_v1.Job
+		template, err = p.hookTemplate(resource.Namespace, hook)
+		if err != nil {
+			return hookFailed, fmt.Errorf("failed to get job template: %s", err)
+		}
+
+		err = p.implementer.CreateJob(newHookJob(template, hook, plan))
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return hookFailed, fmt.Errorf("failed to create job: %s", err)
+		}
+
+		log.WithFields(log.Fields{
+			"identifier": resource.Identifier,
+			"version":    plan.NewVersion,
+			"job":        name,
+			"hook":       hook.String(),
+		}).Info("provider.kubernetes: update hook started")
+
+		current, err = p.implementer.Job(resource.Namespace, name)
+	}
+	if err != nil {
+		return hookFailed, fmt.Errorf("failed to get job %s: %s", name, err)
+	}
+
+	finished, succeeded := jobFinished(current)
+	switch {
+	case finished && !succeeded:
+		return hookFailed, fmt.Errorf("job %s/%s failed", resource.Namespace, name)
+	case finished:
+		err = p.implementer.DeleteJob(resource.Namespace, name)
+		if err != nil && !errors.IsNotFound(err) {
+			log.WithFields(log.Fields{
+				"error": err,
+				"job":   name,
+			}).Warn("provider.kubernetes: failed to delete finished hook job")
+		}
+		return hookPassed, nil
+	case !current.CreationTimestamp.IsZero() && time.Since(current.CreationTimestamp.Time) > hook.timeout:
+		return hookFailed, fmt.Errorf("job %s/%s timed out", resource.Namespace, name)
+	}
+	return hookPending, nil
+}
+
+func jobFinished(job *batch_v1.Job) (finished, succeeded bool) {
+	for _, c := range job.Status.Conditions {
+		if c.Status != v1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batch_v1.JobComplete:
+			return true, true
+		case batch_v1.JobFailed:
+			return true, false
+		}
+	}
+	if job.Status.Succeeded > 0 {
+		return true, true
+	}
+	return false, false
+}
+
+// preUpdateHook - checks pre update hook, update is applied once it passes and
+// aborted when it fails. Failure is final for the event, it's already recorded and
+// notified so callers don't report it as an error to be retried. Hook that is still
+// running is checked again by the next event or by the waiting updates watcher.
+func (p *Provider) preUpdateHook(event *types.Event, plan *UpdatePlan) hookResult {
+	hook, err := getUpdateHook(hookStagePreUpdate, plan.Resource.GetAnnotations())
+	if err == nil && hook == nil {
+		return hookPassed
+	}
+
+	result := hookFailed
+	if err == nil {
+		result, err = p.checkHookJob(hook, plan)
+	}
+
+	switch result {
+	case hookPending:
+		p.waitForHook(event, plan, hook)
+	case hookFailed:
+		p.doneWaiting(plan.Resource.Identifier)
+		p.recordHistory(plan, false)
+		p.updateStatus(plan, false, "pre-update hook failed")
+		p.notifyHook(plan, types.LevelError, fmt.Sprintf("Pre update hook for %s %s/%s %s failed, update aborted: %s",
+			plan.Resource.Kind(), plan.Resource.Namespace, plan.Resource.Name, plan.delta(), err))
+	case hookPassed:
+		p.doneWaiting(plan.Resource.Identifier)
+		p.notifyHook(plan, types.LevelInfo, fmt.Sprintf("Pre update hook for %s %s/%s %s succeeded",
+			plan.Resource.Kind(), plan.Resource.Namespace, plan.Resource.Name, plan.delta()))
+	}
+	return result
+}
+
+// postUpdateHook - checks rollout and post update hook of the applied update, resource
+// is rolled back when either of them fails. Hook that doesn't finish right away is
+// checked by the waiting updates watcher.
+func (p *Provider) postUpdateHook(plan *UpdatePlan) bool {
+	hook, err := getUpdateHook(hookStagePostUpdate, plan.Resource.GetAnnotations())
+	if err == nil && hook == nil {
+		return true
+	}
+
+	result := hookFailed
+	deadline := time.Now().Add(DefaultHookTimeout)
+	if err == nil {
+		deadline = time.Now().Add(hook.timeout)
+		result, err = p.checkPostUpdateHook(hook, plan, deadline)
+	}
+	if result == hookPending {
+		p.waitForPostUpdateHook(plan, hook, deadline)
+		return true
+	}
+	return p.postUpdateHookDone(plan, result, err)
+}
+
+// checkPostUpdateHook - post update hook job is started once the resource rolls out
+func (p *Provider) checkPostUpdateHook(hook *updateHook, plan *UpdatePlan, deadline time.Time) (hookResult, error) {
+	current, err := p.implementer.Get(plan.Resource)
+	if err != nil {
+		return hookFailed, fmt.Errorf("failed to get rollout status: %s", err)
+	}
+	if !current.RolloutComplete() {
+		if time.Now().After(deadline) {
+			return hookFailed, fmt.Errorf("rollout timed out")
+		}
+		return hookPending, nil
+	}
+	return p.checkHookJob(hook, plan)
+}
+
+// postUpdateHookDone - notifies about the hook outcome, resource is rolled back
+// when the hook failed
+func (p *Provider) postUpdateHookDone(plan *UpdatePlan, result hookResult, err error) bool {
+	if result == hookPassed {
+		p.notifyHook(plan, types.LevelInfo, fmt.Sprintf("Post update hook for %s %s/%s %s succeeded",
+			plan.Resource.Kind(), plan.Resource.Namespace, plan.Resource.Name, plan.delta()))
+		return true
+	}
+
+	msg := fmt.Sprintf("Post update hook for %s %s/%s %s failed: %s", plan.Resource.Kind(), plan.Resource.Namespace, plan.Resource.Name, plan.delta(), err)
+	rollbackErr := p.rollback(plan)
+	if rollbackErr != nil {
+		msg = fmt.Sprintf("%s, rollback failed: %s", msg, rollbackErr)
+	} else {
+		msg = fmt.Sprintf("%s, rolled back to %s", msg, plan.CurrentVersion)
+	}
+	p.notifyHook(plan, types.LevelError, msg)
+	return false
+}
+
+// waitForHook - stores update so it's retried until its pre update hook finishes
+func (p *Provider) waitForHook(event *types.Event, plan *UpdatePlan, hook *updateHook) {
+	log.WithFields(log.Fields{
+		"identifier": plan.Resource.Identifier,
+		"version":    plan.NewVersion,
+		"hook":       hook.String(),
+	}).Info("provider.kubernetes: update is waiting for update hook")
+
+	if p.waiting == nil || event == nil {
+		return
+	}
+
+	err := p.waiting.SaveWaitingUpdate(&types.WaitingUpdate{
+		Identifier: plan.Resource.Identifier,
+		Version:    plan.NewVersion,
+		Hook:       string(hook.stage),
+		Event:      event,
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": plan.Resource.Identifier,
+		}).Error("provider.kubernetes: failed to save waiting update")
+	}
+}
+
+// waitForPostUpdateHook - stores applied update so its post update hook is
+// checked until it finishes
+func (p *Provider) waitForPostUpdateHook(plan *UpdatePlan, hook *updateHook, deadline time.Time) {
+	log.WithFields(log.Fields{
+		"identifier": plan.Resource.Identifier,
+		"version":    plan.NewVersion,
+		"hook":       hook.String(),
+	}).Info("provider.kubernetes: waiting for rollout and post update hook")
+
+	if p.waiting == nil {
+		log.WithFields(log.Fields{
+			"identifier": plan.Resource.Identifier,
+		}).Warn("provider.kubernetes: waiting store is not configured, post update hook won't be checked")
+		return
+	}
+
+	err := p.waiting.SaveWaitingUpdate(&types.WaitingUpdate{
+		Identifier:     plan.Resource.Identifier,
+		Version:        plan.NewVersion,
+		Hook:           string(hook.stage),
+		CurrentVersion: plan.CurrentVersion,
+		Changes:        plan.Changes,
+		Deadline:       deadline,
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": plan.Resource.Identifier,
+		}).Error("provider.kubernetes: failed to save waiting update")
+	}
+}
+
+// resumePostUpdateHook - checks post update hook of an update applied earlier
+func (p *Provider) resumePostUpdateHook(update *types.WaitingUpdate) {
+	var resource *k8s.GenericResource
+	for _, ir := range p.cache.Indexed() {
+		if ir.Resource.Identifier == update.Identifier {
+			resource = ir.Resource.DeepCopy()
+			break
+		}
+	}
+	// resource was removed or updated again meanwhile
+	if resource == nil || !runsTag(resource, update.Version) {
+		p.doneWaiting(update.Identifier)
+		return
+	}
+
+	plan := &UpdatePlan{
+		Resource:       resource,
+		CurrentVersion: update.CurrentVersion,
+		NewVersion:     update.Version,
+		Changes:        update.Changes,
+	}
+
+	hook, err := getUpdateHook(hookStagePostUpdate, resource.GetAnnotations())
+	if err == nil && hook == nil {
+		p.doneWaiting(update.Identifier)
+		return
+	}
+
+	p.locks.Lock(resource.Identifier)
+	defer p.locks.Unlock(resource.Identifier)
+
+	result := hookFailed
+	if err == nil {
+		result, err = p.checkPostUpdateHook(hook, plan, update.Deadline)
+	}
+	if result == hookPending {
+		return
+	}
+
+	p.doneWaiting(update.Identifier)
+	if !p.postUpdateHookDone(plan, result, err) {
+		p.recordHistory(plan, false)
+		p.updateStatus(plan, false, "post-update hook failed")
+	}
+}
+
+// runsTag - checks whether any of the resource containers runs the version
+func runsTag(resource *k8s.GenericResource, version string) bool {
+	for _, current := range append(resource.GetImages(), resource.GetInitImages()...) {
+		ref, err := image.Parse(current)
+		if err == nil && ref.Tag() == version {
+			return true
+		}
+	}
+	return false
+}
+
+// rollback - restores previous images, rolled back version is recorded so
+// it's not applied again
+func (p *Provider) rollback(plan *UpdatePlan) error {
+	return p.revert(plan, map[string]string{
+		types.QuillaHookFailedAnnotation: plan.NewVersion,
+	})
+}
+
+// revert - restores images that resource ran before the update
+func (p *Provider) revert(plan *UpdatePlan, extraAnnotations map[string]string) error {
+	resource := plan.Resource.DeepCopy()
+
+	for _, change := range plan.Changes {
+		previous := change.PreviousImage
+		if previous == "" {
+			return fmt.Errorf("previous image of container %s is unknown", change.Container)
+		}
+		if change.Init {
+			for idx, c := range resource.InitContainers() {
+				if c.Name == change.Container {
+					resource.UpdateInitContainer(idx, previous)
+				}
+			}
+			continue
+		}
+		for idx, c := range resource.Containers() {
+			if c.Name == change.Container {
+				resource.UpdateContainer(idx, previous)
+			}
+		}
+	}
+
+	annotations := resource.GetAnnotations()
+	for k, v := range extraAnnotations {
+		annotations[k] = v
+	}
+	annotations["kubernetes.io/change-cause"] = fmt.Sprintf("quilla rollback, version %s->%s [%s]", plan.NewVersion, plan.CurrentVersion, time.Now().Format(time.RFC3339))
+	resource.SetAnnotations(annotations)
+
+	return p.implementer.Update(resource, plan.Changes)
+}
+
+func (p *Provider) notifyHook(plan *UpdatePlan, level types.Level, message string) {
+	resource := plan.Resource
+	p.sender.Send(types.EventNotification{
+		ResourceKind: resource.Kind(),
+		Identifier:   resource.Identifier,
+		Name:         "update hook",
+		Message:      message,
+		CreatedAt:    time.Now(),
+		Type:         types.NotificationUpdateHook,
+		Level:        level,
+		Channels:     types.ParseEventNotificationChannels(resource.GetAnnotations()),
+		Metadata: map[string]string{
+			"provider":  p.GetName(),
+			"namespace": resource.GetNamespace(),
+			"name":      resource.GetName(),
+			"version":   plan.NewVersion,
+		},
+	})
+}
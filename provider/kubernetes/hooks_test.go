@@ -0,0 +1,440 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/internal/gate"
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	batch_v1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const migrationTemplate = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  backoffLimit: 0
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: migrate
+        image: gcr.io/v2-namespace/hello-world:1.1.1
+        command: ["/app", "migrate"]
+`
+
+func hookedDeployment(annotations map[string]string) *apps_v1.Deployment {
+	replicas := int32(1)
+	ann := map[string]string{
+		types.QuillaPolicyLabel: "all",
+	}
+	for k, v := range annotations {
+		ann[k] = v
+	}
+	return &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        "dep-1",
+			Namespace:   "xxxx",
+			Annotations: ann,
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+					},
+				},
+			},
+		},
+		Status: apps_v1.DeploymentStatus{
+			Replicas:          1,
+			UpdatedReplicas:   1,
+			AvailableReplicas: 1,
+		},
+	}
+}
+
+func completeJob(succeeded bool) func(job *batch_v1.Job) {
+	return func(job *batch_v1.Job) {
+		condition := batch_v1.JobComplete
+		if !succeeded {
+			condition = batch_v1.JobFailed
+		}
+		job.Status.Conditions = []batch_v1.JobCondition{
+			{Type: condition, Status: v1.ConditionTrue},
+		}
+	}
+}
+
+func TestGetUpdateHook(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantSource  string
+		wantName    string
+		wantKey     string
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{
+			name:        "not configured",
+			annotations: map[string]string{},
+		},
+		{
+			name:        "config map",
+			annotations: map[string]string{types.QuillaPreUpdateHookAnnotation: "configmap:migrations"},
+			wantSource:  "configmap",
+			wantName:    "migrations",
+			wantKey:     defaultHookTemplateKey,
+			wantTimeout: DefaultHookTimeout,
+		},
+		{
+			name: "config map key and timeout",
+			annotations: map[string]string{
+				types.QuillaPreUpdateHookAnnotation: "configmap:migrations/up.yaml",
+				types.QuillaHookTimeoutAnnotation:   "2m",
+			},
+			wantSource:  "configmap",
+			wantName:    "migrations",
+			wantKey:     "up.yaml",
+			wantTimeout: 2 * time.Minute,
+		},
+		{
+			name:        "suspended job",
+			annotations: map[string]string{types.QuillaPreUpdateHookAnnotation: "job:migrate"},
+			wantSource:  "job",
+			wantName:    "migrate",
+			wantTimeout: DefaultHookTimeout,
+		},
+		{
+			name:        "invalid source",
+			annotations: map[string]string{types.QuillaPreUpdateHookAnnotation: "secret:migrate"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, err := getUpdateHook(hookStagePreUpdate, tt.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getUpdateHook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantSource == "" {
+				if hook != nil {
+					t.Errorf("expected no hook, got: %s", hook)
+				}
+				return
+			}
+			if hook.source != tt.wantSource || hook.name != tt.wantName || hook.key != tt.wantKey || hook.timeout != tt.wantTimeout {
+				t.Errorf("unexpected hook: %+v", hook)
+			}
+		})
+	}
+}
+
+func TestPreUpdateHookFromConfigMap(t *testing.T) {
+	var created *batch_v1.Job
+	fi := &fakeImplementer{
+		client: fake.NewSimpleClientset(&v1.ConfigMap{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "migrations", Namespace: "xxxx"},
+			Data:       map[string]string{"job.yaml": migrationTemplate},
+		}),
+		onCreateJob: func(job *batch_v1.Job) {
+			created = job
+			completeJob(true)(job)
+		},
+	}
+	sender := &fakeSender{}
+	dep := hookedDeployment(map[string]string{types.QuillaPreUpdateHookAnnotation: "configmap:migrations"})
+	provider, teardown := newTestingProvider(t, fi, sender, dep)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+
+	if created == nil {
+		t.Fatalf("expected hook job to be created")
+	}
+	if created.Namespace != "xxxx" {
+		t.Errorf("hook job should run in resource namespace, got: %s", created.Namespace)
+	}
+	container := created.Spec.Template.Spec.Containers[0]
+	if container.Image != "gcr.io/v2-namespace/hello-world:1.1.2" {
+		t.Errorf("expected new image in hook job, got: %s", container.Image)
+	}
+	if envValue(container.Env, gate.EnvVersion) != "1.1.2" {
+		t.Errorf("unexpected version env: %s", envValue(container.Env, gate.EnvVersion))
+	}
+
+	if fi.updated == nil {
+		t.Fatalf("expected resource to be updated after pre update hook succeeded")
+	}
+	if fi.updated.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.2" {
+		t.Errorf("unexpected image: %s", fi.updated.Containers()[0].Image)
+	}
+	if len(fi.jobs) != 0 {
+		t.Errorf("expected succeeded hook job to be deleted")
+	}
+}
+
+func TestPreUpdateHookFailureAbortsUpdate(t *testing.T) {
+	suspend := true
+	fi := &fakeImplementer{
+		jobs: map[string]*batch_v1.Job{
+			"migrate": {
+				ObjectMeta: meta_v1.ObjectMeta{Name: "migrate", Namespace: "xxxx"},
+				Spec: batch_v1.JobSpec{
+					Suspend:  &suspend,
+					Selector: &meta_v1.LabelSelector{MatchLabels: map[string]string{"controller-uid": "1234"}},
+					Template: v1.PodTemplateSpec{
+						ObjectMeta: meta_v1.ObjectMeta{Labels: map[string]string{"controller-uid": "1234"}},
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{{Name: "migrate", Image: "gcr.io/v2-namespace/hello-world:1.1.1"}},
+						},
+					},
+				},
+			},
+		},
+		onCreateJob: completeJob(false),
+	}
+	sender := &fakeSender{}
+	dep := hookedDeployment(map[string]string{types.QuillaPreUpdateHookAnnotation: "job:migrate"})
+	provider, teardown := newTestingProvider(t, fi, sender, dep)
+	defer teardown()
+
+	// failure is recorded and notified, event isn't retried by the queue
+	err := provider.Submit(types.Event{
+		Repository: types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.2"},
+	})
+	if err != nil {
+		t.Errorf("pre update hook failure shouldn't be retried, got: %s", err)
+	}
+
+	if fi.updated != nil {
+		t.Fatalf("resource shouldn't be updated after pre update hook failed")
+	}
+	if len(fi.jobs) != 2 {
+		t.Errorf("expected failed hook job to be kept, got %d jobs", len(fi.jobs))
+	}
+	for name, job := range fi.jobs {
+		if name == "migrate" {
+			continue
+		}
+		if job.Spec.Selector != nil || job.Spec.Suspend != nil {
+			t.Errorf("hook job shouldn't inherit selector or suspend from template")
+		}
+		if _, ok := job.Spec.Template.Labels["controller-uid"]; ok {
+			t.Errorf("hook job shouldn't inherit controller labels")
+		}
+	}
+	if sender.sentEvent.Type != types.NotificationUpdateHook || sender.sentEvent.Level != types.LevelError {
+		t.Errorf("expected hook failure notification, got: %s", sender.sentEvent.Message)
+	}
+}
+
+func TestPostUpdateHookFailureRollsBack(t *testing.T) {
+	fi := &fakeImplementer{
+		client: fake.NewSimpleClientset(&v1.ConfigMap{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "smoke", Namespace: "xxxx"},
+			Data:       map[string]string{"test.yaml": migrationTemplate},
+		}),
+		onCreateJob: completeJob(false),
+	}
+	sender := &fakeSender{}
+	dep := hookedDeployment(map[string]string{types.QuillaPostUpdateHookAnnotation: "configmap:smoke/test.yaml"})
+	provider, teardown := newTestingProvider(t, fi, sender, dep)
+	defer teardown()
+
+	err := provider.Submit(types.Event{
+		Repository: types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.2"},
+	})
+	if err == nil {
+		t.Errorf("expected update to fail")
+	}
+
+	if fi.updated == nil {
+		t.Fatalf("expected resource to be rolled back")
+	}
+	if fi.updated.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.1" {
+		t.Errorf("expected previous image after rollback, got: %s", fi.updated.Containers()[0].Image)
+	}
+	if fi.updated.GetAnnotations()[types.QuillaHookFailedAnnotation] != "1.1.2" {
+		t.Errorf("expected rolled back version to be recorded")
+	}
+	if sender.sentEvent.Type != types.NotificationUpdateHook || sender.sentEvent.Level != types.LevelError {
+		t.Errorf("expected hook failure notification, got: %s", sender.sentEvent.Message)
+	}
+}
+
+func TestRevertRestoresPreviousImage(t *testing.T) {
+	fi := &fakeImplementer{}
+	dep := hookedDeployment(nil)
+	dep.Spec.Template.Spec.Containers[0].Image = "gcr.io/v2-namespace/hello-world:1.1.2"
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, dep)
+	defer teardown()
+
+	plan := &UpdatePlan{
+		Resource:       MustParseGR(dep),
+		CurrentVersion: "1.1.1",
+		NewVersion:     "1.1.2",
+		Changes: types.ContainerChanges{
+			{
+				Container:      "app",
+				Image:          "gcr.io/v2-namespace/hello-world:1.1.2",
+				PreviousImage:  "gcr.io/v2-namespace/hello-world:v1.1.1",
+				CurrentVersion: "1.1.1",
+				NewVersion:     "1.1.2",
+			},
+		},
+	}
+
+	err := provider.revert(plan, nil)
+	if err != nil {
+		t.Fatalf("failed to revert: %s", err)
+	}
+	if fi.updated.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:v1.1.1" {
+		t.Errorf("expected image the container ran before, got: %s", fi.updated.Containers()[0].Image)
+	}
+}
+
+func TestRolledBackVersionIsIgnored(t *testing.T) {
+	fi := &fakeImplementer{}
+	dep := hookedDeployment(map[string]string{types.QuillaHookFailedAnnotation: "1.1.2"})
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, dep)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+	if fi.updated != nil {
+		t.Fatalf("rolled back version shouldn't be applied again")
+	}
+
+	submitVersion(t, provider, "1.1.3")
+	if fi.updated == nil {
+		t.Fatalf("expected newer version to be applied")
+	}
+}
+
+func TestPreUpdateHookDoesNotBlock(t *testing.T) {
+	store, storeTeardown := NewTestingUtils()
+	defer storeTeardown()
+
+	fi := &fakeImplementer{
+		client: fake.NewSimpleClientset(&v1.ConfigMap{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "migrations", Namespace: "xxxx"},
+			Data:       map[string]string{"job.yaml": migrationTemplate},
+		}),
+	}
+	dep := hookedDeployment(map[string]string{types.QuillaPreUpdateHookAnnotation: "configmap:migrations"})
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, dep)
+	defer teardown()
+	provider.SetWaitingStore(store)
+
+	submitVersion(t, provider, "1.1.2")
+	if fi.updated != nil {
+		t.Fatalf("resource shouldn't be updated while pre update hook runs")
+	}
+	if len(fi.jobs) != 1 {
+		t.Fatalf("expected hook job to be started, got %d jobs", len(fi.jobs))
+	}
+
+	waiting, err := store.ListWaitingUpdates()
+	if err != nil {
+		t.Fatalf("failed to list waiting updates: %s", err)
+	}
+	if len(waiting) != 1 || waiting[0].Hook != "pre-update" || waiting[0].Event == nil {
+		t.Fatalf("expected update to wait for the hook, got: %+v", waiting)
+	}
+
+	// hook job finishes, the same job is picked up by the next check
+	for _, job := range fi.jobs {
+		completeJob(true)(job)
+	}
+	provider.checkWaitingUpdates()
+
+	if fi.updated == nil || fi.updated.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.2" {
+		t.Fatalf("expected resource to be updated once the hook passed")
+	}
+	if len(fi.jobs) != 0 {
+		t.Errorf("expected succeeded hook job to be deleted")
+	}
+	waiting, err = store.ListWaitingUpdates()
+	if err != nil {
+		t.Fatalf("failed to list waiting updates: %s", err)
+	}
+	if len(waiting) != 0 {
+		t.Errorf("expected waiting update to be removed, got: %d", len(waiting))
+	}
+}
+
+func TestPostUpdateHookResumedAfterRestart(t *testing.T) {
+	store, storeTeardown := NewTestingUtils()
+	defer storeTeardown()
+
+	annotations := map[string]string{types.QuillaPostUpdateHookAnnotation: "configmap:smoke/test.yaml"}
+	inProgress := hookedDeployment(annotations)
+	inProgress.Status = apps_v1.DeploymentStatus{Replicas: 1}
+	rollingOut := MustParseGR(inProgress)
+
+	fi := &fakeImplementer{
+		client: fake.NewSimpleClientset(&v1.ConfigMap{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "smoke", Namespace: "xxxx"},
+			Data:       map[string]string{"test.yaml": migrationTemplate},
+		}),
+		current:     map[string]*k8s.GenericResource{rollingOut.Identifier: rollingOut},
+		onCreateJob: completeJob(false),
+	}
+	sender := &fakeSender{}
+	provider, teardown := newTestingProvider(t, fi, sender, hookedDeployment(annotations))
+	provider.SetWaitingStore(store)
+
+	submitVersion(t, provider, "1.1.2")
+	teardown()
+
+	if fi.updated == nil || fi.updated.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.2" {
+		t.Fatalf("expected resource to be updated")
+	}
+	if len(fi.jobs) != 0 {
+		t.Fatalf("post update hook shouldn't start before the rollout completes")
+	}
+	waiting, err := store.ListWaitingUpdates()
+	if err != nil {
+		t.Fatalf("failed to list waiting updates: %s", err)
+	}
+	if len(waiting) != 1 || waiting[0].Hook != "post-update" || waiting[0].CurrentVersion != "1.1.1" {
+		t.Fatalf("expected update to wait for the hook, got: %+v", waiting)
+	}
+
+	// quilla restarts after the rollout completed
+	updated := hookedDeployment(annotations)
+	updated.Spec.Template.Spec.Containers[0].Image = "gcr.io/v2-namespace/hello-world:1.1.2"
+	delete(fi.current, rollingOut.Identifier)
+	provider, teardown = newTestingProvider(t, fi, sender, updated)
+	defer teardown()
+	provider.SetWaitingStore(store)
+
+	provider.checkWaitingUpdates()
+
+	if fi.updated.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.1" {
+		t.Errorf("expected previous image after failed hook, got: %s", fi.updated.Containers()[0].Image)
+	}
+	if fi.updated.GetAnnotations()[types.QuillaHookFailedAnnotation] != "1.1.2" {
+		t.Errorf("expected rolled back version to be recorded")
+	}
+	if sender.sentEvent.Type != types.NotificationUpdateHook || sender.sentEvent.Level != types.LevelError {
+		t.Errorf("expected hook failure notification, got: %s", sender.sentEvent.Message)
+	}
+	waiting, err = store.ListWaitingUpdates()
+	if err != nil {
+		t.Fatalf("failed to list waiting updates: %s", err)
+	}
+	if len(waiting) != 0 {
+		t.Errorf("expected waiting update to be removed, got: %d", len(waiting))
+	}
+}
@@ -2,20 +2,24 @@ package kubernetes
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
 
 	apps_v1 "k8s.io/api/apps/v1"
 	batch_v1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s_types "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	core_v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -24,14 +28,20 @@ import (
 type Implementer interface {
 	Namespaces() (*v1.NamespaceList, error)
 	Deployments(namespace string) (*apps_v1.DeploymentList, error)
-	Update(obj *k8s.GenericResource) error
+	Update(obj *k8s.GenericResource, changes types.ContainerChanges) error
+	Get(obj *k8s.GenericResource) (*k8s.GenericResource, error)
 	Secret(namespace, name string) (*v1.Secret, error)
 	Pods(namespace, labelSelector string) (*v1.PodList, error)
 	DeletePod(namespace, name string, opts *meta_v1.DeleteOptions) error
-	CreateJob(name string, image string, secret string) error
+	CreateJob(job *batch_v1.Job) error
 	Job(namespace, name string) (*batch_v1.Job, error)
+	DeleteJob(namespace, name string) error
 
 	ConfigMaps(namespace string) core_v1.ConfigMapInterface
+
+	// Annotate - sets annotations of the resource, empty values remove annotations
+	Annotate(obj *k8s.GenericResource, annotations map[string]string) error
+	Events(namespace string) core_v1.EventInterface
 }
 
 // KubernetesImplementer - default kubernetes client implementer, uses
@@ -115,100 +125,124 @@ func (i *KubernetesImplementer) Deployments(namespace string) (*apps_v1.Deployme
 	return l, err
 }
 
-// Update converts generic resource into specific kubernetes type and updates it
-func (i *KubernetesImplementer) Update(obj *k8s.GenericResource) error {
-	// retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-	// 	// Retrieve the latest version of Deployment before attempting update
-	// 	// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
-	// 	_, updateErr := i.client.Extensions().Deployments(deployment.Namespace).Update(deployment)
-	// 	return updateErr
-	// })
-	// return retryErr
+// FieldManager - field manager used when patching resources
+const FieldManager = "quilla"
 
-	switch resource := obj.GetResource().(type) {
-	case *apps_v1.Deployment:
-		_, err := i.client.AppsV1().Deployments(resource.Namespace).Update(context.TODO(), resource, meta_v1.UpdateOptions{})
-		if err != nil {
-			return err
-		}
-	case *apps_v1.StatefulSet:
-		_, err := i.client.AppsV1().StatefulSets(resource.Namespace).Update(context.TODO(), resource, meta_v1.UpdateOptions{})
-		if err != nil {
-			return err
-		}
-	case *apps_v1.DaemonSet:
-		_, err := i.client.AppsV1().DaemonSets(resource.Namespace).Update(context.TODO(), resource, meta_v1.UpdateOptions{})
-		if err != nil {
-			return err
-		}
-	case *batch_v1.CronJob:
-		_, err := i.client.BatchV1().CronJobs(resource.Namespace).Update(context.TODO(), resource, meta_v1.UpdateOptions{})
-		if err != nil {
-			return err
-		}
-	case *batch_v1.Job:
-		_, err := i.client.BatchV1().Jobs(resource.Namespace).Update(context.TODO(), resource, meta_v1.UpdateOptions{})
-		if err != nil {
-			return err
-		}
-	default:
-		return fmt.Errorf("unsupported object type")
-	}
-	return nil
+// Update patches images of the changed containers and quilla labels/annotations
+// of the generic resource, fields owned by other controllers are left untouched
+func (i *KubernetesImplementer) Update(obj *k8s.GenericResource, changes types.ContainerChanges) error {
+	return patchResource(i.client, obj, changes)
 }
 
-func (i *KubernetesImplementer) CreateJob(name string, image string, secret string) error {
-	ns := os.Getenv("NAMESPACE")
-	if ns == "" {
-		ns = "default"
+func patchResource(client kubernetes.Interface, obj *k8s.GenericResource, changes types.ContainerChanges) error {
+	data, err := obj.StrategicMergePatch(changes)
+	if err != nil {
+		return err
 	}
+	return patch(client, obj, k8s_types.StrategicMergePatchType, data)
+}
 
-	var backoff int32 = 1
+// Annotate - merges annotations into resource metadata, nothing else is patched
+// so the update doesn't start a rollout
+func (i *KubernetesImplementer) Annotate(obj *k8s.GenericResource, annotations map[string]string) error {
+	return annotateResource(i.client, obj, annotations)
+}
 
-	jobSpec := &batch_v1.Job{
-		ObjectMeta: meta_v1.ObjectMeta{
-			Name:      fmt.Sprintf("gate-job-%s", name),
-			Namespace: ns,
-		},
-		Spec: batch_v1.JobSpec{
-			Template: v1.PodTemplateSpec{
-				Spec: v1.PodSpec{
-					Containers: []v1.Container{
-						{
-							Name:            "job",
-							Image:           image,
-							ImagePullPolicy: v1.PullIfNotPresent,
-						},
-					},
-					RestartPolicy: v1.RestartPolicyNever,
-				},
-			},
-			BackoffLimit: &backoff,
+func annotateResource(client kubernetes.Interface, obj *k8s.GenericResource, annotations map[string]string) error {
+	values := make(map[string]interface{}, len(annotations))
+	for k, v := range annotations {
+		if v == "" {
+			values[k] = nil
+			continue
+		}
+		values[k] = v
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": values,
 		},
+	})
+	if err != nil {
+		return err
 	}
+	return patch(client, obj, k8s_types.MergePatchType, data)
+}
+
+func patch(client kubernetes.Interface, obj *k8s.GenericResource, pt k8s_types.PatchType, data []byte) error {
+	opts := meta_v1.PatchOptions{FieldManager: FieldManager}
 
-	if secret != "" {
-		jobSpec.Spec.Template.Spec.Containers[0].EnvFrom = []v1.EnvFromSource{
-			{
-				SecretRef: &v1.SecretEnvSource{
-					LocalObjectReference: v1.LocalObjectReference{
-						Name: secret,
-					},
-				},
-			},
+	// the apiserver applies the patch to the latest state and retries internally
+	// when the object changes underneath, it returns a Conflict once those retries
+	// run out (ie: busy controllers updating the resource). RetryOnConflict uses
+	// exponential backoff to avoid exhausting the apiserver
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		switch resource := obj.GetResource().(type) {
+		case *apps_v1.Deployment:
+			_, err = client.AppsV1().Deployments(resource.Namespace).Patch(context.TODO(), resource.Name, pt, data, opts)
+		case *apps_v1.StatefulSet:
+			_, err = client.AppsV1().StatefulSets(resource.Namespace).Patch(context.TODO(), resource.Name, pt, data, opts)
+		case *apps_v1.DaemonSet:
+			_, err = client.AppsV1().DaemonSets(resource.Namespace).Patch(context.TODO(), resource.Name, pt, data, opts)
+		case *batch_v1.CronJob:
+			_, err = client.BatchV1().CronJobs(resource.Namespace).Patch(context.TODO(), resource.Name, pt, data, opts)
+		case *batch_v1.Job:
+			_, err = client.BatchV1().Jobs(resource.Namespace).Patch(context.TODO(), resource.Name, pt, data, opts)
+		default:
+			return fmt.Errorf("unsupported object type")
 		}
-	}
+		if errors.IsConflict(err) {
+			log.WithFields(log.Fields{
+				"error":    err,
+				"identity": obj.Identifier,
+			}).Warn("provider.kubernetes: conflict while patching resource, retrying")
+		}
+		return err
+	})
+}
 
-	_, err := i.client.BatchV1().Jobs(ns).Create(context.TODO(), jobSpec, meta_v1.CreateOptions{})
+// Get - fetches current state of the generic resource, used to
+// track rollout status
+func (i *KubernetesImplementer) Get(obj *k8s.GenericResource) (*k8s.GenericResource, error) {
+	var (
+		current interface{}
+		err     error
+	)
+	switch resource := obj.GetResource().(type) {
+	case *apps_v1.Deployment:
+		current, err = i.client.AppsV1().Deployments(resource.Namespace).Get(context.TODO(), resource.Name, meta_v1.GetOptions{})
+	case *apps_v1.StatefulSet:
+		current, err = i.client.AppsV1().StatefulSets(resource.Namespace).Get(context.TODO(), resource.Name, meta_v1.GetOptions{})
+	case *apps_v1.DaemonSet:
+		current, err = i.client.AppsV1().DaemonSets(resource.Namespace).Get(context.TODO(), resource.Name, meta_v1.GetOptions{})
+	case *batch_v1.CronJob:
+		current, err = i.client.BatchV1().CronJobs(resource.Namespace).Get(context.TODO(), resource.Name, meta_v1.GetOptions{})
+	case *batch_v1.Job:
+		current, err = i.client.BatchV1().Jobs(resource.Namespace).Get(context.TODO(), resource.Name, meta_v1.GetOptions{})
+	default:
+		return nil, fmt.Errorf("unsupported object type")
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return k8s.NewGenericResource(current)
+}
 
-	return nil
+// CreateJob - creates job in its namespace
+func (i *KubernetesImplementer) CreateJob(job *batch_v1.Job) error {
+	_, err := i.client.BatchV1().Jobs(job.Namespace).Create(context.TODO(), job, meta_v1.CreateOptions{})
+	return err
 }
 
+// Job - get job by name
 func (i *KubernetesImplementer) Job(namespace, name string) (*batch_v1.Job, error) {
-	return i.client.BatchV1().Jobs(namespace).Get(context.TODO(), fmt.Sprintf("gate-job-%s", name), meta_v1.GetOptions{})
+	return i.client.BatchV1().Jobs(namespace).Get(context.TODO(), name, meta_v1.GetOptions{})
+}
+
+// DeleteJob - deletes job together with its pods
+func (i *KubernetesImplementer) DeleteJob(namespace, name string) error {
+	propagation := meta_v1.DeletePropagationBackground
+	return i.client.BatchV1().Jobs(namespace).Delete(context.TODO(), name, meta_v1.DeleteOptions{PropagationPolicy: &propagation})
 }
 
 // Secret - get secret
@@ -230,3 +264,8 @@ func (i *KubernetesImplementer) DeletePod(namespace, name string, opts *meta_v1.
 func (i *KubernetesImplementer) ConfigMaps(namespace string) core_v1.ConfigMapInterface {
 	return i.client.CoreV1().ConfigMaps(namespace)
 }
+
+// Events - returns an interface to events for a specified namespace
+func (i *KubernetesImplementer) Events(namespace string) core_v1.EventInterface {
+	return i.client.CoreV1().Events(namespace)
+}
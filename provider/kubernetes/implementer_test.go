@@ -0,0 +1,280 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	batch_v1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8s_testing "k8s.io/client-go/testing"
+)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func TestPatchResourceDeployment(t *testing.T) {
+	existing := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "dep-1",
+			Namespace: "xxxx",
+			Labels: map[string]string{
+				"app":                   "dep-1",
+				types.QuillaPolicyLabel: "all",
+			},
+			Annotations: map[string]string{
+				"owner":                                "team-a",
+				types.QuillaAutoApproveAfterAnnotation: "4h",
+			},
+		},
+		Spec: apps_v1.DeploymentSpec{
+			// replicas managed by HPA
+			Replicas: int32Ptr(7),
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Name:  "app",
+							Image: "gcr.io/v2-namespace/hello-world:1.1.1",
+							// resources managed by VPA
+							Resources: v1.ResourceRequirements{
+								Requests: v1.ResourceList{
+									v1.ResourceCPU: resource.MustParse("250m"),
+								},
+							},
+						},
+						{
+							Name:  "sidecar",
+							Image: "envoy:1.0.0",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	client := fake.NewSimpleClientset(existing)
+
+	// resource as seen by quilla, stale replicas and resources
+	stale := existing.DeepCopy()
+	stale.Spec.Replicas = int32Ptr(1)
+	stale.Spec.Template.Spec.Containers[0].Resources = v1.ResourceRequirements{}
+	// sidecar was updated by someone else since quilla has seen it
+	stale.Spec.Template.Spec.Containers[1].Image = "envoy:0.9.0"
+	gr, err := k8s.NewGenericResource(stale)
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+
+	labels := gr.GetLabels()
+	delete(labels, types.QuillaPolicyLabel)
+	gr.SetLabels(labels)
+	annotations := gr.GetAnnotations()
+	annotations[types.QuillaPolicyLabel] = "major"
+	delete(annotations, types.QuillaAutoApproveAfterAnnotation)
+	gr.SetAnnotations(annotations)
+	gr.UpdateContainer(0, "gcr.io/v2-namespace/hello-world:1.2.0")
+	gr.SetSpecAnnotations(map[string]string{types.QuillaUpdateTimeAnnotation: "now"})
+
+	err = patchResource(client, gr, types.ContainerChanges{
+		{Container: "app", Image: "gcr.io/v2-namespace/hello-world:1.2.0"},
+	})
+	if err != nil {
+		t.Fatalf("failed to patch resource: %s", err)
+	}
+
+	updated, err := client.AppsV1().Deployments("xxxx").Get(context.TODO(), "dep-1", meta_v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get deployment: %s", err)
+	}
+
+	if *updated.Spec.Replicas != 7 {
+		t.Errorf("expected replicas to be preserved, got: %d", *updated.Spec.Replicas)
+	}
+	containers := updated.Spec.Template.Spec.Containers
+	if len(containers) != 2 {
+		t.Fatalf("expected 2 containers, got: %d", len(containers))
+	}
+	if containers[0].Image != "gcr.io/v2-namespace/hello-world:1.2.0" {
+		t.Errorf("unexpected image: %s", containers[0].Image)
+	}
+	if containers[0].Resources.Requests.Cpu().String() != "250m" {
+		t.Errorf("expected resources to be preserved, got: %s", containers[0].Resources.Requests.Cpu())
+	}
+	if containers[1].Image != "envoy:1.0.0" {
+		t.Errorf("unexpected sidecar image: %s", containers[1].Image)
+	}
+	if _, ok := updated.Labels[types.QuillaPolicyLabel]; ok {
+		t.Errorf("expected policy label to be removed")
+	}
+	if updated.Labels["app"] != "dep-1" {
+		t.Errorf("expected app label to be preserved")
+	}
+	if updated.Annotations[types.QuillaPolicyLabel] != "major" {
+		t.Errorf("unexpected policy annotation: %s", updated.Annotations[types.QuillaPolicyLabel])
+	}
+	if updated.Annotations["owner"] != "team-a" {
+		t.Errorf("expected owner annotation to be preserved")
+	}
+	if _, ok := updated.Annotations[types.QuillaAutoApproveAfterAnnotation]; ok {
+		t.Errorf("expected removed quilla annotation to be removed")
+	}
+	if updated.Spec.Template.Annotations[types.QuillaUpdateTimeAnnotation] != "now" {
+		t.Errorf("expected update time annotation to be set")
+	}
+}
+
+func TestPatchResourceCronJob(t *testing.T) {
+	existing := &batch_v1.CronJob{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cron-1",
+			Namespace: "xxxx",
+		},
+		Spec: batch_v1.CronJobSpec{
+			Schedule: "* * * * *",
+			JobTemplate: batch_v1.JobTemplateSpec{
+				Spec: batch_v1.JobSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{Name: "job", Image: "gcr.io/v2-namespace/job:1.0.0"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(existing)
+
+	gr, err := k8s.NewGenericResource(existing.DeepCopy())
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+	gr.UpdateContainer(0, "gcr.io/v2-namespace/job:1.1.0")
+
+	err = patchResource(client, gr, types.ContainerChanges{
+		{Container: "job", Image: "gcr.io/v2-namespace/job:1.1.0"},
+	})
+	if err != nil {
+		t.Fatalf("failed to patch resource: %s", err)
+	}
+
+	updated, err := client.BatchV1().CronJobs("xxxx").Get(context.TODO(), "cron-1", meta_v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get cronjob: %s", err)
+	}
+	if updated.Spec.Schedule != "* * * * *" {
+		t.Errorf("expected schedule to be preserved, got: %s", updated.Spec.Schedule)
+	}
+	image := updated.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image
+	if image != "gcr.io/v2-namespace/job:1.1.0" {
+		t.Errorf("unexpected image: %s", image)
+	}
+}
+
+func TestAnnotateResource(t *testing.T) {
+	existing := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "dep-1",
+			Namespace: "xxxx",
+			Annotations: map[string]string{
+				"owner": "team-a",
+				types.QuillaStatusAnnotationPrefix + "error": "failed to update",
+			},
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Replicas: int32Ptr(3),
+		},
+	}
+	client := fake.NewSimpleClientset(existing)
+
+	gr, err := k8s.NewGenericResource(existing.DeepCopy())
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+
+	err = annotateResource(client, gr, map[string]string{
+		types.QuillaStatusAnnotationPrefix + "outcome": "succeeded",
+		types.QuillaStatusAnnotationPrefix + "error":   "",
+	})
+	if err != nil {
+		t.Fatalf("failed to annotate resource: %s", err)
+	}
+
+	updated, err := client.AppsV1().Deployments("xxxx").Get(context.TODO(), "dep-1", meta_v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get deployment: %s", err)
+	}
+	if updated.Annotations[types.QuillaStatusAnnotationPrefix+"outcome"] != "succeeded" {
+		t.Errorf("expected outcome annotation to be set, got: %v", updated.Annotations)
+	}
+	if _, ok := updated.Annotations[types.QuillaStatusAnnotationPrefix+"error"]; ok {
+		t.Errorf("expected empty annotation to be removed")
+	}
+	if updated.Annotations["owner"] != "team-a" || *updated.Spec.Replicas != 3 {
+		t.Errorf("expected other fields to be preserved")
+	}
+}
+
+func TestPatchResourceRetriesConflict(t *testing.T) {
+	existing := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "dep-1",
+			Namespace: "xxxx",
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+					},
+				},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(existing)
+
+	// apiserver gave up on its own retries once
+	var attempts int
+	client.PrependReactor("patch", "deployments", func(action k8s_testing.Action) (bool, runtime.Object, error) {
+		attempts++
+		if attempts == 1 {
+			return true, nil, errors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, "dep-1", fmt.Errorf("object was modified"))
+		}
+		return false, nil, nil
+	})
+
+	gr, err := k8s.NewGenericResource(existing.DeepCopy())
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+	gr.UpdateContainer(0, "gcr.io/v2-namespace/hello-world:1.2.0")
+
+	err = patchResource(client, gr, types.ContainerChanges{
+		{Container: "app", Image: "gcr.io/v2-namespace/hello-world:1.2.0"},
+	})
+	if err != nil {
+		t.Fatalf("failed to patch resource: %s", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected patch to be retried once, attempts: %d", attempts)
+	}
+
+	updated, err := client.AppsV1().Deployments("xxxx").Get(context.TODO(), "dep-1", meta_v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get deployment: %s", err)
+	}
+	if updated.Spec.Template.Spec.Containers[0].Image != "gcr.io/v2-namespace/hello-world:1.2.0" {
+		t.Errorf("unexpected image: %s", updated.Spec.Template.Spec.Containers[0].Image)
+	}
+}
@@ -2,7 +2,6 @@ package kubernetes
 
 import (
 	"fmt"
-	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -16,11 +15,12 @@ import (
 
 	"github.com/quilla-hq/quilla/approvals"
 	"github.com/quilla-hq/quilla/extension/notification"
-	"github.com/quilla-hq/quilla/internal/gate"
 	"github.com/quilla-hq/quilla/internal/k8s"
 	"github.com/quilla-hq/quilla/internal/policy"
+	"github.com/quilla-hq/quilla/registry"
 	"github.com/quilla-hq/quilla/types"
 	"github.com/quilla-hq/quilla/util/image"
+	"github.com/quilla-hq/quilla/util/keylock"
 	"github.com/quilla-hq/quilla/util/policies"
 
 	log "github.com/sirupsen/logrus"
@@ -58,6 +58,16 @@ type GenericResourceCache interface {
 	// The slice and its contents should be treated as read-only.
 	Values() []*k8s.GenericResource
 
+	// Lookup returns copies of resources that use given image repository
+	Lookup(repository string) []*k8s.IndexedResource
+
+	// Identifiers returns identifiers of resources that use given image repository
+	Identifiers(repository string) []string
+
+	// Indexed returns all resources with parsed policies and images,
+	// resources must be treated as read-only.
+	Indexed() []*k8s.IndexedResource
+
 	// Register registers ch to receive a value when Notify is called.
 	Register(chan int, int)
 }
@@ -72,15 +82,58 @@ type UpdatePlan struct {
 	CurrentVersion string
 	// New version that's already in the deployment
 	NewVersion string
+
+	// Changes - updated containers
+	Changes types.ContainerChanges
+
+	// PromotedFrom - source resource when version is promoted
+	PromotedFrom string
+
+	// Trigger, Digest and Approvers are recorded in version history
+	Trigger   string
+	Digest    string
+	Approvers types.Users
+
+	// group - update group the plan belongs to
+	group *updateGroup
 }
 
 func (p *UpdatePlan) String() string {
 	if p.Resource != nil {
-		return fmt.Sprintf("%s %s->%s", p.Resource.Identifier, p.CurrentVersion, p.NewVersion)
+		return fmt.Sprintf("%s %s", p.Resource.Identifier, p.delta())
 	}
 	return "empty plan"
 }
 
+// delta - version change, lists every container when
+// more than one container is updated
+func (p *UpdatePlan) delta() string {
+	if len(p.Changes) > 1 {
+		return p.Changes.String()
+	}
+	return fmt.Sprintf("%s->%s", p.CurrentVersion, p.NewVersion)
+}
+
+// lineage - promotion source, empty when version isn't promoted
+func (p *UpdatePlan) lineage() string {
+	if p.PromotedFrom == "" {
+		return ""
+	}
+	return fmt.Sprintf(", promoted from %s", p.PromotedFrom)
+}
+
+func (p *UpdatePlan) metadata(provider string) map[string]string {
+	metadata := map[string]string{
+		"provider":  provider,
+		"namespace": p.Resource.GetNamespace(),
+		"name":      p.Resource.GetName(),
+	}
+	if p.PromotedFrom != "" {
+		metadata["promotedFrom"] = p.PromotedFrom
+	}
+	return metadata
+}
+
 // Provider - kubernetes provider for auto update
 type Provider struct {
 	implementer Implementer
@@ -91,8 +144,27 @@ type Provider struct {
 
 	cache GenericResourceCache
 
-	events chan *types.Event
-	stop   chan struct{}
+	gates GateStore
+
+	versions VersionStore
+
+	waiting WaitingStore
+
+	limiter updateLimiter
+
+	history HistoryStore
+
+	digests registry.DigestResolver
+
+	status statusWriter
+
+	submitter EventSubmitter
+
+	// serialises updates of the same resource, events are processed
+	// by multiple queue workers
+	locks keylock.KeyLock
+
+	stop chan struct{}
 }
 
 // NewProvider - create new kubernetes based provider
@@ -101,16 +173,37 @@ func NewProvider(implementer Implementer, sender notification.Sender, approvalMa
 		implementer:     implementer,
 		cache:           cache,
 		approvalManager: approvalManager,
-		events:          make(chan *types.Event, 100),
 		stop:            make(chan struct{}),
 		sender:          sender,
 	}, nil
 }
 
-// Submit - submit event to provider
+// Submit - process event, safe to call concurrently
 func (p *Provider) Submit(event types.Event) error {
-	p.events <- &event
-	return nil
+	_, err := p.processEvent(&event)
+	return err
+}
+
+// EventSubmitter - submits events through the providers queue
+type EventSubmitter interface {
+	Submit(event types.Event) error
+}
+
+// SetEventSubmitter - sets submitter of the events that provider creates itself
+// (gates, dependencies, promotions) so they are serialized with other events of
+// the resource. Without it events are processed directly.
+func (p *Provider) SetEventSubmitter(submitter EventSubmitter) {
+	p.submitter = submitter
+}
+
+// submit - submits event created by the provider, only this provider processes it
+func (p *Provider) submit(event *types.Event) error {
+	if p.submitter == nil {
+		_, err := p.processEvent(event)
+		return err
+	}
+	event.Provider = p.GetName()
+	return p.submitter.Submit(*event)
 }
 
 // GetName - get provider name
@@ -151,6 +244,16 @@ func getImagePullSecretFromMeta(labels map[string]string, annotations map[string
 	return ""
 }
 
+// getImagePullSecrets - secret from quilla annotation followed by pod image pull secrets
+func getImagePullSecrets(gr *k8s.GenericResource) []string {
+	var secrets []string
+	specifiedSecret := getImagePullSecretFromMeta(gr.GetLabels(), gr.GetAnnotations())
+	if specifiedSecret != "" {
+		secrets = append(secrets, specifiedSecret)
+	}
+	return append(secrets, gr.GetImagePullSecrets()...)
+}
+
 func getInitContainerTrackingFromMeta(labels map[string]string, annotations map[string]string) bool {
 
 	searchKey := strings.ToLower(types.QuillaInitContainerAnnotation)
@@ -174,13 +277,13 @@ func getInitContainerTrackingFromMeta(labels map[string]string, annotations map[
 func (p *Provider) TrackedImages() ([]*types.TrackedImage, error) {
 	var trackedImages []*types.TrackedImage
 
-	for _, gr := range p.cache.Values() {
+	for _, ir := range p.cache.Indexed() {
+		gr := ir.Resource
 		labels := gr.GetLabels()
 		annotations := gr.GetAnnotations()
 
 		// ignoring unlabelled deployments
-		plc := policy.GetPolicyFromLabelsOrAnnotations(labels, annotations)
-		if plc.Type() == policy.PolicyTypeNone {
+		if !ir.Tracked() {
 			continue
 		}
 
@@ -203,29 +306,17 @@ func (p *Provider) TrackedImages() ([]*types.TrackedImage, error) {
 		// trigger type, we only care for "poll" type triggers
 		trigger := policies.GetTriggerPolicy(labels, annotations)
 
-		// getting image pull secrets
-		var secrets []string
-		specifiedSecret := getImagePullSecretFromMeta(labels, annotations)
-		if specifiedSecret != "" {
-			secrets = append(secrets, specifiedSecret)
-		}
-		secrets = append(secrets, gr.GetImagePullSecrets()...)
+		secrets := getImagePullSecrets(gr)
 
-		images := gr.GetImages()
-		if getInitContainerTrackingFromMeta(labels, annotations) {
-			images = append(images, gr.GetInitImages()...)
-		}
-		for _, img := range images {
-			ref, err := image.Parse(img)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"error":     err,
-					"image":     img,
-					"namespace": gr.Namespace,
-					"name":      gr.Name,
-				}).Error("provider.kubernetes: failed to parse image")
+		trackInit := getInitContainerTrackingFromMeta(labels, annotations)
+		for _, c := range ir.Containers {
+			if c.Init && !trackInit {
+				continue
+			}
+			if c.Policy.Type() == policy.PolicyTypeNone {
 				continue
 			}
+			ref := c.Ref
 			svp := make(map[string]string)
 
 			semverTag, err := semver.NewVersion(ref.Tag())
@@ -243,7 +334,7 @@ func (p *Provider) TrackedImages() ([]*types.TrackedImage, error) {
 				Namespace:    gr.Namespace,
 				Secrets:      secrets,
 				Meta:         make(map[string]string),
-				Policy:       plc,
+				Policy:       c.Policy,
 			})
 		}
 	}
@@ -252,22 +343,20 @@ func (p *Provider) TrackedImages() ([]*types.TrackedImage, error) {
 }
 
 func (p *Provider) startInternal() error {
-	for {
-		select {
-		case event := <-p.events:
-			_, err := p.processEvent(event)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"error": err,
-					"image": event.Repository.Name,
-					"tag":   event.Repository.Tag,
-				}).Error("provider.kubernetes: failed to process event")
-			}
-		case <-p.stop:
-			log.Info("provider.kubernetes: got shutdown signal, stopping...")
-			return nil
-		}
+	if p.gates != nil {
+		go p.watchGates()
+	}
+	if p.versions != nil {
+		go p.watchPromotions()
+	}
+	if p.waiting != nil {
+		go p.watchDependencies()
 	}
+	go p.watchUpdateQueue()
+	go p.watchStatus()
+	<-p.stop
+	log.Info("provider.kubernetes: got shutdown signal, stopping...")
+	return nil
 }
 
 func (p *Provider) processEvent(event *types.Event) (updated []*k8s.GenericResource, err error) {
@@ -284,98 +373,150 @@ func (p *Provider) processEvent(event *types.Event) (updated []*k8s.GenericResou
 		return
 	}
 
-	approvedPlans := p.checkForApprovals(event, plans)
+	for _, plan := range plans {
+		plan.Trigger = event.TriggerName
+		plan.Digest = event.Repository.Digest
+	}
 
-	return p.updateDeployments(approvedPlans)
-}
+	plans, groups := groupPlans(plans)
 
-func (p *Provider) updateDeployments(plans []*UpdatePlan) (updated []*k8s.GenericResource, err error) {
-	for _, plan := range plans {
-		resource := plan.Resource
+	approvedPlans := p.checkForApprovals(event, plans)
 
-		annotations := resource.GetAnnotations()
+	updated, err = p.updateDeployments(event, approvedPlans)
 
-		notificationChannels := types.ParseEventNotificationChannels(annotations)
+	for _, g := range groups {
+		groupUpdated, groupErr := p.updateGroup(event, g)
+		updated = append(updated, groupUpdated...)
+		if groupErr == nil {
+			continue
+		}
+		if err != nil {
+			err = fmt.Errorf("%s; %s", err, groupErr)
+		} else {
+			err = groupErr
+		}
+	}
 
-		p.sender.Send(types.EventNotification{
-			ResourceKind: resource.Kind(),
-			Identifier:   resource.Identifier,
-			Name:         "preparing to update resource",
-			Message:      fmt.Sprintf("Preparing to update %s %s/%s %s->%s (%s)", resource.Kind(), resource.Namespace, resource.Name, plan.CurrentVersion, plan.NewVersion, strings.Join(resource.GetImages(), ", ")),
-			CreatedAt:    time.Now(),
-			Type:         types.NotificationPreDeploymentUpdate,
-			Level:        types.LevelDebug,
-			Channels:     notificationChannels,
-			Metadata: map[string]string{
-				"provider":  p.GetName(),
-				"namespace": resource.GetNamespace(),
-				"name":      resource.GetName(),
-			},
-		})
+	return updated, err
+}
 
-		var err error
+// updateDeployments - applies plans, dependencies are updated first and
+// plans with dependencies that aren't ready wait for them
+func (p *Provider) updateDeployments(event *types.Event, plans []*UpdatePlan) (updated []*k8s.GenericResource, err error) {
+	sorted, cyclic := sortPlans(plans)
+	if len(cyclic) > 0 {
+		p.notifyCycle(cyclic)
+	}
 
-		timestamp := time.Now().Format(time.RFC3339)
-		annotations["kubernetes.io/change-cause"] = fmt.Sprintf("quilla automated update, version %s -> %s [%s]", plan.CurrentVersion, plan.NewVersion, timestamp)
+	planned := make(map[string]*UpdatePlan, len(sorted))
+	for _, plan := range sorted {
+		planned[plan.Resource.Identifier] = plan
+	}
+	applied := map[string]bool{}
 
-		resource.SetAnnotations(annotations)
+	var failed []string
+	for _, plan := range sorted {
+		resource := plan.Resource
 
-		err = p.implementer.Update(resource)
-		kubernetesVersionedUpdatesCounter.With(prometheus.Labels{"kubernetes": fmt.Sprintf("%s/%s", resource.Namespace, resource.Name)}).Inc()
-		if err != nil {
+		deps, depErr := getDependencies(resource)
+		if depErr != nil {
 			log.WithFields(log.Fields{
-				"error":      err,
-				"namespace":  resource.Namespace,
-				"deployment": resource.Name,
-				"kind":       resource.Kind(),
-				"update":     fmt.Sprintf("%s->%s", plan.CurrentVersion, plan.NewVersion),
-			}).Error("provider.kubernetes: got error while updating resource")
-
-			p.sender.Send(types.EventNotification{
-				Name:         "update resource",
-				ResourceKind: resource.Kind(),
-				Identifier:   resource.Identifier,
-				Message:      fmt.Sprintf("%s %s/%s update %s->%s failed, error: %s", resource.Kind(), resource.Namespace, resource.Name, plan.CurrentVersion, plan.NewVersion, err),
-				CreatedAt:    time.Now(),
-				Type:         types.NotificationDeploymentUpdate,
-				Level:        types.LevelError,
-				Channels:     notificationChannels,
-				Metadata: map[string]string{
-					"provider":  p.GetName(),
-					"namespace": resource.GetNamespace(),
-					"name":      resource.GetName(),
-				},
-			})
-
+				"error":      depErr,
+				"identifier": resource.Identifier,
+			}).Error("provider.kubernetes: invalid dependencies, skipping update")
+			failed = append(failed, resource.Identifier)
+			continue
+		}
+		if notReady := p.dependenciesReady(plan, deps, planned, applied); len(notReady) > 0 {
+			p.waitForDependencies(event, plan, notReady)
+			continue
+		}
+		if p.preUpdateHook(event, plan) != hookPassed {
 			continue
 		}
 
-		err = p.updateComplete(plan)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error":     err,
-				"name":      resource.Name,
-				"kind":      resource.Kind(),
-				"namespace": resource.Namespace,
-			}).Warn("provider.kubernetes: got error while archiving approvals counter after successful update")
+		started, ok := p.applyLimited(plan)
+		if !started {
+			continue
 		}
+		if !ok {
+			failed = append(failed, resource.Identifier)
+			continue
+		}
+		if len(deps) > 0 {
+			p.doneWaiting(resource.Identifier)
+		}
+		applied[resource.Identifier] = true
+		updated = append(updated, resource)
+	}
 
-		var msg string
-		releaseNotes := types.ParseReleaseNotesURL(resource.GetAnnotations())
-		if releaseNotes != "" {
-			msg = fmt.Sprintf("Successfully updated %s %s/%s %s->%s (%s). Release notes: %s", resource.Kind(), resource.Namespace, resource.Name, plan.CurrentVersion, plan.NewVersion, strings.Join(resource.GetImages(), ", "), releaseNotes)
-		} else {
-			msg = fmt.Sprintf("Successfully updated %s %s/%s %s->%s (%s)", resource.Kind(), resource.Namespace, resource.Name, plan.CurrentVersion, plan.NewVersion, strings.Join(resource.GetImages(), ", "))
+	if len(cyclic) > 0 {
+		for _, plan := range cyclic {
+			failed = append(failed, plan.Resource.Identifier)
 		}
+	}
+
+	if len(failed) > 0 {
+		err = fmt.Errorf("failed to update: %s", strings.Join(failed, ", "))
+	}
+
+	return
+}
+
+// updateDeployment - applies update plan, returns false if update failed
+func (p *Provider) updateDeployment(plan *UpdatePlan) (ok bool) {
+	resource := plan.Resource
+
+	// failure - why update failed, written back as resource status
+	var failure string
+	defer func() {
+		p.recordHistory(plan, ok)
+		p.updateStatus(plan, ok, failure)
+	}()
+
+	annotations := resource.GetAnnotations()
+
+	notificationChannels := types.ParseEventNotificationChannels(annotations)
+
+	p.sender.Send(types.EventNotification{
+		ResourceKind: resource.Kind(),
+		Identifier:   resource.Identifier,
+		Name:         "preparing to update resource",
+		Message:      fmt.Sprintf("Preparing to update %s %s/%s %s (%s)%s", resource.Kind(), resource.Namespace, resource.Name, plan.delta(), strings.Join(resource.GetImages(), ", "), plan.lineage()),
+		CreatedAt:    time.Now(),
+		Type:         types.NotificationPreDeploymentUpdate,
+		Level:        types.LevelDebug,
+		Channels:     notificationChannels,
+		Metadata:     plan.metadata(p.GetName()),
+	})
 
-		err = p.sender.Send(types.EventNotification{
+	var err error
+
+	timestamp := time.Now().Format(time.RFC3339)
+	annotations["kubernetes.io/change-cause"] = fmt.Sprintf("quilla automated update, version %s [%s]", plan.delta(), timestamp)
+
+	resource.SetAnnotations(annotations)
+
+	err = p.implementer.Update(resource, plan.Changes)
+	kubernetesVersionedUpdatesCounter.With(prometheus.Labels{"kubernetes": fmt.Sprintf("%s/%s", resource.Namespace, resource.Name)}).Inc()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"namespace":  resource.Namespace,
+			"deployment": resource.Name,
+			"kind":       resource.Kind(),
+			"update":     plan.delta(),
+		}).Error("provider.kubernetes: got error while updating resource")
+		failure = err.Error()
+
+		p.sender.Send(types.EventNotification{
+			Name:         "update resource",
 			ResourceKind: resource.Kind(),
 			Identifier:   resource.Identifier,
-			Name:         "update resource",
-			Message:      msg,
+			Message:      fmt.Sprintf("%s %s/%s update %s failed, error: %s", resource.Kind(), resource.Namespace, resource.Name, plan.delta(), err),
 			CreatedAt:    time.Now(),
 			Type:         types.NotificationDeploymentUpdate,
-			Level:        types.LevelSuccess,
+			Level:        types.LevelError,
 			Channels:     notificationChannels,
 			Metadata: map[string]string{
 				"provider":  p.GetName(),
@@ -383,28 +524,66 @@ func (p *Provider) updateDeployments(plans []*UpdatePlan) (updated []*k8s.Generi
 				"name":      resource.GetName(),
 			},
 		})
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error":     err,
-				"name":      resource.Name,
-				"kind":      resource.Kind(),
-				"previous":  plan.CurrentVersion,
-				"new":       plan.NewVersion,
-				"namespace": resource.Namespace,
-			}).Error("provider.kubernetes: got error while sending notification")
-		}
 
+		return false
+	}
+
+	if !p.postUpdateHook(plan) {
+		failure = "post-update hook failed"
+		return false
+	}
+
+	p.recordUpdate(plan)
+
+	err = p.updateComplete(plan)
+	if err != nil {
 		log.WithFields(log.Fields{
+			"error":     err,
+			"name":      resource.Name,
+			"kind":      resource.Kind(),
+			"namespace": resource.Namespace,
+		}).Warn("provider.kubernetes: got error while archiving approvals counter after successful update")
+	}
+
+	var msg string
+	releaseNotes := types.ParseReleaseNotesURL(resource.GetAnnotations())
+	if releaseNotes != "" {
+		msg = fmt.Sprintf("Successfully updated %s %s/%s %s (%s)%s. Release notes: %s", resource.Kind(), resource.Namespace, resource.Name, plan.delta(), strings.Join(resource.GetImages(), ", "), plan.lineage(), releaseNotes)
+	} else {
+		msg = fmt.Sprintf("Successfully updated %s %s/%s %s (%s)%s", resource.Kind(), resource.Namespace, resource.Name, plan.delta(), strings.Join(resource.GetImages(), ", "), plan.lineage())
+	}
+
+	err = p.sender.Send(types.EventNotification{
+		ResourceKind: resource.Kind(),
+		Identifier:   resource.Identifier,
+		Name:         "update resource",
+		Message:      msg,
+		CreatedAt:    time.Now(),
+		Type:         types.NotificationDeploymentUpdate,
+		Level:        types.LevelSuccess,
+		Channels:     notificationChannels,
+		Metadata:     plan.metadata(p.GetName()),
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":     err,
 			"name":      resource.Name,
 			"kind":      resource.Kind(),
 			"previous":  plan.CurrentVersion,
 			"new":       plan.NewVersion,
 			"namespace": resource.Namespace,
-		}).Info("provider.kubernetes: resource updated")
-		updated = append(updated, resource)
+		}).Error("provider.kubernetes: got error while sending notification")
 	}
 
-	return
+	log.WithFields(log.Fields{
+		"name":      resource.Name,
+		"kind":      resource.Kind(),
+		"previous":  plan.CurrentVersion,
+		"new":       plan.NewVersion,
+		"namespace": resource.Namespace,
+	}).Info("provider.kubernetes: resource updated")
+
+	return true
 }
 
 func getDesiredImage(delta map[string]string, currentImage string) (string, error) {
@@ -429,21 +608,32 @@ func getDesiredImage(delta map[string]string, currentImage string) (string, erro
 	return "", fmt.Errorf("image %s not found in deltas", currentImage)
 }
 
+// Resources - identifiers of resources that track the repository
+func (p *Provider) Resources(repository string) []string {
+	ref, err := image.Parse(repository)
+	if err != nil {
+		return nil
+	}
+	return p.cache.Identifiers(ref.Repository())
+}
+
 // createUpdatePlans - impacted deployments by changed repository
 func (p *Provider) createUpdatePlans(repo *types.Repository) ([]*UpdatePlan, error) {
 	impacted := []*UpdatePlan{}
 
-	for _, resource := range p.cache.Values() {
+	eventRepoRef, err := image.Parse(repo.String())
+	if err != nil {
+		return nil, err
+	}
 
-		labels := resource.GetLabels()
-		annotations := resource.GetAnnotations()
+	for _, ir := range p.cache.Lookup(eventRepoRef.Repository()) {
+		resource := ir.Resource
 
-		plc := policy.GetPolicyFromLabelsOrAnnotations(labels, annotations)
-		if plc.Type() == policy.PolicyTypeNone {
+		if !ir.Tracked() {
 			continue
 		}
 
-		updated, shouldUpdateDeployment, err := checkForUpdate(plc, repo, resource)
+		updated, shouldUpdateDeployment, err := checkForUpdate(ir.Policy, repo, resource)
 		if err != nil {
 			log.WithFields(log.Fields{
 				"error":      err,
@@ -454,38 +644,59 @@ func (p *Provider) createUpdatePlans(repo *types.Repository) ([]*UpdatePlan, err
 			continue
 		}
 
-		if shouldUpdateDeployment {
-			ns := os.Getenv("NAMESPACE")
-			if ns == "" {
-				ns = "default"
+		checked := time.Now()
+		p.setStatus(resource, func(s *types.ResourceStatus) {
+			s.LastCheck = checked
+			if shouldUpdateDeployment {
+				s.NewestVersion = updated.NewVersion
 			}
-			g := gate.GetGateFromLabelsOrAnnotations(resource.Identifier, labels, annotations)
-			if g.Type() != gate.GateTypeNone {
-				job, err := p.implementer.Job(ns, resource.Name)
-				if err != nil {
-					log.Error(err)
-					if strings.Contains(err.Error(), "not found") {
-						log.Println("creating job...")
-						var secret = ""
-						if s, ok := annotations[types.QuillaGateJobSecret]; ok {
-							secret = s
-						}
-						err := p.implementer.CreateJob(resource.Name, g.(*gate.JobGate).Image, secret)
-						if err != nil {
-							log.Error(err)
-						} else {
-							log.Println("job created.")
-						}
-					}
-
-					continue
-				}
+		})
 
-				if !g.ShouldPass(job) {
+		if shouldUpdateDeployment {
+			if resource.GetAnnotations()[types.QuillaHookFailedAnnotation] == updated.NewVersion {
+				log.WithFields(log.Fields{
+					"name":      resource.Name,
+					"kind":      resource.Kind(),
+					"namespace": resource.Namespace,
+					"version":   updated.NewVersion,
+				}).Debug("provider.kubernetes: version was rolled back after failed update hook, ignoring")
+				continue
+			}
+			if p.heldBack(updated) {
+				log.WithFields(log.Fields{
+					"name":      resource.Name,
+					"kind":      resource.Kind(),
+					"namespace": resource.Namespace,
+					"version":   updated.NewVersion,
+				}).Debug("provider.kubernetes: version was rolled back, ignoring")
+				continue
+			}
+			promo, err := getPromotion(resource, p.cache.Indexed())
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error":     err,
+					"name":      resource.Name,
+					"kind":      resource.Kind(),
+					"namespace": resource.Namespace,
+				}).Error("provider.kubernetes: failed to get promotion source")
+				continue
+			}
+			if promo != nil {
+				if !p.checkPromotion(promo, updated.NewVersion) {
+					log.WithFields(log.Fields{
+						"name":      resource.Name,
+						"kind":      resource.Kind(),
+						"namespace": resource.Namespace,
+						"source":    promo.source,
+						"version":   updated.NewVersion,
+					}).Debug("provider.kubernetes: version can't be promoted yet, ignoring")
 					continue
 				}
-
-				log.Println("gate passed approving changes")
+				updated.PromotedFrom = promo.source
+			}
+			// update groups are gated once for all members
+			if getGroup(resource) == "" && !p.checkGate(repo, updated) {
+				continue
 			}
 			impacted = append(impacted, updated)
 		}
@@ -1,6 +1,7 @@
 package kubernetes
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -16,7 +17,9 @@ import (
 	apps_v1 "k8s.io/api/apps/v1"
 	batch_v1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	core_v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
@@ -57,8 +60,26 @@ type fakeImplementer struct {
 
 	// stores value of an updated deployment
 	updated *k8s.GenericResource
+	// all updates in order
+	updates []*k8s.GenericResource
+	// identifier of a resource that fails to update
+	failUpdate string
+	// current state of resources returned by Get
+	current map[string]*k8s.GenericResource
 
 	availableSecret *v1.Secret
+
+	// gate and hook jobs
+	jobs        map[string]*batch_v1.Job
+	deletedJobs []string
+	// called for every created job, used to complete jobs
+	onCreateJob func(job *batch_v1.Job)
+
+	// backs ConfigMaps and Events when set
+	client kubernetes.Interface
+
+	// annotations set by Annotate, keyed by resource identifier
+	annotated map[string]map[string]string
 }
 
 func (i *fakeImplementer) Namespaces() (*v1.NamespaceList, error) {
@@ -73,11 +94,25 @@ func (i *fakeImplementer) Deployments(namespace string) (*apps_v1.DeploymentList
 	return i.deploymentList, nil
 }
 
-func (i *fakeImplementer) Update(obj *k8s.GenericResource) error {
+func (i *fakeImplementer) Update(obj *k8s.GenericResource, changes types.ContainerChanges) error {
+	if obj.Identifier == i.failUpdate {
+		return fmt.Errorf("failed to update %s", obj.Identifier)
+	}
 	i.updated = obj
+	i.updates = append(i.updates, obj)
 	return nil
 }
 
+func (i *fakeImplementer) Get(obj *k8s.GenericResource) (*k8s.GenericResource, error) {
+	if current, ok := i.current[obj.Identifier]; ok {
+		return current, nil
+	}
+	if i.updated != nil && i.updated.Identifier == obj.Identifier {
+		return i.updated, nil
+	}
+	return obj, nil
+}
+
 func (i *fakeImplementer) Secret(namespace, name string) (*v1.Secret, error) {
 	return i.availableSecret, nil
 }
@@ -100,19 +135,69 @@ func (i *fakeImplementer) DeletePod(namespace, name string, opts *meta_v1.Delete
 }
 
 func (i *fakeImplementer) ConfigMaps(namespace string) core_v1.ConfigMapInterface {
+	if i.client == nil {
+		return nil
+	}
+	return i.client.CoreV1().ConfigMaps(namespace)
+}
+
+func (i *fakeImplementer) Annotate(obj *k8s.GenericResource, annotations map[string]string) error {
+	if i.annotated == nil {
+		i.annotated = make(map[string]map[string]string)
+	}
+	current, ok := i.annotated[obj.Identifier]
+	if !ok {
+		current = make(map[string]string)
+		i.annotated[obj.Identifier] = current
+	}
+	for k, v := range annotations {
+		if v == "" {
+			delete(current, k)
+			continue
+		}
+		current[k] = v
+	}
 	return nil
 }
 
-func (i *fakeImplementer) CreateJob(name string, image string, secret string) error {
+func (i *fakeImplementer) Events(namespace string) core_v1.EventInterface {
+	if i.client == nil {
+		return nil
+	}
+	return i.client.CoreV1().Events(namespace)
+}
+
+func (i *fakeImplementer) CreateJob(job *batch_v1.Job) error {
+	if i.jobs == nil {
+		i.jobs = make(map[string]*batch_v1.Job)
+	}
+	if _, ok := i.jobs[job.Name]; ok {
+		return errors.NewAlreadyExists(batch_v1.Resource("jobs"), job.Name)
+	}
+	i.jobs[job.Name] = job
+	if i.onCreateJob != nil {
+		i.onCreateJob(job)
+	}
 	return nil
 }
 
 func (i *fakeImplementer) Job(namespace, name string) (*batch_v1.Job, error) {
-	return nil, nil
+	job, ok := i.jobs[name]
+	if !ok {
+		return nil, errors.NewNotFound(batch_v1.Resource("jobs"), name)
+	}
+	return job, nil
+}
+
+func (i *fakeImplementer) DeleteJob(namespace, name string) error {
+	delete(i.jobs, name)
+	i.deletedJobs = append(i.deletedJobs, name)
+	return nil
 }
 
 type fakeSender struct {
 	sentEvent types.EventNotification
+	sent      []types.EventNotification
 }
 
 func (s *fakeSender) Configure(cfg *notification.Config) (bool, error) {
@@ -121,6 +206,7 @@ func (s *fakeSender) Configure(cfg *notification.Config) (bool, error) {
 
 func (s *fakeSender) Send(event types.EventNotification) error {
 	s.sentEvent = event
+	s.sent = append(s.sent, event)
 	return nil
 }
 
@@ -143,6 +229,30 @@ func NewTestingUtils() (*sql.SQLStore, func()) {
 	return store, teardown
 }
 
+// newTestingProvider - provider that watches the deployments, gates and
+// promotions are backed by a testing store
+func newTestingProvider(t *testing.T, fi *fakeImplementer, sender *fakeSender, deps ...*apps_v1.Deployment) (*Provider, func()) {
+	grc := &k8s.GenericResourceCache{}
+	for _, dep := range deps {
+		grc.Add(MustParseGR(dep))
+	}
+
+	approver, teardown := approver()
+	store, storeTeardown := NewTestingUtils()
+
+	provider, err := NewProvider(fi, sender, approver, grc)
+	if err != nil {
+		t.Fatalf("failed to get provider: %s", err)
+	}
+	provider.SetGateStore(store)
+	provider.SetVersionStore(store)
+
+	return provider, func() {
+		teardown()
+		storeTeardown()
+	}
+}
+
 func approver() (*approvals.DefaultManager, func()) {
 	store, teardown := NewTestingUtils()
 	return approvals.New(&approvals.Opts{
@@ -0,0 +1,505 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/quilla-hq/quilla/types"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var updateQueueDepthGauge = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "kubernetes_update_queue_depth",
+		Help: "How many updates are queued by rollout concurrency limits and update budgets.",
+	},
+)
+
+var updatesInFlightGauge = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "kubernetes_updates_in_flight",
+		Help: "How many updated resources are still rolling out.",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(updateQueueDepthGauge)
+	prometheus.MustRegister(updatesInFlightGauge)
+}
+
+// updateQueueCheckInterval - how often rollouts in progress are checked and
+// queued updates are retried
+var updateQueueCheckInterval = 10 * time.Second
+
+// rolloutSlotTimeout - rollout stops counting towards concurrency limits
+// after this period even if it didn't complete
+var rolloutSlotTimeout = 15 * time.Minute
+
+// budgetPeriod - period of the updates per hour budget
+const budgetPeriod = time.Hour
+
+// UpdateLimits - limits how many updates are applied at once, zero means no limit
+type UpdateLimits struct {
+	// Concurrency - cluster-wide number of rollouts in progress
+	Concurrency int
+	// NamespaceConcurrency - number of rollouts in progress per namespace, empty key
+	// holds the default for namespaces that aren't listed
+	NamespaceConcurrency map[string]int
+	// UpdatesPerHour - default update budget of a resource, can be overridden
+	// with quilla.sh/updatesPerHour annotation
+	UpdatesPerHour int
+}
+
+// ParseNamespaceLimits - parses per namespace limits, ie: "production=2,staging=5,10"
+// where the plain number is the default for other namespaces
+func ParseNamespaceLimits(value string) (map[string]int, error) {
+	limits := map[string]int{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		namespace, limit := "", entry
+		if idx := strings.Index(entry, "="); idx >= 0 {
+			namespace, limit = strings.TrimSpace(entry[:idx]), strings.TrimSpace(entry[idx+1:])
+			if namespace == "" {
+				return nil, fmt.Errorf("invalid namespace limit: %s", entry)
+			}
+		}
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid namespace limit: %s", entry)
+		}
+		limits[namespace] = n
+	}
+	return limits, nil
+}
+
+// UpdateQueue - exposes updates held back by limits
+type UpdateQueue interface {
+	UpdateQueue() *types.UpdateQueue
+}
+
+// SetUpdateLimits - sets rollout concurrency limits and update budgets
+func (p *Provider) SetUpdateLimits(limits UpdateLimits) {
+	p.limiter.mu.Lock()
+	defer p.limiter.mu.Unlock()
+	p.limiter.limits = limits
+}
+
+type rollout struct {
+	plan      *UpdatePlan
+	startedAt time.Time
+}
+
+type queuedUpdate struct {
+	plan     *UpdatePlan
+	reason   string
+	queuedAt time.Time
+}
+
+// updateLimiter - tracks rollouts in progress, applied updates and
+// updates that wait for a slot or budget
+type updateLimiter struct {
+	mu     sync.Mutex
+	limits UpdateLimits
+
+	inFlight map[string]*rollout
+	applied  map[string][]time.Time
+	queue    []*queuedUpdate
+}
+
+func (l *updateLimiter) namespaceLimit(namespace string) int {
+	if n, ok := l.limits.NamespaceConcurrency[namespace]; ok {
+		return n
+	}
+	return l.limits.NamespaceConcurrency[""]
+}
+
+func (l *updateLimiter) tracksRollouts() bool {
+	return l.limits.Concurrency > 0 || len(l.limits.NamespaceConcurrency) > 0
+}
+
+func (l *updateLimiter) budget(plan *UpdatePlan) int {
+	n, err := getInt(types.QuillaUpdatesPerHourAnnotation, plan.Resource.GetLabels(), plan.Resource.GetAnnotations())
+	if err == nil && n > 0 {
+		return n
+	}
+	return l.limits.UpdatesPerHour
+}
+
+// blocked - returns why plan can't be applied now, empty when it can
+func (l *updateLimiter) blocked(plan *UpdatePlan, now time.Time) string {
+	if g := plan.representedGroup(); g != nil {
+		return l.blockedGroup(g, now)
+	}
+
+	resource := plan.Resource
+	if reason := l.budgetUsed(plan, now); reason != "" {
+		return reason
+	}
+
+	if !l.tracksRollouts() {
+		return ""
+	}
+	if l.limits.Concurrency > 0 && len(l.inFlight) >= l.limits.Concurrency {
+		return fmt.Sprintf("cluster limit of %d rollouts reached", l.limits.Concurrency)
+	}
+	if limit := l.namespaceLimit(resource.Namespace); limit > 0 {
+		if l.inFlightIn(resource.Namespace) >= limit {
+			return fmt.Sprintf("namespace %s limit of %d rollouts reached", resource.Namespace, limit)
+		}
+	}
+	return ""
+}
+
+// blockedGroup - group members roll out together so limits need room for all
+// of them, group that is larger than a limit starts once nothing else rolls out
+func (l *updateLimiter) blockedGroup(g *updateGroup, now time.Time) string {
+	perNamespace := map[string]int{}
+	for _, plan := range g.plans {
+		if reason := l.budgetUsed(plan, now); reason != "" {
+			return fmt.Sprintf("%s: %s", plan.Resource.Identifier, reason)
+		}
+		perNamespace[plan.Resource.Namespace]++
+	}
+
+	if !l.tracksRollouts() {
+		return ""
+	}
+	if limit := l.limits.Concurrency; limit > 0 && len(l.inFlight) > 0 && len(l.inFlight)+len(g.plans) > limit {
+		return fmt.Sprintf("cluster limit of %d rollouts reached", limit)
+	}
+	for namespace, n := range perNamespace {
+		limit := l.namespaceLimit(namespace)
+		if limit == 0 {
+			continue
+		}
+		if inFlight := l.inFlightIn(namespace); inFlight > 0 && inFlight+n > limit {
+			return fmt.Sprintf("namespace %s limit of %d rollouts reached", namespace, limit)
+		}
+	}
+	return ""
+}
+
+// budgetUsed - returns why resource used its hourly budget, empty when it didn't
+func (l *updateLimiter) budgetUsed(plan *UpdatePlan, now time.Time) string {
+	budget := l.budget(plan)
+	if budget <= 0 {
+		return ""
+	}
+
+	identifier := plan.Resource.Identifier
+	var recent []time.Time
+	for _, t := range l.applied[identifier] {
+		if now.Sub(t) < budgetPeriod {
+			recent = append(recent, t)
+		}
+	}
+	l.applied[identifier] = recent
+	if len(recent) >= budget {
+		return fmt.Sprintf("update budget of %d per hour used", budget)
+	}
+	return ""
+}
+
+func (l *updateLimiter) inFlightIn(namespace string) int {
+	var n int
+	for _, r := range l.inFlight {
+		if r.plan.Resource.Namespace == namespace {
+			n++
+		}
+	}
+	return n
+}
+
+// acquire - reserves rollout slot and budget for the plan, plan is queued
+// when limits are reached
+func (l *updateLimiter) acquire(plan *UpdatePlan) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.init()
+	now := time.Now()
+
+	// queued plan of the resource is replaced by a newer one, older plans
+	// that arrive late are dropped
+	for _, q := range l.queue {
+		if q.plan.Resource.Identifier != plan.Resource.Identifier {
+			continue
+		}
+		if newerPlan(plan, q.plan) {
+			q.plan = plan
+		} else {
+			log.WithFields(log.Fields{
+				"identifier": plan.Resource.Identifier,
+				"version":    plan.NewVersion,
+				"queued":     q.plan.NewVersion,
+			}).Info("provider.kubernetes: newer update is already queued, ignoring")
+		}
+		return false
+	}
+
+	reason := l.blocked(plan, now)
+	if reason != "" {
+		l.queue = append(l.queue, &queuedUpdate{plan: plan, reason: reason, queuedAt: now})
+		updateQueueDepthGauge.Set(float64(len(l.queue)))
+		log.WithFields(log.Fields{
+			"identifier": plan.Resource.Identifier,
+			"version":    plan.NewVersion,
+			"reason":     reason,
+		}).Info("provider.kubernetes: update queued")
+		return false
+	}
+
+	l.start(plan, now)
+	return true
+}
+
+// newerPlan - semver versions are compared, otherwise the latest plan wins.
+// Rollbacks are requested explicitly so they always replace queued plans.
+func newerPlan(plan, queued *UpdatePlan) bool {
+	if plan.Trigger == types.TriggerTypeRollback.String() {
+		return true
+	}
+	newVersion, err := semver.NewVersion(plan.NewVersion)
+	if err != nil {
+		return true
+	}
+	queuedVersion, err := semver.NewVersion(queued.NewVersion)
+	if err != nil {
+		return true
+	}
+	return !newVersion.LessThan(queuedVersion)
+}
+
+func (l *updateLimiter) init() {
+	if l.inFlight == nil {
+		l.inFlight = map[string]*rollout{}
+		l.applied = map[string][]time.Time{}
+	}
+}
+
+func (l *updateLimiter) start(plan *UpdatePlan, now time.Time) {
+	if g := plan.representedGroup(); g != nil {
+		for _, member := range g.plans {
+			l.start(member, now)
+		}
+		return
+	}
+	if l.budget(plan) > 0 {
+		l.applied[plan.Resource.Identifier] = append(l.applied[plan.Resource.Identifier], now)
+	}
+	if l.tracksRollouts() {
+		l.inFlight[plan.Resource.Identifier] = &rollout{plan: plan, startedAt: now}
+		updatesInFlightGauge.Set(float64(len(l.inFlight)))
+	}
+}
+
+// release - frees rollout slot, failed updates don't use the budget
+func (l *updateLimiter) release(plan *UpdatePlan, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.init()
+	delete(l.inFlight, plan.Resource.Identifier)
+	updatesInFlightGauge.Set(float64(len(l.inFlight)))
+
+	if failed {
+		applied := l.applied[plan.Resource.Identifier]
+		if len(applied) > 0 {
+			l.applied[plan.Resource.Identifier] = applied[:len(applied)-1]
+		}
+	}
+}
+
+// next - removes first queued plan that can be applied now
+func (l *updateLimiter) next() *UpdatePlan {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.init()
+	now := time.Now()
+	for i, q := range l.queue {
+		reason := l.blocked(q.plan, now)
+		if reason != "" {
+			q.reason = reason
+			continue
+		}
+		l.queue = append(l.queue[:i], l.queue[i+1:]...)
+		updateQueueDepthGauge.Set(float64(len(l.queue)))
+		l.start(q.plan, now)
+		return q.plan
+	}
+	return nil
+}
+
+func (l *updateLimiter) rollouts() []*rollout {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rollouts := make([]*rollout, 0, len(l.inFlight))
+	for _, r := range l.inFlight {
+		rollouts = append(rollouts, r)
+	}
+	return rollouts
+}
+
+// UpdateQueue - returns updates that are rolling out and queued
+func (p *Provider) UpdateQueue() *types.UpdateQueue {
+	l := &p.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	queue := &types.UpdateQueue{
+		InFlight: len(l.inFlight),
+		Depth:    len(l.queue),
+		Updates:  make([]*types.QueuedUpdate, 0, len(l.queue)),
+	}
+	for _, q := range l.queue {
+		queue.Updates = append(queue.Updates, &types.QueuedUpdate{
+			Identifier:     q.plan.Resource.Identifier,
+			Namespace:      q.plan.Resource.Namespace,
+			CurrentVersion: q.plan.CurrentVersion,
+			NewVersion:     q.plan.NewVersion,
+			Reason:         q.reason,
+			QueuedAt:       q.queuedAt,
+		})
+	}
+	return queue
+}
+
+// applyLimited - applies plan if limits allow it, otherwise plan is queued.
+// Returns whether update was started and whether it succeeded.
+func (p *Provider) applyLimited(plan *UpdatePlan) (started, ok bool) {
+	if !p.limiter.acquire(plan) {
+		return false, false
+	}
+	return true, p.apply(plan)
+}
+
+func (p *Provider) apply(plan *UpdatePlan) bool {
+	p.locks.Lock(plan.Resource.Identifier)
+	ok := p.updateDeployment(plan)
+	p.locks.Unlock(plan.Resource.Identifier)
+
+	if !ok {
+		p.limiter.release(plan, true)
+	}
+	return ok
+}
+
+// watchUpdateQueue - periodically frees slots of finished rollouts and applies
+// queued updates
+func (p *Provider) watchUpdateQueue() {
+	ticker := time.NewTicker(updateQueueCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.checkUpdateQueue()
+		}
+	}
+}
+
+func (p *Provider) checkUpdateQueue() {
+	now := time.Now()
+	for _, r := range p.limiter.rollouts() {
+		current, err := p.implementer.Get(r.plan.Resource)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"identifier": r.plan.Resource.Identifier,
+			}).Warn("provider.kubernetes: failed to get rollout status")
+		}
+		switch {
+		case err == nil && current.RolloutComplete():
+		case now.Sub(r.startedAt) > rolloutSlotTimeout:
+			log.WithFields(log.Fields{
+				"identifier": r.plan.Resource.Identifier,
+			}).Warn("provider.kubernetes: rollout didn't complete in time, releasing its slot")
+		default:
+			continue
+		}
+		p.limiter.release(r.plan, false)
+	}
+
+	for plan := p.limiter.next(); plan != nil; plan = p.limiter.next() {
+		if plan.representedGroup() != nil {
+			// group waits for its members to roll out, queue keeps moving meanwhile
+			go p.applyQueuedGroup(plan)
+			continue
+		}
+		err := p.refresh(plan)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"identifier": plan.Resource.Identifier,
+			}).Error("provider.kubernetes: failed to get queued resource, update dropped")
+			p.limiter.release(plan, true)
+			continue
+		}
+		if len(plan.Changes) == 0 {
+			log.WithFields(log.Fields{
+				"identifier": plan.Resource.Identifier,
+				"version":    plan.NewVersion,
+			}).Info("provider.kubernetes: queued update is already applied")
+			p.limiter.release(plan, true)
+			continue
+		}
+		if !p.apply(plan) {
+			continue
+		}
+		if deps, _ := getDependencies(plan.Resource); len(deps) > 0 {
+			p.doneWaiting(plan.Resource.Identifier)
+		}
+	}
+}
+
+// refresh - re-reads resource of a queued plan, resource could have changed
+// while the plan waited. Changes of containers that already run the image or
+// were removed are dropped.
+func (p *Provider) refresh(plan *UpdatePlan) error {
+	current, err := p.implementer.Get(plan.Resource)
+	if err != nil {
+		return err
+	}
+	resource := current.DeepCopy()
+
+	var changes types.ContainerChanges
+	for _, change := range plan.Changes {
+		containers := resource.Containers()
+		if change.Init {
+			containers = resource.InitContainers()
+		}
+		for idx, c := range containers {
+			if c.Name != change.Container || c.Image == change.Image {
+				continue
+			}
+			change.PreviousImage = c.Image
+			if change.Init {
+				resource.UpdateInitContainer(idx, change.Image)
+			} else {
+				resource.UpdateContainer(idx, change.Image)
+			}
+			changes = append(changes, change)
+		}
+	}
+	if len(changes) > 0 {
+		setUpdateTime(resource)
+	}
+
+	plan.Resource = resource
+	plan.Changes = changes
+	return nil
+}
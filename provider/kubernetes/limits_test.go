@@ -0,0 +1,316 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// limitedDeployment - deployment that is still rolling out unless ready
+func limitedDeployment(namespace, name string, annotations map[string]string, ready bool) *apps_v1.Deployment {
+	replicas := int32(1)
+	ann := map[string]string{
+		types.QuillaPolicyLabel: "all",
+	}
+	for k, v := range annotations {
+		ann[k] = v
+	}
+	dep := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: ann,
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: name, Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+					},
+				},
+			},
+		},
+	}
+	if ready {
+		dep.Status = apps_v1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	}
+	return dep
+}
+
+func TestParseNamespaceLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]int
+		wantErr bool
+	}{
+		{name: "default", value: "5", want: map[string]int{"": 5}},
+		{name: "namespaces", value: "production=2, staging=5,10", want: map[string]int{"production": 2, "staging": 5, "": 10}},
+		{name: "invalid limit", value: "production=two", wantErr: true},
+		{name: "missing namespace", value: "=2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNamespaceLimits(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNamespaceLimits() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseNamespaceLimits() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("ParseNamespaceLimits() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimitQueuesUpdates(t *testing.T) {
+	fi := &fakeImplementer{current: map[string]*k8s.GenericResource{}}
+	deployments := []*apps_v1.Deployment{
+		limitedDeployment("shop", "a", nil, false),
+		limitedDeployment("shop", "b", nil, false),
+		limitedDeployment("shop", "c", nil, false),
+	}
+	for _, dep := range deployments {
+		gr := MustParseGR(dep)
+		fi.current[gr.Identifier] = gr
+	}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, deployments...)
+	defer teardown()
+	provider.SetUpdateLimits(UpdateLimits{Concurrency: 1})
+
+	submitVersion(t, provider, "1.1.2")
+	if len(fi.updates) != 1 {
+		t.Fatalf("expected a single rollout, got: %d", len(fi.updates))
+	}
+
+	queue := provider.UpdateQueue()
+	if queue.InFlight != 1 || queue.Depth != 2 {
+		t.Fatalf("unexpected queue: in flight %d, depth %d", queue.InFlight, queue.Depth)
+	}
+	if !strings.Contains(queue.Updates[0].Reason, "cluster limit of 1") || queue.Updates[0].NewVersion != "1.1.2" {
+		t.Errorf("unexpected queued update: %+v", queue.Updates[0])
+	}
+
+	// first rollout is still in progress
+	provider.checkUpdateQueue()
+	if len(fi.updates) != 1 {
+		t.Fatalf("queued updates shouldn't start before rollout completes, got: %d", len(fi.updates))
+	}
+
+	first := fi.updates[0].Identifier
+	fi.current[first] = MustParseGR(limitedDeployment("shop", strings.TrimPrefix(first, "deployment/shop/"), nil, true))
+	provider.checkUpdateQueue()
+
+	if len(fi.updates) != 2 {
+		t.Fatalf("expected next queued update to start, got: %d", len(fi.updates))
+	}
+	if queue := provider.UpdateQueue(); queue.InFlight != 1 || queue.Depth != 1 {
+		t.Errorf("unexpected queue: in flight %d, depth %d", queue.InFlight, queue.Depth)
+	}
+}
+
+func TestNamespaceConcurrencyLimit(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		limitedDeployment("production", "a", nil, false),
+		limitedDeployment("production", "b", nil, false),
+		limitedDeployment("staging", "a", nil, false),
+	)
+	defer teardown()
+	provider.SetUpdateLimits(UpdateLimits{NamespaceConcurrency: map[string]int{"production": 1}})
+
+	submitVersion(t, provider, "1.1.2")
+	if len(fi.updates) != 2 {
+		t.Fatalf("expected one production and one staging rollout, got: %d", len(fi.updates))
+	}
+
+	queue := provider.UpdateQueue()
+	if queue.Depth != 1 || queue.Updates[0].Namespace != "production" {
+		t.Fatalf("expected production update to be queued, got: %+v", queue.Updates)
+	}
+	if !strings.Contains(queue.Updates[0].Reason, "namespace production limit of 1") {
+		t.Errorf("unexpected reason: %s", queue.Updates[0].Reason)
+	}
+}
+
+func TestUpdateBudget(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		limitedDeployment("shop", "a", map[string]string{types.QuillaUpdatesPerHourAnnotation: "1"}, true),
+	)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+	if len(fi.updates) != 1 {
+		t.Fatalf("expected update within budget, got: %d", len(fi.updates))
+	}
+
+	submitVersion(t, provider, "1.1.3")
+	submitVersion(t, provider, "1.1.4")
+	if len(fi.updates) != 1 {
+		t.Fatalf("expected updates over budget to be queued, got: %d", len(fi.updates))
+	}
+
+	queue := provider.UpdateQueue()
+	if queue.Depth != 1 {
+		t.Fatalf("expected newer update to replace queued one, got depth: %d", queue.Depth)
+	}
+	if queue.Updates[0].NewVersion != "1.1.4" || !strings.Contains(queue.Updates[0].Reason, "budget of 1 per hour") {
+		t.Errorf("unexpected queued update: %+v", queue.Updates[0])
+	}
+
+	provider.checkUpdateQueue()
+	if len(fi.updates) != 1 {
+		t.Errorf("budget shouldn't allow updates within the hour")
+	}
+}
+
+func TestQueuedUpdateNotReplacedByOlder(t *testing.T) {
+	fi := &fakeImplementer{}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		limitedDeployment("shop", "a", map[string]string{types.QuillaUpdatesPerHourAnnotation: "1"}, true),
+	)
+	defer teardown()
+
+	submitVersion(t, provider, "1.1.2")
+	submitVersion(t, provider, "1.1.4")
+	submitVersion(t, provider, "1.1.3")
+
+	queue := provider.UpdateQueue()
+	if queue.Depth != 1 || queue.Updates[0].NewVersion != "1.1.4" {
+		t.Errorf("expected queued update to keep the newer version, got: %+v", queue.Updates)
+	}
+}
+
+// queuedLimitedProvider - provider with two deployments and a concurrency limit of one,
+// returns identifiers of the updated and the queued deployment
+func queuedLimitedProvider(t *testing.T, fi *fakeImplementer) (*Provider, func(), string, string) {
+	deployments := []*apps_v1.Deployment{
+		limitedDeployment("shop", "a", nil, false),
+		limitedDeployment("shop", "b", nil, false),
+	}
+	for _, dep := range deployments {
+		gr := MustParseGR(dep)
+		fi.current[gr.Identifier] = gr
+	}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, deployments...)
+	provider.SetUpdateLimits(UpdateLimits{Concurrency: 1})
+
+	submitVersion(t, provider, "1.1.2")
+	if len(fi.updates) != 1 {
+		teardown()
+		t.Fatalf("expected a single rollout, got: %d", len(fi.updates))
+	}
+	first := fi.updates[0].Identifier
+	queued := "deployment/shop/a"
+	if first == queued {
+		queued = "deployment/shop/b"
+	}
+	fi.current[first] = MustParseGR(limitedDeployment("shop", strings.TrimPrefix(first, "deployment/shop/"), nil, true))
+	return provider, teardown, first, queued
+}
+
+func TestQueuedUpdateUsesCurrentResource(t *testing.T) {
+	fi := &fakeImplementer{current: map[string]*k8s.GenericResource{}}
+	provider, teardown, _, queued := queuedLimitedProvider(t, fi)
+	defer teardown()
+
+	// sidecar was added while the update waited
+	dep := limitedDeployment("shop", strings.TrimPrefix(queued, "deployment/shop/"), nil, true)
+	dep.Spec.Template.Spec.Containers = append(dep.Spec.Template.Spec.Containers, v1.Container{Name: "sidecar", Image: "envoy:1.0.0"})
+	fi.current[queued] = MustParseGR(dep)
+
+	provider.checkUpdateQueue()
+
+	if len(fi.updates) != 2 {
+		t.Fatalf("expected queued update to start, got: %d", len(fi.updates))
+	}
+	applied := fi.updates[1]
+	containers := applied.Containers()
+	if len(containers) != 2 || containers[1].Image != "envoy:1.0.0" {
+		t.Errorf("expected current containers to be kept, got: %v", applied.GetImages())
+	}
+	if containers[0].Image != "gcr.io/v2-namespace/hello-world:1.1.2" {
+		t.Errorf("expected updated image, got: %s", containers[0].Image)
+	}
+}
+
+func TestQueuedUpdateAlreadyApplied(t *testing.T) {
+	fi := &fakeImplementer{current: map[string]*k8s.GenericResource{}}
+	provider, teardown, _, queued := queuedLimitedProvider(t, fi)
+	defer teardown()
+
+	// image was updated by hand while the update waited
+	dep := limitedDeployment("shop", strings.TrimPrefix(queued, "deployment/shop/"), nil, true)
+	dep.Spec.Template.Spec.Containers[0].Image = "gcr.io/v2-namespace/hello-world:1.1.2"
+	fi.current[queued] = MustParseGR(dep)
+
+	provider.checkUpdateQueue()
+
+	if len(fi.updates) != 1 {
+		t.Fatalf("update that is already applied shouldn't be patched again, got: %d updates", len(fi.updates))
+	}
+	if queue := provider.UpdateQueue(); queue.Depth != 0 || queue.InFlight != 0 {
+		t.Errorf("unexpected queue: in flight %d, depth %d", queue.InFlight, queue.Depth)
+	}
+}
+
+func TestGroupCountsAgainstConcurrencyLimit(t *testing.T) {
+	fi := &fakeImplementer{current: map[string]*k8s.GenericResource{}}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		limitedDeployment("shop", "a", nil, false),
+		groupMember("api", nil),
+		groupMember("worker", nil),
+	)
+	defer teardown()
+	provider.SetUpdateLimits(UpdateLimits{Concurrency: 2})
+
+	submitVersion(t, provider, "1.1.2")
+	if len(fi.updates) != 1 {
+		t.Fatalf("group shouldn't start without room for all members, got: %d updates", len(fi.updates))
+	}
+
+	queue := provider.UpdateQueue()
+	if queue.InFlight != 1 || queue.Depth != 1 {
+		t.Fatalf("unexpected queue: in flight %d, depth %d", queue.InFlight, queue.Depth)
+	}
+	if queue.Updates[0].Identifier != "group/shop" || !strings.Contains(queue.Updates[0].Reason, "cluster limit of 2") {
+		t.Errorf("expected group to be queued as a whole, got: %+v", queue.Updates[0])
+	}
+
+	for _, r := range provider.limiter.rollouts() {
+		provider.limiter.release(r.plan, false)
+	}
+	rep := provider.limiter.next()
+	if rep == nil || rep.representedGroup() == nil {
+		t.Fatalf("expected queued group, got: %v", rep)
+	}
+	if queue := provider.UpdateQueue(); queue.InFlight != 2 || queue.Depth != 0 {
+		t.Errorf("expected every group member to hold a slot, got: in flight %d, depth %d", queue.InFlight, queue.Depth)
+	}
+
+	for _, name := range []string{"api", "worker"} {
+		fi.current["deployment/shop/"+name] = MustParseGR(groupMember(name, nil))
+	}
+	_, err := provider.applyGroup(rep.group, rep)
+	if err != nil {
+		t.Fatalf("failed to apply group: %s", err)
+	}
+	if len(fi.updates) != 3 {
+		t.Errorf("expected group members to be updated, got: %d updates", len(fi.updates))
+	}
+}
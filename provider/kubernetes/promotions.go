@@ -0,0 +1,345 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/types"
+	"github.com/quilla-hq/quilla/util/image"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultPromotionSoak - how long version has to run healthily in the source resource
+const DefaultPromotionSoak = time.Hour
+
+// promotionCheckInterval - how often source resources are checked
+var promotionCheckInterval = time.Minute
+
+// VersionStore - persists versions that ran in resources
+type VersionStore interface {
+	CreateResourceVersion(version *types.ResourceVersion) (*types.ResourceVersion, error)
+	UpdateResourceVersion(version *types.ResourceVersion) error
+	GetResourceVersion(q *types.GetResourceVersionQuery) (*types.ResourceVersion, error)
+	ListResourceVersions(q *types.GetResourceVersionQuery) ([]*types.ResourceVersion, error)
+}
+
+// SetVersionStore - sets store that keeps version history, resources are never
+// promoted without it
+func (p *Provider) SetVersionStore(versions VersionStore) {
+	p.versions = versions
+}
+
+// promotion - source resource and soak period of a promoted resource
+type promotion struct {
+	source string
+	soak   time.Duration
+}
+
+// getPromotion - resolves promotion source, returns nil when resource isn't promoted
+func getPromotion(resource *k8s.GenericResource, indexed []*k8s.IndexedResource) (*promotion, error) {
+	annotations := resource.GetAnnotations()
+	value := strings.TrimSpace(annotations[types.QuillaPromoteFromAnnotation])
+	if value == "" {
+		return nil, nil
+	}
+
+	promo := &promotion{soak: DefaultPromotionSoak}
+	if soak, ok := annotations[types.QuillaPromoteSoakAnnotation]; ok {
+		d, err := time.ParseDuration(soak)
+		if err != nil || d < 0 {
+			log.WithFields(log.Fields{
+				"error": err,
+				"soak":  soak,
+			}).Warn("provider.kubernetes: invalid promotion soak period, using default")
+		} else {
+			promo.soak = d
+		}
+	}
+
+	parts := strings.Split(value, "/")
+	switch len(parts) {
+	case 3:
+		// full identifier, ie: deployment/staging/api
+		promo.source = value
+	case 2:
+		// namespace/name, resource of the same kind is preferred
+		for _, ir := range indexed {
+			r := ir.Resource
+			if r.Namespace != parts[0] || r.Name != parts[1] {
+				continue
+			}
+			if promo.source == "" || r.Kind() == resource.Kind() {
+				promo.source = r.Identifier
+			}
+		}
+		if promo.source == "" {
+			return nil, fmt.Errorf("promotion source %s not found", value)
+		}
+	default:
+		return nil, fmt.Errorf("invalid promotion source: %s", value)
+	}
+
+	if promo.source == resource.Identifier {
+		return nil, fmt.Errorf("resource can't be promoted from itself")
+	}
+
+	return promo, nil
+}
+
+// checkPromotion - checks whether version soaked in the source resource and
+// passed its approvals
+func (p *Provider) checkPromotion(promo *promotion, version string) bool {
+	if p.versions == nil {
+		log.WithFields(log.Fields{
+			"source": promo.source,
+		}).Error("provider.kubernetes: version store is not configured, resource can't be promoted")
+		return false
+	}
+
+	existing, err := p.versions.GetResourceVersion(&types.GetResourceVersionQuery{
+		Identifier: promo.source,
+		Version:    version,
+	})
+	if err != nil {
+		if err != store.ErrRecordNotFound {
+			log.WithFields(log.Fields{
+				"error":   err,
+				"source":  promo.source,
+				"version": version,
+			}).Error("provider.kubernetes: failed to get source version")
+		}
+		return false
+	}
+
+	return existing.Approved && existing.Soaked(promo.soak, time.Now())
+}
+
+// watchPromotions - periodically records health of promotion sources and
+// submits events for versions that soaked
+func (p *Provider) watchPromotions() {
+	ticker := time.NewTicker(promotionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.checkPromotions()
+		}
+	}
+}
+
+func (p *Provider) checkPromotions() {
+	indexed := p.cache.Indexed()
+
+	byIdentifier := make(map[string]*k8s.IndexedResource, len(indexed))
+	for _, ir := range indexed {
+		byIdentifier[ir.Resource.Identifier] = ir
+	}
+
+	targets := map[*k8s.IndexedResource]*promotion{}
+	sources := map[string]*k8s.IndexedResource{}
+	for _, ir := range indexed {
+		promo, err := getPromotion(ir.Resource, indexed)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"identifier": ir.Resource.Identifier,
+			}).Error("provider.kubernetes: failed to get promotion source")
+			continue
+		}
+		if promo == nil {
+			continue
+		}
+		targets[ir] = promo
+		if source, ok := byIdentifier[promo.source]; ok {
+			sources[promo.source] = source
+		}
+	}
+
+	for _, source := range sources {
+		p.observeVersions(source)
+	}
+
+	for target, promo := range targets {
+		p.promote(target, promo)
+	}
+}
+
+// observeVersions - records versions running in the resource together with
+// their health, replaced versions are retired
+func (p *Provider) observeVersions(ir *k8s.IndexedResource) {
+	resource := ir.Resource
+	healthy := resource.RolloutComplete()
+	now := time.Now()
+
+	minApprovals, _ := getInt(types.QuillaMinimumApprovalsLabel, resource.GetLabels(), resource.GetAnnotations())
+
+	running := map[string]bool{}
+	for _, c := range ir.Containers {
+		version := c.Ref.Tag()
+		if running[version] {
+			continue
+		}
+		running[version] = true
+
+		existing, err := p.versions.GetResourceVersion(&types.GetResourceVersionQuery{
+			Identifier: resource.Identifier,
+			Version:    version,
+		})
+		if err == store.ErrRecordNotFound {
+			// version wasn't applied by quilla, it's only approved when
+			// resource doesn't require approvals
+			existing, err = p.versions.CreateResourceVersion(&types.ResourceVersion{
+				Identifier: resource.Identifier,
+				Version:    version,
+				Image:      c.Image,
+				Approved:   minApprovals == 0,
+			})
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"identifier": resource.Identifier,
+				"version":    version,
+			}).Error("provider.kubernetes: failed to record resource version")
+			continue
+		}
+
+		changed := false
+		switch {
+		case !existing.RetiredAt.IsZero():
+			// version was rolled out again
+			existing.RetiredAt = time.Time{}
+			existing.HealthySince = time.Time{}
+			changed = true
+		case healthy && existing.HealthySince.IsZero():
+			existing.HealthySince = now
+			changed = true
+		case !healthy && !existing.HealthySince.IsZero():
+			// soak period starts again once resource is healthy
+			existing.HealthySince = time.Time{}
+			changed = true
+		}
+		if changed {
+			p.saveResourceVersion(existing)
+		}
+	}
+
+	versions, err := p.versions.ListResourceVersions(&types.GetResourceVersionQuery{Identifier: resource.Identifier})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": resource.Identifier,
+		}).Error("provider.kubernetes: failed to list resource versions")
+		return
+	}
+	for _, v := range versions {
+		if running[v.Version] || !v.RetiredAt.IsZero() {
+			continue
+		}
+		v.RetiredAt = now
+		p.saveResourceVersion(v)
+	}
+}
+
+// promote - submits event for the newest version that can be promoted
+func (p *Provider) promote(target *k8s.IndexedResource, promo *promotion) {
+	versions, err := p.versions.ListResourceVersions(&types.GetResourceVersionQuery{Identifier: promo.source})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err,
+			"source": promo.source,
+		}).Error("provider.kubernetes: failed to list source versions")
+		return
+	}
+
+	now := time.Now()
+	for _, v := range versions {
+		if !v.Approved || !v.Soaked(promo.soak, now) {
+			continue
+		}
+
+		ref, err := image.Parse(v.Image)
+		if err != nil {
+			return
+		}
+
+		// nothing to do if target already runs this version
+		for _, c := range target.Containers {
+			if c.Ref.Repository() == ref.Repository() && c.Ref.Tag() == v.Version {
+				return
+			}
+		}
+
+		event := &types.Event{
+			Repository:  types.Repository{Name: ref.Repository(), Tag: v.Version},
+			TriggerName: types.TriggerTypePromotion.String(),
+			CreatedAt:   now,
+		}
+		err = p.submit(event)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":   err,
+				"source":  promo.source,
+				"target":  target.Resource.Identifier,
+				"version": v.Version,
+			}).Error("provider.kubernetes: failed to promote version")
+		}
+		return
+	}
+}
+
+// recordUpdate - records versions applied by quilla, they passed
+// approvals and gates of the resource
+func (p *Provider) recordUpdate(plan *UpdatePlan) {
+	if p.versions == nil {
+		return
+	}
+
+	for _, change := range plan.Changes {
+		existing, err := p.versions.GetResourceVersion(&types.GetResourceVersionQuery{
+			Identifier: plan.Resource.Identifier,
+			Version:    change.NewVersion,
+		})
+		switch {
+		case err == store.ErrRecordNotFound:
+			_, err = p.versions.CreateResourceVersion(&types.ResourceVersion{
+				Identifier:   plan.Resource.Identifier,
+				Version:      change.NewVersion,
+				Image:        change.Image,
+				Approved:     true,
+				PromotedFrom: plan.PromotedFrom,
+			})
+		case err == nil:
+			existing.Approved = true
+			existing.RetiredAt = time.Time{}
+			existing.HealthySince = time.Time{}
+			existing.PromotedFrom = plan.PromotedFrom
+			err = p.versions.UpdateResourceVersion(existing)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":      err,
+				"identifier": plan.Resource.Identifier,
+				"version":    change.NewVersion,
+			}).Error("provider.kubernetes: failed to record updated version")
+		}
+	}
+}
+
+func (p *Provider) saveResourceVersion(v *types.ResourceVersion) {
+	err := p.versions.UpdateResourceVersion(v)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": v.Identifier,
+			"version":    v.Version,
+		}).Error("provider.kubernetes: failed to update resource version")
+	}
+}
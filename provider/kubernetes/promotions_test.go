@@ -0,0 +1,222 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func promotionDeployment(namespace, tag string, annotations map[string]string, ready bool) *apps_v1.Deployment {
+	replicas := int32(1)
+	dep := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        "dep-1",
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: "app", Image: "gcr.io/v2-namespace/hello-world:" + tag},
+					},
+				},
+			},
+		},
+	}
+	if ready {
+		dep.Status = apps_v1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	}
+	return dep
+}
+
+// backdate - moves start of the healthy period of the source version
+func backdate(t *testing.T, provider *Provider, identifier, version string, d time.Duration) {
+	v, err := provider.versions.GetResourceVersion(&types.GetResourceVersionQuery{Identifier: identifier, Version: version})
+	if err != nil {
+		t.Fatalf("failed to get resource version: %s", err)
+	}
+	if v.HealthySince.IsZero() {
+		t.Fatalf("expected version %s to be healthy", version)
+	}
+	v.HealthySince = v.HealthySince.Add(-d)
+	err = provider.versions.UpdateResourceVersion(v)
+	if err != nil {
+		t.Fatalf("failed to update resource version: %s", err)
+	}
+}
+
+func TestGetPromotion(t *testing.T) {
+	staging := MustParseGR(promotionDeployment("staging", "1.1.2", nil, true))
+	grc := &k8s.GenericResourceCache{}
+	grc.Add(staging)
+
+	tests := []struct {
+		name       string
+		value      string
+		soak       string
+		wantSource string
+		wantSoak   time.Duration
+		wantErr    bool
+	}{
+		{name: "namespace and name", value: "staging/dep-1", wantSource: "deployment/staging/dep-1", wantSoak: DefaultPromotionSoak},
+		{name: "identifier", value: "statefulset/staging/db", soak: "30m", wantSource: "statefulset/staging/db", wantSoak: 30 * time.Minute},
+		{name: "missing source", value: "staging/other", wantErr: true},
+		{name: "self", value: "production/dep-1", wantErr: true},
+		{name: "invalid", value: "dep-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := map[string]string{types.QuillaPromoteFromAnnotation: tt.value}
+			if tt.soak != "" {
+				annotations[types.QuillaPromoteSoakAnnotation] = tt.soak
+			}
+			target := MustParseGR(promotionDeployment("production", "1.1.1", annotations, true))
+			if tt.name == "self" {
+				grc.Add(target)
+			}
+
+			promo, err := getPromotion(target, grc.Indexed())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getPromotion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if promo.source != tt.wantSource || promo.soak != tt.wantSoak {
+				t.Errorf("unexpected promotion: %+v", promo)
+			}
+		})
+	}
+}
+
+func TestPromotionAfterSoak(t *testing.T) {
+	fi := &fakeImplementer{}
+	sender := &fakeSender{}
+	staging := promotionDeployment("staging", "1.1.2", nil, true)
+	production := promotionDeployment("production", "1.1.1", map[string]string{
+		types.QuillaPolicyLabel:           "all",
+		types.QuillaPromoteFromAnnotation: "staging/dep-1",
+		types.QuillaPromoteSoakAnnotation: "1h",
+	}, true)
+	provider, teardown := newTestingProvider(t, fi, sender, staging, production)
+	defer teardown()
+
+	// new tag doesn't reach production before it ran in staging
+	submitVersion(t, provider, "1.1.2")
+	if fi.updated != nil {
+		t.Fatalf("production shouldn't be updated before version soaked in staging")
+	}
+
+	provider.checkPromotions()
+	if fi.updated != nil {
+		t.Fatalf("production shouldn't be updated during soak period")
+	}
+
+	backdate(t, provider, "deployment/staging/dep-1", "1.1.2", 2*time.Hour)
+	provider.checkPromotions()
+
+	if fi.updated == nil {
+		t.Fatalf("expected production to be updated after soak period")
+	}
+	if fi.updated.Identifier != "deployment/production/dep-1" {
+		t.Errorf("unexpected resource updated: %s", fi.updated.Identifier)
+	}
+	if fi.updated.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.2" {
+		t.Errorf("unexpected image: %s", fi.updated.Containers()[0].Image)
+	}
+	if !strings.Contains(sender.sentEvent.Message, "promoted from deployment/staging/dep-1") {
+		t.Errorf("expected lineage in notification, got: %s", sender.sentEvent.Message)
+	}
+	if sender.sentEvent.Metadata["promotedFrom"] != "deployment/staging/dep-1" {
+		t.Errorf("expected lineage in notification metadata, got: %v", sender.sentEvent.Metadata)
+	}
+
+	promoted, err := provider.versions.GetResourceVersion(&types.GetResourceVersionQuery{Identifier: "deployment/production/dep-1", Version: "1.1.2"})
+	if err != nil {
+		t.Fatalf("failed to get promoted version: %s", err)
+	}
+	if !promoted.Approved || promoted.PromotedFrom != "deployment/staging/dep-1" {
+		t.Errorf("unexpected promoted version: %+v", promoted)
+	}
+}
+
+func TestPromotionRequiresSourceApprovals(t *testing.T) {
+	fi := &fakeImplementer{}
+	// version was deployed to staging manually, bypassing required approvals
+	staging := promotionDeployment("staging", "1.1.2", map[string]string{
+		types.QuillaMinimumApprovalsLabel: "1",
+	}, true)
+	production := promotionDeployment("production", "1.1.1", map[string]string{
+		types.QuillaPolicyLabel:           "all",
+		types.QuillaPromoteFromAnnotation: "staging/dep-1",
+	}, true)
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{}, staging, production)
+	defer teardown()
+
+	provider.checkPromotions()
+	backdate(t, provider, "deployment/staging/dep-1", "1.1.2", 2*time.Hour)
+	provider.checkPromotions()
+
+	if fi.updated != nil {
+		t.Fatalf("version that didn't pass source approvals shouldn't be promoted")
+	}
+}
+
+func TestPromotionSoakRestartsWhenUnhealthy(t *testing.T) {
+	fi := &fakeImplementer{}
+	grc := &k8s.GenericResourceCache{}
+	grc.Add(MustParseGR(promotionDeployment("staging", "1.1.2", nil, true)))
+	grc.Add(MustParseGR(promotionDeployment("production", "1.1.1", map[string]string{
+		types.QuillaPolicyLabel:           "all",
+		types.QuillaPromoteFromAnnotation: "staging/dep-1",
+	}, true)))
+
+	approver, teardown := approver()
+	defer teardown()
+	store, storeTeardown := NewTestingUtils()
+	defer storeTeardown()
+
+	provider, err := NewProvider(fi, &fakeSender{}, approver, grc)
+	if err != nil {
+		t.Fatalf("failed to get provider: %s", err)
+	}
+	provider.SetVersionStore(store)
+
+	provider.checkPromotions()
+
+	// staging becomes unhealthy
+	grc.Add(MustParseGR(promotionDeployment("staging", "1.1.2", nil, false)))
+	provider.checkPromotions()
+
+	v, err := store.GetResourceVersion(&types.GetResourceVersionQuery{Identifier: "deployment/staging/dep-1", Version: "1.1.2"})
+	if err != nil {
+		t.Fatalf("failed to get resource version: %s", err)
+	}
+	if !v.HealthySince.IsZero() {
+		t.Errorf("expected soak period to restart")
+	}
+
+	// staging rolls out new version, previous one is retired
+	grc.Add(MustParseGR(promotionDeployment("staging", "1.1.3", nil, true)))
+	provider.checkPromotions()
+
+	v, err = store.GetResourceVersion(&types.GetResourceVersionQuery{Identifier: "deployment/staging/dep-1", Version: "1.1.2"})
+	if err != nil {
+		t.Fatalf("failed to get resource version: %s", err)
+	}
+	if v.RetiredAt.IsZero() {
+		t.Errorf("expected replaced version to be retired")
+	}
+	if fi.updated != nil {
+		t.Errorf("production shouldn't be updated")
+	}
+}
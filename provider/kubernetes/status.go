@@ -0,0 +1,370 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quilla-hq/quilla/internal/k8s"
+	"github.com/quilla-hq/quilla/types"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/reference"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StatusMode - where update status of tracked resources is written
+type StatusMode string
+
+// Available status modes, status isn't written when mode is empty
+const (
+	StatusModeNone        StatusMode = ""
+	StatusModeAnnotations StatusMode = "annotations"
+	StatusModeConfigMap   StatusMode = "configmap"
+)
+
+// ParseStatusMode - parses status writeback mode
+func ParseStatusMode(value string) (StatusMode, error) {
+	switch mode := StatusMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case StatusModeNone, StatusModeAnnotations, StatusModeConfigMap:
+		return mode, nil
+	}
+	return StatusModeNone, fmt.Errorf("unknown status mode: %s", value)
+}
+
+// DefaultStatusInterval - minimum time between status writes of a resource
+const DefaultStatusInterval = 30 * time.Second
+
+// statusFlushInterval - how often status changes held back by the interval are written
+var statusFlushInterval = 10 * time.Second
+
+// eventSource - component of events emitted on workloads
+const eventSource = "quilla"
+
+// Event reasons
+const (
+	eventReasonUpdated           = "Updated"
+	eventReasonUpdateFailed      = "UpdateFailed"
+	eventReasonApprovalRequested = "ApprovalRequested"
+	eventReasonApproved          = "Approved"
+	eventReasonRejected          = "ApprovalRejected"
+	eventReasonApprovalDrifted   = "ApprovalDrifted"
+	eventReasonGatePassed        = "GatePassed"
+	eventReasonGateFailed        = "GateFailed"
+)
+
+// StatusWriteback - keeps update status on tracked resources so it's visible with kubectl
+type StatusWriteback struct {
+	Mode StatusMode
+	// Events - emit events on workloads for updates, failures, approvals and gate results
+	Events bool
+	// Interval - minimum time between status writes and identical events of a resource
+	Interval time.Duration
+}
+
+// SetStatusWriteback - enables status writeback and events
+func (p *Provider) SetStatusWriteback(opts StatusWriteback) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultStatusInterval
+	}
+	p.status.mu.Lock()
+	defer p.status.mu.Unlock()
+	p.status.opts = opts
+}
+
+type trackedStatus struct {
+	resource  *k8s.GenericResource
+	status    types.ResourceStatus
+	dirty     bool
+	writtenAt time.Time
+}
+
+// statusWriter - holds status of tracked resources until it can be written
+type statusWriter struct {
+	mu   sync.Mutex
+	opts StatusWriteback
+
+	statuses map[string]*trackedStatus
+	// events - when identical event of a resource was last emitted
+	events map[string]time.Time
+}
+
+// setStatus - updates resource status, status is written right away unless
+// it was written within the interval
+func (p *Provider) setStatus(resource *k8s.GenericResource, update func(s *types.ResourceStatus)) {
+	w := &p.status
+	w.mu.Lock()
+	if w.opts.Mode == StatusModeNone {
+		w.mu.Unlock()
+		return
+	}
+	if w.statuses == nil {
+		w.statuses = make(map[string]*trackedStatus)
+	}
+	tracked, ok := w.statuses[resource.Identifier]
+	if !ok {
+		tracked = &trackedStatus{}
+		w.statuses[resource.Identifier] = tracked
+	}
+	tracked.resource = resource
+	update(&tracked.status)
+	tracked.dirty = true
+
+	now := time.Now()
+	if now.Sub(tracked.writtenAt) < w.opts.Interval {
+		w.mu.Unlock()
+		return
+	}
+	tracked.dirty = false
+	tracked.writtenAt = now
+	mode, status := w.opts.Mode, tracked.status
+	w.mu.Unlock()
+
+	p.writeStatus(mode, resource, &status)
+}
+
+// watchStatus - periodically writes status changes held back by the interval
+func (p *Provider) watchStatus() {
+	ticker := time.NewTicker(statusFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.flushStatus()
+		}
+	}
+}
+
+func (p *Provider) flushStatus() {
+	type pending struct {
+		resource *k8s.GenericResource
+		status   types.ResourceStatus
+	}
+
+	w := &p.status
+	w.mu.Lock()
+	mode := w.opts.Mode
+	now := time.Now()
+	var writes []pending
+	for _, tracked := range w.statuses {
+		if !tracked.dirty || now.Sub(tracked.writtenAt) < w.opts.Interval {
+			continue
+		}
+		tracked.dirty = false
+		tracked.writtenAt = now
+		writes = append(writes, pending{resource: tracked.resource, status: tracked.status})
+	}
+	w.mu.Unlock()
+
+	for _, write := range writes {
+		p.writeStatus(mode, write.resource, &write.status)
+	}
+}
+
+func (p *Provider) writeStatus(mode StatusMode, resource *k8s.GenericResource, status *types.ResourceStatus) {
+	var err error
+	switch mode {
+	case StatusModeAnnotations:
+		annotations := make(map[string]string)
+		for k, v := range status.Data() {
+			annotations[types.QuillaStatusAnnotationPrefix+k] = v
+		}
+		err = p.implementer.Annotate(resource, annotations)
+	case StatusModeConfigMap:
+		err = p.writeStatusConfigMap(resource, status)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": resource.Identifier,
+			"mode":       mode,
+		}).Error("provider.kubernetes: failed to write resource status")
+	}
+}
+
+// statusConfigMapName - name of the config map that holds status of the resource
+func statusConfigMapName(resource *k8s.GenericResource) string {
+	name := fmt.Sprintf("quilla-status-%s-%s", resource.Kind(), resource.Name)
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return strings.TrimRight(name, "-.")
+}
+
+func (p *Provider) writeStatusConfigMap(resource *k8s.GenericResource, status *types.ResourceStatus) error {
+	configMaps := p.implementer.ConfigMaps(resource.Namespace)
+	if configMaps == nil {
+		return fmt.Errorf("config maps are not available")
+	}
+
+	data := make(map[string]string)
+	for k, v := range status.Data() {
+		if v != "" {
+			data[k] = v
+		}
+	}
+
+	name := statusConfigMapName(resource)
+	existing, err := configMaps.Get(context.TODO(), name, meta_v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = configMaps.Create(context.TODO(), &v1.ConfigMap{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      name,
+				Namespace: resource.Namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "quilla",
+				},
+				Annotations: map[string]string{
+					types.QuillaStatusAnnotationPrefix + "resource": resource.Identifier,
+				},
+			},
+			Data: data,
+		}, meta_v1.CreateOptions{FieldManager: FieldManager})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	existing.Data = data
+	_, err = configMaps.Update(context.TODO(), existing, meta_v1.UpdateOptions{FieldManager: FieldManager})
+	return err
+}
+
+// recordEvent - emits event on the workload, identical events of the resource
+// aren't repeated within the interval
+func (p *Provider) recordEvent(resource *k8s.GenericResource, eventType, reason, message string) {
+	w := &p.status
+	w.mu.Lock()
+	if !w.opts.Events {
+		w.mu.Unlock()
+		return
+	}
+	if w.events == nil {
+		w.events = make(map[string]time.Time)
+	}
+	now := time.Now()
+	key := resource.Identifier + "/" + reason + "/" + message
+	if now.Sub(w.events[key]) < w.opts.Interval {
+		w.mu.Unlock()
+		return
+	}
+	for k, emitted := range w.events {
+		if now.Sub(emitted) >= w.opts.Interval {
+			delete(w.events, k)
+		}
+	}
+	w.events[key] = now
+	w.mu.Unlock()
+
+	err := p.createEvent(resource, eventType, reason, message, now)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": resource.Identifier,
+			"reason":     reason,
+		}).Error("provider.kubernetes: failed to create event")
+	}
+}
+
+func (p *Provider) createEvent(resource *k8s.GenericResource, eventType, reason, message string, now time.Time) error {
+	obj, ok := resource.GetResource().(runtime.Object)
+	if !ok {
+		return fmt.Errorf("unsupported object type")
+	}
+	ref, err := reference.GetReference(scheme.Scheme, obj)
+	if err != nil {
+		return err
+	}
+
+	events := p.implementer.Events(resource.Namespace)
+	if events == nil {
+		return fmt.Errorf("events are not available")
+	}
+
+	timestamp := meta_v1.NewTime(now)
+	_, err = events.Create(context.TODO(), &v1.Event{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", resource.Name, now.UnixNano()),
+			Namespace: resource.Namespace,
+		},
+		InvolvedObject: *ref,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: eventSource},
+		FirstTimestamp: timestamp,
+		LastTimestamp:  timestamp,
+		Count:          1,
+	}, meta_v1.CreateOptions{})
+	return err
+}
+
+// approvalStatus - records approval of the plan and its votes
+func (p *Provider) approvalStatus(plan *UpdatePlan, approval *types.Approval) {
+	p.setStatus(plan.Resource, func(s *types.ResourceStatus) {
+		s.ApprovalID = approval.Identifier
+		s.VotesReceived = approval.VotesReceived
+		s.VotesRequired = approval.VotesRequired
+	})
+
+	switch approval.Status() {
+	case types.ApprovalStatusPending:
+		p.recordEvent(plan.Resource, v1.EventTypeNormal, eventReasonApprovalRequested,
+			fmt.Sprintf("Update %s is waiting for approval %s, votes %d/%d", plan.delta(), approval.Identifier, approval.VotesReceived, approval.VotesRequired))
+	case types.ApprovalStatusApproved:
+		p.recordEvent(plan.Resource, v1.EventTypeNormal, eventReasonApproved, fmt.Sprintf("Update %s was approved", plan.delta()))
+	case types.ApprovalStatusRejected:
+		p.recordEvent(plan.Resource, v1.EventTypeWarning, eventReasonRejected, fmt.Sprintf("Update %s was rejected", plan.delta()))
+	}
+}
+
+// gateStatus - records gate state of the plan
+func (p *Provider) gateStatus(plan *UpdatePlan, run *types.Gate) {
+	state := fmt.Sprintf("%s %s", run.Version, run.StatusString())
+	if run.Status == types.GateStatusFailed && run.Message != "" {
+		state = fmt.Sprintf("%s: %s", state, run.Message)
+	}
+	p.setStatus(plan.Resource, func(s *types.ResourceStatus) {
+		s.Gate = state
+	})
+
+	switch run.Status {
+	case types.GateStatusSucceeded:
+		p.recordEvent(plan.Resource, v1.EventTypeNormal, eventReasonGatePassed, fmt.Sprintf("Gate for version %s passed", run.Version))
+	case types.GateStatusFailed:
+		p.recordEvent(plan.Resource, v1.EventTypeWarning, eventReasonGateFailed, fmt.Sprintf("Gate for version %s failed: %s", run.Version, run.Message))
+	}
+}
+
+// updateStatus - records outcome of the applied plan
+func (p *Provider) updateStatus(plan *UpdatePlan, ok bool, failure string) {
+	resource := plan.Resource
+	p.setStatus(resource, func(s *types.ResourceStatus) {
+		if ok {
+			s.LastOutcome = types.HistoryOutcomeSucceeded
+			s.Error = ""
+			s.ApprovalID = ""
+			s.VotesReceived, s.VotesRequired = 0, 0
+			return
+		}
+		s.LastOutcome = types.HistoryOutcomeFailed
+		s.Error = failure
+	})
+
+	if ok {
+		p.recordEvent(resource, v1.EventTypeNormal, eventReasonUpdated, fmt.Sprintf("Updated %s", plan.delta()))
+		return
+	}
+	p.recordEvent(resource, v1.EventTypeWarning, eventReasonUpdateFailed, fmt.Sprintf("Update %s failed: %s", plan.delta(), failure))
+}
@@ -0,0 +1,143 @@
+package kubernetes
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/types"
+
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func listEvents(t *testing.T, fi *fakeImplementer, namespace string) []v1.Event {
+	events, err := fi.client.CoreV1().Events(namespace).List(context.TODO(), meta_v1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list events: %s", err)
+	}
+	return events.Items
+}
+
+func TestStatusAnnotations(t *testing.T) {
+	fi := &fakeImplementer{client: fake.NewSimpleClientset()}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		limitedDeployment("shop", "a", nil, true),
+	)
+	defer teardown()
+	provider.SetStatusWriteback(StatusWriteback{Mode: StatusModeAnnotations, Events: true, Interval: time.Nanosecond})
+
+	submitVersion(t, provider, "1.1.2")
+
+	status := fi.annotated["deployment/shop/a"]
+	if status[types.QuillaStatusAnnotationPrefix+"outcome"] != "succeeded" {
+		t.Errorf("unexpected outcome: %v", status)
+	}
+	if status[types.QuillaStatusAnnotationPrefix+"newestVersion"] != "1.1.2" {
+		t.Errorf("unexpected newest version: %v", status)
+	}
+	if status[types.QuillaStatusAnnotationPrefix+"lastCheck"] == "" {
+		t.Errorf("expected last check time to be set")
+	}
+	if _, ok := status[types.QuillaStatusAnnotationPrefix+"approval"]; ok {
+		t.Errorf("resource without approvals shouldn't have approval status")
+	}
+
+	events := listEvents(t, fi, "shop")
+	if len(events) != 1 {
+		t.Fatalf("expected a single event, got: %d", len(events))
+	}
+	if events[0].Reason != eventReasonUpdated || events[0].InvolvedObject.Kind != "Deployment" || events[0].InvolvedObject.Name != "a" {
+		t.Errorf("unexpected event: %+v", events[0])
+	}
+}
+
+func TestStatusRateLimited(t *testing.T) {
+	fi := &fakeImplementer{client: fake.NewSimpleClientset(), failUpdate: "deployment/shop/a"}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		limitedDeployment("shop", "a", nil, true),
+	)
+	defer teardown()
+	provider.SetStatusWriteback(StatusWriteback{Mode: StatusModeAnnotations, Events: true, Interval: time.Hour})
+
+	for i := 0; i < 2; i++ {
+		provider.Submit(types.Event{
+			Repository: types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.2"},
+		})
+	}
+
+	// only the check before the first update was written
+	status := fi.annotated["deployment/shop/a"]
+	if status[types.QuillaStatusAnnotationPrefix+"lastCheck"] == "" {
+		t.Fatalf("expected first status to be written")
+	}
+	if _, ok := status[types.QuillaStatusAnnotationPrefix+"outcome"]; ok {
+		t.Errorf("status shouldn't be written again within the interval")
+	}
+
+	events := listEvents(t, fi, "shop")
+	if len(events) != 1 || events[0].Reason != eventReasonUpdateFailed || events[0].Type != v1.EventTypeWarning {
+		t.Fatalf("expected a single failure event, got: %+v", events)
+	}
+
+	provider.flushStatus()
+	if _, ok := fi.annotated["deployment/shop/a"][types.QuillaStatusAnnotationPrefix+"outcome"]; ok {
+		t.Errorf("status shouldn't be flushed within the interval")
+	}
+
+	provider.status.statuses["deployment/shop/a"].writtenAt = time.Now().Add(-2 * time.Hour)
+	provider.flushStatus()
+
+	status = fi.annotated["deployment/shop/a"]
+	if status[types.QuillaStatusAnnotationPrefix+"outcome"] != "failed" {
+		t.Errorf("expected held back outcome to be flushed, got: %v", status)
+	}
+	if !strings.Contains(status[types.QuillaStatusAnnotationPrefix+"error"], "failed to update") {
+		t.Errorf("unexpected error: %s", status[types.QuillaStatusAnnotationPrefix+"error"])
+	}
+}
+
+func TestStatusConfigMap(t *testing.T) {
+	fi := &fakeImplementer{client: fake.NewSimpleClientset()}
+	provider, teardown := newTestingProvider(t, fi, &fakeSender{},
+		limitedDeployment("shop", "a", map[string]string{types.QuillaMinimumApprovalsLabel: "2"}, true),
+	)
+	defer teardown()
+	provider.SetStatusWriteback(StatusWriteback{Mode: StatusModeConfigMap, Events: true, Interval: time.Nanosecond})
+
+	submitVersion(t, provider, "1.1.2")
+
+	cm, err := fi.client.CoreV1().ConfigMaps("shop").Get(context.TODO(), "quilla-status-deployment-a", meta_v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get status config map: %s", err)
+	}
+	if cm.Data["approval"] != getApprovalIdentifier("deployment/shop/a", "1.1.2") || cm.Data["votes"] != "0/2" {
+		t.Errorf("unexpected approval status: %v", cm.Data)
+	}
+	if len(fi.annotated) != 0 {
+		t.Errorf("resource shouldn't be annotated in config map mode")
+	}
+
+	events := listEvents(t, fi, "shop")
+	if len(events) != 1 || events[0].Reason != eventReasonApprovalRequested {
+		t.Fatalf("expected approval requested event, got: %+v", events)
+	}
+}
+
+func TestParseStatusMode(t *testing.T) {
+	for value, want := range map[string]StatusMode{
+		"":            StatusModeNone,
+		"annotations": StatusModeAnnotations,
+		"ConfigMap":   StatusModeConfigMap,
+	} {
+		got, err := ParseStatusMode(value)
+		if err != nil || got != want {
+			t.Errorf("ParseStatusMode(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseStatusMode("crd"); err == nil {
+		t.Errorf("expected unknown mode to fail")
+	}
+}
@@ -27,6 +27,8 @@ func checkForUpdate(plc policy.Policy, repo *types.Repository, resource *k8s.Gen
 		"policy":    plc.Name(),
 	}).Debug("provider.kubernetes.checkVersionedDeployment: quilla policy found, checking resource...")
 	shouldUpdateDeployment = false
+	labels := resource.GetLabels()
+	annotations := resource.GetAnnotations()
 	if schedule, ok := resource.GetAnnotations()[types.QuillaInitContainerAnnotation]; ok && schedule == "true" {
 		for idx, c := range resource.InitContainers() {
 			containerImageRef, err := image.Parse(c.Image)
@@ -57,13 +59,14 @@ func checkForUpdate(plc policy.Policy, repo *types.Repository, resource *k8s.Gen
 				continue
 			}
 
-			shouldUpdateContainer, err := plc.ShouldUpdate(containerImageRef.Tag(), eventRepoRef.Tag())
+			containerPolicy := policy.GetContainerPolicy(c.Name, plc, labels, annotations)
+			shouldUpdateContainer, err := containerPolicy.ShouldUpdate(containerImageRef.Tag(), eventRepoRef.Tag())
 			if err != nil {
 				log.WithFields(log.Fields{
 					"error":             err,
 					"parsed_image_name": containerImageRef.Remote(),
 					"target_image_name": repo.Name,
-					"policy":            plc.Name(),
+					"policy":            containerPolicy.Name(),
 				}).Error("provider.kubernetes: failed to check whether init container should be updated")
 				continue
 			}
@@ -76,17 +79,27 @@ func checkForUpdate(plc policy.Policy, repo *types.Repository, resource *k8s.Gen
 			setUpdateTime(resource)
 
 			// updating image
+			var newImage string
 			if containerImageRef.Registry() == image.DefaultRegistryHostname {
-				resource.UpdateInitContainer(idx, fmt.Sprintf("%s:%s", containerImageRef.ShortName(), repo.Tag))
+				newImage = fmt.Sprintf("%s:%s", containerImageRef.ShortName(), repo.Tag)
 			} else {
-				resource.UpdateInitContainer(idx, fmt.Sprintf("%s:%s", containerImageRef.Repository(), repo.Tag))
+				newImage = fmt.Sprintf("%s:%s", containerImageRef.Repository(), repo.Tag)
 			}
+			resource.UpdateInitContainer(idx, newImage)
 
 			shouldUpdateDeployment = true
 
 			updatePlan.CurrentVersion = containerImageRef.Tag()
 			updatePlan.NewVersion = repo.Tag
 			updatePlan.Resource = resource
+			updatePlan.Changes = append(updatePlan.Changes, types.ContainerChange{
+				Container:      c.Name,
+				Init:           true,
+				Image:          newImage,
+				PreviousImage:  c.Image,
+				CurrentVersion: containerImageRef.Tag(),
+				NewVersion:     repo.Tag,
+			})
 		}
 	}
 	for idx, c := range resource.Containers() {
@@ -118,13 +131,14 @@ func checkForUpdate(plc policy.Policy, repo *types.Repository, resource *k8s.Gen
 			continue
 		}
 
-		shouldUpdateContainer, err := plc.ShouldUpdate(containerImageRef.Tag(), eventRepoRef.Tag())
+		containerPolicy := policy.GetContainerPolicy(c.Name, plc, labels, annotations)
+		shouldUpdateContainer, err := containerPolicy.ShouldUpdate(containerImageRef.Tag(), eventRepoRef.Tag())
 		if err != nil {
 			log.WithFields(log.Fields{
 				"error":             err,
 				"parsed_image_name": containerImageRef.Remote(),
 				"target_image_name": repo.Name,
-				"policy":            plc.Name(),
+				"policy":            containerPolicy.Name(),
 			}).Error("provider.kubernetes: failed to check whether container should be updated")
 			continue
 		}
@@ -137,17 +151,26 @@ func checkForUpdate(plc policy.Policy, repo *types.Repository, resource *k8s.Gen
 		setUpdateTime(resource)
 
 		// updating image
+		var newImage string
 		if containerImageRef.Registry() == image.DefaultRegistryHostname {
-			resource.UpdateContainer(idx, fmt.Sprintf("%s:%s", containerImageRef.ShortName(), repo.Tag))
+			newImage = fmt.Sprintf("%s:%s", containerImageRef.ShortName(), repo.Tag)
 		} else {
-			resource.UpdateContainer(idx, fmt.Sprintf("%s:%s", containerImageRef.Repository(), repo.Tag))
+			newImage = fmt.Sprintf("%s:%s", containerImageRef.Repository(), repo.Tag)
 		}
+		resource.UpdateContainer(idx, newImage)
 
 		shouldUpdateDeployment = true
 
 		updatePlan.CurrentVersion = containerImageRef.Tag()
 		updatePlan.NewVersion = repo.Tag
 		updatePlan.Resource = resource
+		updatePlan.Changes = append(updatePlan.Changes, types.ContainerChange{
+			Container:      c.Name,
+			Image:          newImage,
+			PreviousImage:  c.Image,
+			CurrentVersion: containerImageRef.Tag(),
+			NewVersion:     repo.Tag,
+		})
 	}
 
 	return updatePlan, shouldUpdateDeployment, nil
@@ -104,6 +104,14 @@ func TestProvider_checkForUpdate(t *testing.T) {
 				}),
 				NewVersion:     "latest",
 				CurrentVersion: "latest",
+				Changes: types.ContainerChanges{
+					{
+						Image:          "gcr.io/v2-namespace/hello-world:latest",
+						PreviousImage:  "gcr.io/v2-namespace/hello-world",
+						CurrentVersion: "latest",
+						NewVersion:     "latest",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -240,6 +248,14 @@ func TestProvider_checkForUpdate(t *testing.T) {
 				}),
 				NewVersion:     "0.2.0",
 				CurrentVersion: "latest",
+				Changes: types.ContainerChanges{
+					{
+						Image:          "karolisr/quilla:0.2.0",
+						PreviousImage:  "karolisr/quilla:latest",
+						CurrentVersion: "latest",
+						NewVersion:     "0.2.0",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -307,6 +323,14 @@ func TestProvider_checkForUpdate(t *testing.T) {
 				}),
 				NewVersion:     "master",
 				CurrentVersion: "master",
+				Changes: types.ContainerChanges{
+					{
+						Image:          "karolisr/quilla:master",
+						PreviousImage:  "karolisr/quilla:master",
+						CurrentVersion: "master",
+						NewVersion:     "master",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -377,6 +401,14 @@ func TestProvider_checkForUpdate(t *testing.T) {
 				}),
 				NewVersion:     "latest-staging",
 				CurrentVersion: "latest-staging",
+				Changes: types.ContainerChanges{
+					{
+						Image:          "karolisr/quilla:latest-staging",
+						PreviousImage:  "karolisr/quilla:latest-staging",
+						CurrentVersion: "latest-staging",
+						NewVersion:     "latest-staging",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -448,6 +480,14 @@ func TestProvider_checkForUpdate(t *testing.T) {
 				}),
 				NewVersion:     "latest-staging",
 				CurrentVersion: "latest-staging",
+				Changes: types.ContainerChanges{
+					{
+						Image:          "eu.gcr.io/karolisr/quilla:latest-staging",
+						PreviousImage:  "eu.gcr.io/karolisr/quilla:latest-staging",
+						CurrentVersion: "latest-staging",
+						NewVersion:     "latest-staging",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -553,6 +593,14 @@ func TestProvider_checkForUpdate(t *testing.T) {
 				}),
 				NewVersion:     "latest-staging",
 				CurrentVersion: "latest-staging",
+				Changes: types.ContainerChanges{
+					{
+						Image:          "eu.gcr.io/karolisr/quilla:latest-staging",
+						PreviousImage:  "eu.gcr.io/karolisr/quilla:latest-staging",
+						CurrentVersion: "latest-staging",
+						NewVersion:     "latest-staging",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -623,6 +671,14 @@ func TestProvider_checkForUpdate(t *testing.T) {
 				}),
 				NewVersion:     "release-2",
 				CurrentVersion: "release-1",
+				Changes: types.ContainerChanges{
+					{
+						Image:          "eu.gcr.io/karolisr/quilla:release-2",
+						PreviousImage:  "eu.gcr.io/karolisr/quilla:release-1",
+						CurrentVersion: "release-1",
+						NewVersion:     "release-2",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -689,6 +745,15 @@ func TestProvider_checkForUpdate(t *testing.T) {
 				}),
 				NewVersion:     "latest",
 				CurrentVersion: "latest",
+				Changes: types.ContainerChanges{
+					{
+						Init:           true,
+						Image:          "gcr.io/v2-namespace/hello-world:latest",
+						PreviousImage:  "gcr.io/v2-namespace/hello-world",
+						CurrentVersion: "latest",
+						NewVersion:     "latest",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -837,6 +902,14 @@ func TestProvider_checkForUpdateSemver(t *testing.T) {
 				}),
 				NewVersion:     "1.1.2",
 				CurrentVersion: "1.1.1",
+				Changes: types.ContainerChanges{
+					{
+						Image:          "gcr.io/v2-namespace/hello-world:1.1.2",
+						PreviousImage:  "gcr.io/v2-namespace/hello-world:1.1.1",
+						CurrentVersion: "1.1.1",
+						NewVersion:     "1.1.2",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -1018,6 +1091,14 @@ func TestProvider_checkForUpdateSemver(t *testing.T) {
 				}),
 				NewVersion:     "1.1.2",
 				CurrentVersion: "1.1.1",
+				Changes: types.ContainerChanges{
+					{
+						Image:          "gcr.io/v2-namespace/hello-world:1.1.2",
+						PreviousImage:  "gcr.io/v2-namespace/hello-world:1.1.1",
+						CurrentVersion: "1.1.1",
+						NewVersion:     "1.1.2",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -1089,6 +1170,14 @@ func TestProvider_checkForUpdateSemver(t *testing.T) {
 				}),
 				NewVersion:     "1.1.2",
 				CurrentVersion: "latest",
+				Changes: types.ContainerChanges{
+					{
+						Image:          "gcr.io/v2-namespace/hello-world:1.1.2",
+						PreviousImage:  "gcr.io/v2-namespace/hello-world:latest",
+						CurrentVersion: "latest",
+						NewVersion:     "1.1.2",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -1166,6 +1255,14 @@ func TestProvider_checkForUpdateSemver(t *testing.T) {
 				}),
 				NewVersion:     "1.1.2",
 				CurrentVersion: "1.1.2",
+				Changes: types.ContainerChanges{
+					{
+						Image:          "gcr.io/v2-namespace/hello-world:1.1.2",
+						PreviousImage:  "gcr.io/v2-namespace/hello-world:1.1.2",
+						CurrentVersion: "1.1.2",
+						NewVersion:     "1.1.2",
+					},
+				},
 			},
 			wantShouldUpdateDeployment: true,
 			wantErr:                    false,
@@ -1246,3 +1343,84 @@ func TestProvider_checkForUpdateSemver(t *testing.T) {
 		})
 	}
 }
+
+func TestProvider_checkForUpdateContainerPolicies(t *testing.T) {
+	newResource := func() *k8s.GenericResource {
+		return MustParseGR(&apps_v1.Deployment{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "dep-1",
+				Namespace: "xxxx",
+				Annotations: map[string]string{
+					types.QuillaPolicyLabel:                      "patch",
+					types.QuillaContainerPolicyPrefix + "worker": "minor",
+					types.QuillaContainersAnnotation:             "app,worker",
+				},
+			},
+			Spec: apps_v1.DeploymentSpec{
+				Template: v1.PodTemplateSpec{
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{
+							{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+							{Name: "worker", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+							{Name: "sidecar", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	tests := []struct {
+		name        string
+		tag         string
+		wantChanges types.ContainerChanges
+		wantImages  []string
+	}{
+		{
+			name: "patch bump updates listed containers",
+			tag:  "1.1.2",
+			wantChanges: types.ContainerChanges{
+				{Container: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.2", PreviousImage: "gcr.io/v2-namespace/hello-world:1.1.1", CurrentVersion: "1.1.1", NewVersion: "1.1.2"},
+				{Container: "worker", Image: "gcr.io/v2-namespace/hello-world:1.1.2", PreviousImage: "gcr.io/v2-namespace/hello-world:1.1.1", CurrentVersion: "1.1.1", NewVersion: "1.1.2"},
+			},
+			wantImages: []string{
+				"gcr.io/v2-namespace/hello-world:1.1.2",
+				"gcr.io/v2-namespace/hello-world:1.1.2",
+				"gcr.io/v2-namespace/hello-world:1.1.1",
+			},
+		},
+		{
+			name: "minor bump only updates worker",
+			tag:  "1.2.0",
+			wantChanges: types.ContainerChanges{
+				{Container: "worker", Image: "gcr.io/v2-namespace/hello-world:1.2.0", PreviousImage: "gcr.io/v2-namespace/hello-world:1.1.1", CurrentVersion: "1.1.1", NewVersion: "1.2.0"},
+			},
+			wantImages: []string{
+				"gcr.io/v2-namespace/hello-world:1.1.1",
+				"gcr.io/v2-namespace/hello-world:1.2.0",
+				"gcr.io/v2-namespace/hello-world:1.1.1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := newResource()
+			plc := policy.GetPolicyFromLabelsOrAnnotations(resource.GetLabels(), resource.GetAnnotations())
+			repo := &types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: tt.tag}
+
+			plan, shouldUpdate, err := checkForUpdate(plc, repo, resource)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !shouldUpdate {
+				t.Fatalf("expected resource to be updated")
+			}
+			if !reflect.DeepEqual(plan.Changes, tt.wantChanges) {
+				t.Errorf("unexpected changes: %v, want: %v", plan.Changes, tt.wantChanges)
+			}
+			if !reflect.DeepEqual(resource.GetImages(), tt.wantImages) {
+				t.Errorf("unexpected images: %v, want: %v", resource.GetImages(), tt.wantImages)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/quilla-hq/quilla/approvals"
 	"github.com/quilla-hq/quilla/types"
@@ -11,18 +14,32 @@ import (
 
 // Provider - generic provider interface
 type Provider interface {
+	// Submit - processes event, returned error means that event should be retried
 	Submit(event types.Event) error
 	TrackedImages() ([]*types.TrackedImage, error)
 	GetName() string
 	Stop()
 }
 
+// ResourceResolver - optional provider interface, lists identifiers of resources
+// that track the repository so the queue can serialize events per resource
+type ResourceResolver interface {
+	Resources(repository string) []string
+}
+
 // Providers - available providers
 type Providers interface {
 	Submit(event types.Event) error
 	TrackedImages() ([]*types.TrackedImage, error)
 	List() []string // list all providers
 	Stop()          // stop all providers
+	// Rollback - reverts resource managed by the provider to a previous version
+	Rollback(provider string, req *types.RollbackRequest) error
+}
+
+// Rollbacker - provider that can revert resources to versions from their history
+type Rollbacker interface {
+	Rollback(req *types.RollbackRequest) error
 }
 
 // New - new providers registry
@@ -40,35 +57,76 @@ func New(providers []Provider, approvalsManager approvals.Manager) *DefaultProvi
 		stopCh:           make(chan struct{}),
 	}
 
-	// subscribing to approved events
-	// TODO: create Start() function for DefaultProviders
-	go dp.subscribeToApproved()
-
 	return dp
 }
 
+// Start - starts subscribing for approved events. EnableQueue has to be called
+// before, approvals replayed from the outbox are then submitted through the queue
+func (p *DefaultProviders) Start() {
+	p.startOnce.Do(func() {
+		go p.subscribeToApproved()
+	})
+}
+
 // DefaultProviders - default providers container
 type DefaultProviders struct {
 	providers        map[string]Provider
 	approvalsManager approvals.Manager
 	stopCh           chan struct{}
+	startOnce        sync.Once
+	stopOnce         sync.Once
+
+	// optional, when set events are submitted through the queue
+	queue *Queue
+}
+
+// EnableQueue - starts event queue, events submitted after this call are coalesced
+// and processed by queue workers instead of being passed to providers directly
+func (p *DefaultProviders) EnableQueue(opts *QueueOpts) error {
+	if opts.Resources == nil {
+		opts.Resources = p.resources
+	}
+	queue := NewQueue(p.dispatch, opts)
+	err := queue.Start()
+	if err != nil {
+		return err
+	}
+	p.queue = queue
+	return nil
 }
 
+// subscribeRetryInterval - how often subscribing for approved events is retried
+const subscribeRetryInterval = 5 * time.Second
+
 func (p *DefaultProviders) subscribeToApproved() {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	approvedCh, err := p.approvalsManager.SubscribeApproved(ctx)
-	if err != nil {
+	approvedCh, err := p.approvalsManager.SubscribeApproved(ctx, "providers")
+	// subscribing needs the store, retrying until it's available
+	for err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
-		}).Fatal("provider.subscribeToApproved: failed to subscribe for approved reqs")
+		}).Error("provider.subscribeToApproved: failed to subscribe for approved reqs, retrying")
+		select {
+		case <-time.After(subscribeRetryInterval):
+		case <-p.stopCh:
+			cancel()
+			return
+		}
+		approvedCh, err = p.approvalsManager.SubscribeApproved(ctx, "providers")
 	}
 
 	for {
 		select {
 		case approval := <-approvedCh:
+			// approved events are delivered again after restart until acknowledged,
+			// submitted events are persisted by the queue
 			approval.Event.TriggerName = types.TriggerTypeApproval.String()
-			p.Submit(*approval.Event)
+			if !p.submitApproved(approval) {
+				cancel()
+				return
+			}
+			approval.Ack()
 		case <-p.stopCh:
 			cancel()
 			return
@@ -77,21 +135,77 @@ func (p *DefaultProviders) subscribeToApproved() {
 
 }
 
+// submitApproved - submits approved event until it succeeds, returns false
+// when providers are stopped before that and event mustn't be acknowledged
+func (p *DefaultProviders) submitApproved(approval *approvals.Delivery) bool {
+	for {
+		err := p.Submit(*approval.Event)
+		if err == nil {
+			return true
+		}
+		log.WithFields(log.Fields{
+			"error":      err,
+			"identifier": approval.Identifier,
+		}).Error("provider.subscribeToApproved: failed to submit approved event, retrying")
+		select {
+		case <-time.After(subscribeRetryInterval):
+		case <-p.stopCh:
+			return false
+		}
+	}
+}
+
 // Submit - submit event to all providers
 func (p *DefaultProviders) Submit(event types.Event) error {
-	for _, provider := range p.providers {
-		err := provider.Submit(event)
-		if err != nil {
+	if p.queue != nil {
+		return p.queue.Add(event)
+	}
+
+	p.dispatch(event)
+
+	return nil
+}
+
+// dispatch - passes event to all providers, returns last error so failed
+// events can be retried
+func (p *DefaultProviders) dispatch(event types.Event) (err error) {
+	for name, provider := range p.providers {
+		if event.Provider != "" && event.Provider != name {
+			continue
+		}
+		submitErr := provider.Submit(event)
+		if submitErr != nil {
 			log.WithFields(log.Fields{
-				"error":    err,
+				"error":    submitErr,
 				"provider": provider.GetName(),
 				"event":    event.Repository,
 				"trigger":  event.TriggerName,
 			}).Error("provider.Submit: submit event failed")
+			err = submitErr
 		}
 	}
 
-	return nil
+	return err
+}
+
+// resources - resources event can update, events of providers that can't
+// resolve resources are serialized per repository
+func (p *DefaultProviders) resources(event types.Event) []string {
+	var resources []string
+	for name, provider := range p.providers {
+		if event.Provider != "" && event.Provider != name {
+			continue
+		}
+		resolver, ok := provider.(ResourceResolver)
+		if !ok {
+			resources = append(resources, name+":"+event.Repository.Name)
+			continue
+		}
+		for _, identifier := range resolver.Resources(event.Repository.Name) {
+			resources = append(resources, name+":"+identifier)
+		}
+	}
+	return resources
 }
 
 // TrackedImages - get tracked images for provider
@@ -121,8 +235,27 @@ func (p *DefaultProviders) List() []string {
 	return list
 }
 
+// Rollback - passes rollback to the provider that manages the resource
+func (p *DefaultProviders) Rollback(provider string, req *types.RollbackRequest) error {
+	pr, ok := p.providers[provider]
+	if !ok {
+		return fmt.Errorf("provider %s not found", provider)
+	}
+	rb, ok := pr.(Rollbacker)
+	if !ok {
+		return fmt.Errorf("provider %s doesn't support rollbacks", provider)
+	}
+	return rb.Rollback(req)
+}
+
 // Stop - stop all providers
 func (p *DefaultProviders) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+	})
+	if p.queue != nil {
+		p.queue.Stop()
+	}
 	for _, provider := range p.providers {
 		provider.Stop()
 	}
@@ -0,0 +1,436 @@
+package provider
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/quilla-hq/quilla/pkg/store"
+	"github.com/quilla-hq/quilla/types"
+	"github.com/quilla-hq/quilla/util/keylock"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// queue defaults
+const (
+	DefaultQueueWorkers     = 4
+	DefaultQueueMaxAttempts = 5
+	DefaultQueueBaseBackoff = time.Second
+	DefaultQueueMaxBackoff  = 5 * time.Minute
+)
+
+// QueueOpts - event queue options
+type QueueOpts struct {
+	// Store is optional, pending events are persisted and replayed
+	// after restart when it's set
+	Store store.Store
+
+	// Resources is optional, returns resources event can update. Events that
+	// share a resource are never processed concurrently, events are
+	// serialized per repository when it's not set.
+	Resources func(event types.Event) []string
+
+	Workers     int
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Queue - work queue between triggers and providers. Events are coalesced per
+// queue key (see types.QueueKey), keeping only the newest tag. Events that update
+// the same resource are never processed by more than one worker at a time, failed
+// events are retried with backoff.
+type Queue struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// events waiting to be processed, keyed by queue key
+	pending map[string]*queuedEvent
+	// ready keys, in order of arrival
+	ready []string
+	// events that are being processed by workers
+	processing map[string]*queuedEvent
+	// resources of events that are being processed
+	busy map[string]bool
+	// keys waiting for backoff to expire
+	waiting map[string]*time.Timer
+
+	// persisting - serializes writes of the same key, queue lock isn't
+	// held during store I/O
+	persisting keylock.KeyLock
+
+	process   func(event types.Event) error
+	resources func(event types.Event) []string
+	store     store.Store
+
+	workers     int
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+type queuedEvent struct {
+	*types.QueuedEvent
+	resources []string
+}
+
+// NewQueue - create new event queue, process is called by workers for every event
+func NewQueue(process func(event types.Event) error, opts *QueueOpts) *Queue {
+	q := &Queue{
+		pending:     make(map[string]*queuedEvent),
+		processing:  make(map[string]*queuedEvent),
+		busy:        make(map[string]bool),
+		waiting:     make(map[string]*time.Timer),
+		process:     process,
+		resources:   opts.Resources,
+		store:       opts.Store,
+		workers:     opts.Workers,
+		maxAttempts: opts.MaxAttempts,
+		baseBackoff: opts.BaseBackoff,
+		maxBackoff:  opts.MaxBackoff,
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	if q.resources == nil {
+		q.resources = func(event types.Event) []string {
+			return []string{event.Repository.Name}
+		}
+	}
+	if q.workers <= 0 {
+		q.workers = DefaultQueueWorkers
+	}
+	if q.maxAttempts <= 0 {
+		q.maxAttempts = DefaultQueueMaxAttempts
+	}
+	if q.baseBackoff <= 0 {
+		q.baseBackoff = DefaultQueueBaseBackoff
+	}
+	if q.maxBackoff <= 0 {
+		q.maxBackoff = DefaultQueueMaxBackoff
+	}
+
+	return q
+}
+
+// Start - replays persisted events and starts workers
+func (q *Queue) Start() error {
+	if q.store != nil {
+		queued, err := q.store.ListQueuedEvents()
+		if err != nil {
+			return err
+		}
+		replayed := make([]*queuedEvent, 0, len(queued))
+		for _, qe := range queued {
+			if qe.Event == nil {
+				continue
+			}
+			replayed = append(replayed, &queuedEvent{QueuedEvent: qe, resources: q.resources(*qe.Event)})
+		}
+
+		q.mu.Lock()
+		for _, qe := range replayed {
+			q.pending[qe.ID] = qe
+			q.ready = append(q.ready, qe.ID)
+		}
+		q.mu.Unlock()
+
+		if len(replayed) > 0 {
+			log.WithFields(log.Fields{
+				"events": len(replayed),
+			}).Info("provider.queue: replaying pending events")
+		}
+	}
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+
+	return nil
+}
+
+// Stop - stops workers, waits for events that are being processed. Pending
+// events stay in the store.
+func (q *Queue) Stop() {
+	q.mu.Lock()
+	q.stopped = true
+	for key, timer := range q.waiting {
+		timer.Stop()
+		delete(q.waiting, key)
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	q.wg.Wait()
+}
+
+// Len - number of events waiting to be processed
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Add - adds event to the queue, replacing pending event with the same queue key
+// unless pending event has a newer tag
+func (q *Queue) Add(event types.Event) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	key := types.QueueKey(&event)
+	// resolved before locking, resolving looks up provider caches
+	resources := q.resources(event)
+
+	q.mu.Lock()
+	existing, ok := q.pending[key]
+	if ok && !newer(&event, existing.Event) {
+		q.mu.Unlock()
+		log.WithFields(log.Fields{
+			"repository": event.Repository.Name,
+			"tag":        event.Repository.Tag,
+			"pending":    existing.Event.Repository.Tag,
+		}).Debug("provider.queue: newer event already pending, ignoring")
+		return nil
+	}
+
+	qe := &queuedEvent{
+		QueuedEvent: &types.QueuedEvent{
+			ID:         key,
+			Repository: event.Repository.Name,
+			Event:      &event,
+		},
+		resources: resources,
+	}
+	if ok {
+		qe.CreatedAt = existing.CreatedAt
+	}
+	q.pending[key] = qe
+
+	// event replaces the one that is waiting for retry
+	if timer, waiting := q.waiting[key]; waiting {
+		timer.Stop()
+		delete(q.waiting, key)
+	}
+
+	if q.processing[key] == nil && !q.isReady(key) {
+		q.ready = append(q.ready, key)
+		q.cond.Broadcast()
+	}
+	q.mu.Unlock()
+
+	// event is still processed if it can't be persisted
+	q.persist(key)
+
+	return nil
+}
+
+func (q *Queue) isReady(key string) bool {
+	for _, k := range q.ready {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// next - takes the first ready event that doesn't share resources with
+// events being processed, has to be called with lock held
+func (q *Queue) next() *queuedEvent {
+	for i := 0; i < len(q.ready); i++ {
+		key := q.ready[i]
+		qe, ok := q.pending[key]
+		if !ok {
+			q.ready = append(q.ready[:i], q.ready[i+1:]...)
+			i--
+			continue
+		}
+		if q.processing[key] != nil || q.isBusy(qe.resources) {
+			continue
+		}
+
+		q.ready = append(q.ready[:i], q.ready[i+1:]...)
+		delete(q.pending, key)
+		q.processing[key] = qe
+		for _, r := range qe.resources {
+			q.busy[r] = true
+		}
+		return qe
+	}
+	return nil
+}
+
+func (q *Queue) isBusy(resources []string) bool {
+	for _, r := range resources {
+		if q.busy[r] {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+
+	for {
+		q.mu.Lock()
+		var qe *queuedEvent
+		for !q.stopped {
+			if qe = q.next(); qe != nil {
+				break
+			}
+			q.cond.Wait()
+		}
+		q.mu.Unlock()
+		if qe == nil {
+			return
+		}
+
+		err := q.process(*qe.Event)
+
+		q.mu.Lock()
+		q.release(qe)
+		q.done(qe, err)
+		q.mu.Unlock()
+
+		q.persist(qe.ID)
+	}
+}
+
+// release - frees resources of the processed event, has to be called with lock held
+func (q *Queue) release(qe *queuedEvent) {
+	delete(q.processing, qe.ID)
+	for _, r := range qe.resources {
+		delete(q.busy, r)
+	}
+	// events that waited for the resources can be picked up
+	q.cond.Broadcast()
+}
+
+// done - handles processed event, has to be called with lock held
+func (q *Queue) done(qe *queuedEvent, err error) {
+	key := qe.ID
+
+	// newer event arrived while this one was processed, it replaces the
+	// processed event (including failed one)
+	if _, ok := q.pending[key]; ok {
+		if !q.isReady(key) {
+			q.ready = append(q.ready, key)
+		}
+		return
+	}
+
+	if err == nil {
+		return
+	}
+
+	qe.Attempts++
+	if qe.Attempts >= q.maxAttempts {
+		log.WithFields(log.Fields{
+			"error":      err,
+			"repository": qe.Repository,
+			"tag":        qe.Event.Repository.Tag,
+			"attempts":   qe.Attempts,
+		}).Error("provider.queue: failed to process event, giving up")
+		return
+	}
+
+	backoff := q.backoff(qe.Attempts)
+
+	log.WithFields(log.Fields{
+		"error":      err,
+		"repository": qe.Repository,
+		"tag":        qe.Event.Repository.Tag,
+		"attempts":   qe.Attempts,
+		"retry_in":   backoff.String(),
+	}).Warn("provider.queue: failed to process event, retrying")
+
+	q.pending[key] = qe
+
+	if q.stopped {
+		return
+	}
+
+	q.waiting[key] = time.AfterFunc(backoff, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		if _, ok := q.waiting[key]; !ok {
+			return
+		}
+		delete(q.waiting, key)
+		if q.processing[key] == nil && !q.isReady(key) {
+			q.ready = append(q.ready, key)
+			q.cond.Broadcast()
+		}
+	})
+}
+
+func (q *Queue) backoff(attempts int) time.Duration {
+	backoff := q.baseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= q.maxBackoff {
+			return q.maxBackoff
+		}
+	}
+	return backoff
+}
+
+// persist - writes current state of the key to the store: pending and
+// processed events are saved, events that are done are deleted
+func (q *Queue) persist(key string) {
+	if q.store == nil {
+		return
+	}
+	q.persisting.Lock(key)
+	defer q.persisting.Unlock(key)
+
+	q.mu.Lock()
+	qe, ok := q.pending[key]
+	if !ok {
+		qe, ok = q.processing[key]
+	}
+	var snapshot types.QueuedEvent
+	if ok {
+		snapshot = *qe.QueuedEvent
+	}
+	q.mu.Unlock()
+
+	if !ok {
+		err := q.store.DeleteQueuedEvent(key)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+				"key":   key,
+			}).Error("provider.queue: failed to delete processed event")
+		}
+		return
+	}
+
+	err := q.store.SaveQueuedEvent(&snapshot)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"key":   key,
+		}).Error("provider.queue: failed to persist event")
+	}
+}
+
+// newer - checks whether event should replace pending event with the same queue
+// key, semver tags are compared by version, otherwise the latest event wins
+func newer(event, pending *types.Event) bool {
+	newVersion, err := semver.NewVersion(event.Repository.Tag)
+	if err != nil {
+		return true
+	}
+	pendingVersion, err := semver.NewVersion(pending.Repository.Tag)
+	if err != nil {
+		return true
+	}
+	return !newVersion.LessThan(pendingVersion)
+}
@@ -0,0 +1,427 @@
+package provider
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quilla-hq/quilla/approvals"
+	"github.com/quilla-hq/quilla/pkg/store/sql"
+	"github.com/quilla-hq/quilla/types"
+)
+
+func newTestingUtils(t *testing.T) (*sql.SQLStore, func()) {
+	dir, err := ioutil.TempDir("", "queuetest")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	store, err := sql.New(sql.Opts{DatabaseType: "sqlite3", URI: filepath.Join(dir, "gorm.db")})
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	return store, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func testingEvent(name, tag string) types.Event {
+	return types.Event{
+		Repository: types.Repository{Name: name, Tag: tag},
+	}
+}
+
+type recorder struct {
+	mu        sync.Mutex
+	processed []types.Event
+	done      chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{done: make(chan struct{}, 100)}
+}
+
+func (r *recorder) process(event types.Event) error {
+	r.mu.Lock()
+	r.processed = append(r.processed, event)
+	r.mu.Unlock()
+	r.done <- struct{}{}
+	return nil
+}
+
+func (r *recorder) wait(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, processed: %d", i)
+		}
+	}
+}
+
+func TestQueueCoalesce(t *testing.T) {
+	r := newRecorder()
+	q := NewQueue(r.process, &QueueOpts{Workers: 1})
+
+	// events added before workers are started are coalesced
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.2.0"))
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.2.3"))
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.2.1"))
+	q.Add(testingEvent("gcr.io/v2-namespace/other", "1.0.0"))
+
+	if q.Len() != 2 {
+		t.Fatalf("expected 2 pending events, got: %d", q.Len())
+	}
+
+	q.Start()
+	defer q.Stop()
+	r.wait(t, 2)
+
+	if r.processed[0].Repository.Tag != "1.2.3" {
+		t.Errorf("expected newest tag 1.2.3, got: %s", r.processed[0].Repository.Tag)
+	}
+	if r.processed[1].Repository.Name != "gcr.io/v2-namespace/other" {
+		t.Errorf("unexpected repository: %s", r.processed[1].Repository.Name)
+	}
+}
+
+func TestQueueKeepsUnrelatedTags(t *testing.T) {
+	q := NewQueue(func(event types.Event) error { return nil }, &QueueOpts{Workers: 1})
+
+	approved := testingEvent("gcr.io/v2-namespace/hello-world", "1.2.5")
+	approved.TriggerName = types.TriggerTypeApproval.String()
+
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "dev"))
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "latest"))
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.2.5"))
+	// patch policy wouldn't accept it instead of 1.2.5
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.3.0"))
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.3.0-rc.1"))
+	q.Add(approved)
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.2.6"))
+
+	if q.Len() != 6 {
+		t.Errorf("expected 6 pending events, got: %d", q.Len())
+	}
+}
+
+func TestQueueSerialisesResources(t *testing.T) {
+	var mu sync.Mutex
+	running := map[string]int{}
+	overlapped := false
+	processed := make(chan types.Event, 10)
+	release := make(chan struct{})
+
+	resources := map[string][]string{
+		"gcr.io/v2-namespace/app":     {"default/app"},
+		"gcr.io/v2-namespace/sidecar": {"default/app", "default/worker"},
+		"gcr.io/v2-namespace/other":   {"default/other"},
+	}
+
+	q := NewQueue(func(event types.Event) error {
+		mu.Lock()
+		for _, r := range resources[event.Repository.Name] {
+			running[r]++
+			if running[r] > 1 {
+				overlapped = true
+			}
+		}
+		mu.Unlock()
+
+		<-release
+
+		mu.Lock()
+		for _, r := range resources[event.Repository.Name] {
+			running[r]--
+		}
+		mu.Unlock()
+		processed <- event
+		return nil
+	}, &QueueOpts{
+		Workers: 4,
+		Resources: func(event types.Event) []string {
+			return resources[event.Repository.Name]
+		},
+	})
+	q.Start()
+	defer q.Stop()
+
+	q.Add(testingEvent("gcr.io/v2-namespace/app", "1.0.0"))
+	time.Sleep(50 * time.Millisecond)
+	q.Add(testingEvent("gcr.io/v2-namespace/sidecar", "1.0.0"))
+	q.Add(testingEvent("gcr.io/v2-namespace/app", "2.0.0"))
+	q.Add(testingEvent("gcr.io/v2-namespace/other", "1.0.0"))
+
+	// unrelated resource isn't blocked
+	select {
+	case event := <-processed:
+		t.Fatalf("unexpected event processed before release: %v", event.Repository)
+	case <-time.After(50 * time.Millisecond):
+	}
+	mu.Lock()
+	otherRunning := running["default/other"]
+	mu.Unlock()
+	if otherRunning != 1 {
+		t.Errorf("expected event of unrelated resource to be processed concurrently")
+	}
+
+	close(release)
+	for i := 0; i < 4; i++ {
+		select {
+		case <-processed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, processed: %d", i)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if overlapped {
+		t.Errorf("expected events of the same resource to be processed one at a time")
+	}
+}
+
+func TestQueueSerialisesRepository(t *testing.T) {
+	var mu sync.Mutex
+	running := 0
+	maxRunning := 0
+	processed := make(chan types.Event, 10)
+	release := make(chan struct{})
+
+	q := NewQueue(func(event types.Event) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		<-release
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		processed <- event
+		return nil
+	}, &QueueOpts{Workers: 4})
+	q.Start()
+	defer q.Stop()
+
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.0.0"))
+	// wait for the first event to be picked up by a worker
+	time.Sleep(50 * time.Millisecond)
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.1.0"))
+	time.Sleep(50 * time.Millisecond)
+
+	close(release)
+
+	first := <-processed
+	second := <-processed
+	if first.Repository.Tag != "1.0.0" || second.Repository.Tag != "1.1.0" {
+		t.Errorf("unexpected order: %s, %s", first.Repository.Tag, second.Repository.Tag)
+	}
+	if maxRunning != 1 {
+		t.Errorf("expected repository to be processed by a single worker, got: %d", maxRunning)
+	}
+}
+
+func TestQueueRetry(t *testing.T) {
+	store, teardown := newTestingUtils(t)
+	defer teardown()
+
+	var mu sync.Mutex
+	attempts := 0
+	done := make(chan struct{})
+
+	q := NewQueue(func(event types.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			return errors.New("conflict")
+		}
+		close(done)
+		return nil
+	}, &QueueOpts{Store: store, Workers: 2, BaseBackoff: 10 * time.Millisecond})
+	q.Start()
+	defer q.Stop()
+
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.0.0"))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("event was not retried")
+	}
+
+	// processed event is removed from the store
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		queued, err := store.ListQueuedEvents()
+		if err != nil {
+			t.Fatalf("failed to list queued events: %s", err)
+		}
+		if len(queued) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected processed event to be deleted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQueueGivesUp(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+
+	q := NewQueue(func(event types.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		return errors.New("failed")
+	}, &QueueOpts{Workers: 1, MaxAttempts: 2, BaseBackoff: time.Millisecond})
+	q.Start()
+	defer q.Stop()
+
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.0.0"))
+
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got: %d", attempts)
+	}
+	if q.Len() != 0 {
+		t.Errorf("expected event to be dropped")
+	}
+}
+
+func TestQueueReplay(t *testing.T) {
+	store, teardown := newTestingUtils(t)
+	defer teardown()
+
+	// queue that is stopped before processing anything
+	q := NewQueue(func(event types.Event) error { return nil }, &QueueOpts{Store: store})
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.1.0"))
+	q.Add(testingEvent("gcr.io/v2-namespace/hello-world", "1.1.1"))
+	q.Stop()
+
+	queued, err := store.ListQueuedEvents()
+	if err != nil {
+		t.Fatalf("failed to list queued events: %s", err)
+	}
+	if len(queued) != 1 {
+		t.Fatalf("expected 1 persisted event, got: %d", len(queued))
+	}
+
+	r := newRecorder()
+	restarted := NewQueue(r.process, &QueueOpts{Store: store})
+	err = restarted.Start()
+	if err != nil {
+		t.Fatalf("failed to start queue: %s", err)
+	}
+	defer restarted.Stop()
+	r.wait(t, 1)
+
+	if r.processed[0].Repository.Tag != "1.1.1" {
+		t.Errorf("expected replayed tag 1.1.1, got: %s", r.processed[0].Repository.Tag)
+	}
+}
+
+type recordingProvider struct {
+	r *recorder
+}
+
+func (p *recordingProvider) Submit(event types.Event) error { return p.r.process(event) }
+func (p *recordingProvider) TrackedImages() ([]*types.TrackedImage, error) {
+	return nil, nil
+}
+func (p *recordingProvider) GetName() string { return "recording" }
+func (p *recordingProvider) Stop()           {}
+
+func TestApprovedSubmittedThroughQueue(t *testing.T) {
+	store, teardown := newTestingUtils(t)
+	defer teardown()
+
+	am := approvals.New(&approvals.Opts{Store: store})
+	err := am.Create(&types.Approval{
+		Provider:       types.ProviderTypeKubernetes,
+		Identifier:     "xxx/app-1:1.1.0",
+		CurrentVersion: "1.0.0",
+		NewVersion:     "1.1.0",
+		VotesRequired:  1,
+		Deadline:       time.Now().Add(time.Hour),
+		Event:          &types.Event{Repository: types.Repository{Name: "gcr.io/v2-namespace/hello-world", Tag: "1.1.0"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create approval: %s", err)
+	}
+
+	r := newRecorder()
+	dp := New([]Provider{&recordingProvider{r: r}}, am)
+	defer dp.Stop()
+
+	// subscribing creates the cursor, approval is then replayed from the outbox
+	dp.Start()
+	time.Sleep(100 * time.Millisecond)
+	dp.Stop()
+
+	_, err = am.Approve("xxx/app-1:1.1.0", &types.Voter{Name: "jane"}, "")
+	if err != nil {
+		t.Fatalf("failed to approve: %s", err)
+	}
+
+	// restart, approved event is delivered once the queue is running
+	dp = New([]Provider{&recordingProvider{r: r}}, am)
+	defer dp.Stop()
+	err = dp.EnableQueue(&QueueOpts{Store: store})
+	if err != nil {
+		t.Fatalf("failed to enable queue: %s", err)
+	}
+	dp.Start()
+	r.wait(t, 1)
+
+	if r.processed[0].TriggerName != types.TriggerTypeApproval.String() {
+		t.Errorf("unexpected trigger: %s", r.processed[0].TriggerName)
+	}
+}
+
+type namedProvider struct {
+	recordingProvider
+	name string
+}
+
+func (p *namedProvider) GetName() string { return p.name }
+
+func TestEventForProvider(t *testing.T) {
+	k8s, helm := newRecorder(), newRecorder()
+	dp := New([]Provider{
+		&namedProvider{recordingProvider: recordingProvider{r: k8s}, name: "kubernetes"},
+		&namedProvider{recordingProvider: recordingProvider{r: helm}, name: "helm3"},
+	}, nil)
+	defer dp.Stop()
+	err := dp.EnableQueue(&QueueOpts{})
+	if err != nil {
+		t.Fatalf("failed to enable queue: %s", err)
+	}
+
+	event := testingEvent("gcr.io/v2-namespace/hello-world", "1.1.0")
+	event.Provider = "kubernetes"
+	err = dp.Submit(event)
+	if err != nil {
+		t.Fatalf("failed to submit event: %s", err)
+	}
+	k8s.wait(t, 1)
+
+	select {
+	case <-helm.done:
+		t.Errorf("event for kubernetes provider was dispatched to helm3 provider")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,37 @@
+package registry
+
+import (
+	"github.com/quilla-hq/quilla/extension/credentialshelper"
+	"github.com/quilla-hq/quilla/types"
+)
+
+// DigestResolver - resolves digest the image tag currently points to
+type DigestResolver interface {
+	Digest(image *types.TrackedImage) (string, error)
+}
+
+// NewDigestResolver - digest resolver that queries registry with
+// credentials of the tracked image
+func NewDigestResolver(client Client) DigestResolver {
+	return &digestResolver{client: client}
+}
+
+type digestResolver struct {
+	client Client
+}
+
+func (r *digestResolver) Digest(image *types.TrackedImage) (string, error) {
+	opts := Opts{
+		Registry: image.Image.Scheme() + "://" + image.Image.Registry(),
+		Name:     image.Image.ShortName(),
+		Tag:      image.Image.Tag(),
+	}
+
+	creds, err := credentialshelper.GetCredentials(image)
+	if err == nil {
+		opts.Username = creds.Username
+		opts.Password = creds.Password
+	}
+
+	return r.client.Digest(opts)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -13,6 +14,31 @@ type GetApprovalQuery struct {
 	Identifier string
 	// Rejected   bool
 	Archived bool
+
+	// ExcludeArchived - lists only approvals that aren't archived
+	ExcludeArchived bool
+
+	// filters used when listing approvals, zero values match everything
+	Status           ApprovalStatus
+	Provider         ProviderType
+	Namespace        string
+	IdentifierPrefix string
+	// Voter - approvals the user approved or rejected
+	Voter         string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	Order  string // empty or one of ApprovalOrders
+	Limit  int
+	Offset int
+}
+
+// ApprovalOrders - supported orders of listed approvals
+var ApprovalOrders = []string{
+	"updated_at desc", "updated_at",
+	"created_at desc", "created_at",
+	"deadline desc", "deadline",
+	"identifier desc", "identifier",
 }
 
 // Approval used to store and track updates
@@ -20,16 +46,23 @@ type Approval struct {
 	ID string `json:"id" gorm:"primary_key;type:varchar(36)"`
 
 	// Archived is set to true once approval is finally approved/rejected
-	Archived bool `json:"archived"`
+	Archived bool `json:"archived" gorm:"index:idx_approvals_archived_updated_at"`
 
 	// Provider name - Kubernetes/Helm
-	Provider ProviderType `json:"provider"`
+	Provider ProviderType `json:"provider" gorm:"index"`
 
 	// Identifier is used to inform user about specific
 	// Helm release or k8s deployment
 	// ie: k8s <namespace>/<deployment name>
 	//     helm: <namespace>/<release name>
-	Identifier string `json:"identifier"`
+	Identifier string `json:"identifier" gorm:"index"`
+
+	// Namespace of the updated resource or helm release
+	Namespace string `json:"namespace,omitempty" gorm:"index"`
+
+	// State - status of the approval, kept up to date on every save
+	// so approvals can be listed by status
+	State ApprovalStatus `json:"-" gorm:"index"`
 
 	// Event that triggered evaluation
 	Event *Event `json:"event" gorm:"type:json"`
@@ -39,6 +72,19 @@ type Approval struct {
 	CurrentVersion string `json:"currentVersion"`
 	NewVersion     string `json:"newVersion"`
 
+	// Changes lists updated containers, set when the update
+	// changes more than one container
+	Changes ContainerChanges `json:"changes,omitempty" gorm:"type:json"`
+
+	// Group and Members are set when approval covers an update group,
+	// members are identifiers of resources that are updated together
+	Group   string  `json:"group,omitempty"`
+	Members Members `json:"members,omitempty" gorm:"type:json"`
+
+	// Plan - changes approval was requested for, approved update is
+	// applied only if it's still the planned change
+	Plan *ApprovalPlan `json:"plan,omitempty" gorm:"type:json"`
+
 	// Digest is used to verify that images are the ones that got the approvals.
 	// If digest doesn't match for the image, votes are reset.
 	Digest string `json:"digest"`
@@ -52,6 +98,38 @@ type Approval struct {
 	// IDs for audit
 	Voters JSONB `json:"voters" gorm:"type:json"`
 
+	// Votes - votes together with voter groups, used to check group minimums
+	Votes Votes `json:"votes,omitempty" gorm:"type:json"`
+
+	// Rejections - who rejected the update and why, update is rejected once
+	// rejections satisfy the veto rule
+	Rejections Votes     `json:"rejections,omitempty" gorm:"type:json"`
+	Veto       *VetoRule `json:"veto,omitempty" gorm:"type:json"`
+
+	// Approvers - eligible approvers and votes required from their groups,
+	// anyone can approve when empty
+	Approvers ApproverRules `json:"approvers,omitempty" gorm:"type:json"`
+
+	// Author - author or pusher of the image, set when author
+	// isn't allowed to approve the update
+	Author string `json:"author,omitempty"`
+
+	// Channels - notification channels of the resource, reminders and
+	// expiry notifications are sent to them
+	Channels Channels `json:"channels,omitempty" gorm:"type:json"`
+
+	// AutoApproveAt - approval is approved when nobody rejects it by this
+	// time, zero when lazy consensus isn't enabled. AutoApproveAfter is the
+	// policy duration, used to start over when votes are reset
+	AutoApproveAt    time.Time     `json:"autoApproveAt,omitempty"`
+	AutoApproveAfter time.Duration `json:"autoApproveAfter,omitempty"`
+	AutoApproved     bool          `json:"autoApproved,omitempty"`
+
+	// RemindersSent and Escalated track notifications sent while
+	// approval is pending
+	RemindersSent int  `json:"remindersSent,omitempty"`
+	Escalated     bool `json:"escalated,omitempty"`
+
 	// Explicitly rejected approval
 	// can be set directly by user
 	// so even if deadline is not reached approval
@@ -62,9 +140,15 @@ type Approval struct {
 	Deadline time.Time `json:"deadline"`
 
 	// When this approval was created
-	CreatedAt time.Time `json:"createdAt"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
 	// WHen this approval was updated
-	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"index:idx_approvals_archived_updated_at"`
+}
+
+// BeforeSave - stores current status together with the approval
+func (a *Approval) BeforeSave() error {
+	a.State = a.Status()
+	return nil
 }
 
 func (a *Approval) GetVoters() []string {
@@ -96,6 +180,16 @@ const (
 	ApprovalStatusRejected
 )
 
+// ParseApprovalStatus - parses status name, ie: pending
+func ParseApprovalStatus(value string) (ApprovalStatus, error) {
+	for _, status := range []ApprovalStatus{ApprovalStatusPending, ApprovalStatusApproved, ApprovalStatusRejected} {
+		if strings.EqualFold(value, status.String()) {
+			return status, nil
+		}
+	}
+	return ApprovalStatusUnknown, fmt.Errorf("unknown approval status %q, expected pending, approved or rejected", value)
+}
+
 func (s ApprovalStatus) String() string {
 	switch s {
 	case ApprovalStatusPending:
@@ -115,13 +209,69 @@ func (a *Approval) Status() ApprovalStatus {
 		return ApprovalStatusRejected
 	}
 
-	if a.VotesReceived >= a.VotesRequired {
+	if a.AutoApproved {
+		return ApprovalStatusApproved
+	}
+
+	if a.VotesReceived >= a.VotesRequired && len(a.Approvers.Missing(a.Votes)) == 0 {
 		return ApprovalStatusApproved
 	}
 
 	return ApprovalStatusPending
 }
 
+// CanVote - returns why voter isn't allowed to approve or reject, empty when voter is eligible
+func (a *Approval) CanVote(voter *Voter) string {
+	if a.Author != "" && strings.EqualFold(a.Author, voter.Name) {
+		return fmt.Sprintf("%s pushed the image and can't vote on its update", voter.Name)
+	}
+	if !a.Approvers.Eligible(voter) {
+		return fmt.Sprintf("%s isn't an eligible approver, approvers: %s", voter.Name, a.Approvers)
+	}
+	return ""
+}
+
+// AddVote - records vote of an eligible voter
+func (a *Approval) AddVote(voter *Voter, comment string) {
+	a.AddVoter(voter.Name)
+	a.Votes = append(a.Votes, Vote{Voter: voter.Name, Groups: voter.Groups, Comment: comment, At: time.Now()})
+	a.VotesReceived++
+}
+
+// AddRejection - records rejection, approval is rejected once rejections
+// satisfy the veto rule
+func (a *Approval) AddRejection(voter *Voter, comment string) {
+	a.Rejections = append(a.Rejections, Vote{Voter: voter.Name, Groups: voter.Groups, Comment: comment, At: time.Now()})
+	if a.Veto.Vetoed(a.Rejections) {
+		a.Rejected = true
+	}
+}
+
+// ResetVotes - drops collected votes and pins approval to the new image digest,
+// auto-approval of the previous image doesn't carry over, lazy consensus starts over
+func (a *Approval) ResetVotes(digest string) {
+	a.Voters = JSONB{}
+	a.Votes = nil
+	a.Rejections = nil
+	a.VotesReceived = 0
+	a.AutoApproved = false
+	if !a.AutoApproveAt.IsZero() {
+		after := a.AutoApproveAfter
+		if after == 0 {
+			// approvals stored before the duration was recorded
+			after = a.AutoApproveAt.Sub(a.CreatedAt)
+		}
+		a.AutoApproveAt = time.Now().Add(after)
+	}
+	if a.Plan != nil {
+		a.Plan.PinDigest(a.Digest, digest)
+	}
+	a.Digest = digest
+	if a.Event != nil {
+		a.Event.Repository.Digest = digest
+	}
+}
+
 // Expired - checks if approval is already expired
 func (a *Approval) Expired() bool {
 	return a.Deadline.Before(time.Now())
@@ -130,9 +280,31 @@ func (a *Approval) Expired() bool {
 // Delta of what's changed
 // ie: webhookrelay/webhook-demo:0.15.0 -> webhookrelay/webhook-demo:0.16.0
 func (a *Approval) Delta() string {
+	if len(a.Changes) > 1 {
+		return a.Changes.String()
+	}
 	return fmt.Sprintf("%s -> %s", a.CurrentVersion, a.NewVersion)
 }
 
+// Channels is stored as a JSON blob
+type Channels []string
+
+func (c Channels) Value() (driver.Value, error) {
+	j, err := json.Marshal(c)
+	return j, err
+}
+
+func (c *Channels) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed.")
+	}
+	return json.Unmarshal(source, c)
+}
+
 // JSONB is stored as a JSON blob
 type JSONB map[string]interface{}
 
@@ -163,3 +335,19 @@ func (b *JSONB) Scan(src interface{}) error {
 
 	return nil
 }
+
+// ApprovalTimelineEntry - single step in the life of an approval, ie: vote,
+// reminder or archiving
+type ApprovalTimelineEntry struct {
+	At      time.Time `json:"at"`
+	Action  string    `json:"action"`
+	Actor   string    `json:"actor,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// ApprovalTimeline - approval together with its history, oldest entries first
+type ApprovalTimeline struct {
+	Approval *Approval               `json:"approval"`
+	Entries  []ApprovalTimelineEntry `json:"entries"`
+}
@@ -0,0 +1,286 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Voter - identity of the user voting on an approval, groups come from
+// authenticated user roles or bot user mappings
+type Voter struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// InGroup - checks whether voter belongs to the group
+func (v *Voter) InGroup(group string) bool {
+	for _, g := range v.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// ApproverRule - group or user that is eligible to approve updates, at least
+// Minimum votes have to come from the group
+type ApproverRule struct {
+	Group   string `json:"group,omitempty"`
+	User    string `json:"user,omitempty"`
+	Minimum int    `json:"minimum,omitempty"`
+}
+
+func (r ApproverRule) String() string {
+	if r.User != "" {
+		return "user:" + r.User
+	}
+	if r.Minimum > 0 {
+		return fmt.Sprintf("group:%s=%d", r.Group, r.Minimum)
+	}
+	return "group:" + r.Group
+}
+
+// ApproverRules - eligible approvers, anyone can approve when there are no rules
+type ApproverRules []ApproverRule
+
+// ParseApproverRules - parses comma separated approvers,
+// ie: "group:sre=1,group:product=1,user:alice"
+func ParseApproverRules(value string) (ApproverRules, error) {
+	var rules ApproverRules
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		idx := strings.Index(entry, ":")
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid approver %q, expected group:<name> or user:<name>", entry)
+		}
+		kind, name := entry[:idx], entry[idx+1:]
+
+		var rule ApproverRule
+		switch kind {
+		case "group":
+			if eq := strings.Index(name, "="); eq >= 0 {
+				minimum, err := strconv.Atoi(name[eq+1:])
+				if err != nil || minimum < 0 {
+					return nil, fmt.Errorf("invalid minimum of approver %q", entry)
+				}
+				name, rule.Minimum = name[:eq], minimum
+			}
+			rule.Group = name
+		case "user":
+			rule.User = name
+		default:
+			return nil, fmt.Errorf("invalid approver %q, expected group:<name> or user:<name>", entry)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid approver %q, name is empty", entry)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (r ApproverRules) String() string {
+	entries := make([]string, 0, len(r))
+	for _, rule := range r {
+		entries = append(entries, rule.String())
+	}
+	return strings.Join(entries, ", ")
+}
+
+// Eligible - checks whether voter matches any of the rules
+func (r ApproverRules) Eligible(voter *Voter) bool {
+	if len(r) == 0 {
+		return true
+	}
+	for _, rule := range r {
+		if rule.User != "" && rule.User == voter.Name {
+			return true
+		}
+		if rule.Group != "" && voter.InGroup(rule.Group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing - group minimums that votes don't satisfy yet, a vote counts
+// towards every group of the voter
+func (r ApproverRules) Missing(votes Votes) []string {
+	var missing []string
+	for _, rule := range r {
+		if rule.Group == "" || rule.Minimum == 0 {
+			continue
+		}
+		var n int
+		for _, vote := range votes {
+			voter := Voter{Name: vote.Voter, Groups: vote.Groups}
+			if voter.InGroup(rule.Group) {
+				n++
+			}
+		}
+		if n < rule.Minimum {
+			missing = append(missing, fmt.Sprintf("%d/%d from %s", n, rule.Minimum, rule.Group))
+		}
+	}
+	return missing
+}
+
+func (r ApproverRules) Value() (driver.Value, error) {
+	j, err := json.Marshal(r)
+	return j, err
+}
+
+func (r *ApproverRules) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed.")
+	}
+	return json.Unmarshal(source, r)
+}
+
+// Vote - approval vote or rejection, groups are the ones voter belonged to when voting
+type Vote struct {
+	Voter   string    `json:"voter"`
+	Groups  []string  `json:"groups,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Votes - votes of an approval
+type Votes []Vote
+
+// Has - checks whether the voter already voted
+func (v Votes) Has(voter string) bool {
+	for _, vote := range v {
+		if vote.Voter == voter {
+			return true
+		}
+	}
+	return false
+}
+
+// String - voters with their comments, ie: alice (too risky), bob
+func (v Votes) String() string {
+	entries := make([]string, 0, len(v))
+	for _, vote := range v {
+		if vote.Comment != "" {
+			entries = append(entries, fmt.Sprintf("%s (%s)", vote.Voter, vote.Comment))
+			continue
+		}
+		entries = append(entries, vote.Voter)
+	}
+	return strings.Join(entries, ", ")
+}
+
+func (v Votes) Value() (driver.Value, error) {
+	j, err := json.Marshal(v)
+	return j, err
+}
+
+func (v *Votes) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed.")
+	}
+	return json.Unmarshal(source, v)
+}
+
+// VetoRule - rejections that veto the update, single rejection from anyone
+// vetoes it when the rule is empty
+type VetoRule struct {
+	// Rejections - number of rejections that veto the update
+	Rejections int `json:"rejections,omitempty"`
+	// Group - single rejection from a member of the group vetoes the update
+	Group string `json:"group,omitempty"`
+}
+
+// ParseVetoRule - parses comma separated number of rejections and
+// group, ie: "2", "group:sre" or "2,group:sre"
+func ParseVetoRule(value string) (*VetoRule, error) {
+	var rule VetoRule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.HasPrefix(entry, "group:") {
+			rule.Group = strings.TrimPrefix(entry, "group:")
+			if rule.Group == "" {
+				return nil, fmt.Errorf("invalid veto %q, group name is empty", entry)
+			}
+			continue
+		}
+		rejections, err := strconv.Atoi(entry)
+		if err != nil || rejections < 1 {
+			return nil, fmt.Errorf("invalid veto %q, expected number of rejections or group:<name>", entry)
+		}
+		rule.Rejections = rejections
+	}
+	if rule.Rejections == 0 && rule.Group == "" {
+		return nil, nil
+	}
+	return &rule, nil
+}
+
+func (r *VetoRule) String() string {
+	if r == nil {
+		return "any rejection"
+	}
+	var entries []string
+	if r.Rejections > 0 {
+		entries = append(entries, fmt.Sprintf("%d rejections", r.Rejections))
+	}
+	if r.Group != "" {
+		entries = append(entries, "rejection from "+r.Group)
+	}
+	return strings.Join(entries, " or ")
+}
+
+// Vetoed - checks whether rejections veto the update
+func (r *VetoRule) Vetoed(rejections Votes) bool {
+	if r == nil || (r.Rejections == 0 && r.Group == "") {
+		return len(rejections) > 0
+	}
+	if r.Rejections > 0 && len(rejections) >= r.Rejections {
+		return true
+	}
+	if r.Group != "" {
+		for _, rejection := range rejections {
+			voter := Voter{Name: rejection.Voter, Groups: rejection.Groups}
+			if voter.InGroup(r.Group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *VetoRule) Value() (driver.Value, error) {
+	j, err := json.Marshal(r)
+	return j, err
+}
+
+func (r *VetoRule) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed.")
+	}
+	return json.Unmarshal(source, r)
+}
@@ -0,0 +1,130 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseApproverRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    ApproverRules
+		wantErr bool
+	}{
+		{name: "empty", value: ""},
+		{
+			name:  "groups and users",
+			value: "group:sre=1, group:payments-leads,user:alice",
+			want: ApproverRules{
+				{Group: "sre", Minimum: 1},
+				{Group: "payments-leads"},
+				{User: "alice"},
+			},
+		},
+		{name: "missing kind", value: "sre", wantErr: true},
+		{name: "unknown kind", value: "team:sre", wantErr: true},
+		{name: "invalid minimum", value: "group:sre=one", wantErr: true},
+		{name: "missing name", value: "group:=1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseApproverRules(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseApproverRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseApproverRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApprovalCanVote(t *testing.T) {
+	approval := &Approval{
+		Approvers: ApproverRules{{Group: "sre"}, {User: "alice"}},
+		Author:    "bob",
+	}
+
+	tests := []struct {
+		name     string
+		voter    Voter
+		eligible bool
+	}{
+		{name: "group member", voter: Voter{Name: "carol", Groups: []string{"sre"}}, eligible: true},
+		{name: "listed user", voter: Voter{Name: "alice"}, eligible: true},
+		{name: "author", voter: Voter{Name: "bob", Groups: []string{"sre"}}},
+		{name: "outsider", voter: Voter{Name: "dave", Groups: []string{"finance"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := approval.CanVote(&tt.voter)
+			if (reason == "") != tt.eligible {
+				t.Errorf("CanVote() = %q, eligible %v", reason, tt.eligible)
+			}
+		})
+	}
+}
+
+func TestParseVetoRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    *VetoRule
+		wantErr bool
+	}{
+		{name: "empty", value: ""},
+		{name: "rejections", value: "2", want: &VetoRule{Rejections: 2}},
+		{name: "group", value: "group:sre", want: &VetoRule{Group: "sre"}},
+		{name: "both", value: "3, group:sre", want: &VetoRule{Rejections: 3, Group: "sre"}},
+		{name: "zero rejections", value: "0", wantErr: true},
+		{name: "missing group", value: "group:", wantErr: true},
+		{name: "unknown", value: "user:alice", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVetoRule(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVetoRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseVetoRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVetoRuleVetoed(t *testing.T) {
+	one := Votes{{Voter: "alice", Groups: []string{"dev"}}}
+	two := Votes{{Voter: "alice", Groups: []string{"dev"}}, {Voter: "bob"}}
+	sre := Votes{{Voter: "carol", Groups: []string{"sre"}}}
+
+	tests := []struct {
+		name       string
+		rule       *VetoRule
+		rejections Votes
+		vetoed     bool
+	}{
+		{name: "no rejections", rejections: nil},
+		{name: "any rejection", rejections: one, vetoed: true},
+		{name: "below threshold", rule: &VetoRule{Rejections: 2}, rejections: one},
+		{name: "threshold reached", rule: &VetoRule{Rejections: 2}, rejections: two, vetoed: true},
+		{name: "outside of group", rule: &VetoRule{Group: "sre"}, rejections: two},
+		{name: "group member", rule: &VetoRule{Group: "sre"}, rejections: sre, vetoed: true},
+		{name: "group member below threshold", rule: &VetoRule{Rejections: 3, Group: "sre"}, rejections: sre, vetoed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Vetoed(tt.rejections); got != tt.vetoed {
+				t.Errorf("Vetoed() = %v, want %v", got, tt.vetoed)
+			}
+		})
+	}
+}
+
+func TestVotesString(t *testing.T) {
+	votes := Votes{{Voter: "alice", Comment: "breaks checkout"}, {Voter: "bob"}}
+	if got := votes.String(); got != "alice (breaks checkout), bob" {
+		t.Errorf("unexpected votes: %s", got)
+	}
+}
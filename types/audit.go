@@ -10,15 +10,29 @@ const (
 	AuditActionDeleted = "deleted"
 
 	// Approval specific actions
-	AuditActionApprovalApproved = "approved"
-	AuditActionApprovalRejected = "rejected"
-	AuditActionApprovalExpired  = "expired"
-	AuditActionApprovalArchived = "archived"
+	AuditActionApprovalApproved     = "approved"
+	AuditActionApprovalRejected     = "rejected"
+	AuditActionApprovalExpired      = "expired"
+	AuditActionApprovalArchived     = "archived"
+	AuditActionApprovalReset        = "reset"
+	AuditActionApprovalReminded     = "reminded"
+	AuditActionApprovalEscalated    = "escalated"
+	AuditActionApprovalAutoApproved = "auto-approved"
+	AuditActionApprovalLinkUsed     = "link-used"
+
+	// Gate specific actions
+	AuditActionGatePassed = "passed"
+	AuditActionGateFailed = "failed"
+
+	// Resource specific actions
+	AuditActionRollback = "rollback"
 
 	// audit specific resource kinds (others are set by
 	// providers, ie: deployment, daemonset, helm chart)
 	AuditResourceKindApproval = "approval"
 	AuditResourceKindWebhook  = "webhook"
+	AuditResourceKindGate     = "gate"
+	AuditResourceKindResource = "resource"
 )
 
 // AuditLog - audit logs lets users basic things happening in quilla such as
@@ -35,7 +49,7 @@ type AuditLog struct {
 	// create/delete/update
 	Action       string `json:"action"`
 	ResourceKind string `json:"resourceKind"` // approval/deployment/daemonset/statefulset/etc...
-	Identifier   string `json:"identifier"`
+	Identifier   string `json:"identifier" gorm:"index"`
 
 	Message     string `json:"message"`
 	Payload     string `json:"payload"` // can be used for bigger messages such as webhook payload
@@ -62,6 +76,9 @@ type AuditLogQuery struct {
 	Limit    int    `json:"limit"`
 	Offset   int    `json:"offset"`
 
+	// Identifier - entries of a single resource or approval
+	Identifier string `json:"identifier"`
+
 	ResourceKindFilter []string `json:"resourceKindFilter"`
 }
 
@@ -0,0 +1,111 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ContainerChange - image change of a single container
+type ContainerChange struct {
+	Container      string `json:"container"`
+	Init           bool   `json:"init,omitempty"`
+	Image          string `json:"image"`
+	PreviousImage  string `json:"previousImage,omitempty"`
+	CurrentVersion string `json:"currentVersion"`
+	NewVersion     string `json:"newVersion"`
+}
+
+func (c ContainerChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Container, c.CurrentVersion, c.NewVersion)
+}
+
+// ContainerChanges is stored as a JSON blob
+type ContainerChanges []ContainerChange
+
+func (c ContainerChanges) String() string {
+	changes := make([]string, 0, len(c))
+	for _, change := range c {
+		changes = append(changes, change.String())
+	}
+	return strings.Join(changes, ", ")
+}
+
+func (c ContainerChanges) Value() (driver.Value, error) {
+	j, err := json.Marshal(c)
+	return j, err
+}
+
+func (c *ContainerChanges) Scan(src interface{}) error {
+	// approvals created before changes were tracked
+	if src == nil {
+		return nil
+	}
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed.")
+	}
+
+	var changes ContainerChanges
+	if err := json.Unmarshal(source, &changes); err != nil {
+		return err
+	}
+
+	*c = changes
+
+	return nil
+}
+
+// ParseContainers - parses list of containers that should be updated,
+// empty list means that all containers are updated
+func ParseContainers(annotations map[string]string) []string {
+	containers := []string{}
+	if annotations == nil {
+		return containers
+	}
+	containersStr, ok := annotations[QuillaContainersAnnotation]
+	if ok {
+		for _, c := range strings.Split(containersStr, ",") {
+			c = strings.TrimSpace(c)
+			if c != "" {
+				containers = append(containers, c)
+			}
+		}
+	}
+
+	return containers
+}
+
+// Members - resource identifiers, stored as a JSON blob
+type Members []string
+
+func (m Members) String() string {
+	return strings.Join(m, ", ")
+}
+
+func (m Members) Value() (driver.Value, error) {
+	j, err := json.Marshal(m)
+	return j, err
+}
+
+func (m *Members) Scan(src interface{}) error {
+	// approvals that don't cover a group
+	if src == nil {
+		return nil
+	}
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed.")
+	}
+
+	var members Members
+	if err := json.Unmarshal(source, &members); err != nil {
+		return err
+	}
+
+	*m = members
+
+	return nil
+}
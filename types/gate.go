@@ -1,15 +1,44 @@
 package types
 
+import "time"
+
 type GetGateQuery struct {
 	ID         string
 	Identifier string
+	Version    string
+
+	// Pending - only runs that are not complete yet
+	Pending bool
 }
 
+// Gate - gate run for a specific resource and target version, a new
+// run is created when the target version changes
 type Gate struct {
 	ID         string `json:"id" gorm:"primary_key;type:varchar(36)"`
-	Identifier string `json:"identifier"`
-	Complete   bool   `json:"complete"`
-	Status     int    `json:"status"`
+	Identifier string `json:"identifier" gorm:"index"`
+
+	// Type - job, http or manual
+	Type string `json:"type"`
+
+	// Version and Image - candidate that is being verified
+	Version string `json:"version"`
+	Image   string `json:"image"`
+
+	// Job - name of the job that verifies candidate
+	Job string `json:"job"`
+
+	// Event is submitted again while gate is in progress
+	Event *Event `json:"event" gorm:"type:json"`
+
+	Message  string `json:"message"`
+	Complete bool   `json:"complete"`
+	Status   int    `json:"status"`
+
+	// Deadline - gate fails if it's not complete by the deadline
+	Deadline time.Time `json:"deadline"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 const (
@@ -18,3 +47,19 @@ const (
 	GateStatusSucceeded  = 2
 	GateStatusFailed     = 3
 )
+
+// StatusString - human readable gate status
+func (g *Gate) StatusString() string {
+	switch g.Status {
+	case GateStatusCreated:
+		return "created"
+	case GateStatusInProgress:
+		return "in progress"
+	case GateStatusSucceeded:
+		return "succeeded"
+	case GateStatusFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,89 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// HistoryOutcome - result of an update
+type HistoryOutcome string
+
+// Available outcomes
+const (
+	HistoryOutcomeSucceeded HistoryOutcome = "succeeded"
+	HistoryOutcomeFailed    HistoryOutcome = "failed"
+)
+
+// GetHistoryQuery - history of a resource, newest entries first
+type GetHistoryQuery struct {
+	Identifier string
+	Limit      int
+}
+
+// HistoryEntry - image version change of a resource container, written by
+// providers for every update they apply
+type HistoryEntry struct {
+	ID        string    `json:"id" gorm:"primary_key;type:varchar(36)"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Provider   string `json:"provider"`
+	Identifier string `json:"identifier" gorm:"index"`
+	// Container - container name, values path for helm releases
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Digest    string `json:"digest,omitempty"`
+	Trigger   string `json:"trigger"`
+	Approvers Users  `json:"approvers" gorm:"type:json"`
+
+	Outcome HistoryOutcome `json:"outcome"`
+	Message string         `json:"message,omitempty"`
+}
+
+// Holds - rolled back version isn't applied again while a successful rollback
+// is the latest change of the container
+func (e *HistoryEntry) Holds(version string) bool {
+	return e.Trigger == TriggerTypeRollback.String() && e.Outcome == HistoryOutcomeSucceeded && e.From == version
+}
+
+// RollbackRequest - reverts resource containers that run the image to the version
+type RollbackRequest struct {
+	Identifier string `json:"identifier"`
+	Image      string `json:"image"`
+	Version    string `json:"version"`
+	Digest     string `json:"digest,omitempty"`
+	// User - who requested the rollback
+	User string `json:"user"`
+	// Queued - rollback waits for rollout limits and is applied by the update queue
+	Queued bool `json:"queued,omitempty"`
+}
+
+// Users - user names, stored as a JSON blob
+type Users []string
+
+func (u Users) Value() (driver.Value, error) {
+	j, err := json.Marshal(u)
+	return j, err
+}
+
+func (u *Users) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed.")
+	}
+
+	var users Users
+	if err := json.Unmarshal(source, &users); err != nil {
+		return err
+	}
+
+	*u = users
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package types
+
+import (
+	"time"
+)
+
+// LinkToken - used approval link token, links are single-use so tokens
+// are recorded once they are used
+type LinkToken struct {
+	ID         string    `json:"id" gorm:"primary_key;type:varchar(36)"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ApprovalID string    `json:"approvalId"`
+	Action     string    `json:"action"`
+	Recipient  string    `json:"recipient"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
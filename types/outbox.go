@@ -0,0 +1,38 @@
+package types
+
+import (
+	"time"
+)
+
+// Approval event kinds
+const (
+	ApprovalEventRequested = "requested"
+	ApprovalEventApproved  = "approved"
+)
+
+// ApprovalEvent - approval event in the outbox, every subscriber gets
+// it at least once, events are ordered by Seq
+type ApprovalEvent struct {
+	Seq        uint64    `json:"seq" gorm:"primary_key;AUTO_INCREMENT"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"index"`
+	Kind       string    `json:"kind" gorm:"index"`
+	ApprovalID string    `json:"approvalId"`
+	Identifier string    `json:"identifier"`
+
+	// Approval - JSON snapshot of the approval when the event was published
+	Approval string `json:"approval" gorm:"type:text"`
+}
+
+// GetApprovalEventsQuery - events of the kind published after the sequence number
+type GetApprovalEventsQuery struct {
+	Kind  string
+	After uint64
+	Limit int
+}
+
+// OutboxCursor - last event acknowledged by the subscriber
+type OutboxCursor struct {
+	Subscriber string    `json:"subscriber" gorm:"primary_key;type:varchar(255)"`
+	Seq        uint64    `json:"seq"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,117 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PlannedChange - single change of an update plan, target is a container of
+// kubernetes resource or values path of helm release
+type PlannedChange struct {
+	Resource string `json:"resource"`
+	Target   string `json:"target"`
+	Init     bool   `json:"init,omitempty"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+func (c PlannedChange) key() string {
+	return fmt.Sprintf("%s/%s/%t", c.Resource, c.Target, c.Init)
+}
+
+func (c PlannedChange) String() string {
+	target := c.Target
+	if c.Init {
+		target += " (init)"
+	}
+	return fmt.Sprintf("%s %s: %s -> %s", c.Resource, target, c.From, c.To)
+}
+
+// ApprovalPlan - update plan approval was requested for, approved update
+// is applied only while the plan still matches the cluster
+type ApprovalPlan struct {
+	Changes []PlannedChange `json:"changes"`
+	Digest  string          `json:"digest,omitempty"`
+}
+
+// Empty - approval doesn't carry a plan, ie: it was requested before plans were stored
+func (p *ApprovalPlan) Empty() bool {
+	return p == nil || len(p.Changes) == 0
+}
+
+// Sort - orders changes by resource and target so plans can be compared
+func (p *ApprovalPlan) Sort() {
+	sort.Slice(p.Changes, func(i, j int) bool {
+		return p.Changes[i].key() < p.Changes[j].key()
+	})
+}
+
+// PinDigest - pins plan to the new image digest, changes that set the
+// previous digest are updated to set the new one
+func (p *ApprovalPlan) PinDigest(previous, digest string) {
+	for i := range p.Changes {
+		if previous != "" && p.Changes[i].To == previous {
+			p.Changes[i].To = digest
+		}
+	}
+	p.Digest = digest
+}
+
+// Diff - before/after diff of the plan, one change per line
+func (p *ApprovalPlan) Diff() string {
+	if p == nil {
+		return ""
+	}
+	lines := make([]string, 0, len(p.Changes))
+	for _, c := range p.Changes {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Drift - differences between approved plan and the current one, empty
+// when current plan is exactly the approved one
+func (p *ApprovalPlan) Drift(current *ApprovalPlan) []string {
+	approved := make(map[string]PlannedChange, len(p.Changes))
+	for _, c := range p.Changes {
+		approved[c.key()] = c
+	}
+
+	var drift []string
+	for _, c := range current.Changes {
+		a, ok := approved[c.key()]
+		if !ok {
+			drift = append(drift, fmt.Sprintf("unapproved change %s", c))
+			continue
+		}
+		delete(approved, c.key())
+		if a.From != c.From || a.To != c.To {
+			drift = append(drift, fmt.Sprintf("approved %s, planned %s -> %s", a, c.From, c.To))
+		}
+	}
+	for _, a := range approved {
+		drift = append(drift, fmt.Sprintf("approved change no longer planned %s", a))
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+func (p *ApprovalPlan) Value() (driver.Value, error) {
+	j, err := json.Marshal(p)
+	return j, err
+}
+
+func (p *ApprovalPlan) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) failed.")
+	}
+	return json.Unmarshal(source, p)
+}
@@ -0,0 +1,69 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApprovalPlanDrift(t *testing.T) {
+	approved := &ApprovalPlan{Changes: []PlannedChange{
+		{Resource: "deployment/default/api", Target: "api", From: "api:1.0.0", To: "api:1.1.0"},
+		{Resource: "deployment/default/api", Target: "migrate", Init: true, From: "api:1.0.0", To: "api:1.1.0"},
+	}}
+
+	tests := []struct {
+		name    string
+		current []PlannedChange
+		want    []string
+	}{
+		{
+			name:    "same plan",
+			current: []PlannedChange{approved.Changes[1], approved.Changes[0]},
+		},
+		{
+			name: "changed source version",
+			current: []PlannedChange{
+				{Resource: "deployment/default/api", Target: "api", From: "api:0.9.0", To: "api:1.1.0"},
+				approved.Changes[1],
+			},
+			want: []string{"approved deployment/default/api api: api:1.0.0 -> api:1.1.0, planned api:0.9.0 -> api:1.1.0"},
+		},
+		{
+			name: "added and missing changes",
+			current: []PlannedChange{
+				approved.Changes[0],
+				{Resource: "deployment/default/api", Target: "sidecar", From: "api:1.0.0", To: "api:1.1.0"},
+			},
+			want: []string{
+				"approved change no longer planned deployment/default/api migrate (init): api:1.0.0 -> api:1.1.0",
+				"unapproved change deployment/default/api sidecar: api:1.0.0 -> api:1.1.0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := approved.Drift(&ApprovalPlan{Changes: tt.current})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Drift() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApprovalResetPinsPlan(t *testing.T) {
+	approval := &Approval{
+		Digest: "sha256:aaa",
+		Plan: &ApprovalPlan{Digest: "sha256:aaa", Changes: []PlannedChange{
+			{Resource: "default/release", Target: "image.tag", From: "1.0.0", To: "1.1.0"},
+			{Resource: "default/release", Target: "image.digest", From: "sha256:000", To: "sha256:aaa"},
+		}},
+	}
+	approval.ResetVotes("sha256:bbb")
+
+	if approval.Plan.Digest != "sha256:bbb" {
+		t.Errorf("expected plan to be pinned to new digest, got: %s", approval.Plan.Digest)
+	}
+	if approval.Plan.Changes[0].To != "1.1.0" || approval.Plan.Changes[1].To != "sha256:bbb" {
+		t.Errorf("unexpected plan changes: %v", approval.Plan.Changes)
+	}
+}
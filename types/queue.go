@@ -0,0 +1,60 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/semver"
+)
+
+// QueuedEvent - event waiting to be processed by providers. Only the newest
+// event is kept for each queue key so pending work survives restarts.
+type QueuedEvent struct {
+	// ID - queue key of the event, see QueueKey
+	ID         string    `json:"id" gorm:"primary_key;type:varchar(255)"`
+	Repository string    `json:"repository"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+
+	Event *Event `json:"event" gorm:"type:json"`
+
+	// Attempts - how many times providers failed to process this event
+	Attempts int `json:"attempts"`
+}
+
+// QueueKey - events with the same key replace each other while pending. Only
+// events that any policy would pick the same way share the key: pushes of the
+// same tag and semver tags of the same minor version. Triggers are kept apart
+// so approved updates aren't replaced by new pushes.
+func QueueKey(event *Event) string {
+	tag := event.Repository.Tag
+	if v, err := semver.NewVersion(tag); err == nil {
+		tag = fmt.Sprintf("%d.%d.x", v.Major(), v.Minor())
+		if v.Prerelease() != "" {
+			tag += "-" + v.Prerelease()
+		}
+	}
+	key := event.Repository.Name + ":" + tag + "#" + event.TriggerName
+	if event.Provider != "" {
+		key += "@" + event.Provider
+	}
+	return key
+}
+
+// UpdateQueue - updates held back by rollout concurrency limits and update budgets
+type UpdateQueue struct {
+	// InFlight - updates that are still rolling out
+	InFlight int             `json:"inFlight"`
+	Depth    int             `json:"depth"`
+	Updates  []*QueuedUpdate `json:"updates"`
+}
+
+// QueuedUpdate - update plan waiting for a free rollout slot or update budget
+type QueuedUpdate struct {
+	Identifier     string    `json:"identifier"`
+	Namespace      string    `json:"namespace"`
+	CurrentVersion string    `json:"currentVersion"`
+	NewVersion     string    `json:"newVersion"`
+	Reason         string    `json:"reason"`
+	QueuedAt       time.Time `json:"queuedAt"`
+}
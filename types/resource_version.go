@@ -0,0 +1,45 @@
+package types
+
+import "time"
+
+type GetResourceVersionQuery struct {
+	Identifier string
+	Version    string
+}
+
+// ResourceVersion - version that ran in a resource, used to decide whether
+// version can be promoted to resources that follow it
+type ResourceVersion struct {
+	ID         string `json:"id" gorm:"primary_key;type:varchar(36)"`
+	Identifier string `json:"identifier" gorm:"index"`
+	Version    string `json:"version"`
+	Image      string `json:"image"`
+
+	// Approved - version was applied by quilla after passing approvals and
+	// gates, or resource doesn't require approvals
+	Approved bool `json:"approved"`
+
+	// HealthySince - start of the current healthy period, zero while rollout
+	// is in progress or resource is unhealthy
+	HealthySince time.Time `json:"healthySince"`
+	// RetiredAt - when version was replaced in the resource
+	RetiredAt time.Time `json:"retiredAt"`
+
+	// PromotedFrom - resource identifier version was promoted from
+	PromotedFrom string `json:"promotedFrom,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Soaked - returns true if version was healthy for at least soak period
+func (v *ResourceVersion) Soaked(soak time.Duration, now time.Time) bool {
+	if v.HealthySince.IsZero() {
+		return false
+	}
+	end := now
+	if !v.RetiredAt.IsZero() {
+		end = v.RetiredAt
+	}
+	return end.Sub(v.HealthySince) >= soak
+}
@@ -0,0 +1,46 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// ResourceStatus - update status of a tracked resource, written back onto the resource
+// as annotations or into a status config map
+type ResourceStatus struct {
+	// LastCheck - when quilla last checked resource against a new version
+	LastCheck time.Time `json:"lastCheck"`
+	// NewestVersion - newest version allowed by the resource policy
+	NewestVersion string `json:"newestVersion"`
+
+	// ApprovalID - pending approval of the newest version
+	ApprovalID    string `json:"approvalId"`
+	VotesReceived int    `json:"votesReceived"`
+	VotesRequired int    `json:"votesRequired"`
+
+	Gate string `json:"gate"`
+
+	// LastOutcome - outcome of the last update, Error is set when it failed
+	LastOutcome HistoryOutcome `json:"lastOutcome"`
+	Error       string         `json:"error"`
+}
+
+// Data - status as key value pairs, empty values mark fields that aren't set
+func (s *ResourceStatus) Data() map[string]string {
+	data := map[string]string{
+		"lastCheck":     "",
+		"newestVersion": s.NewestVersion,
+		"approval":      s.ApprovalID,
+		"votes":         "",
+		"gate":          s.Gate,
+		"outcome":       string(s.LastOutcome),
+		"error":         s.Error,
+	}
+	if !s.LastCheck.IsZero() {
+		data["lastCheck"] = s.LastCheck.UTC().Format(time.RFC3339)
+	}
+	if s.ApprovalID != "" {
+		data["votes"] = fmt.Sprintf("%d/%d", s.VotesReceived, s.VotesRequired)
+	}
+	return data
+}
@@ -23,9 +23,60 @@ const QuillaDefaultPort = 9300
 // quillaPolicyLabel - quilla update policies (version checking)
 const QuillaPolicyLabel = "quilla.sh/policy"
 
+// quillaContainerPolicyPrefix - per container policy, ie: quilla.sh/policy.worker=minor
+// overrides resource policy for the worker container
+const QuillaContainerPolicyPrefix = QuillaPolicyLabel + "."
+
+// quillaContainersAnnotation - optional comma separated list of containers that
+// should be updated, ie: quilla.sh/containers=app,worker
+const QuillaContainersAnnotation = "quilla.sh/containers"
+
 const QuillaGateLabel = "quilla.sh/gate"
 const QuillaGateJobSecret = "quilla.sh/job-secret"
 
+// quillaGateTimeoutAnnotation - optional gate timeout, ie: 30m, defaults to 1h
+const QuillaGateTimeoutAnnotation = "quilla.sh/gateTimeout"
+
+// quillaPreUpdateHookAnnotation - job template that has to succeed before the update
+// is applied, ie: configmap:migrations, configmap:migrations/job.yaml or job:migrate
+// where job:migrate is a suspended job in the resource namespace
+const QuillaPreUpdateHookAnnotation = "quilla.sh/preUpdateHook"
+
+// quillaPostUpdateHookAnnotation - job template that runs after the rollout,
+// resource is rolled back if it fails
+const QuillaPostUpdateHookAnnotation = "quilla.sh/postUpdateHook"
+
+// quillaHookTimeoutAnnotation - optional hook timeout, ie: 5m, defaults to 10m
+const QuillaHookTimeoutAnnotation = "quilla.sh/hookTimeout"
+
+// quillaPromoteFromAnnotation - resource only receives versions that soaked in the source
+// resource and passed its approvals, ie: staging/api or deployment/staging/api
+const QuillaPromoteFromAnnotation = "quilla.sh/promoteFrom"
+
+// quillaPromoteSoakAnnotation - how long version has to run healthily in the source
+// resource before it's promoted, ie: 2h, defaults to 1h
+const QuillaPromoteSoakAnnotation = "quilla.sh/promoteSoak"
+
+// quillaGroupAnnotation - resources of the same group that use the updated image
+// are approved, gated and updated together, ie: quilla.sh/group=shop
+const QuillaGroupAnnotation = "quilla.sh/group"
+
+// quillaDependsOnAnnotation - comma separated resources that have to be updated to the
+// same version and finish rolling out first, ie: ns/deployment/db-migrator
+const QuillaDependsOnAnnotation = "quilla.sh/dependsOn"
+
+// quillaUpdatesPerHourAnnotation - how many times resource can be updated within an hour,
+// further updates are queued until the budget allows them
+const QuillaUpdatesPerHourAnnotation = "quilla.sh/updatesPerHour"
+
+// quillaStatusAnnotationPrefix - prefix of status annotations written back onto tracked
+// resources, kept outside of quilla.sh/ so update patches don't overwrite them
+const QuillaStatusAnnotationPrefix = "status.quilla.sh/"
+
+// quillaHookFailedAnnotation - set when a resource is rolled back after post update hook
+// or its update group failed, this version won't be applied again
+const QuillaHookFailedAnnotation = "quilla.sh/hookFailedVersion"
+
 const QuillaImagePullSecretAnnotation = "quilla.sh/imagePullSecret"
 
 // quillaTriggerLabel - trigger label is used to specify custom trigger types
@@ -62,9 +113,25 @@ const QuillaMinimumApprovalsLabel = "quilla.sh/approvals"
 // quillaUpdateTimeAnnotation - update time
 const QuillaUpdateTimeAnnotation = "quilla.sh/update-time"
 
+// quillaApproversAnnotation - eligible approvers and minimum votes from groups,
+// ie: quilla.sh/approvers=group:sre=1,group:product=1,user:alice
+const QuillaApproversAnnotation = "quilla.sh/approvers"
+
+// quillaVetoAnnotation - rejections that veto the update, single rejection vetoes
+// it by default, ie: quilla.sh/veto=2,group:sre
+const QuillaVetoAnnotation = "quilla.sh/veto"
+
+// quillaSeparationOfDutiesAnnotation - author or pusher of the image reported by the
+// registry webhook can't approve its update
+const QuillaSeparationOfDutiesAnnotation = "quilla.sh/separationOfDuties"
+
 // quillaApprovalDeadlineLabel - approval deadline
 const QuillaApprovalDeadlineLabel = "quilla.sh/approvalDeadline"
 
+// quillaAutoApproveAfterAnnotation - pending approval is approved when nobody
+// rejects it within the duration, ie: 4h
+const QuillaAutoApproveAfterAnnotation = "quilla.sh/autoApproveAfter"
+
 // quillaApprovalDeadlineDefault - default deadline in hours
 const QuillaApprovalDeadlineDefault = 24
 
@@ -107,6 +174,11 @@ type Event struct {
 	CreatedAt  time.Time  `json:"createdAt,omitempty"`
 	// optional field to identify trigger
 	TriggerName string `json:"triggerName,omitempty"`
+	// Author - optional author or pusher of the image reported by the registry
+	Author string `json:"author,omitempty"`
+	// Provider - optional, only the named provider processes the event (ie: events
+	// that kubernetes provider resubmits once gates pass)
+	Provider string `json:"provider,omitempty"`
 }
 
 func (e *Event) Value() (driver.Value, error) {
@@ -164,9 +236,13 @@ type TriggerType int
 
 // Available trigger types
 const (
-	TriggerTypeDefault  TriggerType = iota // default policy is to wait for external triggers
-	TriggerTypePoll                        // poll policy sets up watchers for the affected repositories
-	TriggerTypeApproval                    // fulfilled approval requests trigger events
+	TriggerTypeDefault    TriggerType = iota // default policy is to wait for external triggers
+	TriggerTypePoll                          // poll policy sets up watchers for the affected repositories
+	TriggerTypeApproval                      // fulfilled approval requests trigger events
+	TriggerTypeGate                          // gates that are in progress or passed trigger events
+	TriggerTypePromotion                     // versions that soaked in the source resource trigger events
+	TriggerTypeDependency                    // updates waiting for their dependencies trigger events
+	TriggerTypeRollback                      // users revert resources to versions from their history
 )
 
 func (t TriggerType) String() string {
@@ -177,6 +253,14 @@ func (t TriggerType) String() string {
 		return "poll"
 	case TriggerTypeApproval:
 		return "approval"
+	case TriggerTypeGate:
+		return "gate"
+	case TriggerTypePromotion:
+		return "promotion"
+	case TriggerTypeDependency:
+		return "dependency"
+	case TriggerTypeRollback:
+		return "rollback"
 	default:
 		return "default"
 	}
@@ -253,6 +337,8 @@ const (
 
 	NotificationUpdateApproved
 	NotificationUpdateRejected
+
+	NotificationUpdateHook
 )
 
 func (n Notification) String() string {
@@ -275,6 +361,8 @@ func (n Notification) String() string {
 		return "update approved"
 	case NotificationUpdateRejected:
 		return "update rejected "
+	case NotificationUpdateHook:
+		return "update hook"
 	default:
 		return "unknown"
 	}
@@ -362,6 +450,16 @@ const (
 	ProviderTypeHelm
 )
 
+// ParseProviderType - parses provider name, ie: kubernetes
+func ParseProviderType(value string) (ProviderType, error) {
+	for _, t := range []ProviderType{ProviderTypeKubernetes, ProviderTypeHelm} {
+		if strings.EqualFold(value, t.String()) {
+			return t, nil
+		}
+	}
+	return ProviderTypeUnknown, fmt.Errorf("unknown provider %q, expected kubernetes or helm", value)
+}
+
 func (t ProviderType) String() string {
 	switch t {
 	case ProviderTypeUnknown:
@@ -157,3 +157,28 @@ func TestParseReleaseNotesURL(t *testing.T) {
 		})
 	}
 }
+
+func TestParseContainers(t *testing.T) {
+	got := ParseContainers(map[string]string{QuillaContainersAnnotation: "app, worker,"})
+	if !reflect.DeepEqual(got, []string{"app", "worker"}) {
+		t.Errorf("ParseContainers() = %v", got)
+	}
+	if got := ParseContainers(nil); len(got) != 0 {
+		t.Errorf("expected no containers, got: %v", got)
+	}
+}
+
+func TestApprovalDeltaChanges(t *testing.T) {
+	a := &Approval{CurrentVersion: "1.1.1", NewVersion: "1.2.0"}
+	if a.Delta() != "1.1.1 -> 1.2.0" {
+		t.Errorf("unexpected delta: %s", a.Delta())
+	}
+
+	a.Changes = ContainerChanges{
+		{Container: "app", CurrentVersion: "1.1.0", NewVersion: "1.2.0"},
+		{Container: "worker", CurrentVersion: "1.1.1", NewVersion: "1.2.0"},
+	}
+	if a.Delta() != "app: 1.1.0 -> 1.2.0, worker: 1.1.1 -> 1.2.0" {
+		t.Errorf("unexpected delta: %s", a.Delta())
+	}
+}
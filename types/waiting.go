@@ -0,0 +1,31 @@
+package types
+
+import (
+	"time"
+)
+
+// WaitingUpdate - update that waits for resources it depends on or for its update
+// hook. Only the newest version is kept for each resource so waiting updates
+// survive restarts.
+type WaitingUpdate struct {
+	Identifier string `json:"identifier" gorm:"primary_key;type:varchar(255)"`
+	Version    string `json:"version"`
+
+	// DependsOn - comma separated identifiers of dependencies that aren't ready yet
+	DependsOn string `json:"dependsOn"`
+
+	// Hook - update hook stage that is still running, pre-update or post-update
+	Hook string `json:"hook,omitempty"`
+
+	// Event is submitted again until dependencies are ready
+	Event *Event `json:"event" gorm:"type:json"`
+
+	// CurrentVersion, Changes and Deadline of an update that was applied and waits
+	// for its post update hook, used to roll the update back if the hook fails
+	CurrentVersion string           `json:"currentVersion,omitempty"`
+	Changes        ContainerChanges `json:"changes,omitempty" gorm:"type:json"`
+	Deadline       time.Time        `json:"deadline,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
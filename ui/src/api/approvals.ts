@@ -1,5 +1,30 @@
 import { getAuthToken } from "./auth";
 
+export type PlannedChange = {
+  resource: string;
+  target: string;
+  init?: boolean;
+  from: string;
+  to: string;
+};
+
+export type ApprovalPlan = {
+  changes: PlannedChange[];
+  digest?: string;
+};
+
+export type Vote = {
+  voter: string;
+  groups?: string[];
+  comment?: string;
+  at: string;
+};
+
+export type VetoRule = {
+  rejections?: number;
+  group?: string;
+};
+
 export type Approval = {
   createdAt: string;
   currentVersion: string;
@@ -16,17 +41,36 @@ export type Approval = {
   };
   id: string;
   identifier: string;
+  group?: string;
+  members?: string[];
+  plan?: ApprovalPlan;
   message: string;
   newVersion: string;
   provider: string;
   updatedAt: string;
   voters: any;
+  votes?: Vote[];
+  rejections?: Vote[];
+  veto?: VetoRule;
   rejected: boolean;
   archived: boolean;
   votesReceived: number;
   votesRequired: number;
 };
 
+export type ApprovalTimelineEntry = {
+  at: string;
+  action: string;
+  actor?: string;
+  comment?: string;
+  message?: string;
+};
+
+export type ApprovalTimeline = {
+  approval: Approval;
+  entries: ApprovalTimelineEntry[];
+};
+
 export type ApprovalPayload = {
   identifier: string;
   provider: string;
@@ -37,6 +81,7 @@ export type ApprovalVotePayload = {
   id: string;
   identifier: string;
   action: string;
+  comment?: string;
 };
 
 const voteApproval = async (payload: ApprovalVotePayload) => {
@@ -63,9 +108,12 @@ const setApproval = async (payload: ApprovalPayload) => {
   return response.json();
 };
 
+// approvalsLimit - API lists 100 approvals unless asked for more
+const approvalsLimit = 1000;
+
 const getApprovals = async () => {
   const token = getAuthToken();
-  const response = await fetch("/v1/approvals", {
+  const response = await fetch(`/v1/approvals?limit=${approvalsLimit}`, {
     headers: {
       Authorization: `Bearer ${token}`,
     },
@@ -73,9 +121,40 @@ const getApprovals = async () => {
   return response.json();
 };
 
+const getApprovalTimeline = async (id: string): Promise<ApprovalTimeline> => {
+  const token = getAuthToken();
+  const response = await fetch(`/v1/approvals/${id}/timeline`, {
+    headers: {
+      Authorization: `Bearer ${token}`,
+    },
+  });
+  return response.json();
+};
+
+const planDiff = (plan?: ApprovalPlan) =>
+  (plan?.changes ?? []).map(
+    (change) =>
+      `${change.resource} ${change.target}${change.init ? " (init)" : ""}: ${
+        change.from
+      } -> ${change.to}`
+  );
+
+const formatVotes = (votes?: Vote[]) =>
+  (votes ?? []).map(
+    (vote) => `${vote.voter}${vote.comment ? `: ${vote.comment}` : ""}`
+  );
+
 const approvalIsComplete = (approval: Approval) =>
   approval.archived ||
   approval.rejected ||
   approval.votesReceived >= approval.votesRequired;
 
-export { getApprovals, setApproval, voteApproval, approvalIsComplete };
+export {
+  getApprovals,
+  getApprovalTimeline,
+  setApproval,
+  voteApproval,
+  approvalIsComplete,
+  formatVotes,
+  planDiff,
+};
@@ -1,11 +1,25 @@
-import { Button, Popconfirm, Progress, Space, TableProps, Tooltip } from "antd";
+import { useState } from "react";
+import {
+  Button,
+  Input,
+  Modal,
+  Popconfirm,
+  Progress,
+  Space,
+  TableProps,
+  Timeline,
+  Tooltip,
+} from "antd";
 import DataTable from "../../components/DataTable";
 import TripleDataDisplay from "../../components/TripleDataDisplay";
 import {
   approvalIsComplete,
   Approval as ApprovalType,
   ApprovalVotePayload,
+  formatVotes,
   getApprovals,
+  getApprovalTimeline,
+  planDiff,
   voteApproval,
 } from "../../api/approvals";
 import {
@@ -18,6 +32,7 @@ import {
   DatabaseOutlined,
   DeleteOutlined,
   DislikeOutlined,
+  HistoryOutlined,
   LikeOutlined,
 } from "@ant-design/icons";
 import CountDown from "../../components/CountDown";
@@ -28,6 +43,114 @@ const getProgress = (approval: ApprovalType) => {
   return (approval.votesReceived * 100) / approval.votesRequired;
 };
 
+const VoteButton = ({
+  approval,
+  action,
+  approvalVote,
+  children,
+  ...buttonProps
+}: {
+  approval: ApprovalType;
+  action: "approve" | "reject";
+  approvalVote: UseMutationResult<any, Error, ApprovalVotePayload, unknown>;
+  children: React.ReactNode;
+  type?: "primary";
+  danger?: boolean;
+}) => {
+  const [comment, setComment] = useState("");
+  return (
+    <Popconfirm
+      title={action === "approve" ? "Approve" : "Reject"}
+      description={
+        <>
+          Are you sure you want {action} this release?
+          <Input.TextArea
+            placeholder="Comment (optional)"
+            maxLength={500}
+            value={comment}
+            onChange={(e) => setComment(e.target.value)}
+          />
+        </>
+      }
+      onConfirm={() => {
+        approvalVote.mutate({
+          id: approval.id,
+          identifier: approval.identifier,
+          action,
+          comment: comment.trim() || undefined,
+        });
+        setComment("");
+      }}
+    >
+      <Button {...buttonProps}>{children}</Button>
+    </Popconfirm>
+  );
+};
+
+const ApprovalHistory = ({ approval }: { approval: ApprovalType }) => {
+  const [open, setOpen] = useState(false);
+  const timeline = useQuery({
+    queryKey: ["approval-timeline", approval.id],
+    queryFn: () => getApprovalTimeline(approval.id),
+    enabled: open,
+  });
+
+  return (
+    <>
+      <Tooltip title="Approval history">
+        <Button onClick={() => setOpen(true)}>
+          <HistoryOutlined />
+        </Button>
+      </Tooltip>
+      <Modal
+        title={`History of ${approval.identifier}`}
+        open={open}
+        footer={null}
+        onCancel={() => setOpen(false)}
+      >
+        <Timeline
+          pending={timeline.isLoading ? "Loading..." : undefined}
+          items={timeline.data?.entries?.map((entry) => ({
+            color:
+              entry.action === "rejected"
+                ? "red"
+                : entry.action === "approved" ||
+                  entry.action === "auto-approved"
+                ? "green"
+                : "gray",
+            children: (
+              <>
+                <div>
+                  {new Date(entry.at).toLocaleString()} {entry.action}
+                  {entry.actor ? ` by ${entry.actor}` : ""}
+                </div>
+                {entry.comment && <div>"{entry.comment}"</div>}
+                {entry.message && <div>{entry.message}</div>}
+              </>
+            ),
+          }))}
+        />
+      </Modal>
+    </>
+  );
+};
+
+const votesTooltip = (approval: ApprovalType) => {
+  const votes = formatVotes(approval.votes);
+  const rejections = formatVotes(approval.rejections);
+  if (!votes.length && !rejections.length) return null;
+  return (
+    <>
+      {votes.map((vote) => (
+        <div key={`vote-${vote}`}>Approved by {vote}</div>
+      ))}
+      {rejections.map((rejection) => (
+        <div key={`rejection-${rejection}`}>Rejected by {rejection}</div>
+      ))}
+    </>
+  );
+};
+
 const columns: (
   approvalVote: UseMutationResult<any, Error, ApprovalVotePayload, unknown>
 ) => TableProps<ApprovalType>["columns"] = (
@@ -47,16 +170,50 @@ const columns: (
     title: "Identifier",
     dataIndex: "identifier",
     key: "identifier",
+    render: (_, approval) =>
+      approval.members?.length ? (
+        <Tooltip title={approval.members.join(", ")}>
+          {approval.identifier} ({approval.members.length} resources)
+        </Tooltip>
+      ) : (
+        approval.identifier
+      ),
   },
   {
     title: "Votes",
-    render: (_, approval) =>
-      `${approval.votesReceived} / ${approval.votesRequired}`,
+    render: (_, approval) => {
+      const votes = `${approval.votesReceived} / ${approval.votesRequired}`;
+      const rejections = approval.rejections?.length
+        ? ` (${approval.rejections.length} rejected)`
+        : "";
+      const tooltip = votesTooltip(approval);
+      return tooltip ? (
+        <Tooltip title={tooltip}>
+          {votes}
+          {rejections}
+        </Tooltip>
+      ) : (
+        votes
+      );
+    },
   },
   {
     title: "Delta",
-    render: (_, approval) =>
-      `${approval.currentVersion} -> ${approval.newVersion}`,
+    render: (_, approval) => {
+      const delta = `${approval.currentVersion} -> ${approval.newVersion}`;
+      const diff = planDiff(approval.plan);
+      return diff.length ? (
+        <Tooltip
+          title={diff.map((line) => (
+            <div key={line}>{line}</div>
+          ))}
+        >
+          {delta} ({diff.length} changes)
+        </Tooltip>
+      ) : (
+        delta
+      );
+    },
   },
   {
     title: "Status",
@@ -108,36 +265,23 @@ const columns: (
     title: "Action",
     render: (_, approval) => (
       <Space>
-        <Popconfirm
-          title="Approve"
-          description="Are you sure you want approve this release?"
-          onConfirm={() =>
-            approvalVote.mutate({
-              id: approval.id,
-              identifier: approval.identifier,
-              action: "approve",
-            })
-          }
+        <VoteButton
+          approval={approval}
+          action="approve"
+          approvalVote={approvalVote}
+          type="primary"
         >
-          <Button type="primary">
-            <LikeOutlined />
-          </Button>
-        </Popconfirm>
-        <Popconfirm
-          title="Reject"
-          description="Are you sure you want reject this release?"
-          onConfirm={() =>
-            approvalVote.mutate({
-              id: approval.id,
-              identifier: approval.identifier,
-              action: "reject",
-            })
-          }
+          <LikeOutlined />
+        </VoteButton>
+        <VoteButton
+          approval={approval}
+          action="reject"
+          approvalVote={approvalVote}
+          danger
         >
-          <Button danger>
-            <DislikeOutlined />
-          </Button>
-        </Popconfirm>
+          <DislikeOutlined />
+        </VoteButton>
+        <ApprovalHistory approval={approval} />
         <Tooltip title="Archive approval">
           <Popconfirm
             title="Archive"
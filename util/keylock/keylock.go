@@ -0,0 +1,52 @@
+package keylock
+
+import (
+	"sync"
+)
+
+// KeyLock - mutex per key, used to serialise work on the same resource
+// while allowing work on different resources to run concurrently.
+// Zero value is ready to use.
+type KeyLock struct {
+	mu    sync.Mutex
+	locks map[string]*entry
+}
+
+type entry struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock - locks key, blocks until key is available
+func (l *KeyLock) Lock(key string) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*entry)
+	}
+	e, ok := l.locks[key]
+	if !ok {
+		e = &entry{}
+		l.locks[key] = e
+	}
+	e.refs++
+	l.mu.Unlock()
+
+	e.Lock()
+}
+
+// Unlock - unlocks key
+func (l *KeyLock) Unlock(key string) {
+	l.mu.Lock()
+	e, ok := l.locks[key]
+	if !ok {
+		l.mu.Unlock()
+		return
+	}
+	e.refs--
+	if e.refs == 0 {
+		delete(l.locks, key)
+	}
+	l.mu.Unlock()
+
+	e.Unlock()
+}
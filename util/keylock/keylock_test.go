@@ -0,0 +1,32 @@
+package keylock
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestKeyLock(t *testing.T) {
+	var l KeyLock
+	var wg sync.WaitGroup
+
+	counters := map[string]int{"a": 0, "b": 0}
+	for i := 0; i < 100; i++ {
+		for _, key := range []string{"a", "b"} {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				l.Lock(key)
+				counters[key]++
+				l.Unlock(key)
+			}(key)
+		}
+	}
+	wg.Wait()
+
+	if counters["a"] != 100 || counters["b"] != 100 {
+		t.Errorf("unexpected counters: %v", counters)
+	}
+	if len(l.locks) != 0 {
+		t.Errorf("expected all locks to be released, got: %d", len(l.locks))
+	}
+}
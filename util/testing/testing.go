@@ -52,11 +52,19 @@ func (i *FakeK8sImplementer) Deployments(namespace string) (*apps_v1.DeploymentL
 }
 
 // Update - update deployment
-func (i *FakeK8sImplementer) Update(obj *k8s.GenericResource) error {
+func (i *FakeK8sImplementer) Update(obj *k8s.GenericResource, changes types.ContainerChanges) error {
 	i.Updated = obj
 	return nil
 }
 
+// Get - returns last updated resource
+func (i *FakeK8sImplementer) Get(obj *k8s.GenericResource) (*k8s.GenericResource, error) {
+	if i.Updated != nil {
+		return i.Updated, nil
+	}
+	return obj, nil
+}
+
 // Secret - get secret
 func (i *FakeK8sImplementer) Secret(namespace, name string) (*v1.Secret, error) {
 	if i.Error != nil {
@@ -79,7 +87,21 @@ func (i *FakeK8sImplementer) ConfigMaps(namespace string) core_v1.ConfigMapInter
 	panic("not implemented")
 }
 
-func (i *FakeK8sImplementer) CreateJob(name string, image string, secret string) error {
+// Annotate - does nothing
+func (i *FakeK8sImplementer) Annotate(obj *k8s.GenericResource, annotations map[string]string) error {
+	return nil
+}
+
+// Events - returns nothing (not implemented)
+func (i *FakeK8sImplementer) Events(namespace string) core_v1.EventInterface {
+	panic("not implemented")
+}
+
+func (i *FakeK8sImplementer) CreateJob(job *batch_v1.Job) error {
+	return nil
+}
+
+func (i *FakeK8sImplementer) DeleteJob(namespace, name string) error {
 	return nil
 }
 